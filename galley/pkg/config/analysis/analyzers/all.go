@@ -37,7 +37,10 @@ func All() []analysis.Analyzer {
 	analyzers := []analysis.Analyzer{
 		// Please keep this list sorted alphabetically by pkg.name for convenience
 		&annotations.K8sAnalyzer{},
+		&authz.AllowOverriddenByDenyAnalyzer{},
 		&authz.AuthorizationPoliciesAnalyzer{},
+		&authz.HTTPFieldsOnTCPPortAnalyzer{},
+		&authz.PeerIdentityAnalyzer{},
 		&deployment.ServiceAssociationAnalyzer{},
 		&deployment.ApplicationUIDAnalyzer{},
 		&deprecation.FieldAnalyzer{},
@@ -56,7 +59,9 @@ func All() []analysis.Analyzer {
 		&virtualservice.DestinationRuleAnalyzer{},
 		&virtualservice.GatewayAnalyzer{},
 		&virtualservice.RegexAnalyzer{},
+		&virtualservice.ShadowedRouteAnalyzer{},
 		&destinationrule.CaCertificateAnalyzer{},
+		&destinationrule.TLSModeConflictAnalyzer{},
 		&serviceentry.ProtocolAdressesAnalyzer{},
 		&webhook.Analyzer{},
 	}
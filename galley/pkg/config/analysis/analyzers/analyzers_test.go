@@ -341,6 +341,63 @@ var testGrid = []testCase{
 			{msg.ReferencedResourceNotFound, "AuthorizationPolicy httpbin-bogus-not-ns.httpbin"},
 		},
 	},
+	{
+		name: "authorizationpolicies allow overridden by deny",
+		inputFiles: []string{
+			"testdata/authorizationpolicies-allow-overridden-by-deny.yaml",
+		},
+		analyzer: &authz.AllowOverriddenByDenyAnalyzer{},
+		expected: []message{
+			{msg.AuthorizationPolicyAllowOverriddenByDeny, "AuthorizationPolicy allow-sleep.httpbin"},
+			{msg.AuthorizationPolicyAllowOverriddenByDeny, "AuthorizationPolicy allow-reviews.reviews"},
+		},
+	},
+	{
+		name: "authorizationpolicies peer identity",
+		inputFiles: []string{
+			"testdata/authorizationpolicies-peer-identity.yaml",
+		},
+		analyzer: &authz.PeerIdentityAnalyzer{},
+		expected: []message{
+			{msg.AuthorizationPolicyPeerIdentityUnavailable, "AuthorizationPolicy httpbin.httpbin"},
+			{msg.AuthorizationPolicyPeerIdentityUnavailable, "AuthorizationPolicy httpbin.httpbin"},
+			{msg.AuthorizationPolicyPeerIdentityUnavailable, "AuthorizationPolicy plaintext.plaintext"},
+			{msg.AuthorizationPolicyPeerIdentityUnavailable, "AuthorizationPolicy plaintext.plaintext"},
+		},
+	},
+	{
+		name: "authorizationpolicies http fields on tcp port",
+		inputFiles: []string{
+			"testdata/authorizationpolicies-http-fields-tcp.yaml",
+		},
+		analyzer: &authz.HTTPFieldsOnTCPPortAnalyzer{},
+		expected: []message{
+			{msg.AuthorizationPolicyHTTPFieldsOnTCPPort, "AuthorizationPolicy mysql-allow.db"},
+			{msg.AuthorizationPolicyHTTPFieldsOnTCPPort, "AuthorizationPolicy web-deny.db"},
+		},
+	},
+	{
+		name: "destinationrule tls mode conflicts",
+		inputFiles: []string{
+			"testdata/destinationrule-tls-conflicts.yaml",
+		},
+		analyzer: &destinationrule.TLSModeConflictAnalyzer{},
+		expected: []message{
+			{msg.DestinationRuleTLSModeConflict, "DestinationRule reviews-disable.strict"},
+			{msg.DestinationRuleTLSModeConflict, "DestinationRule reviews-disable.strict"},
+			{msg.DestinationRuleTLSModeConflict, "DestinationRule reviews-simple.default"},
+			{msg.DestinationRuleTLSModeConflict, "DestinationRule default.istio-system"},
+		},
+	},
+	{
+		name:           "destinationrule tls mode without auto mtls",
+		inputFiles:     []string{"testdata/destinationrule-tls-no-automtls.yaml"},
+		meshConfigFile: "testdata/mesh-without-automtls.yaml",
+		analyzer:       &destinationrule.TLSModeConflictAnalyzer{},
+		expected: []message{
+			{msg.StrictMTLSWithoutClientMTLS, "PeerAuthentication default.strict"},
+		},
+	},
 	{
 		name: "destinationrule with no cacert, simple at destinationlevel",
 		inputFiles: []string{
@@ -433,6 +490,30 @@ var testGrid = []testCase{
 			{msg.VirtualServiceIneffectiveMatch, "VirtualService tls-routing.none"},
 		},
 	},
+	{
+		name: "virtualServiceShadowedRoutes",
+		inputFiles: []string{
+			"testdata/virtualservice_shadowedroutes.yaml",
+		},
+		analyzer: &virtualservice.ShadowedRouteAnalyzer{},
+		expected: []message{
+			{msg.VirtualServiceShadowedRoute, "VirtualService catch-all-first"},
+			{msg.VirtualServiceShadowedRoute, "VirtualService broad-prefix-first"},
+			{msg.VirtualServiceShadowedRoute, "VirtualService broad-prefix-first"},
+			{msg.VirtualServiceShadowedRoute, "VirtualService regex-first"},
+			{msg.VirtualServiceShadowedRoute, "VirtualService gateway-routes-b.default"},
+			{msg.VirtualServiceDelegateRouteConflict, "VirtualService delegate.default"},
+			{msg.VirtualServiceShadowedRoute, "VirtualService delegate.default"},
+		},
+	},
+	{
+		name: "virtualServiceShadowedRoutes ignores duplicate matches reported by validation",
+		inputFiles: []string{
+			"testdata/virtualservice_dupmatches.yaml",
+		},
+		analyzer: &virtualservice.ShadowedRouteAnalyzer{},
+		expected: []message{},
+	},
 	{
 		name: "host defined in virtualservice not found in the gateway",
 		inputFiles: []string{
@@ -0,0 +1,171 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"strings"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// AllowOverriddenByDenyAnalyzer checks for ALLOW authorization policy rules that have no effect, because a DENY
+// policy applying to the same workloads denies every request they allow. DENY policies are evaluated first.
+type AllowOverriddenByDenyAnalyzer struct{}
+
+var _ analysis.Analyzer = &AllowOverriddenByDenyAnalyzer{}
+
+func (a *AllowOverriddenByDenyAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "auth.AllowOverriddenByDenyAnalyzer",
+		Description: "Checks for ALLOW authorization policy rules that are overridden by DENY policies",
+		Inputs: collection.Names{
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+			collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+		},
+	}
+}
+
+func (a *AllowOverriddenByDenyAnalyzer) Analyze(c analysis.Context) {
+	var allows, denies []*resource.Instance
+	c.ForEach(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(), func(r *resource.Instance) bool {
+		switch r.Message.(*v1beta1.AuthorizationPolicy).Action {
+		case v1beta1.AuthorizationPolicy_ALLOW:
+			allows = append(allows, r)
+		case v1beta1.AuthorizationPolicy_DENY:
+			denies = append(denies, r)
+		}
+		return true
+	})
+
+	root := rootNamespace(c)
+	for _, allow := range allows {
+		ap := allow.Message.(*v1beta1.AuthorizationPolicy)
+		for i, rule := range ap.Rules {
+			a.analyzeRule(c, root, allow, i, rule, denies)
+		}
+	}
+}
+
+func (a *AllowOverriddenByDenyAnalyzer) analyzeRule(c analysis.Context, root string, allow *resource.Instance, i int,
+	rule *v1beta1.Rule, denies []*resource.Instance) {
+	for _, deny := range denies {
+		if !appliesToAllWorkloadsOf(root, deny, allow) {
+			continue
+		}
+		for j, denyRule := range deny.Message.(*v1beta1.AuthorizationPolicy).Rules {
+			if ruleCovers(denyRule, rule) {
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewAuthorizationPolicyAllowOverriddenByDeny(allow, ruleName(i), ruleName(j), deny.Metadata.FullName.String()))
+				return
+			}
+		}
+	}
+}
+
+// appliesToAllWorkloadsOf returns true if the policy applies to every workload that the other policy applies to.
+func appliesToAllWorkloadsOf(root string, policy, other *resource.Instance) bool {
+	ns := policy.Metadata.FullName.Namespace
+	if ns != other.Metadata.FullName.Namespace && ns.String() != root {
+		return false
+	}
+	selector := policy.Message.(*v1beta1.AuthorizationPolicy).Selector
+	if len(selector.GetMatchLabels()) == 0 {
+		return true
+	}
+	otherLabels := other.Message.(*v1beta1.AuthorizationPolicy).Selector.GetMatchLabels()
+	for k, v := range selector.GetMatchLabels() {
+		if ov, ok := otherLabels[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ruleCovers returns true if every request matched by the rule is also matched by the covering rule. It is
+// conservative, and only considers covering rules with sources.
+func ruleCovers(covering, rule *v1beta1.Rule) bool {
+	if len(covering.To) > 0 || len(covering.When) > 0 {
+		return false
+	}
+	if len(covering.From) == 0 {
+		return true
+	}
+	if len(rule.From) == 0 {
+		return false
+	}
+	for _, from := range rule.From {
+		covered := false
+		for _, coveringFrom := range covering.From {
+			if sourceCovers(coveringFrom.GetSource(), from.GetSource()) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func sourceCovers(covering, source *v1beta1.Source) bool {
+	if len(covering.GetNotPrincipals()) > 0 || len(covering.GetNotRequestPrincipals()) > 0 ||
+		len(covering.GetNotNamespaces()) > 0 || len(covering.GetIpBlocks()) > 0 || len(covering.GetNotIpBlocks()) > 0 ||
+		len(covering.GetRemoteIpBlocks()) > 0 || len(covering.GetNotRemoteIpBlocks()) > 0 {
+		return false
+	}
+	return valuesCover(covering.GetPrincipals(), source.GetPrincipals()) &&
+		valuesCover(covering.GetRequestPrincipals(), source.GetRequestPrincipals()) &&
+		valuesCover(covering.GetNamespaces(), source.GetNamespaces())
+}
+
+// valuesCover returns true if every value is matched by one of the patterns, or if there are no patterns.
+func valuesCover(patterns, values []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if len(values) == 0 {
+		return false
+	}
+	for _, v := range values {
+		covered := false
+		for _, p := range patterns {
+			if p == "*" || p == v || (!strings.Contains(v, "*") && valueMatch(v, p)) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// valueMatch matches a value against a pattern with an optional prefix or suffix wildcard.
+func valueMatch(v, pattern string) bool {
+	if strings.HasPrefix(pattern, "*") {
+		return strings.HasSuffix(v, strings.TrimPrefix(pattern, "*"))
+	}
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(v, strings.TrimSuffix(pattern, "*"))
+	}
+	return v == pattern
+}
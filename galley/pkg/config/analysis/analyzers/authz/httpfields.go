@@ -0,0 +1,204 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	configKube "istio.io/istio/pkg/config/kube"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// HTTPFieldsOnTCPPortAnalyzer checks for authorization policy rules using fields that only apply to HTTP requests on
+// ports that are declared as TCP. Such rules never match in ALLOW policies, and in DENY policies the HTTP only fields
+// are ignored so the rule denies more than intended.
+type HTTPFieldsOnTCPPortAnalyzer struct{}
+
+var _ analysis.Analyzer = &HTTPFieldsOnTCPPortAnalyzer{}
+
+func (a *HTTPFieldsOnTCPPortAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "auth.HTTPFieldsOnTCPPortAnalyzer",
+		Description: "Checks for authorization policy rules using HTTP only fields on TCP ports",
+		Inputs: collection.Names{
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+			collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+			collections.K8SCoreV1Namespaces.Name(),
+			collections.K8SCoreV1Pods.Name(),
+			collections.K8SCoreV1Services.Name(),
+		},
+	}
+}
+
+// servicePort is a port of a service selecting the workloads of a policy.
+type servicePort struct {
+	service    string
+	targetPort int
+	tcp        bool
+}
+
+func (a *HTTPFieldsOnTCPPortAnalyzer) Analyze(c analysis.Context) {
+	workloads := initWorkloads(c)
+	c.ForEach(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(), func(r *resource.Instance) bool {
+		a.analyzePolicy(r, c, workloads)
+		return true
+	})
+}
+
+func (a *HTTPFieldsOnTCPPortAnalyzer) analyzePolicy(r *resource.Instance, c analysis.Context, workloads []workload) {
+	ap := r.Message.(*v1beta1.AuthorizationPolicy)
+	var effect string
+	switch ap.Action {
+	case v1beta1.AuthorizationPolicy_ALLOW:
+		effect = "the rule never allows requests on this port"
+	case v1beta1.AuthorizationPolicy_DENY:
+		effect = "the rule denies all requests on this port that match its other fields"
+	default:
+		return
+	}
+	targets := policyTargets(r, c, workloads)
+	if len(targets) == 0 {
+		return
+	}
+	ports := targetServicePorts(c, targets)
+
+	for i, rule := range ap.Rules {
+		fields := httpOnlyFields(rule)
+		if len(fields) == 0 {
+			continue
+		}
+		rulePorts := map[int]bool{}
+		for _, to := range rule.To {
+			for _, p := range to.GetOperation().GetPorts() {
+				if n, err := strconv.Atoi(p); err == nil {
+					rulePorts[n] = true
+				}
+			}
+		}
+		var tcpPorts []servicePort
+		allTCP := true
+		for _, sp := range ports {
+			if len(rulePorts) > 0 && !rulePorts[sp.targetPort] {
+				continue
+			}
+			if sp.tcp {
+				tcpPorts = append(tcpPorts, sp)
+			} else {
+				allTCP = false
+			}
+		}
+		// Without explicit ports, a rule is only reported if it can never apply to an HTTP port.
+		if len(rulePorts) == 0 && !allTCP {
+			continue
+		}
+		for _, sp := range tcpPorts {
+			c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+				msg.NewAuthorizationPolicyHTTPFieldsOnTCPPort(r, ruleName(i), strings.Join(fields, ", "), sp.targetPort, sp.service, effect))
+		}
+	}
+}
+
+// httpOnlyFields returns the names of the fields used by the rule that only apply to HTTP requests.
+func httpOnlyFields(rule *v1beta1.Rule) []string {
+	set := map[string]bool{}
+	for _, from := range rule.From {
+		src := from.GetSource()
+		if len(src.GetRequestPrincipals()) > 0 {
+			set["requestPrincipals"] = true
+		}
+		if len(src.GetNotRequestPrincipals()) > 0 {
+			set["notRequestPrincipals"] = true
+		}
+	}
+	for _, to := range rule.To {
+		op := to.GetOperation()
+		for name, values := range map[string][]string{
+			"hosts":      op.GetHosts(),
+			"notHosts":   op.GetNotHosts(),
+			"methods":    op.GetMethods(),
+			"notMethods": op.GetNotMethods(),
+			"paths":      op.GetPaths(),
+			"notPaths":   op.GetNotPaths(),
+		} {
+			if len(values) > 0 {
+				set[name] = true
+			}
+		}
+	}
+	for _, when := range rule.When {
+		if strings.HasPrefix(when.GetKey(), "request.") {
+			set[when.GetKey()] = true
+		}
+	}
+	out := make([]string, 0, len(set))
+	for f := range set {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// targetServicePorts returns the ports of the services selecting any of the pods, with the target port that the
+// policy ports refer to.
+func targetServicePorts(c analysis.Context, pods []*v1.Pod) []servicePort {
+	var out []servicePort
+	c.ForEach(collections.K8SCoreV1Services.Name(), func(r *resource.Instance) bool {
+		svc := r.Message.(*v1.ServiceSpec)
+		if len(svc.Selector) == 0 {
+			return true
+		}
+		selector := k8s_labels.SelectorFromSet(svc.Selector)
+		var selected *v1.Pod
+		for _, p := range pods {
+			if p.Namespace == r.Metadata.FullName.Namespace.String() && selector.Matches(k8s_labels.Set(p.Labels)) {
+				selected = p
+				break
+			}
+		}
+		if selected == nil {
+			return true
+		}
+		for _, port := range svc.Ports {
+			target := util.ServiceTargetPort(port, selected)
+			if target == 0 {
+				continue
+			}
+			out = append(out, servicePort{
+				service:    r.Metadata.FullName.String(),
+				targetPort: target,
+				tcp:        configKube.ConvertProtocol(port.Port, port.Name, port.Protocol, port.AppProtocol).IsTCP(),
+			})
+		}
+		return true
+	})
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].service != out[j].service {
+			return out[i].service < out[j].service
+		}
+		return out[i].targetPort < out[j].targetPort
+	})
+	return out
+}
@@ -0,0 +1,168 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// PeerIdentityAnalyzer checks for authorization policy sources based on the peer identity, principals and
+// namespaces, that can never match because the requests they are meant to match are not mutual TLS. That is the case
+// when mutual TLS is disabled for the workloads the policy applies to, or when the source workloads have no sidecar.
+type PeerIdentityAnalyzer struct{}
+
+var _ analysis.Analyzer = &PeerIdentityAnalyzer{}
+
+func (a *PeerIdentityAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "auth.PeerIdentityAnalyzer",
+		Description: "Checks for authorization policy principals and namespaces that can never match",
+		Inputs: collection.Names{
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+			collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+			collections.IstioSecurityV1Beta1Peerauthentications.Name(),
+			collections.K8SCoreV1Namespaces.Name(),
+			collections.K8SCoreV1Pods.Name(),
+		},
+	}
+}
+
+func (a *PeerIdentityAnalyzer) Analyze(c analysis.Context) {
+	workloads := initWorkloads(c)
+	peerAuthns := util.InitPeerAuthentications(c, rootNamespace(c))
+	c.ForEach(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(), func(r *resource.Instance) bool {
+		a.analyzePolicy(r, c, workloads, peerAuthns)
+		return true
+	})
+}
+
+func (a *PeerIdentityAnalyzer) analyzePolicy(r *resource.Instance, c analysis.Context, workloads []workload,
+	peerAuthns *util.PeerAuthentications) {
+	ap := r.Message.(*v1beta1.AuthorizationPolicy)
+	if ap.Action == v1beta1.AuthorizationPolicy_CUSTOM {
+		return
+	}
+	mode := targetsMTLSMode(r, policyTargets(r, c, workloads), peerAuthns)
+	for i, rule := range ap.Rules {
+		for _, from := range rule.From {
+			src := from.GetSource()
+			if mode == v1beta1.PeerAuthentication_MutualTLS_DISABLE {
+				for _, f := range []struct {
+					name   string
+					values []string
+				}{{"principals", src.GetPrincipals()}, {"namespaces", src.GetNamespaces()}} {
+					if len(f.values) == 0 {
+						continue
+					}
+					c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+						msg.NewAuthorizationPolicyPeerIdentityUnavailable(r, ruleName(i),
+							fmt.Sprintf("%s %v", f.name, f.values),
+							"PeerAuthentication sets mTLS mode DISABLE for the workloads the policy applies to"))
+				}
+				continue
+			}
+			for _, p := range src.GetPrincipals() {
+				ns, sa, ok := parsePrincipal(p)
+				if !ok || !noSidecars(workloads, ns, sa) {
+					continue
+				}
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewAuthorizationPolicyPeerIdentityUnavailable(r, ruleName(i), "principal "+p,
+						fmt.Sprintf("no workload using service account %s in namespace %s has a sidecar, %s", sa, ns, plaintextEffect(mode))))
+			}
+			for _, ns := range src.GetNamespaces() {
+				if strings.Contains(ns, "*") || !noSidecars(workloads, ns, "") {
+					continue
+				}
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewAuthorizationPolicyPeerIdentityUnavailable(r, ruleName(i), "namespace "+ns,
+						fmt.Sprintf("no workload in namespace %s has a sidecar, %s", ns, plaintextEffect(mode))))
+			}
+		}
+	}
+}
+
+func plaintextEffect(mode v1beta1.PeerAuthentication_MutualTLS_Mode) string {
+	switch mode {
+	case v1beta1.PeerAuthentication_MutualTLS_STRICT:
+		return "and their plaintext requests are rejected by STRICT mTLS mode"
+	case v1beta1.PeerAuthentication_MutualTLS_PERMISSIVE:
+		return "and their plaintext requests are accepted by PERMISSIVE mTLS mode without a peer identity"
+	default:
+		return "so their plaintext requests have no peer identity"
+	}
+}
+
+// parsePrincipal returns the namespace and service account of a principal in the
+// <trust domain>/ns/<namespace>/sa/<service account> format, without wildcards.
+func parsePrincipal(p string) (string, string, bool) {
+	parts := strings.Split(p, "/")
+	if len(parts) != 5 || parts[1] != "ns" || parts[3] != "sa" || strings.Contains(parts[2], "*") ||
+		strings.Contains(parts[4], "*") {
+		return "", "", false
+	}
+	return parts[2], parts[4], true
+}
+
+// noSidecars returns true if there are workloads in the namespace using the service account, or any service account
+// if it is empty, and none of them has a sidecar.
+func noSidecars(workloads []workload, ns, sa string) bool {
+	found := false
+	for _, w := range workloads {
+		if w.pod.Namespace != ns {
+			continue
+		}
+		podSA := w.pod.Spec.ServiceAccountName
+		if podSA == "" {
+			podSA = "default"
+		}
+		if sa != "" && podSA != sa {
+			continue
+		}
+		if w.inMesh {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// targetsMTLSMode returns the mutual TLS mode of the workloads the policy applies to, if they all have the same mode.
+// Without workloads, the mode of the policy's namespace is returned.
+func targetsMTLSMode(r *resource.Instance, targets []*v1.Pod,
+	peerAuthns *util.PeerAuthentications) v1beta1.PeerAuthentication_MutualTLS_Mode {
+	if len(targets) == 0 {
+		mode, _ := peerAuthns.MTLSMode(r.Metadata.FullName.Namespace.String(), nil, 0)
+		return mode
+	}
+	mode, _ := peerAuthns.MTLSMode(targets[0].Namespace, targets[0].Labels, 0)
+	for _, p := range targets[1:] {
+		if m, _ := peerAuthns.MTLSMode(p.Namespace, p.Labels, 0); m != mode {
+			return v1beta1.PeerAuthentication_MutualTLS_UNSET
+		}
+	}
+	return mode
+}
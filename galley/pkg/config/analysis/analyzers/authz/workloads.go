@@ -0,0 +1,80 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/mesh/v1alpha1"
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// workload is a pod, and whether it has a sidecar.
+type workload struct {
+	pod    *v1.Pod
+	inMesh bool
+}
+
+func initWorkloads(c analysis.Context) []workload {
+	var out []workload
+	c.ForEach(collections.K8SCoreV1Pods.Name(), func(r *resource.Instance) bool {
+		out = append(out, workload{pod: r.Message.(*v1.Pod), inMesh: util.PodInMesh(r, c)})
+		return true
+	})
+	return out
+}
+
+// policyTargets returns the pods with sidecars that the authorization policy applies to.
+func policyTargets(r *resource.Instance, c analysis.Context, workloads []workload) []*v1.Pod {
+	ap := r.Message.(*v1beta1.AuthorizationPolicy)
+	ns := r.Metadata.FullName.Namespace.String()
+	meshWide := ns == rootNamespace(c)
+	selector := k8s_labels.Everything()
+	if ap.Selector != nil {
+		selector = k8s_labels.SelectorFromSet(ap.Selector.MatchLabels)
+	}
+	var out []*v1.Pod
+	for _, w := range workloads {
+		if !w.inMesh || (!meshWide && w.pod.Namespace != ns) {
+			continue
+		}
+		if selector.Matches(k8s_labels.Set(w.pod.Labels)) {
+			out = append(out, w.pod)
+		}
+	}
+	return out
+}
+
+// rootNamespace returns the root namespace of the mesh config. Unlike fetchMeshConfig, it reads the mesh config of
+// each analysis.
+func rootNamespace(c analysis.Context) string {
+	root := ""
+	c.ForEach(collections.IstioMeshV1Alpha1MeshConfig.Name(), func(r *resource.Instance) bool {
+		root = r.Message.(*v1alpha1.MeshConfig).GetRootNamespace()
+		return r.Metadata.FullName.Name != util.MeshConfigName
+	})
+	return root
+}
+
+func ruleName(i int) string {
+	return fmt.Sprintf("#%d", i)
+}
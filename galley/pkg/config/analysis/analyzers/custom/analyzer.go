@@ -0,0 +1,189 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package custom
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"k8s.io/client-go/util/jsonpath"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/scope"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+// Analyzer is an analyzer declared by a Rule.
+type Analyzer struct {
+	name        string
+	description string
+	collection  collection.Name
+	messageType *diag.MessageType
+	match       []*condition
+	require     []*condition
+}
+
+var _ analysis.Analyzer = &Analyzer{}
+
+// Metadata implements analysis.Analyzer
+func (a *Analyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "custom." + a.name,
+		Description: a.description,
+		Inputs:      collection.Names{a.collection},
+	}
+}
+
+// MessageType returns the type of the messages reported by the analyzer.
+func (a *Analyzer) MessageType() *diag.MessageType {
+	return a.messageType
+}
+
+// Analyze implements analysis.Analyzer
+func (a *Analyzer) Analyze(c analysis.Context) {
+	c.ForEach(a.collection, func(r *resource.Instance) bool {
+		obj, err := toObject(r)
+		if err != nil {
+			scope.Analysis.Warnf("Custom analyzer %q failed to convert %s: %v", a.name, r.Metadata.FullName, err)
+			return true
+		}
+		for _, cond := range a.match {
+			if !cond.holds(obj) {
+				return true
+			}
+		}
+		for _, cond := range a.require {
+			if !cond.holds(obj) {
+				c.Report(a.collection, diag.NewMessage(a.messageType, r))
+				break
+			}
+		}
+		return true
+	})
+}
+
+// toObject returns the representation of the resource the conditions are evaluated against. The spec is encoded
+// as canonical proto JSON, so that paths use the same field names as the Kubernetes YAML.
+func toObject(r *resource.Instance) (map[string]interface{}, error) {
+	spec, err := gogoprotomarshal.ToJSONMap(r.Message)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        r.Metadata.FullName.Name.String(),
+			"namespace":   r.Metadata.FullName.Namespace.String(),
+			"labels":      toInterfaceMap(r.Metadata.Labels),
+			"annotations": toInterfaceMap(r.Metadata.Annotations),
+		},
+		"spec": spec,
+	}, nil
+}
+
+func toInterfaceMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+type condition struct {
+	operator Operator
+	path     *jsonpath.JSONPath
+	values   []string
+	regexp   *regexp.Regexp
+}
+
+// holds evaluates the condition against the object.
+func (c *condition) holds(obj map[string]interface{}) bool {
+	values, err := c.find(obj)
+	if err != nil {
+		// Paths that do not apply to the object, such as a range over a scalar, select nothing.
+		values = nil
+	}
+	switch c.operator {
+	case Exists:
+		return len(values) > 0
+	case NotExists:
+		return len(values) == 0
+	}
+
+	positive := c.operator == Equals || c.operator == In || c.operator == Matches
+	if positive && len(values) == 0 {
+		return false
+	}
+	for _, v := range values {
+		var ok bool
+		if c.regexp != nil {
+			ok = c.regexp.MatchString(v)
+		} else {
+			for _, expected := range c.values {
+				if v == expected {
+					ok = true
+					break
+				}
+			}
+		}
+		if ok != positive {
+			return false
+		}
+	}
+	return true
+}
+
+// find returns the string representation of the values selected by the path.
+func (c *condition) find(obj map[string]interface{}) ([]string, error) {
+	results, err := c.path.FindResults(obj)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, result := range results {
+		for _, v := range result {
+			s, ok := valueString(v)
+			if ok {
+				out = append(out, s)
+			}
+		}
+	}
+	return out, nil
+}
+
+func valueString(v reflect.Value) (string, bool) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Map, reflect.Slice:
+		js, err := json.Marshal(v.Interface())
+		if err != nil {
+			return "", false
+		}
+		return string(js), true
+	default:
+		return fmt.Sprint(v.Interface()), true
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package custom
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/galley/pkg/config/analysis/testing/fixtures"
+	"istio.io/istio/pkg/config/resource"
+)
+
+const rules = `
+analyzers:
+- name: gateway-team-label
+  description: Gateways must declare their owning team
+  collection: istio/networking/v1alpha3/gateways
+  code: ORG0001
+  level: Error
+  message: Gateway is missing the team label
+  match:
+  - path: '{.metadata.namespace}'
+    operator: NotIn
+    values: [istio-system]
+  require:
+  - path: '{.metadata.labels.team}'
+    operator: Exists
+- name: gateway-wildcard-host
+  collection: istio/networking/v1alpha3/gateways
+  code: ORG0002
+  message: Gateway servers must not use wildcard hosts
+  require:
+  - path: '{.spec.servers[*].hosts[*]}'
+    operator: NotMatches
+    value: '(^|/)\*$'
+`
+
+func gateway(name, namespace string, labels map[string]string, hosts ...string) *resource.Instance {
+	return &resource.Instance{
+		Metadata: resource.Metadata{
+			FullName: resource.NewFullName(resource.Namespace(namespace), resource.LocalName(name)),
+			Labels:   labels,
+		},
+		Message: &v1alpha3.Gateway{
+			Servers: []*v1alpha3.Server{{
+				Port:  &v1alpha3.Port{Number: 80, Name: "http", Protocol: "HTTP"},
+				Hosts: hosts,
+			}},
+		},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	g := NewWithT(t)
+	analyzers, err := Parse([]byte(rules))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(analyzers).To(HaveLen(2))
+	g.Expect(analyzers[0].Metadata().Name).To(Equal("custom.gateway-team-label"))
+	g.Expect(analyzers[0].Metadata().Inputs[0].String()).To(Equal("istio/networking/v1alpha3/gateways"))
+
+	ctx := &fixtures.Context{Resources: []*resource.Instance{
+		gateway("labeled", "default", map[string]string{"team": "payments"}, "payments.example.com"),
+		gateway("unlabeled", "default", nil, "*"),
+		gateway("system", "istio-system", nil, "istio-system/*.example.com"),
+		gateway("namespaced-wildcard", "istio-system", nil, "default/*"),
+	}}
+	reported := map[string][]string{}
+	for _, a := range analyzers {
+		ctx.Reports = nil
+		a.Analyze(ctx)
+		for _, m := range ctx.Reports {
+			reported[m.Type.Code()] = append(reported[m.Type.Code()], m.Resource.Metadata.FullName.String())
+		}
+	}
+	g.Expect(reported).To(Equal(map[string][]string{
+		"ORG0001": {"default/unlabeled"},
+		"ORG0002": {"default/unlabeled", "istio-system/namespaced-wildcard"},
+	}))
+}
+
+func TestAnalyzeProtoJSONPaths(t *testing.T) {
+	g := NewWithT(t)
+	analyzers, err := Parse([]byte(`
+analyzers:
+- name: exported
+  collection: istio/networking/v1alpha3/virtualservices
+  code: ORG0003
+  message: VirtualServices must not be exported to all namespaces
+  require:
+  - path: '{.spec.exportTo[*]}'
+    operator: NotIn
+    values: ['*']
+- name: api-prefix
+  collection: istio/networking/v1alpha3/virtualservices
+  code: ORG0004
+  message: VirtualServices must only match paths under /api
+  require:
+  - path: '{.spec.http[*].match[*].uri.prefix}'
+    operator: Matches
+    value: '^/api/'
+`))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	virtualService := func(name string, exportTo []string, prefix string) *resource.Instance {
+		return &resource.Instance{
+			Metadata: resource.Metadata{FullName: resource.NewFullName("default", resource.LocalName(name))},
+			Message: &v1alpha3.VirtualService{
+				Hosts:    []string{"example.com"},
+				ExportTo: exportTo,
+				Http: []*v1alpha3.HTTPRoute{{
+					Match: []*v1alpha3.HTTPMatchRequest{{
+						Uri: &v1alpha3.StringMatch{MatchType: &v1alpha3.StringMatch_Prefix{Prefix: prefix}},
+					}},
+				}},
+			},
+		}
+	}
+	ctx := &fixtures.Context{Resources: []*resource.Instance{
+		virtualService("valid", []string{"."}, "/api/v1"),
+		virtualService("exported", []string{"*"}, "/api/v1"),
+		virtualService("root-prefix", []string{"."}, "/"),
+	}}
+	reported := map[string][]string{}
+	for _, a := range analyzers {
+		ctx.Reports = nil
+		a.Analyze(ctx)
+		for _, m := range ctx.Reports {
+			reported[m.Type.Code()] = append(reported[m.Type.Code()], m.Resource.Metadata.FullName.String())
+		}
+	}
+	g.Expect(reported).To(Equal(map[string][]string{
+		"ORG0003": {"default/exported"},
+		"ORG0004": {"default/root-prefix"},
+	}))
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules string
+		err   string
+	}{
+		{
+			name:  "unknown collection",
+			rules: "analyzers: [{name: a, collection: istio/foo, code: ORG0001, message: m, require: [{path: '{.spec}', operator: Exists}]}]",
+			err:   `unknown collection "istio/foo"`,
+		},
+		{
+			name: "reserved code",
+			rules: "analyzers: [{name: a, collection: istio/networking/v1alpha3/gateways, code: IST0001, message: m, " +
+				"require: [{path: '{.spec}', operator: Exists}]}]",
+			err: `invalid code "IST0001"`,
+		},
+		{
+			name: "invalid level",
+			rules: "analyzers: [{name: a, collection: istio/networking/v1alpha3/gateways, code: ORG0001, level: Fatal, message: m, " +
+				"require: [{path: '{.spec}', operator: Exists}]}]",
+			err: `invalid level "Fatal"`,
+		},
+		{
+			name:  "no required condition",
+			rules: "analyzers: [{name: a, collection: istio/networking/v1alpha3/gateways, code: ORG0001, message: m}]",
+			err:   "at least one required condition is needed",
+		},
+		{
+			name: "unknown operator",
+			rules: "analyzers: [{name: a, collection: istio/networking/v1alpha3/gateways, code: ORG0001, message: m, " +
+				"require: [{path: '{.spec}', operator: Contains}]}]",
+			err: `unknown operator "Contains"`,
+		},
+		{
+			name: "invalid path",
+			rules: "analyzers: [{name: a, collection: istio/networking/v1alpha3/gateways, code: ORG0001, message: m, " +
+				"require: [{path: '{.spec', operator: Exists}]}]",
+			err: "invalid path",
+		},
+		{
+			name: "duplicate code",
+			rules: "analyzers: [" +
+				"{name: a, collection: istio/networking/v1alpha3/gateways, code: ORG0001, message: m, require: [{path: '{.spec}', operator: Exists}]}," +
+				"{name: b, collection: istio/networking/v1alpha3/gateways, code: ORG0001, message: m, require: [{path: '{.spec}', operator: Exists}]}]",
+			err: "duplicate message code ORG0001",
+		},
+		{
+			name:  "unknown field",
+			rules: "analyzers: [{name: a, collections: istio/networking/v1alpha3/gateways}]",
+			err:   `unknown field "collections"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := Parse([]byte(c.rules))
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(c.err))
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+	g.Expect(ioutil.WriteFile(filepath.Join(dir, "gateways.yaml"), []byte(rules), 0o644)).To(Succeed())
+	g.Expect(ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("not analyzers"), 0o644)).To(Succeed())
+
+	analyzers, err := Load([]string{dir})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(analyzers).To(HaveLen(2))
+
+	_, err = Load([]string{dir, filepath.Join(dir, "gateways.yaml")})
+	g.Expect(err).To(MatchError(ContainSubstring("duplicate custom analyzer")))
+}
@@ -0,0 +1,274 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package custom implements analyzers declared by rules over the fields of resources, so that organization specific
+// checks can be added without rebuilding istioctl or istiod. For example, the following rule reports Gateways outside
+// of istio-system without a team label:
+//
+//	analyzers:
+//	- name: gateway-team-label
+//	  collection: istio/networking/v1alpha3/gateways
+//	  code: ORG0001
+//	  level: Error
+//	  message: Gateway is missing the team label
+//	  match:
+//	  - path: '{.metadata.namespace}'
+//	    operator: NotEquals
+//	    value: istio-system
+//	  require:
+//	  - path: '{.metadata.labels.team}'
+//	    operator: Exists
+package custom
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// Operator is the comparison applied by a Condition to the values selected by its path.
+type Operator string
+
+const (
+	// Exists holds if the path selects at least one value.
+	Exists Operator = "Exists"
+	// NotExists holds if the path selects no value.
+	NotExists Operator = "NotExists"
+	// Equals holds if the path selects at least one value, and all of them are equal to the condition value.
+	Equals Operator = "Equals"
+	// NotEquals holds if none of the selected values is equal to the condition value.
+	NotEquals Operator = "NotEquals"
+	// In holds if the path selects at least one value, and all of them are one of the condition values.
+	In Operator = "In"
+	// NotIn holds if none of the selected values is one of the condition values.
+	NotIn Operator = "NotIn"
+	// Matches holds if the path selects at least one value, and all of them match the condition regular expression.
+	Matches Operator = "Matches"
+	// NotMatches holds if none of the selected values matches the condition regular expression.
+	NotMatches Operator = "NotMatches"
+)
+
+// codeRegexp is the format of the message codes of custom analyzers. The IST prefix is reserved for Istio.
+var codeRegexp = regexp.MustCompile(`^[A-Z]+[0-9]{4}$`)
+
+// Rules is the declarative format of a set of custom analyzers.
+type Rules struct {
+	Analyzers []Rule `json:"analyzers"`
+}
+
+// Rule declares an analyzer that reports a message for every resource of a collection that matches all the Match
+// conditions, but fails one of the Require conditions.
+type Rule struct {
+	// Name of the analyzer, unique among the custom analyzers.
+	Name string `json:"name"`
+	// Description of the analyzer, shown when listing analyzers.
+	Description string `json:"description,omitempty"`
+	// Collection of the analyzed resources, e.g. istio/networking/v1alpha3/gateways.
+	Collection string `json:"collection"`
+	// Code of the reported message, e.g. ORG0001.
+	Code string `json:"code"`
+	// Level of the reported message: Info, Warning or Error. Defaults to Warning.
+	Level string `json:"level,omitempty"`
+	// Message reported for the resources violating the rule.
+	Message string `json:"message"`
+	// Match restricts the rule to the resources satisfying all of the conditions.
+	Match []Condition `json:"match,omitempty"`
+	// Require lists the conditions every matched resource must satisfy.
+	Require []Condition `json:"require"`
+}
+
+// Condition is a predicate over the values selected in a resource by a JSONPath expression. The resource is
+// represented as an object with a metadata field, holding its name, namespace, labels and annotations, and a spec
+// field, holding the body of the resource.
+type Condition struct {
+	// Path is a JSONPath expression, e.g. {.metadata.labels.team} or {.spec.servers[*].hosts[*]}.
+	Path     string   `json:"path"`
+	Operator Operator `json:"operator"`
+	Value    string   `json:"value,omitempty"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// Parse parses custom analyzers from their YAML or JSON declaration.
+func Parse(data []byte) ([]*Analyzer, error) {
+	var rules Rules
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, err
+	}
+	var errs error
+	var out []*Analyzer
+	for _, r := range rules.Analyzers {
+		a, err := newAnalyzer(r)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("analyzer %q: %v", r.Name, err))
+			continue
+		}
+		out = append(out, a)
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return out, checkUnique(out)
+}
+
+// Load parses the custom analyzers declared in the files. Directories are expanded to the YAML and JSON files they
+// contain, so that analyzers can be loaded from a mounted ConfigMap.
+func Load(paths []string) ([]*Analyzer, error) {
+	var files []string
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := ioutil.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			switch filepath.Ext(e.Name()) {
+			case ".yaml", ".yml", ".json":
+				// Skip the hidden entries created by ConfigMap volumes.
+				if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+					files = append(files, filepath.Join(p, e.Name()))
+				}
+			}
+		}
+	}
+
+	var out []*Analyzer
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		analyzers, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse custom analyzers in %s: %v", f, err)
+		}
+		out = append(out, analyzers...)
+	}
+	return out, checkUnique(out)
+}
+
+func checkUnique(analyzers []*Analyzer) error {
+	names := map[string]bool{}
+	codes := map[string]bool{}
+	for _, a := range analyzers {
+		if names[a.name] {
+			return fmt.Errorf("duplicate custom analyzer %q", a.name)
+		}
+		names[a.name] = true
+		if codes[a.messageType.Code()] {
+			return fmt.Errorf("duplicate message code %s in custom analyzer %q", a.messageType.Code(), a.name)
+		}
+		codes[a.messageType.Code()] = true
+	}
+	return nil
+}
+
+func newAnalyzer(r Rule) (*Analyzer, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	s, found := collections.All.Find(r.Collection)
+	if !found {
+		return nil, fmt.Errorf("unknown collection %q", r.Collection)
+	}
+	if !codeRegexp.MatchString(r.Code) || strings.HasPrefix(r.Code, "IST") {
+		return nil, fmt.Errorf("invalid code %q: must be an uppercase prefix other than IST followed by four digits", r.Code)
+	}
+	level := diag.Warning
+	if r.Level != "" {
+		l, ok := diag.GetUppercaseStringToLevelMap()[strings.ToUpper(r.Level)]
+		if !ok {
+			return nil, fmt.Errorf("invalid level %q: valid values are %v", r.Level, diag.GetAllLevelStrings())
+		}
+		level = l
+	}
+	if r.Message == "" {
+		return nil, fmt.Errorf("message is required")
+	}
+	if len(r.Require) == 0 {
+		return nil, fmt.Errorf("at least one required condition is needed")
+	}
+	match, err := compileConditions(r.Match)
+	if err != nil {
+		return nil, fmt.Errorf("invalid match: %v", err)
+	}
+	require, err := compileConditions(r.Require)
+	if err != nil {
+		return nil, fmt.Errorf("invalid require: %v", err)
+	}
+	return &Analyzer{
+		name:        r.Name,
+		description: r.Description,
+		collection:  s.Name(),
+		// The message is not a format string.
+		messageType: diag.NewMessageType(level, r.Code, strings.ReplaceAll(r.Message, "%", "%%")),
+		match:       match,
+		require:     require,
+	}, nil
+}
+
+func compileConditions(conditions []Condition) ([]*condition, error) {
+	var out []*condition
+	for i, c := range conditions {
+		cc, err := compileCondition(c)
+		if err != nil {
+			return nil, fmt.Errorf("condition %d: %v", i, err)
+		}
+		out = append(out, cc)
+	}
+	return out, nil
+}
+
+func compileCondition(c Condition) (*condition, error) {
+	path := jsonpath.New(c.Path).AllowMissingKeys(true)
+	if err := path.Parse(c.Path); err != nil {
+		return nil, fmt.Errorf("invalid path %q: %v", c.Path, err)
+	}
+	cc := &condition{operator: c.Operator, path: path}
+	switch c.Operator {
+	case Exists, NotExists:
+	case Equals, NotEquals:
+		cc.values = []string{c.Value}
+	case In, NotIn:
+		if len(c.Values) == 0 {
+			return nil, fmt.Errorf("operator %s requires values", c.Operator)
+		}
+		cc.values = c.Values
+	case Matches, NotMatches:
+		re, err := regexp.Compile(c.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", c.Value, err)
+		}
+		cc.regexp = re
+	default:
+		return nil, fmt.Errorf("unknown operator %q", c.Operator)
+	}
+	return cc, nil
+}
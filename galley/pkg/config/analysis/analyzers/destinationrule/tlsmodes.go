@@ -0,0 +1,239 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package destinationrule
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/mesh/v1alpha1"
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// TLSModeConflictAnalyzer checks, for every service port, that the client TLS mode set by DestinationRules is
+// compatible with the server side mutual TLS mode set by PeerAuthentication policies for the workloads of the
+// service, and that clients can use mutual TLS when the workloads have no sidecar.
+type TLSModeConflictAnalyzer struct{}
+
+var _ analysis.Analyzer = &TLSModeConflictAnalyzer{}
+
+func (a *TLSModeConflictAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "destinationrule.TLSModeConflictAnalyzer",
+		Description: "Checks for conflicts between DestinationRule TLS modes and PeerAuthentication mTLS modes",
+		Inputs: collection.Names{
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+			collections.IstioNetworkingV1Alpha3Destinationrules.Name(),
+			collections.IstioSecurityV1Beta1Peerauthentications.Name(),
+			collections.K8SCoreV1Namespaces.Name(),
+			collections.K8SCoreV1Pods.Name(),
+			collections.K8SCoreV1Services.Name(),
+		},
+	}
+}
+
+// gatewayLabel is the label set on the pods of the Istio gateways.
+const gatewayLabel = "istio"
+
+// servicePod is a pod selected by a service, and whether it has a sidecar.
+type servicePod struct {
+	pod    *v1.Pod
+	inMesh bool
+}
+
+func (a *TLSModeConflictAnalyzer) Analyze(c analysis.Context) {
+	rootNamespace := ""
+	autoMTLS := true
+	c.ForEach(collections.IstioMeshV1Alpha1MeshConfig.Name(), func(r *resource.Instance) bool {
+		mc := r.Message.(*v1alpha1.MeshConfig)
+		rootNamespace = mc.GetRootNamespace()
+		if mc.GetEnableAutoMtls() != nil {
+			autoMTLS = mc.GetEnableAutoMtls().GetValue()
+		}
+		return r.Metadata.FullName.Name != util.MeshConfigName
+	})
+	peerAuthns := util.InitPeerAuthentications(c, rootNamespace)
+
+	pods := map[resource.Namespace][]servicePod{}
+	c.ForEach(collections.K8SCoreV1Pods.Name(), func(r *resource.Instance) bool {
+		pod := r.Message.(*v1.Pod)
+		// The TLS settings of gateways are configured by Gateway resources, not PeerAuthentication.
+		if _, ok := pod.Labels[gatewayLabel]; ok {
+			return true
+		}
+		ns := r.Metadata.FullName.Namespace
+		pods[ns] = append(pods[ns], servicePod{pod: pod, inMesh: util.PodInMesh(r, c)})
+		return true
+	})
+	var destinationRules []*resource.Instance
+	c.ForEach(collections.IstioNetworkingV1Alpha3Destinationrules.Name(), func(r *resource.Instance) bool {
+		destinationRules = append(destinationRules, r)
+		return true
+	})
+
+	c.ForEach(collections.K8SCoreV1Services.Name(), func(r *resource.Instance) bool {
+		ns := r.Metadata.FullName.Namespace
+		svc := r.Message.(*v1.ServiceSpec)
+		if util.IsSystemNamespace(ns) || len(svc.Selector) == 0 {
+			return true
+		}
+		var selected []servicePod
+		selector := k8s_labels.SelectorFromSet(svc.Selector)
+		for _, p := range pods[ns] {
+			if selector.Matches(k8s_labels.Set(p.pod.Labels)) {
+				selected = append(selected, p)
+			}
+		}
+		// Without pods, there is nothing to tell whether the workloads have sidecars.
+		if len(selected) == 0 {
+			return true
+		}
+		fqdn := util.ConvertHostToFQDN(ns, r.Metadata.FullName.Name.String())
+		drs := serviceDestinationRules(fqdn, ns.String(), rootNamespace, destinationRules)
+		for _, port := range svc.Ports {
+			a.analyzePort(c, r, port, selected, drs, peerAuthns, autoMTLS)
+		}
+		return true
+	})
+}
+
+func (a *TLSModeConflictAnalyzer) analyzePort(c analysis.Context, svc *resource.Instance, port v1.ServicePort, pods []servicePod,
+	drs []*resource.Instance, peerAuthns *util.PeerAuthentications, autoMTLS bool) {
+	svcName := svc.Metadata.FullName.String()
+	clientTLS := false
+	for _, dr := range drs {
+		tls := portTLSSettings(dr.Message.(*v1alpha3.DestinationRule), uint32(port.Port))
+		if tls == nil {
+			continue
+		}
+		clientTLS = true
+		// A conflict is only reported when every workload of the service is affected.
+		reason := ""
+		for _, p := range pods {
+			r := tlsConflict(tls.Mode, p, port, peerAuthns)
+			if r == "" {
+				reason = ""
+				break
+			}
+			if reason == "" {
+				reason = r
+			}
+		}
+		if reason != "" {
+			c.Report(collections.IstioNetworkingV1Alpha3Destinationrules.Name(),
+				msg.NewDestinationRuleTLSModeConflict(dr, dr.Metadata.FullName.String(), tls.Mode.String(), int(port.Port), svcName, reason))
+		}
+	}
+	if autoMTLS || clientTLS {
+		return
+	}
+	// Without automatic mutual TLS or a DestinationRule setting TLS, clients use plaintext.
+	reported := map[*resource.Instance]bool{}
+	for _, p := range pods {
+		if !p.inMesh {
+			continue
+		}
+		mode, pa := peerAuthns.MTLSMode(p.pod.Namespace, p.pod.Labels, uint32(util.ServiceTargetPort(port, p.pod)))
+		if mode == v1beta1.PeerAuthentication_MutualTLS_STRICT && !reported[pa] {
+			reported[pa] = true
+			c.Report(collections.IstioSecurityV1Beta1Peerauthentications.Name(),
+				msg.NewStrictMTLSWithoutClientMTLS(pa, int(port.Port), svcName))
+		}
+	}
+}
+
+// tlsConflict returns why connections using the client TLS mode to the port of the pod fail, or an empty string if
+// they do not.
+func tlsConflict(clientMode v1alpha3.ClientTLSSettings_TLSmode, p servicePod, port v1.ServicePort,
+	peerAuthns *util.PeerAuthentications) string {
+	if !p.inMesh {
+		if clientMode == v1alpha3.ClientTLSSettings_ISTIO_MUTUAL {
+			return fmt.Sprintf("pod %s/%s has no sidecar to terminate Istio mutual TLS", p.pod.Namespace, p.pod.Name)
+		}
+		return ""
+	}
+	mode, pa := peerAuthns.MTLSMode(p.pod.Namespace, p.pod.Labels, uint32(util.ServiceTargetPort(port, p.pod)))
+	switch {
+	case mode == v1beta1.PeerAuthentication_MutualTLS_STRICT && clientMode == v1alpha3.ClientTLSSettings_DISABLE:
+		return fmt.Sprintf("PeerAuthentication %s requires mutual TLS for pod %s/%s", pa.Metadata.FullName, p.pod.Namespace, p.pod.Name)
+	case mode == v1beta1.PeerAuthentication_MutualTLS_STRICT &&
+		(clientMode == v1alpha3.ClientTLSSettings_SIMPLE || clientMode == v1alpha3.ClientTLSSettings_MUTUAL):
+		return fmt.Sprintf("PeerAuthentication %s requires Istio mutual TLS for pod %s/%s", pa.Metadata.FullName, p.pod.Namespace, p.pod.Name)
+	case mode == v1beta1.PeerAuthentication_MutualTLS_DISABLE && clientMode == v1alpha3.ClientTLSSettings_ISTIO_MUTUAL:
+		return fmt.Sprintf("PeerAuthentication %s disables mutual TLS for pod %s/%s", pa.Metadata.FullName, p.pod.Namespace, p.pod.Name)
+	}
+	return ""
+}
+
+// portTLSSettings returns the client TLS settings of the DestinationRule for the port, which may be overridden by a
+// port level traffic policy.
+func portTLSSettings(dr *v1alpha3.DestinationRule, port uint32) *v1alpha3.ClientTLSSettings {
+	for _, pls := range dr.GetTrafficPolicy().GetPortLevelSettings() {
+		if pls.GetPort().GetNumber() == port && pls.GetTls() != nil {
+			return pls.GetTls()
+		}
+	}
+	return dr.GetTrafficPolicy().GetTls()
+}
+
+// serviceDestinationRules returns the DestinationRules that clients use for the service: the most specific match in
+// each namespace. A DestinationRule in the root namespace is only used if the service's namespace has none.
+func serviceDestinationRules(fqdn, svcNs, rootNamespace string, drs []*resource.Instance) []*resource.Instance {
+	best := map[resource.Namespace]*resource.Instance{}
+	var namespaces []resource.Namespace
+	for _, r := range drs {
+		ns := r.Metadata.FullName.Namespace
+		h := host.Name(util.ConvertHostToFQDN(ns, r.Message.(*v1alpha3.DestinationRule).Host))
+		if !h.Matches(host.Name(fqdn)) {
+			continue
+		}
+		current, ok := best[ns]
+		if !ok {
+			namespaces = append(namespaces, ns)
+		}
+		if !ok || moreSpecific(h, host.Name(util.ConvertHostToFQDN(ns, current.Message.(*v1alpha3.DestinationRule).Host))) {
+			best[ns] = r
+		}
+	}
+	var out []*resource.Instance
+	_, svcNsHasDR := best[resource.Namespace(svcNs)]
+	for _, ns := range namespaces {
+		if ns.String() == rootNamespace && svcNsHasDR && svcNs != rootNamespace {
+			continue
+		}
+		out = append(out, best[ns])
+	}
+	return out
+}
+
+// moreSpecific returns true if the host a is more specific than the host b.
+func moreSpecific(a, b host.Name) bool {
+	aWildcard, bWildcard := a.IsWildCarded(), b.IsWildCarded()
+	if aWildcard != bWildcard {
+		return !aWildcard
+	}
+	return len(strings.TrimPrefix(string(a), "*")) > len(strings.TrimPrefix(string(b), "*"))
+}
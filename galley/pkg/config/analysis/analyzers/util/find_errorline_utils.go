@@ -46,6 +46,10 @@ const (
 	// Required parameters: http index, match index, where to match, match key.
 	HeaderAndQueryParamsRegexMatch = "{.spec.http[%d].match[%d].%s.%s.regex}"
 
+	// Path for the name of an http route.
+	// Required parameters: http index.
+	HTTPRouteName = "{.spec.http[%d].name}"
+
 	// Path for regex match of allowOrigins.
 	// Required parameters: http index, allowOrigins index.
 	AllowOriginsRegexMatch = "{.spec.http[%d].corsPolicy.allowOrigins[%d].regex}"
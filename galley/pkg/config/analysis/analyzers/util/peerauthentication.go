@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// PeerAuthentications resolves the effective mutual TLS mode of workloads from PeerAuthentication policies.
+type PeerAuthentications struct {
+	rootNamespace string
+	policies      []*resource.Instance
+}
+
+// InitPeerAuthentications reads the PeerAuthentication policies. Policies in the root namespace without a selector
+// apply to the whole mesh.
+func InitPeerAuthentications(c analysis.Context, rootNamespace string) *PeerAuthentications {
+	p := &PeerAuthentications{rootNamespace: rootNamespace}
+	c.ForEach(collections.IstioSecurityV1Beta1Peerauthentications.Name(), func(r *resource.Instance) bool {
+		p.policies = append(p.policies, r)
+		return true
+	})
+	// Prefer the oldest policy when several apply, as istiod does.
+	sort.SliceStable(p.policies, func(i, j int) bool {
+		return p.policies[i].Metadata.CreateTime.Before(p.policies[j].Metadata.CreateTime)
+	})
+	return p
+}
+
+// MTLSMode returns the effective mutual TLS mode on a port of a workload, from the port level, workload, namespace
+// and mesh wide policies in that order of precedence, along with the policy that sets it. Without labels, only the
+// namespace and mesh wide policies are considered. Without any policy, the mode is PERMISSIVE.
+func (p *PeerAuthentications) MTLSMode(ns string, labels map[string]string,
+	port uint32) (v1beta1.PeerAuthentication_MutualTLS_Mode, *resource.Instance) {
+	var workload, namespace, mesh *resource.Instance
+	for _, r := range p.policies {
+		pa := r.Message.(*v1beta1.PeerAuthentication)
+		paNs := r.Metadata.FullName.Namespace.String()
+		switch {
+		case paNs == ns && pa.Selector != nil:
+			if labels != nil && workload == nil &&
+				k8s_labels.SelectorFromSet(pa.Selector.MatchLabels).Matches(k8s_labels.Set(labels)) {
+				workload = r
+			}
+		case paNs == ns:
+			if namespace == nil {
+				namespace = r
+			}
+		case pa.Selector == nil && paNs == p.rootNamespace:
+			if mesh == nil {
+				mesh = r
+			}
+		}
+	}
+	if workload != nil {
+		if mode := workload.Message.(*v1beta1.PeerAuthentication).PortLevelMtls[port].GetMode(); mode != v1beta1.PeerAuthentication_MutualTLS_UNSET {
+			return mode, workload
+		}
+	}
+	for _, r := range []*resource.Instance{workload, namespace, mesh} {
+		if r == nil {
+			continue
+		}
+		if mode := r.Message.(*v1beta1.PeerAuthentication).GetMtls().GetMode(); mode != v1beta1.PeerAuthentication_MutualTLS_UNSET {
+			return mode, r
+		}
+	}
+	return v1beta1.PeerAuthentication_MutualTLS_PERMISSIVE, nil
+}
+
+// ServiceTargetPort returns the port number on the pod that a service port targets, or 0 if a named target port is
+// not declared by the pod.
+func ServiceTargetPort(port v1.ServicePort, pod *v1.Pod) int {
+	if port.TargetPort.StrVal == "" {
+		if n := port.TargetPort.IntValue(); n > 0 {
+			return n
+		}
+		return int(port.Port)
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, cp := range container.Ports {
+			if cp.Name == port.TargetPort.StrVal {
+				return int(cp.ContainerPort)
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,425 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package virtualservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// ShadowedRouteAnalyzer checks for HTTP routes that can never be used because a preceding route matches every
+// request they match. Routes are considered in the order the proxy evaluates them: the routes of a delegate
+// VirtualService are merged into the delegating route, and the routes of all VirtualServices for the same host on a
+// gateway are concatenated in creation order.
+type ShadowedRouteAnalyzer struct{}
+
+var _ analysis.Analyzer = &ShadowedRouteAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *ShadowedRouteAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "virtualservice.ShadowedRouteAnalyzer",
+		Description: "Checks for HTTP routes that are never used because a preceding route matches all their requests",
+		Inputs: collection.Names{
+			collections.IstioNetworkingV1Alpha3Virtualservices.Name(),
+		},
+	}
+}
+
+// httpRoute is an HTTP route as evaluated by the proxy, along with the rule of the VirtualService it originates from.
+type httpRoute struct {
+	route *v1alpha3.HTTPRoute
+	// namespace of the VirtualService that holds the hosts and gateways the route is used for.
+	namespace resource.Namespace
+	r         *resource.Instance
+	index     int
+	name      string
+}
+
+type routeKey struct {
+	vs    resource.FullName
+	index int
+}
+
+// routeUsage records the route tables a route appears in, and how many of them shadow it.
+type routeUsage struct {
+	route       *httpRoute
+	tables      int
+	shadowed    int
+	shadowingBy *httpRoute
+}
+
+// Analyze implements Analyzer
+func (a *ShadowedRouteAnalyzer) Analyze(ctx analysis.Context) {
+	var vses []*resource.Instance
+	delegates := map[resource.FullName]*resource.Instance{}
+	ctx.ForEach(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), func(r *resource.Instance) bool {
+		if len(r.Message.(*v1alpha3.VirtualService).Hosts) == 0 {
+			delegates[r.Metadata.FullName] = r
+		} else {
+			vses = append(vses, r)
+		}
+		return true
+	})
+	// Match the order in which pilot evaluates VirtualServices.
+	sort.SliceStable(vses, func(i, j int) bool {
+		if vses[i].Metadata.CreateTime.Equal(vses[j].Metadata.CreateTime) {
+			in := vses[i].Metadata.FullName.Name.String() + "." + vses[i].Metadata.FullName.Namespace.String()
+			jn := vses[j].Metadata.FullName.Name.String() + "." + vses[j].Metadata.FullName.Namespace.String()
+			return in < jn
+		}
+		return vses[i].Metadata.CreateTime.Before(vses[j].Metadata.CreateTime)
+	})
+
+	reportedConflicts := map[routeKey]bool{}
+	routes := make(map[resource.FullName][]*httpRoute, len(vses))
+	for _, r := range vses {
+		routes[r.Metadata.FullName] = effectiveRoutes(ctx, r, delegates, reportedConflicts)
+	}
+
+	// Each route table is a list of routes that the proxy evaluates in order for a gateway. For the mesh gateway,
+	// only a single VirtualService is used per host, while the routes of all VirtualServices for a host are
+	// concatenated on other gateways.
+	type tableKey struct {
+		gateway string
+		host    string
+	}
+	tables := map[tableKey][]*httpRoute{}
+	var tableKeys []tableKey
+	for _, r := range vses {
+		vs := r.Message.(*v1alpha3.VirtualService)
+		ns := r.Metadata.FullName.Namespace
+		for _, gw := range vsGateways(vs, ns) {
+			if gw == util.MeshGateway {
+				k := tableKey{gateway: gw, host: r.Metadata.FullName.String()}
+				tables[k] = routes[r.Metadata.FullName]
+				tableKeys = append(tableKeys, k)
+				continue
+			}
+			for _, h := range vs.Hosts {
+				k := tableKey{gateway: gw, host: util.ConvertHostToFQDN(ns, h)}
+				if _, f := tables[k]; !f {
+					tableKeys = append(tableKeys, k)
+				}
+				tables[k] = append(tables[k], routes[r.Metadata.FullName]...)
+			}
+		}
+	}
+
+	ignored := ignoredRoutes(vses, delegates)
+	usages := map[routeKey]*routeUsage{}
+	var usageKeys []routeKey
+	for _, k := range tableKeys {
+		table := tables[k]
+		for j, rj := range table {
+			if !routeApplies(rj, k.gateway) {
+				continue
+			}
+			key := routeKey{vs: rj.r.Metadata.FullName, index: rj.index}
+			u, f := usages[key]
+			if !f {
+				u = &routeUsage{route: rj}
+				usages[key] = u
+				usageKeys = append(usageKeys, key)
+			}
+			u.tables++
+			for _, ri := range table[:j] {
+				if routeCovers(ri, rj, k.gateway) {
+					u.shadowed++
+					if u.shadowingBy == nil {
+						u.shadowingBy = ri
+					}
+					break
+				}
+			}
+		}
+	}
+
+	for _, key := range usageKeys {
+		u := usages[key]
+		// Duplicate matches within a VirtualService are already reported by validation.
+		if u.shadowed == 0 || u.shadowed != u.tables || ignored[key] {
+			continue
+		}
+		m := msg.NewVirtualServiceShadowedRoute(u.route.r, u.route.name, u.shadowingBy.name,
+			u.shadowingBy.r.Metadata.FullName.String())
+		if line, ok := util.ErrorLine(u.route.r, fmt.Sprintf(util.HTTPRouteName, u.route.index)); ok {
+			m.Line = line
+		}
+		ctx.Report(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), m)
+	}
+}
+
+// effectiveRoutes returns the HTTP routes of the VirtualService, with the routes of any delegate VirtualServices
+// merged into the delegating route as pilot does. Delegate routes which are dropped because their match conflicts
+// with the delegating route are reported.
+func effectiveRoutes(ctx analysis.Context, r *resource.Instance, delegates map[resource.FullName]*resource.Instance,
+	reported map[routeKey]bool) []*httpRoute {
+	vs := r.Message.(*v1alpha3.VirtualService)
+	ns := r.Metadata.FullName.Namespace
+	var out []*httpRoute
+	for i, route := range vs.Http {
+		if route.Delegate == nil {
+			out = append(out, &httpRoute{route: route, namespace: ns, r: r, index: i, name: httpRouteName(route, i)})
+			continue
+		}
+		delegateNs := ns
+		if route.Delegate.Namespace != "" {
+			delegateNs = resource.Namespace(route.Delegate.Namespace)
+		}
+		d, ok := delegates[resource.NewFullName(delegateNs, resource.LocalName(route.Delegate.Name))]
+		if !ok {
+			// A missing delegate is not used, and the delegating route is ignored.
+			continue
+		}
+		for j, delegateRoute := range d.Message.(*v1alpha3.VirtualService).Http {
+			merged := model.MergeHTTPRoute(route, delegateRoute)
+			if merged != nil {
+				out = append(out, &httpRoute{route: merged, namespace: ns, r: d, index: j, name: httpRouteName(delegateRoute, j)})
+				continue
+			}
+			key := routeKey{vs: d.Metadata.FullName, index: j}
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+			m := msg.NewVirtualServiceDelegateRouteConflict(d, httpRouteName(delegateRoute, j), httpRouteName(route, i),
+				r.Metadata.FullName.String())
+			if line, ok := util.ErrorLine(d, fmt.Sprintf(util.HTTPRouteName, j)); ok {
+				m.Line = line
+			}
+			ctx.Report(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), m)
+		}
+	}
+	return out
+}
+
+// ignoredRoutes returns the routes that validation already reports as unreachable because all their matches
+// duplicate those of previous routes in the same VirtualService.
+func ignoredRoutes(vses []*resource.Instance, delegates map[resource.FullName]*resource.Instance) map[routeKey]bool {
+	out := map[routeKey]bool{}
+	all := append([]*resource.Instance{}, vses...)
+	for _, d := range delegates {
+		all = append(all, d)
+	}
+	for _, r := range all {
+		encountered := map[string]bool{}
+		emptyMatchEncountered := false
+		for i, route := range r.Message.(*v1alpha3.VirtualService).Http {
+			if len(route.Match) == 0 {
+				if emptyMatchEncountered {
+					out[routeKey{vs: r.Metadata.FullName, index: i}] = true
+				}
+				emptyMatchEncountered = true
+				continue
+			}
+			duplicates := 0
+			for _, m := range route.Match {
+				k := matchKey(m)
+				if encountered[k] {
+					duplicates++
+				}
+				encountered[k] = true
+			}
+			if duplicates == len(route.Match) {
+				out[routeKey{vs: r.Metadata.FullName, index: i}] = true
+			}
+		}
+	}
+	return out
+}
+
+func matchKey(m *v1alpha3.HTTPMatchRequest) string {
+	unnamed := *m
+	unnamed.Name = ""
+	b, err := json.Marshal(&unnamed)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+func httpRouteName(route *v1alpha3.HTTPRoute, index int) string {
+	if route.Name != "" {
+		return fmt.Sprintf("%q", route.Name)
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// vsGateways returns the namespace qualified gateways of the VirtualService.
+func vsGateways(vs *v1alpha3.VirtualService, ns resource.Namespace) []string {
+	if len(vs.Gateways) == 0 {
+		return []string{util.MeshGateway}
+	}
+	out := make([]string, 0, len(vs.Gateways))
+	for _, gw := range vs.Gateways {
+		out = append(out, qualifiedGateway(gw, ns))
+	}
+	return out
+}
+
+func qualifiedGateway(gw string, ns resource.Namespace) string {
+	if gw == util.MeshGateway {
+		return gw
+	}
+	return resource.NewShortOrFullName(ns, gw).String()
+}
+
+// applicableMatches returns the matches of the route that apply to the gateway. A route without matches matches
+// all requests, which is represented by a single empty match.
+func applicableMatches(r *httpRoute, gateway string) []*v1alpha3.HTTPMatchRequest {
+	if len(r.route.Match) == 0 {
+		return []*v1alpha3.HTTPMatchRequest{{}}
+	}
+	var out []*v1alpha3.HTTPMatchRequest
+	for _, m := range r.route.Match {
+		if len(m.Gateways) == 0 {
+			out = append(out, m)
+			continue
+		}
+		for _, gw := range m.Gateways {
+			if qualifiedGateway(gw, r.namespace) == gateway {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func routeApplies(r *httpRoute, gateway string) bool {
+	return len(applicableMatches(r, gateway)) > 0
+}
+
+// routeCovers returns true if every request matched by b on the gateway is also matched by a.
+func routeCovers(a, b *httpRoute, gateway string) bool {
+	am := applicableMatches(a, gateway)
+	if len(am) == 0 {
+		return false
+	}
+	for _, bm := range applicableMatches(b, gateway) {
+		covered := false
+		for _, m := range am {
+			if matchCovers(m, bm) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCovers returns true if every request matched by b is also matched by a, assuming that both apply to the
+// same gateway. It is conservative, and returns false when it cannot tell.
+func matchCovers(a, b *v1alpha3.HTTPMatchRequest) bool {
+	if !stringMatchCovers(a.Uri, b.Uri, a.IgnoreUriCase, b.IgnoreUriCase) ||
+		!stringMatchCovers(a.Scheme, b.Scheme, false, false) ||
+		!stringMatchCovers(a.Method, b.Method, false, false) ||
+		!stringMatchCovers(a.Authority, b.Authority, false, false) {
+		return false
+	}
+	if !stringMatchesCover(a.Headers, b.Headers) || !stringMatchesCover(a.QueryParams, b.QueryParams) {
+		return false
+	}
+	for k, am := range a.WithoutHeaders {
+		bm, ok := b.WithoutHeaders[k]
+		if !ok || !proto.Equal(am, bm) {
+			return false
+		}
+	}
+	if a.Port != 0 && a.Port != b.Port {
+		return false
+	}
+	for k, v := range a.SourceLabels {
+		if bv, ok := b.SourceLabels[k]; !ok || bv != v {
+			return false
+		}
+	}
+	if a.SourceNamespace != "" && a.SourceNamespace != b.SourceNamespace {
+		return false
+	}
+	return true
+}
+
+// stringMatchesCover returns true if every header or query parameter condition in a is implied by b.
+func stringMatchesCover(a, b map[string]*v1alpha3.StringMatch) bool {
+	for k, am := range a {
+		bm, ok := b[k]
+		if !ok {
+			return false
+		}
+		// An empty match only requires the header or query parameter to be present.
+		if am.GetMatchType() != nil && !stringMatchCovers(am, bm, false, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// stringMatchCovers returns true if every string matched by b is also matched by a.
+func stringMatchCovers(a, b *v1alpha3.StringMatch, aIgnoreCase, bIgnoreCase bool) bool {
+	if a.GetMatchType() == nil {
+		return true
+	}
+	if b.GetMatchType() == nil || (bIgnoreCase && !aIgnoreCase) {
+		return false
+	}
+	normalize := func(s string) string {
+		if aIgnoreCase {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	switch am := a.MatchType.(type) {
+	case *v1alpha3.StringMatch_Exact:
+		return b.GetExact() != "" && normalize(b.GetExact()) == normalize(am.Exact)
+	case *v1alpha3.StringMatch_Prefix:
+		if b.GetExact() != "" {
+			return strings.HasPrefix(normalize(b.GetExact()), normalize(am.Prefix))
+		}
+		return b.GetPrefix() != "" && strings.HasPrefix(normalize(b.GetPrefix()), normalize(am.Prefix))
+	case *v1alpha3.StringMatch_Regex:
+		if b.GetRegex() != "" {
+			return b.GetRegex() == am.Regex && aIgnoreCase == bIgnoreCase
+		}
+		if b.GetExact() == "" {
+			return false
+		}
+		expr := "^(?:" + am.Regex + ")$"
+		if aIgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		return err == nil && re.MatchString(b.GetExact())
+	}
+	return false
+}
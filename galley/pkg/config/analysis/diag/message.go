@@ -71,7 +71,7 @@ func (m *Message) Unstructured(includeOrigin bool) map[string]interface{} {
 
 	result["code"] = m.Type.Code()
 	result["level"] = m.Type.Level().String()
-	if includeOrigin && m.Resource != nil {
+	if includeOrigin && m.Resource != nil && m.Resource.Origin != nil {
 		result["origin"] = m.Resource.Origin.FriendlyName()
 		if m.Resource.Origin.Reference() != nil {
 			loc := m.Resource.Origin.Reference().String()
@@ -123,7 +123,7 @@ func (m *Message) UnstructuredAnalysisMessageBase() map[string]interface{} {
 // Origin returns the origin of the message
 func (m *Message) Origin() string {
 	origin := ""
-	if m.Resource != nil {
+	if m.Resource != nil && m.Resource.Origin != nil {
 		loc := ""
 		if m.Resource.Origin.Reference() != nil {
 			loc = " " + m.Resource.Origin.Reference().String()
@@ -176,6 +176,34 @@ var (
 	// ConflictingGateways defines a diag.MessageType for message "ConflictingGateways".
 	// Description: Gateway should not have the same selector, port and matched hosts of server
 	ConflictingGateways = diag.NewMessageType(diag.Error, "IST0145", "Conflict with gateways %s (workload selector %s, port %s, hosts %v).")
+
+	// VirtualServiceShadowedRoute defines a diag.MessageType for message "VirtualServiceShadowedRoute".
+	// Description: A VirtualService route will never be used because a preceding route matches every request it matches.
+	VirtualServiceShadowedRoute = diag.NewMessageType(diag.Warning, "IST0146", "VirtualService rule %v is not used because every request it matches is matched first by rule %v of VirtualService %s.")
+
+	// VirtualServiceDelegateRouteConflict defines a diag.MessageType for message "VirtualServiceDelegateRouteConflict".
+	// Description: A route of a delegate VirtualService will never be used because its match conflicts with the delegating route.
+	VirtualServiceDelegateRouteConflict = diag.NewMessageType(diag.Warning, "IST0147", "VirtualService rule %v is not used because its match conflicts with the delegating rule %v of VirtualService %s.")
+
+	// AuthorizationPolicyAllowOverriddenByDeny defines a diag.MessageType for message "AuthorizationPolicyAllowOverriddenByDeny".
+	// Description: An ALLOW AuthorizationPolicy rule has no effect because a DENY policy denies all the requests it allows.
+	AuthorizationPolicyAllowOverriddenByDeny = diag.NewMessageType(diag.Warning, "IST0148", "ALLOW rule %v has no effect because all the requests it allows are denied by rule %v of DENY policy %s.")
+
+	// AuthorizationPolicyPeerIdentityUnavailable defines a diag.MessageType for message "AuthorizationPolicyPeerIdentityUnavailable".
+	// Description: An AuthorizationPolicy source based on the peer identity can never match because the requests are not mutual TLS.
+	AuthorizationPolicyPeerIdentityUnavailable = diag.NewMessageType(diag.Warning, "IST0149", "Rule %v source %s can never match because %s.")
+
+	// AuthorizationPolicyHTTPFieldsOnTCPPort defines a diag.MessageType for message "AuthorizationPolicyHTTPFieldsOnTCPPort".
+	// Description: An AuthorizationPolicy rule uses HTTP only fields on a port which is not HTTP.
+	AuthorizationPolicyHTTPFieldsOnTCPPort = diag.NewMessageType(diag.Warning, "IST0150", "Rule %v uses HTTP only fields (%s) which never match requests on TCP port %v of service %s, so %s.")
+
+	// DestinationRuleTLSModeConflict defines a diag.MessageType for message "DestinationRuleTLSModeConflict".
+	// Description: The TLS mode of a DestinationRule conflicts with the workloads of a service, so TLS handshakes will fail.
+	DestinationRuleTLSModeConflict = diag.NewMessageType(diag.Error, "IST0151", "DestinationRule %s sets TLS mode %s for port %v of service %s, but %s, so connections will fail.")
+
+	// StrictMTLSWithoutClientMTLS defines a diag.MessageType for message "StrictMTLSWithoutClientMTLS".
+	// Description: A PeerAuthentication requires mutual TLS for a service, but clients will not use it.
+	StrictMTLSWithoutClientMTLS = diag.NewMessageType(diag.Error, "IST0152", "Port %v of service %s requires mutual TLS, but automatic mutual TLS is disabled and no DestinationRule sets TLS mode ISTIO_MUTUAL for it, so connections will fail.")
 )
 
 // All returns a list of all known message types.
@@ -223,6 +251,13 @@ func All() []*diag.MessageType {
 		LocalhostListener,
 		InvalidApplicationUID,
 		ConflictingGateways,
+		VirtualServiceShadowedRoute,
+		VirtualServiceDelegateRouteConflict,
+		AuthorizationPolicyAllowOverriddenByDeny,
+		AuthorizationPolicyPeerIdentityUnavailable,
+		AuthorizationPolicyHTTPFieldsOnTCPPort,
+		DestinationRuleTLSModeConflict,
+		StrictMTLSWithoutClientMTLS,
 	}
 }
 
@@ -646,3 +681,83 @@ func NewConflictingGateways(r *resource.Instance, gateway string, selector strin
 		hosts,
 	)
 }
+
+// NewVirtualServiceShadowedRoute returns a new diag.Message based on VirtualServiceShadowedRoute.
+func NewVirtualServiceShadowedRoute(r *resource.Instance, ruleno string, shadowingrule string, virtualservice string) diag.Message {
+	return diag.NewMessage(
+		VirtualServiceShadowedRoute,
+		r,
+		ruleno,
+		shadowingrule,
+		virtualservice,
+	)
+}
+
+// NewVirtualServiceDelegateRouteConflict returns a new diag.Message based on VirtualServiceDelegateRouteConflict.
+func NewVirtualServiceDelegateRouteConflict(r *resource.Instance, ruleno string, rootrule string, virtualservice string) diag.Message {
+	return diag.NewMessage(
+		VirtualServiceDelegateRouteConflict,
+		r,
+		ruleno,
+		rootrule,
+		virtualservice,
+	)
+}
+
+// NewAuthorizationPolicyAllowOverriddenByDeny returns a new diag.Message based on AuthorizationPolicyAllowOverriddenByDeny.
+func NewAuthorizationPolicyAllowOverriddenByDeny(r *resource.Instance, ruleno string, denyrule string, denypolicy string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyAllowOverriddenByDeny,
+		r,
+		ruleno,
+		denyrule,
+		denypolicy,
+	)
+}
+
+// NewAuthorizationPolicyPeerIdentityUnavailable returns a new diag.Message based on AuthorizationPolicyPeerIdentityUnavailable.
+func NewAuthorizationPolicyPeerIdentityUnavailable(r *resource.Instance, ruleno string, source string, reason string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyPeerIdentityUnavailable,
+		r,
+		ruleno,
+		source,
+		reason,
+	)
+}
+
+// NewAuthorizationPolicyHTTPFieldsOnTCPPort returns a new diag.Message based on AuthorizationPolicyHTTPFieldsOnTCPPort.
+func NewAuthorizationPolicyHTTPFieldsOnTCPPort(r *resource.Instance, ruleno string, fields string, port int, service string, effect string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyHTTPFieldsOnTCPPort,
+		r,
+		ruleno,
+		fields,
+		port,
+		service,
+		effect,
+	)
+}
+
+// NewDestinationRuleTLSModeConflict returns a new diag.Message based on DestinationRuleTLSModeConflict.
+func NewDestinationRuleTLSModeConflict(r *resource.Instance, destinationrule string, mode string, port int, service string, reason string) diag.Message {
+	return diag.NewMessage(
+		DestinationRuleTLSModeConflict,
+		r,
+		destinationrule,
+		mode,
+		port,
+		service,
+		reason,
+	)
+}
+
+// NewStrictMTLSWithoutClientMTLS returns a new diag.Message based on StrictMTLSWithoutClientMTLS.
+func NewStrictMTLSWithoutClientMTLS(r *resource.Instance, port int, service string) diag.Message {
+	return diag.NewMessage(
+		StrictMTLSWithoutClientMTLS,
+		r,
+		port,
+		service,
+	)
+}
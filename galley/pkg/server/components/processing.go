@@ -15,7 +15,9 @@
 package components
 
 import (
+	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/custom"
 	"istio.io/istio/galley/pkg/config/processing"
 	"istio.io/istio/galley/pkg/config/processing/snapshotter"
 	"istio.io/istio/galley/pkg/config/processor"
@@ -88,7 +90,15 @@ func (p *Processing) Start() (err error) {
 	var distributor snapshotter.Distributor = snapshotter.NewMCPDistributor(p.mcpCache)
 
 	if p.args.EnableConfigAnalysis {
-		combinedAnalyzer := analyzers.AllCombined()
+		var extraAnalyzers []*custom.Analyzer
+		if extraAnalyzers, err = custom.Load(p.args.CustomAnalyzerFiles); err != nil {
+			return
+		}
+		all := analyzers.All()
+		for _, a := range extraAnalyzers {
+			all = append(all, a)
+		}
+		combinedAnalyzer := analysis.Combine("all", all...)
 		combinedAnalyzer.RemoveSkipped(colsInSnapshots, kubeResources.DisabledCollectionNames(), transformProviders)
 
 		distributor = snapshotter.NewAnalyzingDistributor(snapshotter.AnalyzingDistributorSettings{
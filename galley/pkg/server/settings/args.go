@@ -60,6 +60,9 @@ type Args struct { // nolint:maligned
 	// Enable Config Analysis service, that will analyze and update CRD status. UseOldProcessor must be set to false.
 	EnableConfigAnalysis bool
 
+	// Files, or directories of files, declaring custom analyzers to run in addition to the built-in ones.
+	CustomAnalyzerFiles []string
+
 	Snapshots       []string
 	TriggerSnapshot string
 }
@@ -31,6 +31,7 @@ import (
 
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/custom"
 	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/local"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
@@ -76,6 +77,7 @@ var (
 	suppress          []string
 	analysisTimeout   time.Duration
 	recursive         bool
+	customAnalyzers   []string
 
 	fileExtensions = []string{".json", ".yaml", ".yml"}
 )
@@ -104,6 +106,12 @@ func Analyze() *cobra.Command {
   # and suppress MisplacedAnnotation on deployment foobar in namespace default.
   istioctl analyze -S "IST0103=Pod *.testing" -S "IST0107=Deployment foobar.default"
 
+  # Analyze yaml files and write the results in the SARIF format, for code scanning tools
+  istioctl analyze --use-kube=false -o sarif my-istio-config/ > istio-analysis.sarif
+
+  # Analyze the current live cluster with additional analyzers declared in my-analyzers.yaml
+  istioctl analyze --custom-analyzers my-analyzers.yaml
+
   # List available analyzers
   istioctl analyze -L`,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -115,8 +123,17 @@ func Analyze() *cobra.Command {
 				}
 			}
 
+			extraAnalyzers, err := custom.Load(customAnalyzers)
+			if err != nil {
+				return err
+			}
+			all := analyzers.All()
+			for _, a := range extraAnalyzers {
+				all = append(all, a)
+			}
+
 			if listAnalyzers {
-				fmt.Print(AnalyzersAsString(analyzers.All()))
+				fmt.Print(AnalyzersAsString(all))
 				return nil
 			}
 
@@ -148,7 +165,7 @@ func Analyze() *cobra.Command {
 				selectedNamespace = ""
 			}
 
-			sa := local.NewSourceAnalyzer(schema.MustGet(), analyzers.AllCombined(),
+			sa := local.NewSourceAnalyzer(schema.MustGet(), analysis.Combine("all", all...),
 				resource.Namespace(selectedNamespace), resource.Namespace(istioNamespace), nil, true, analysisTimeout)
 
 			// Check for suppressions and add them to our SourceAnalyzer
@@ -167,6 +184,12 @@ func Analyze() *cobra.Command {
 						break
 					}
 				}
+				for _, a := range extraAnalyzers {
+					if a.MessageType().Code() == parts[0] {
+						codeIsValid = true
+						break
+					}
+				}
 
 				if !codeIsValid {
 					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: Supplied message code '%s' is an unknown message code and will not have any effect.\n", parts[0])
@@ -313,6 +336,8 @@ func Analyze() *cobra.Command {
 		"The duration to wait before failing")
 	analysisCmd.PersistentFlags().BoolVarP(&recursive, "recursive", "R", false,
 		"Process directory arguments recursively. Useful when you want to analyze related manifests organized within the same directory.")
+	analysisCmd.PersistentFlags().StringArrayVar(&customAnalyzers, "custom-analyzers", []string{},
+		"Files, or directories of files, declaring additional analyzers to run. Can be repeated.")
 	return analysisCmd
 }
 
@@ -323,7 +348,8 @@ func gatherFiles(cmd *cobra.Command, args []string) ([]local.ReaderSource, error
 
 		// Handle "-" as stdin as a special case.
 		if f == "-" {
-			if isatty.IsTerminal(os.Stdin.Fd()) && !isJSONorYAMLOutputFormat() {
+			// TODO: Refactor output writer so that it is smart enough to know when to output what.
+			if isatty.IsTerminal(os.Stdin.Fd()) && msgOutputFormat == formatting.LogFormat {
 				fmt.Fprint(cmd.OutOrStdout(), "Reading from stdin:\n")
 			}
 			r = os.Stdin
@@ -449,8 +475,3 @@ func analyzeTargetAsString() string {
 	}
 	return fmt.Sprintf("namespace: %s", selectedNamespace)
 }
-
-// TODO: Refactor output writer so that it is smart enough to know when to output what.
-func isJSONorYAMLOutputFormat() bool {
-	return msgOutputFormat == formatting.JSONFormat || msgOutputFormat == formatting.YAMLFormat
-}
@@ -0,0 +1,321 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ghodss/yaml"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/api/label"
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/inject"
+)
+
+// injectionParameters is the injection configuration of a revision.
+type injectionParameters struct {
+	injector        *ExternalInjector
+	sidecarTemplate inject.Templates
+	valuesConfig    string
+	meshConfig      *meshconfig.MeshConfig
+}
+
+// injectedPodView holds the parts of a pod template that sidecar injection changes.
+type injectedPodView struct {
+	Annotations    map[string]string  `json:"annotations,omitempty"`
+	Labels         map[string]string  `json:"labels,omitempty"`
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+	Containers     []corev1.Container `json:"containers,omitempty"`
+	Volumes        []corev1.Volume    `json:"volumes,omitempty"`
+}
+
+func experimentalInjectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inject",
+		Short: "Commands related to sidecar injection",
+		Long:  `Commands related to sidecar injection`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.HelpFunc()(cmd, args)
+			if len(args) != 0 {
+				return fmt.Errorf("unknown subcommand %q", args[0])
+			}
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(injectDiffCommand())
+	return cmd
+}
+
+func injectDiffCommand() *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var selector string
+	var contextLines int
+
+	cmd := &cobra.Command{
+		Use:   "diff [<deployment>...]",
+		Short: "Preview the changes sidecar injection by another revision would make to Deployments",
+		Long: `
+Renders the sidecar injection of the pod template of Deployments with the injection configuration of the
+revision their pods are currently injected by, and with the one of the target revision read from the cluster, and
+prints the differences of the annotations, labels, containers and volumes. This previews the changes a restart of
+the Deployments would make after migrating them to the target revision.
+`,
+		Example: `  # Preview the changes of migrating the Deployments of the default namespace to the canary revision
+  istioctl x inject diff --revision canary
+
+  # Preview the changes of migrating the reviews-v1 Deployment of the bookinfo namespace to the canary revision
+  istioctl x inject diff reviews-v1 -n bookinfo --revision canary
+
+  # Preview the changes for the Deployments labeled app=reviews, using captured injection configuration
+  istioctl x inject diff -l app=reviews --revision canary \
+    --injectConfigFile /tmp/inj-template.tmpl \
+    --meshConfigFile /tmp/mesh.yaml \
+    --valuesFile /tmp/values.json`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) > 0 && selector != "" {
+				return fmt.Errorf("deployment names and --selector cannot be used together")
+			}
+			client, err := kube.NewExtendedClient(kube.BuildClientCmd(kubeconfig, configContext), "")
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %v", err)
+			}
+			ns := handlers.HandleNamespace(namespace, defaultNamespace)
+
+			var deployments []appsv1.Deployment
+			if len(args) > 0 {
+				for _, name := range args {
+					d, err := client.AppsV1().Deployments(ns).Get(context.TODO(), name, metav1.GetOptions{})
+					if err != nil {
+						return err
+					}
+					deployments = append(deployments, *d)
+				}
+			} else {
+				list, err := client.AppsV1().Deployments(ns).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+				if err != nil {
+					return err
+				}
+				deployments = list.Items
+			}
+			if len(deployments) == 0 {
+				fmt.Fprintf(c.OutOrStdout(), "No Deployments found in namespace %s.\n", ns)
+				return nil
+			}
+
+			targetRevision := opts.Revision
+			if targetRevision == "" {
+				targetRevision = defaultRevisionName
+			}
+			params := map[string]*injectionParameters{}
+			getParams := func(revision string) (*injectionParameters, error) {
+				if p, ok := params[revision]; ok {
+					return p, nil
+				}
+				p, err := loadInjectionParameters(revision, revision == targetRevision)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load the injection configuration of revision %q: %v", revision, err)
+				}
+				params[revision] = p
+				return p, nil
+			}
+
+			for i := range deployments {
+				d := &deployments[i]
+				currentRevision, err := deploymentRevision(client, d)
+				if err != nil {
+					return err
+				}
+				current := &d.Spec.Template
+				if currentRevision != "" {
+					p, err := getParams(currentRevision)
+					if err != nil {
+						return err
+					}
+					if current, err = renderInjection(d, currentRevision, p); err != nil {
+						return err
+					}
+				}
+				p, err := getParams(targetRevision)
+				if err != nil {
+					return err
+				}
+				target, err := renderInjection(d, targetRevision, p)
+				if err != nil {
+					return err
+				}
+				if err := printInjectionDiff(c.OutOrStdout(), d, currentRevision, targetRevision, current, target, contextLines); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		PersistentPreRunE: func(c *cobra.Command, args []string) error {
+			// Failures to reach the injection webhook are logged, the default for log messages should be stderr
+			_ = c.Root().PersistentFlags().Set("log_target", "stderr")
+
+			return c.Parent().PersistentPreRunE(c, args)
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&selector, "selector", "l", "",
+		"Label selector of the Deployments to preview")
+	cmd.PersistentFlags().IntVar(&contextLines, "context", 3,
+		"Number of lines of context in the diff")
+	cmd.PersistentFlags().StringVar(&meshConfigFile, "meshConfigFile", "",
+		"Mesh configuration filename of the target revision. Takes precedence over --meshConfigMapName if set")
+	cmd.PersistentFlags().StringVar(&injectConfigFile, "injectConfigFile", "",
+		"Injection configuration filename of the target revision")
+	cmd.PersistentFlags().StringVar(&valuesFile, "valuesFile", "",
+		"Injection values configuration filename of the target revision")
+	cmd.PersistentFlags().StringVar(&meshConfigMapName, "meshConfigMapName", defaultMeshConfigMapName,
+		fmt.Sprintf("ConfigMap name for Istio mesh configuration, key should be %q", configMapKey))
+	cmd.PersistentFlags().StringVar(&injectConfigMapName, "injectConfigMapName", defaultInjectConfigMapName,
+		fmt.Sprintf("ConfigMap name for Istio sidecar injection, key should be %q.", injectConfigMapKey))
+	_ = cmd.PersistentFlags().MarkHidden("injectConfigMapName")
+	opts.AttachControlPlaneFlags(cmd)
+	return cmd
+}
+
+// loadInjectionParameters loads the injection configuration of the revision from the cluster. The configuration
+// files set by flags are only used for the target revision.
+func loadInjectionParameters(revision string, target bool) (*injectionParameters, error) {
+	// Loading the configuration of a revision updates the global ConfigMap names, restore them for the next one.
+	savedMeshConfigFile, savedInjectConfigFile, savedValuesFile := meshConfigFile, injectConfigFile, valuesFile
+	savedMeshConfigMapName, savedInjectConfigMapName := meshConfigMapName, injectConfigMapName
+	defer func() {
+		meshConfigFile, injectConfigFile, valuesFile = savedMeshConfigFile, savedInjectConfigFile, savedValuesFile
+		meshConfigMapName, injectConfigMapName = savedMeshConfigMapName, savedInjectConfigMapName
+	}()
+	if !target {
+		meshConfigFile, injectConfigFile, valuesFile = "", "", ""
+	}
+
+	rev := revision
+	if rev == defaultRevisionName {
+		rev = ""
+	}
+	p := &injectionParameters{}
+	var err error
+	if p.injector, p.meshConfig, err = setupKubeInjectParameters(&p.sidecarTemplate, &p.valuesConfig, rev); err != nil {
+		return nil, err
+	}
+	if p.injector != nil && p.injector.clientConfig != nil {
+		return p, nil
+	}
+	// Without the injection webhook, the templates are rendered locally and need the mesh config and values.
+	if p.meshConfig == nil {
+		if p.meshConfig, err = getMeshConfigFromConfigMap(kubeconfig, "x inject diff", rev); err != nil {
+			return nil, err
+		}
+	}
+	if p.valuesConfig == "" {
+		if p.valuesConfig, err = getValuesFromConfigMap(kubeconfig, rev); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// deploymentRevision returns the revision that injected the pods of the Deployment, or an empty string if they
+// are not injected.
+func deploymentRevision(client kube.ExtendedClient, d *appsv1.Deployment) (string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return "", err
+	}
+	pod, err := GetFirstPod(client.CoreV1(), d.Namespace, selector.String())
+	if err != nil {
+		// Without pods, the Deployment is compared with its pod template.
+		return "", nil
+	}
+	return pod.Labels[label.IoIstioRev.Name], nil
+}
+
+// renderInjection returns the pod template of the Deployment injected by the revision.
+func renderInjection(d *appsv1.Deployment, revision string, p *injectionParameters) (*corev1.PodTemplateSpec, error) {
+	rev := revision
+	if rev == defaultRevisionName {
+		rev = ""
+	}
+	var injector inject.Injector
+	if p.injector != nil && p.injector.clientConfig != nil {
+		injector = *p.injector
+	}
+	out, err := inject.IntoObject(injector, p.sidecarTemplate, p.valuesConfig, rev, p.meshConfig, d, func(string) {})
+	if err != nil {
+		return nil, fmt.Errorf("failed to inject Deployment %s.%s with revision %q: %v", d.Name, d.Namespace, revision, err)
+	}
+	injected, ok := out.(*appsv1.Deployment)
+	if !ok {
+		return nil, fmt.Errorf("unexpected injection result %T for Deployment %s.%s", out, d.Name, d.Namespace)
+	}
+	return &injected.Spec.Template, nil
+}
+
+// printInjectionDiff writes the differences between the injection of the pod template by the current and the
+// target revisions.
+func printInjectionDiff(w io.Writer, d *appsv1.Deployment, currentRevision, targetRevision string,
+	current, target *corev1.PodTemplateSpec, contextLines int) error {
+	from, err := yaml.Marshal(podView(current))
+	if err != nil {
+		return err
+	}
+	to, err := yaml.Marshal(podView(target))
+	if err != nil {
+		return err
+	}
+	fromName := "not injected"
+	if currentRevision != "" {
+		fromName = "revision " + currentRevision
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		FromFile: fmt.Sprintf("Deployment %s.%s (%s)", d.Name, d.Namespace, fromName),
+		A:        difflib.SplitLines(string(from)),
+		ToFile:   fmt.Sprintf("Deployment %s.%s (revision %s)", d.Name, d.Namespace, targetRevision),
+		B:        difflib.SplitLines(string(to)),
+		Context:  contextLines,
+	})
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		_, err = fmt.Fprintf(w, "Deployment %s.%s: no changes\n\n", d.Name, d.Namespace)
+		return err
+	}
+	_, err = fmt.Fprintln(w, diff)
+	return err
+}
+
+func podView(t *corev1.PodTemplateSpec) injectedPodView {
+	return injectedPodView{
+		Annotations:    t.Annotations,
+		Labels:         t.Labels,
+		InitContainers: t.Spec.InitContainers,
+		Containers:     t.Spec.Containers,
+		Volumes:        t.Spec.Volumes,
+	}
+}
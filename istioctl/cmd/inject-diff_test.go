@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	appsv1 "k8s.io/api/apps/v1"
+
+	"istio.io/istio/pkg/config/mesh"
+)
+
+func TestInjectDiff(t *testing.T) {
+	in, err := ioutil.ReadFile("testdata/deployment/hello.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &appsv1.Deployment{}
+	if err := yaml.Unmarshal(in, d); err != nil {
+		t.Fatal(err)
+	}
+	d.Namespace = "default"
+
+	injectConfig, err := ioutil.ReadFile("testdata/inject-config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &injectionParameters{}
+	if p.sidecarTemplate, err = readInjectConfigFile(injectConfig); err != nil {
+		t.Fatal(err)
+	}
+	if p.meshConfig, err = mesh.ReadMeshConfig("testdata/mesh-config.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	values, err := ioutil.ReadFile("testdata/inject-values.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.valuesConfig = string(values)
+
+	target, err := renderInjection(d, "canary", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := printInjectionDiff(&out, d, "", "canary", &d.Spec.Template, target, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"--- Deployment hello.default (not injected)\n",
+		"+++ Deployment hello.default (revision canary)\n",
+		"+  name: istio-proxy\n",
+		"+  name: istio-init\n",
+		"+  sidecar.istio.io/status:",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("diff does not contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := printInjectionDiff(&out, d, "canary", "canary", target, target, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "Deployment hello.default: no changes\n\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
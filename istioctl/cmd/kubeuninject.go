@@ -119,6 +119,25 @@ func removeInjectedContainers(containers []corev1.Container, injectedContainerNa
 	return containers
 }
 
+// proberHandler returns the handler of the application probe the prober was converted from.
+func proberHandler(prober *inject.Prober) corev1.Handler {
+	switch {
+	case prober.TCPSocket != nil:
+		return corev1.Handler{TCPSocket: prober.TCPSocket}
+	case prober.Exec != nil:
+		return corev1.Handler{Exec: prober.Exec}
+	case prober.GRPC != nil:
+		// gRPC probes are converted from exec probes running grpc_health_probe. Probers written before the original
+		// command was recorded are restored with an equivalent one.
+		command := []string{"grpc_health_probe", fmt.Sprintf("-addr=:%d", prober.GRPC.Port)}
+		if prober.GRPC.Service != nil {
+			command = append(command, "-service="+*prober.GRPC.Service)
+		}
+		return corev1.Handler{Exec: &corev1.ExecAction{Command: command}}
+	}
+	return corev1.Handler{HTTPGet: prober.HTTPGet}
+}
+
 func restoreAppProbes(containers []corev1.Container, probers map[string]*inject.Prober) []corev1.Container {
 	re := regexp.MustCompile("/app-health/([a-z]+)/(readyz|livez|startupz)")
 	for name, prober := range probers {
@@ -134,23 +153,17 @@ func restoreAppProbes(containers []corev1.Container, probers map[string]*inject.
 				switch probeType {
 				case "readyz":
 					container.ReadinessProbe = &corev1.Probe{
-						Handler: corev1.Handler{
-							HTTPGet: prober.HTTPGet,
-						},
+						Handler:        proberHandler(prober),
 						TimeoutSeconds: prober.TimeoutSeconds,
 					}
 				case "livez":
 					container.LivenessProbe = &corev1.Probe{
-						Handler: corev1.Handler{
-							HTTPGet: prober.HTTPGet,
-						},
+						Handler:        proberHandler(prober),
 						TimeoutSeconds: prober.TimeoutSeconds,
 					}
 				case "startupz":
 					container.StartupProbe = &corev1.Probe{
-						Handler: corev1.Handler{
-							HTTPGet: prober.HTTPGet,
-						},
+						Handler:        proberHandler(prober),
 						TimeoutSeconds: prober.TimeoutSeconds,
 					}
 				}
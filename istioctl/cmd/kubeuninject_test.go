@@ -16,9 +16,16 @@ package cmd
 
 import (
 	"fmt"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"istio.io/istio/pkg/kube/apimirror"
+	"istio.io/istio/pkg/kube/inject"
 )
 
 func TestKubeUninject(t *testing.T) {
@@ -111,3 +118,36 @@ func TestKubeUninject(t *testing.T) {
 		})
 	}
 }
+
+func TestRestoreAppProbes(t *testing.T) {
+	service := "foo"
+	exec := &corev1.ExecAction{Command: []string{"grpc_health_probe", "-addr=localhost:5000", "-service=foo", "-rpc-timeout=2s"}}
+	tcp := &corev1.TCPSocketAction{Port: intstr.FromInt(9000), Host: "127.0.0.2"}
+	containers := restoreAppProbes([]corev1.Container{{Name: "hello"}}, map[string]*inject.Prober{
+		"/app-health/hello/readyz": {
+			GRPC:           &apimirror.GRPCAction{Port: 5000, Service: &service},
+			Exec:           exec,
+			TimeoutSeconds: 3,
+		},
+		// Probers written before the exec command was recorded.
+		"/app-health/hello/livez": {
+			GRPC: &apimirror.GRPCAction{Port: 5000, Service: &service},
+		},
+		"/app-health/hello/startupz": {
+			TCPSocket: tcp,
+		},
+	})
+	c := containers[0]
+	if want := (&corev1.Probe{Handler: corev1.Handler{Exec: exec}, TimeoutSeconds: 3}); !reflect.DeepEqual(c.ReadinessProbe, want) {
+		t.Errorf("unexpected readiness probe %+v, want %+v", c.ReadinessProbe, want)
+	}
+	wantLiveness := &corev1.Probe{Handler: corev1.Handler{Exec: &corev1.ExecAction{
+		Command: []string{"grpc_health_probe", "-addr=:5000", "-service=foo"},
+	}}}
+	if !reflect.DeepEqual(c.LivenessProbe, wantLiveness) {
+		t.Errorf("unexpected liveness probe %+v, want %+v", c.LivenessProbe, wantLiveness)
+	}
+	if want := (&corev1.Probe{Handler: corev1.Handler{TCPSocket: tcp}}); !reflect.DeepEqual(c.StartupProbe, want) {
+		t.Errorf("unexpected startup probe %+v, want %+v", c.StartupProbe, want)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/istioctl/pkg/writer/compare"
 	"istio.io/istio/istioctl/pkg/writer/envoy/clusters"
 	"istio.io/istio/istioctl/pkg/writer/envoy/configdump"
 	"istio.io/istio/pilot/pkg/model"
@@ -932,13 +933,82 @@ func secretConfigCmd() *cobra.Command {
 	return secretConfigCmd
 }
 
+func diffConfigCmd() *cobra.Command {
+	diffConfigCmd := &cobra.Command{
+		Use:   "diff <pod-name[.namespace]> [<pod-name[.namespace]>]",
+		Short: "Diffs the configuration of the Envoys in two pods, or of an Envoy and a saved config dump",
+		Long: `Diffs the clusters, listeners and routes of two Envoy config dumps, grouped by resource type and name.
+Resources only in one of the config dumps are listed, and the resources that differ are printed as a unified diff.
+The version_info and last_updated fields are ignored.`,
+		Example: `  # Diff the configuration of the Envoys in two pods.
+  istioctl proxy-config diff productpage-v1-bb8d5cbc7-k7qbm productpage-v2-8445d6fb6-p9xqz.default
+
+  # Diff the configuration the Envoy in a pod had earlier with its current configuration.
+  kubectl exec productpage-v1-bb8d5cbc7-k7qbm -c istio-proxy -- curl localhost:15000/config_dump > envoy-config.json
+  istioctl proxy-config diff productpage-v1-bb8d5cbc7-k7qbm --file envoy-config.json
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 1 && configDumpFile != "") || (len(args) == 2 && configDumpFile == "") {
+				return nil
+			}
+			cmd.Println(cmd.UsageString())
+			return fmt.Errorf("diff requires two pod names, or a pod name and --file parameter")
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			var aName, bName string
+			var aDump, bDump []byte
+			var err error
+			if configDumpFile != "" {
+				aName = configDumpFile
+				if aDump, err = readFile(configDumpFile); err != nil {
+					return err
+				}
+			} else {
+				if aName, aDump, err = podConfigDump(args[0]); err != nil {
+					return err
+				}
+			}
+			if bName, bDump, err = podConfigDump(args[len(args)-1]); err != nil {
+				return err
+			}
+			comparator, err := compare.NewProxyComparator(c.OutOrStdout(), aName, aDump, bName, bDump)
+			if err != nil {
+				return err
+			}
+			_, err = comparator.Diff()
+			return err
+		},
+	}
+
+	diffConfigCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
+		"Envoy config dump JSON file to diff the Envoy in the pod with")
+
+	return diffConfigCmd
+}
+
+// podConfigDump returns the name of the pod and the config dump of its Envoy.
+func podConfigDump(podflag string) (string, []byte, error) {
+	podName, podNamespace, err := getPodName(podflag)
+	if err != nil {
+		return "", nil, err
+	}
+	dump, err := extractConfigDump(podName, podNamespace)
+	if err != nil {
+		return "", nil, err
+	}
+	return podName + "." + podNamespace, dump, nil
+}
+
 func proxyConfig() *cobra.Command {
 	configCmd := &cobra.Command{
 		Use:   "proxy-config",
 		Short: "Retrieve information about proxy configuration from Envoy [kube only]",
 		Long:  `A group of commands used to retrieve information about proxy configuration from the Envoy config dump`,
 		Example: `  # Retrieve information about proxy configuration from an Envoy instance.
-  istioctl proxy-config <clusters|listeners|routes|endpoints|bootstrap|log|secret> <pod-name[.namespace]>`,
+  istioctl proxy-config <clusters|listeners|routes|endpoints|bootstrap|log|secret> <pod-name[.namespace]>
+
+  # Diff the configuration of the Envoys in two pods.
+  istioctl proxy-config diff <pod-name[.namespace]> <pod-name[.namespace]>`,
 		Aliases: []string{"pc"},
 	}
 
@@ -952,6 +1022,7 @@ func proxyConfig() *cobra.Command {
 	configCmd.AddCommand(bootstrapConfigCmd())
 	configCmd.AddCommand(endpointConfigCmd())
 	configCmd.AddCommand(secretConfigCmd())
+	configCmd.AddCommand(diffConfigCmd())
 
 	return configCmd
 }
@@ -159,6 +159,22 @@ func TestProxyConfig(t *testing.T) {
 			expectedString:   `config dump has no configuration type`,
 			wantException:    true,
 		},
+		{ // diff requires two pods
+			args:           strings.Split("proxy-config diff httpbin-794b576b6c-qx6pf", " "),
+			expectedString: "Error: diff requires two pod names, or a pod name and --file parameter",
+			wantException:  true,
+		},
+		{ // diff invalid
+			args:           strings.Split("proxy-config diff invalid httpbin-794b576b6c-qx6pf", " "),
+			expectedString: "unable to retrieve Pod: pods \"invalid\" not found",
+			wantException:  true,
+		},
+		{ // diff with valid pod names retrieves Envoy config (fails because we don't check in Envoy config unit tests)
+			execClientConfig: loggingConfig,
+			args:             strings.Split("pc diff httpbin-794b576b6c-qx6pf httpbin-794b576b6c-qx6pf", " "),
+			expectedString:   "config dump has no configuration type",
+			wantException:    true,
+		},
 	}
 
 	for i, c := range cases {
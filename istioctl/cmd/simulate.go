@@ -0,0 +1,323 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
+	"istio.io/istio/pilot/pkg/simulation"
+)
+
+type simulateArgs struct {
+	// Source of the configuration
+	configDumpFile string
+	configDir      string
+
+	// Synthetic proxy, used with configDir
+	proxyType   string
+	proxyIP     string
+	proxyLabels map[string]string
+
+	// Request description
+	host     string
+	address  string
+	port     int
+	path     string
+	headers  []string
+	protocol string
+	tlsMode  string
+	sni      string
+	callMode string
+}
+
+func simulateCmd() *cobra.Command {
+	sArgs := &simulateArgs{}
+	cmd := &cobra.Command{
+		Use:   "simulate [<pod-name>[.<namespace>]]",
+		Short: "Simulate how a proxy handles a request",
+		Long: `Simulates a request through the configuration of a proxy, without sending real traffic.
+
+The configuration is read from the config dump of a running pod, from a config dump file, or generated
+for a synthetic proxy from a local directory of Istio configuration. Services are read from the
+ServiceEntries in the directory.
+
+The listener, filter chain, route and cluster matched by the request are printed, along with the mTLS
+requirement of the matched filter chain and the TLS mode used towards the matched cluster.`,
+		Example: `  # Simulate a request from the productpage pod to the reviews service
+  istioctl x simulate productpage-v1-7f44c4d57c-xyz12.default --host reviews.default.svc.cluster.local --port 9080 --path /reviews
+
+  # Simulate a request received by an ingress gateway, using a config dump file
+  istioctl x simulate -f gateway.json --mode gateway --host example.com --port 8080
+
+  # Simulate a request from a synthetic sidecar in the default namespace, using local configuration
+  istioctl x simulate --config-dir ./config --labels app=client -n default --host example.com --port 80 --header x-user=test`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			sources := len(args)
+			if sArgs.configDumpFile != "" {
+				sources++
+			}
+			if sArgs.configDir != "" {
+				sources++
+			}
+			if sources != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("exactly one of a pod, --file or --config-dir is required")
+			}
+			if sArgs.port == 0 {
+				return fmt.Errorf("--port is required")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			call, err := sArgs.call()
+			if err != nil {
+				return err
+			}
+			var podName, podNamespace string
+			if len(args) == 1 {
+				podName, podNamespace = handlers.InferPodInfo(args[0], handlers.HandleNamespace(namespace, defaultNamespace))
+			}
+			return runSimulation(cmd.OutOrStdout(), sArgs, podName, podNamespace, call)
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&sArgs.configDumpFile, "file", "f", "",
+		"Envoy config dump JSON file")
+	cmd.PersistentFlags().StringVar(&sArgs.configDir, "config-dir", "",
+		"Directory of Istio configuration used to generate the configuration of a synthetic proxy")
+	cmd.PersistentFlags().StringVar(&sArgs.proxyType, "proxy-type", string(model.SidecarProxy),
+		"Type of the synthetic proxy, one of sidecar or router")
+	cmd.PersistentFlags().StringVar(&sArgs.proxyIP, "proxy-ip", "1.1.1.1",
+		"IP address of the synthetic proxy")
+	cmd.PersistentFlags().StringToStringVarP(&sArgs.proxyLabels, "labels", "l", nil,
+		"Labels of the synthetic proxy")
+
+	cmd.PersistentFlags().StringVar(&sArgs.host, "host", "",
+		"Host of the request, used as the Host header and, for TLS requests, the SNI")
+	cmd.PersistentFlags().StringVar(&sArgs.address, "address", "",
+		"Destination IP address of the request")
+	cmd.PersistentFlags().IntVar(&sArgs.port, "port", 0,
+		"Destination port of the request")
+	cmd.PersistentFlags().StringVar(&sArgs.path, "path", "/",
+		"Path of the request")
+	cmd.PersistentFlags().StringArrayVar(&sArgs.headers, "header", nil,
+		"Header of the request in the form key=value, may be repeated")
+	cmd.PersistentFlags().StringVar(&sArgs.protocol, "protocol", string(simulation.HTTP),
+		"Protocol of the request, one of http, http2 or tcp")
+	cmd.PersistentFlags().StringVar(&sArgs.tlsMode, "tls", string(simulation.Plaintext),
+		"TLS mode of the request, one of plaintext, tls or mtls")
+	cmd.PersistentFlags().StringVar(&sArgs.sni, "sni", "",
+		"SNI of the request, defaults to the host for TLS requests")
+	cmd.PersistentFlags().StringVar(&sArgs.callMode, "mode", string(simulation.CallModeOutbound),
+		"How the request reaches the proxy: outbound (redirected from the application), inbound (redirected "+
+			"from the network) or gateway (sent directly to the proxy)")
+	return cmd
+}
+
+// call converts the request flags to a simulation call.
+func (s *simulateArgs) call() (simulation.Call, error) {
+	c := simulation.Call{
+		Address:    s.address,
+		Port:       s.port,
+		Path:       s.path,
+		HostHeader: s.host,
+		Sni:        s.sni,
+		Headers:    http.Header{},
+	}
+	switch p := simulation.Protocol(s.protocol); p {
+	case simulation.HTTP, simulation.HTTP2, simulation.TCP:
+		c.Protocol = p
+	default:
+		return c, fmt.Errorf("unknown protocol %q, must be one of http, http2 or tcp", s.protocol)
+	}
+	switch t := simulation.TLSMode(s.tlsMode); t {
+	case simulation.Plaintext, simulation.TLS, simulation.MTLS:
+		c.TLS = t
+	default:
+		return c, fmt.Errorf("unknown TLS mode %q, must be one of plaintext, tls or mtls", s.tlsMode)
+	}
+	switch m := simulation.CallMode(s.callMode); m {
+	case simulation.CallModeOutbound, simulation.CallModeInbound, simulation.CallModeGateway:
+		c.CallMode = m
+	default:
+		return c, fmt.Errorf("unknown mode %q, must be one of outbound, inbound or gateway", s.callMode)
+	}
+	for _, h := range s.headers {
+		kv := strings.SplitN(h, "=", 2)
+		if len(kv) != 2 {
+			return c, fmt.Errorf("invalid header %q, must be in the form key=value", h)
+		}
+		c.Headers.Add(kv[0], kv[1])
+	}
+	return c, nil
+}
+
+func runSimulation(w io.Writer, s *simulateArgs, podName, podNamespace string, call simulation.Call) error {
+	sim, err := s.simulation(podName, podNamespace)
+	if err != nil {
+		return err
+	}
+	result, err := sim.Simulate(call)
+	if err != nil {
+		return fmt.Errorf("invalid proxy configuration: %v", err)
+	}
+	requiresMTLS, err := sim.RequiresMTLS(result)
+	if err != nil {
+		return fmt.Errorf("invalid proxy configuration: %v", err)
+	}
+	clusterTLS, err := sim.ClusterTLSMode(result)
+	if err != nil {
+		return fmt.Errorf("invalid proxy configuration: %v", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	printField := func(name, value string) {
+		if value != "" {
+			_, _ = fmt.Fprintf(tw, "%s:\t%s\n", name, value)
+		}
+	}
+	printField("Listener", result.ListenerMatched)
+	printField("Filter chain", result.FilterChainMatched)
+	printField("Route config", result.RouteConfigMatched)
+	printField("Virtual host", result.VirtualHostMatched)
+	printField("Route", result.RouteMatched)
+	printField("Cluster", result.ClusterMatched)
+	if result.ListenerMatched != "" {
+		printField("Requires mTLS", fmt.Sprint(requiresMTLS))
+	}
+	printField("Cluster TLS", clusterTLS)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if result.Error != nil {
+		return fmt.Errorf("request could not be routed: %v", result.Error)
+	}
+	return nil
+}
+
+// simulation builds a simulation from the configured source of configuration.
+func (s *simulateArgs) simulation(podName, podNamespace string) (*simulation.Simulation, error) {
+	if s.configDir != "" {
+		return s.simulationFromConfigDir()
+	}
+	var dump []byte
+	var err error
+	if s.configDumpFile != "" {
+		dump, err = readFile(s.configDumpFile)
+	} else {
+		dump, err = extractConfigDump(podName, podNamespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return simulationFromConfigDump(dump)
+}
+
+func (s *simulateArgs) simulationFromConfigDir() (*simulation.Simulation, error) {
+	files, err := ioutil.ReadDir(s.configDir)
+	if err != nil {
+		return nil, err
+	}
+	var configs []string
+	for _, f := range files {
+		if f.IsDir() || !(strings.HasSuffix(f.Name(), ".yaml") || strings.HasSuffix(f.Name(), ".yml")) {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(s.configDir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, string(b))
+	}
+	proxyType := model.NodeType(s.proxyType)
+	if !model.IsApplicationNodeType(proxyType) {
+		return nil, fmt.Errorf("unknown proxy type %q, must be one of sidecar or router", s.proxyType)
+	}
+	cg, err := v1alpha3.NewConfigGen(v1alpha3.TestOptions{ConfigString: strings.Join(configs, "\n---\n")})
+	if err != nil {
+		return nil, err
+	}
+	defer cg.Close()
+	proxyNamespace := handlers.HandleNamespace(namespace, defaultNamespace)
+	proxy := cg.SetupProxy(&model.Proxy{
+		Type:            proxyType,
+		IPAddresses:     []string{s.proxyIP},
+		ConfigNamespace: proxyNamespace,
+		Metadata:        &model.NodeMetadata{Namespace: proxyNamespace, Labels: s.proxyLabels},
+	})
+	return simulation.NewSimulationFromResources(cg.Listeners(proxy), cg.Clusters(proxy), cg.Routes(proxy)), nil
+}
+
+func simulationFromConfigDump(dump []byte) (*simulation.Simulation, error) {
+	cd := configdump.Wrapper{}
+	if err := cd.UnmarshalJSON(dump); err != nil {
+		return nil, fmt.Errorf("failed to parse config dump: %v", err)
+	}
+
+	listenerDump, err := cd.GetDynamicListenerDump(true)
+	if err != nil {
+		return nil, err
+	}
+	listeners := make([]*listener.Listener, 0, len(listenerDump.DynamicListeners))
+	for _, l := range listenerDump.DynamicListeners {
+		ll := &listener.Listener{}
+		if err := l.ActiveState.Listener.UnmarshalTo(ll); err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, ll)
+	}
+
+	clusterDump, err := cd.GetDynamicClusterDump(true)
+	if err != nil {
+		return nil, err
+	}
+	clusters := make([]*cluster.Cluster, 0, len(clusterDump.DynamicActiveClusters))
+	for _, c := range clusterDump.DynamicActiveClusters {
+		cc := &cluster.Cluster{}
+		if err := c.Cluster.UnmarshalTo(cc); err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, cc)
+	}
+
+	routeDump, err := cd.GetDynamicRouteDump(true)
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]*route.RouteConfiguration, 0, len(routeDump.DynamicRouteConfigs))
+	for _, r := range routeDump.DynamicRouteConfigs {
+		rc := &route.RouteConfiguration{}
+		if err := r.RouteConfig.UnmarshalTo(rc); err != nil {
+			return nil, err
+		}
+		routes = append(routes, rc)
+	}
+	return simulation.NewSimulationFromResources(listeners, clusters, routes), nil
+}
@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	cases := []execTestCase{
+		{
+			args:           strings.Split("x simulate --port 80", " "),
+			expectedString: "exactly one of a pod, --file or --config-dir is required",
+			wantException:  true,
+		},
+		{
+			args:           strings.Split("x simulate --config-dir testdata/simulate --host example.com", " "),
+			expectedString: "--port is required",
+			wantException:  true,
+		},
+		{
+			args:           strings.Split("x simulate --config-dir testdata/simulate --host example.com --port 80 --protocol udp", " "),
+			expectedString: "unknown protocol",
+			wantException:  true,
+		},
+		{
+			args: strings.Split("x simulate --config-dir testdata/simulate -n default --host example.com --port 80 --path /api/v1", " "),
+			expectedOutput: `Listener:      0.0.0.0_80
+Route config:  80
+Virtual host:  example.com:80
+Route:         api
+Cluster:       outbound|80|v2|example.com
+Requires mTLS: false
+Cluster TLS:   AUTO
+`,
+		},
+		{
+			args:           strings.Split("x simulate --config-dir testdata/simulate -n default --host example.com --port 80", " "),
+			expectedString: "Cluster:       outbound|80||example.com",
+		},
+		{
+			args:           strings.Split("x simulate --config-dir testdata/simulate -n default --host example.com --port 80 --path /api --header x-user=test", " "),
+			expectedString: "Route:         canary\nCluster:       outbound|80|v1|example.com",
+		},
+		{
+			args:           strings.Split("x simulate --config-dir testdata/simulate -n default --host example.com --port 80 --path /api --header x-user=other", " "),
+			expectedString: "Route:         api\nCluster:       outbound|80|v2|example.com",
+		},
+		{
+			args:           strings.Split("x simulate --config-dir testdata/simulate-invalid --host example.com --port 80", " "),
+			expectedString: "failed to read config",
+			wantException:  true,
+		},
+		{
+			args:           strings.Split("x simulate --config-dir testdata/simulate -n default --proxy-type router --mode gateway --host example.com --port 80", " "),
+			expectedString: "request could not be routed: no listener matched",
+			wantException:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " "), func(t *testing.T) {
+			verifyExecTestOutput(t, c)
+		})
+	}
+}
@@ -20,6 +20,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/url"
 )
 
@@ -152,3 +154,144 @@ func TestFormatter_PrintEmpty(t *testing.T) {
 	yamlOutput, _ := Print(msgs, YAMLFormat, false)
 	g.Expect(yamlOutput).To(Equal("[]\n"))
 }
+
+func fileMessages() diag.Messages {
+	fileMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Error, "B1", "Explosion accident: %v"),
+		&resource.Instance{
+			Metadata: resource.Metadata{FullName: resource.NewFullName("default", "bubble")},
+			Origin: &rt.Origin{
+				Kind:     "SoapBubble",
+				FullName: resource.NewFullName("default", "bubble"),
+				Ref:      &rt.Position{Filename: "bubbles.yaml", Line: 3},
+			},
+		},
+		"the bubble is too big",
+	)
+	fileMsg.Line = 12
+	infoMsg := diag.NewMessage(
+		diag.NewMessageType(diag.Info, "C2", "Collapse danger: %v"),
+		diag.MockResource("GrandCastle"),
+		"the castle is fine",
+	)
+	return diag.Messages{fileMsg, infoMsg}
+}
+
+func TestFormatter_PrintSARIF(t *testing.T) {
+	g := NewWithT(t)
+
+	output, err := Print(fileMessages(), SARIFFormat, false)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	expectedOutput := `{
+	"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+	"version": "2.1.0",
+	"runs": [
+		{
+			"tool": {
+				"driver": {
+					"name": "istioctl analyze",
+					"informationUri": "https://istio.io/latest/docs/reference/config/analysis/",
+					"rules": [
+						{
+							"id": "B1",
+							"helpUri": "` + url.ConfigAnalysis + `/b1/"
+						},
+						{
+							"id": "C2",
+							"helpUri": "` + url.ConfigAnalysis + `/c2/"
+						}
+					]
+				}
+			},
+			"results": [
+				{
+					"ruleId": "B1",
+					"ruleIndex": 0,
+					"level": "error",
+					"message": {
+						"text": "Explosion accident: the bubble is too big"
+					},
+					"locations": [
+						{
+							"physicalLocation": {
+								"artifactLocation": {
+									"uri": "bubbles.yaml"
+								},
+								"region": {
+									"startLine": 12
+								}
+							},
+							"logicalLocations": [
+								{
+									"fullyQualifiedName": "SoapBubble bubble.default",
+									"kind": "resource"
+								}
+							]
+						}
+					]
+				},
+				{
+					"ruleId": "C2",
+					"ruleIndex": 1,
+					"level": "note",
+					"message": {
+						"text": "Collapse danger: the castle is fine"
+					},
+					"locations": [
+						{
+							"logicalLocations": [
+								{
+									"fullyQualifiedName": "GrandCastle",
+									"kind": "resource"
+								}
+							]
+						}
+					]
+				}
+			]
+		}
+	]
+}`
+
+	g.Expect(output).To(Equal(expectedOutput))
+}
+
+func TestFormatter_PrintWithoutOrigin(t *testing.T) {
+	g := NewWithT(t)
+
+	msgs := diag.Messages{diag.NewMessage(
+		diag.NewMessageType(diag.Error, "B1", "Explosion accident: %v"),
+		&resource.Instance{Metadata: resource.Metadata{FullName: resource.NewFullName("default", "bubble")}},
+		"the bubble is too big",
+	)}
+
+	sarifOutput, err := Print(msgs, SARIFFormat, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sarifOutput).NotTo(ContainSubstring("locations"))
+
+	junitOutput, err := Print(msgs, JUnitFormat, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(junitOutput).To(ContainSubstring(`<testcase name="B1" classname="B1">`))
+}
+
+func TestFormatter_PrintJUnit(t *testing.T) {
+	g := NewWithT(t)
+
+	output, err := Print(fileMessages(), JUnitFormat, false)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	expectedOutput := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="2" failures="1">
+	<testsuite name="istioctl analyze" tests="2" failures="1">
+		<testcase name="B1 SoapBubble bubble.default" classname="B1" file="bubbles.yaml" line="12">
+			<failure message="Explosion accident: the bubble is too big" type="Error">Error [B1] (SoapBubble bubble.default bubbles.yaml:12) Explosion accident: the bubble is too big&#xA;` + url.ConfigAnalysis + `/b1/</failure>
+		</testcase>
+		<testcase name="C2 GrandCastle" classname="C2">
+			<system-out>Info [C2] (GrandCastle) Collapse danger: the castle is fine</system-out>
+		</testcase>
+	</testsuite>
+</testsuites>`
+
+	g.Expect(output).To(Equal(expectedOutput))
+}
@@ -0,0 +1,163 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatting
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+// The subset of the SARIF 2.1.0 format used to report analysis messages.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID      string `json:"id"`
+	HelpURI string `json:"helpUri"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+var sarifLevels = map[diag.Level]string{
+	diag.Info:    "note",
+	diag.Warning: "warning",
+	diag.Error:   "error",
+}
+
+func printSARIF(ms diag.Messages) (string, error) {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "istioctl analyze",
+			InformationURI: "https://istio.io/latest/docs/reference/config/analysis/",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	ruleIndexes := map[string]int{}
+	for _, m := range ms {
+		code := m.Type.Code()
+		index, ok := ruleIndexes[code]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndexes[code] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: code, HelpURI: documentationURL(m)})
+		}
+		result := sarifResult{
+			RuleID:    code,
+			RuleIndex: index,
+			Level:     sarifLevels[m.Type.Level()],
+			Message:   sarifMessage{Text: fmt.Sprintf(m.Type.Template(), m.Parameters...)},
+		}
+		if m.Resource != nil && m.Resource.Origin != nil {
+			loc := sarifLocation{
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: m.Resource.Origin.FriendlyName(), Kind: "resource"}},
+			}
+			if file, line := location(m); file != "" {
+				loc.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: file}}
+				if line > 0 {
+					loc.PhysicalLocation.Region = &sarifRegion{StartLine: line}
+				}
+			}
+			result.Locations = []sarifLocation{loc}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	out, err := json.MarshalIndent(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}, "", "\t")
+	return string(out), err
+}
+
+// location returns the file and line the message refers to, if the resource was read from a file. The line is the
+// one of the offending field when it is known, and the one of the resource otherwise.
+func location(m diag.Message) (string, int) {
+	if m.Resource == nil || m.Resource.Origin == nil {
+		return "", 0
+	}
+	pos, ok := m.Resource.Origin.Reference().(*rt.Position)
+	if !ok || pos == nil || pos.Filename == "" {
+		return "", 0
+	}
+	line := pos.Line
+	if m.Line != 0 {
+		line = m.Line
+	}
+	return pos.Filename, line
+}
+
+// documentationURL returns the URL of the documentation of the message.
+func documentationURL(m diag.Message) string {
+	url, _ := m.Unstructured(false)["documentationUrl"].(string)
+	return url
+}
@@ -0,0 +1,246 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// ProxyComparator diffs between the config dumps of two Envoys, such as two pods or a pod and a config dump
+// saved earlier. Resources are compared by type and name, ignoring their version_info and last_updated.
+type ProxyComparator struct {
+	a, b         *configdump.Wrapper
+	aName, bName string
+	w            io.Writer
+	context      int
+}
+
+// NewProxyComparator is a proxy comparator constructor
+func NewProxyComparator(w io.Writer, aName string, aDump []byte, bName string, bDump []byte) (*ProxyComparator, error) {
+	a := &configdump.Wrapper{}
+	if err := json.Unmarshal(aDump, a); err != nil {
+		return nil, fmt.Errorf("failed to parse the config dump of %s: %v", aName, err)
+	}
+	b := &configdump.Wrapper{}
+	if err := json.Unmarshal(bDump, b); err != nil {
+		return nil, fmt.Errorf("failed to parse the config dump of %s: %v", bName, err)
+	}
+	return &ProxyComparator{
+		a:       a,
+		b:       b,
+		aName:   aName,
+		bName:   bName,
+		w:       w,
+		context: 3,
+	}, nil
+}
+
+// proxyResourceType extracts the resources of a type from a config dump, indexed by name.
+type proxyResourceType struct {
+	name    string
+	extract func(w *configdump.Wrapper) (map[string]proto.Message, error)
+}
+
+var proxyResourceTypes = []proxyResourceType{
+	{name: "Clusters", extract: extractClusters},
+	{name: "Listeners", extract: extractListeners},
+	{name: "Routes", extract: extractRoutes},
+}
+
+// Diff prints the resources only in one of the proxies, and a diff of the resources in both that differ.
+// It returns whether the configurations differ.
+func (c *ProxyComparator) Diff() (bool, error) {
+	differ := false
+	for _, t := range proxyResourceTypes {
+		a, err := t.extract(c.a)
+		if err != nil {
+			return false, fmt.Errorf("failed to read the %s of %s: %v", strings.ToLower(t.name), c.aName, err)
+		}
+		b, err := t.extract(c.b)
+		if err != nil {
+			return false, fmt.Errorf("failed to read the %s of %s: %v", strings.ToLower(t.name), c.bName, err)
+		}
+		d, err := c.diffResources(t.name, a, b)
+		if err != nil {
+			return false, err
+		}
+		differ = differ || d
+	}
+	return differ, nil
+}
+
+func (c *ProxyComparator) diffResources(typeName string, a, b map[string]proto.Message) (bool, error) {
+	names := make([]string, 0, len(a)+len(b))
+	for n := range a {
+		names = append(names, n)
+	}
+	for n := range b {
+		if _, f := a[n]; !f {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	for _, n := range names {
+		ar, inA := a[n]
+		br, inB := b[n]
+		switch {
+		case !inB:
+			fmt.Fprintf(&out, "  - %s (only in %s)\n", n, c.aName)
+		case !inA:
+			fmt.Fprintf(&out, "  + %s (only in %s)\n", n, c.bName)
+		default:
+			aJSON, err := resourceJSON(ar)
+			if err != nil {
+				return false, err
+			}
+			bJSON, err := resourceJSON(br)
+			if err != nil {
+				return false, err
+			}
+			if aJSON == bJSON {
+				continue
+			}
+			text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				FromFile: c.aName,
+				A:        difflib.SplitLines(aJSON),
+				ToFile:   c.bName,
+				B:        difflib.SplitLines(bJSON),
+				Context:  c.context,
+			})
+			if err != nil {
+				return false, err
+			}
+			fmt.Fprintf(&out, "  ~ %s\n", n)
+			for _, line := range difflib.SplitLines(text) {
+				fmt.Fprintf(&out, "      %s", line)
+			}
+		}
+	}
+	if out.Len() == 0 {
+		fmt.Fprintf(c.w, "%s Match\n", typeName)
+		return false, nil
+	}
+	fmt.Fprintf(c.w, "%s Differ\n%s", typeName, out.String())
+	return true, nil
+}
+
+func resourceJSON(m proto.Message) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := (&jsonpb.Marshaler{Indent: "  "}).Marshal(buf, m); err != nil {
+		return "", err
+	}
+	buf.WriteString("\n")
+	return buf.String(), nil
+}
+
+func extractClusters(w *configdump.Wrapper) (map[string]proto.Message, error) {
+	dump, err := w.GetClusterConfigDump()
+	if err != nil {
+		return nil, err
+	}
+	anys := make([]*any.Any, 0, len(dump.StaticClusters)+len(dump.DynamicActiveClusters))
+	for _, c := range dump.StaticClusters {
+		anys = append(anys, c.Cluster)
+	}
+	for _, c := range dump.DynamicActiveClusters {
+		anys = append(anys, c.Cluster)
+	}
+	resources := map[string]proto.Message{}
+	for _, a := range anys {
+		// Support v2 or v3 in config dump. See ads.go:RequestedTypes for more info.
+		a.TypeUrl = v3.ClusterType
+		c := &cluster.Cluster{}
+		if err := a.UnmarshalTo(c); err != nil {
+			return nil, err
+		}
+		resources[c.Name] = c
+	}
+	return resources, nil
+}
+
+func extractListeners(w *configdump.Wrapper) (map[string]proto.Message, error) {
+	dump, err := w.GetListenerConfigDump()
+	if err != nil {
+		return nil, err
+	}
+	anys := make([]*any.Any, 0, len(dump.StaticListeners)+len(dump.DynamicListeners))
+	for _, l := range dump.StaticListeners {
+		anys = append(anys, l.Listener)
+	}
+	for _, l := range dump.DynamicListeners {
+		// Draining and warming states are transient, only compare the active listeners.
+		if l.ActiveState != nil {
+			anys = append(anys, l.ActiveState.Listener)
+		}
+	}
+	resources := map[string]proto.Message{}
+	for _, a := range anys {
+		// Support v2 or v3 in config dump. See ads.go:RequestedTypes for more info.
+		a.TypeUrl = v3.ListenerType
+		l := &listener.Listener{}
+		if err := a.UnmarshalTo(l); err != nil {
+			return nil, err
+		}
+		resources[l.Name] = l
+	}
+	return resources, nil
+}
+
+func extractRoutes(w *configdump.Wrapper) (map[string]proto.Message, error) {
+	dump, err := w.GetRouteConfigDump()
+	if err != nil {
+		return nil, err
+	}
+	anys := make([]*any.Any, 0, len(dump.StaticRouteConfigs)+len(dump.DynamicRouteConfigs))
+	for _, r := range dump.StaticRouteConfigs {
+		anys = append(anys, r.RouteConfig)
+	}
+	for _, r := range dump.DynamicRouteConfigs {
+		anys = append(anys, r.RouteConfig)
+	}
+	resources := map[string]proto.Message{}
+	for _, a := range anys {
+		// Support v2 or v3 in config dump. See ads.go:RequestedTypes for more info.
+		a.TypeUrl = v3.RouteType
+		r := &route.RouteConfiguration{}
+		if err := a.UnmarshalTo(r); err != nil {
+			return nil, err
+		}
+		// The virtual hosts are matched by domain, their order is not significant.
+		sort.Slice(r.VirtualHosts, func(i, j int) bool {
+			return r.VirtualHosts[i].Name < r.VirtualHosts[j].Name
+		})
+		resources[r.Name] = r
+	}
+	return resources, nil
+}
@@ -0,0 +1,166 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	adminapi "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func mustAny(t *testing.T, m proto.Message) *anypb.Any {
+	t.Helper()
+	a, err := anypb.New(proto.MessageV2(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// buildConfigDump returns a JSON config dump with the resources, stamped with the version.
+func buildConfigDump(t *testing.T, version string, clusters []*cluster.Cluster, routes []*route.RouteConfiguration) []byte {
+	t.Helper()
+	now := timestamppb.New(time.Now())
+	cds := &adminapi.ClustersConfigDump{}
+	for _, c := range clusters {
+		cds.DynamicActiveClusters = append(cds.DynamicActiveClusters, &adminapi.ClustersConfigDump_DynamicCluster{
+			VersionInfo: version,
+			Cluster:     mustAny(t, c),
+			LastUpdated: now,
+		})
+	}
+	rds := &adminapi.RoutesConfigDump{}
+	for _, r := range routes {
+		rds.DynamicRouteConfigs = append(rds.DynamicRouteConfigs, &adminapi.RoutesConfigDump_DynamicRouteConfig{
+			VersionInfo: version,
+			RouteConfig: mustAny(t, r),
+			LastUpdated: now,
+		})
+	}
+	lds := &adminapi.ListenersConfigDump{
+		DynamicListeners: []*adminapi.ListenersConfigDump_DynamicListener{{
+			Name: "virtualOutbound",
+			ActiveState: &adminapi.ListenersConfigDump_DynamicListenerState{
+				VersionInfo: version,
+				Listener:    mustAny(t, &listener.Listener{Name: "virtualOutbound"}),
+				LastUpdated: now,
+			},
+		}},
+	}
+	dump := &adminapi.ConfigDump{Configs: []*anypb.Any{mustAny(t, cds), mustAny(t, lds), mustAny(t, rds)}}
+	out := &bytes.Buffer{}
+	if err := (&jsonpb.Marshaler{}).Marshal(out, dump); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestProxyComparatorDiff(t *testing.T) {
+	reviews := &cluster.Cluster{Name: "outbound|9080||reviews.default.svc.cluster.local", ConnectTimeout: durationpb.New(time.Second)}
+	ratings := &cluster.Cluster{Name: "outbound|9080||ratings.default.svc.cluster.local", ConnectTimeout: durationpb.New(time.Second)}
+	details := &cluster.Cluster{Name: "outbound|9080||details.default.svc.cluster.local", ConnectTimeout: durationpb.New(time.Second)}
+	reviewsSlow := proto.Clone(reviews).(*cluster.Cluster)
+	reviewsSlow.ConnectTimeout = durationpb.New(5 * time.Second)
+	routes := &route.RouteConfiguration{
+		Name: "9080",
+		VirtualHosts: []*route.VirtualHost{
+			{Name: "reviews.default.svc.cluster.local:9080", Domains: []string{"reviews"}},
+			{Name: "details.default.svc.cluster.local:9080", Domains: []string{"details"}},
+		},
+	}
+	// The same virtual hosts in another order.
+	routesReordered := &route.RouteConfiguration{
+		Name:         "9080",
+		VirtualHosts: []*route.VirtualHost{routes.VirtualHosts[1], routes.VirtualHosts[0]},
+	}
+
+	cases := []struct {
+		name     string
+		a, b     []byte
+		differ   bool
+		expected []string
+	}{
+		{
+			name:     "same configuration with other versions",
+			a:        buildConfigDump(t, "1", []*cluster.Cluster{reviews, ratings}, []*route.RouteConfiguration{routes}),
+			b:        buildConfigDump(t, "2", []*cluster.Cluster{ratings, reviews}, []*route.RouteConfiguration{routesReordered}),
+			differ:   false,
+			expected: []string{"Clusters Match\nListeners Match\nRoutes Match\n"},
+		},
+		{
+			name:   "different clusters",
+			a:      buildConfigDump(t, "1", []*cluster.Cluster{reviews, ratings}, []*route.RouteConfiguration{routes}),
+			b:      buildConfigDump(t, "1", []*cluster.Cluster{reviewsSlow, details}, []*route.RouteConfiguration{routes}),
+			differ: true,
+			expected: []string{
+				"Clusters Differ\n",
+				"  + outbound|9080||details.default.svc.cluster.local (only in pod-b)\n",
+				"  - outbound|9080||ratings.default.svc.cluster.local (only in pod-a)\n",
+				"  ~ outbound|9080||reviews.default.svc.cluster.local\n",
+				"      --- pod-a\n      +++ pod-b\n",
+				`      -  "connectTimeout": "1s"`,
+				`      +  "connectTimeout": "5s"`,
+				"Listeners Match\nRoutes Match\n",
+			},
+		},
+		{
+			name:   "missing routes",
+			a:      buildConfigDump(t, "1", []*cluster.Cluster{reviews}, []*route.RouteConfiguration{routes}),
+			b:      buildConfigDump(t, "1", []*cluster.Cluster{reviews}, nil),
+			differ: true,
+			expected: []string{
+				"Routes Differ\n  - 9080 (only in pod-a)\n",
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			c, err := NewProxyComparator(out, "pod-a", tt.a, "pod-b", tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			differ, err := c.Diff()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if differ != tt.differ {
+				t.Errorf("expected differ %v, got %v", tt.differ, differ)
+			}
+			for _, e := range tt.expected {
+				if !strings.Contains(out.String(), e) {
+					t.Errorf("expected output to contain %q, got:\n%s", e, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestProxyComparatorInvalidDump(t *testing.T) {
+	if _, err := NewProxyComparator(&bytes.Buffer{}, "pod-a", []byte("{}"), "pod-b", []byte("not json")); err == nil {
+		t.Fatal("expected an error for an invalid config dump")
+	}
+}
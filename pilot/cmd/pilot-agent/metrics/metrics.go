@@ -22,7 +22,9 @@ import (
 )
 
 var (
-	typeTag = monitoring.MustCreateLabel("type")
+	typeTag   = monitoring.MustCreateLabel("type")
+	probeTag  = monitoring.MustCreateLabel("probe")
+	resultTag = monitoring.MustCreateLabel("result")
 
 	// StartupTime measures the time it takes for the agent to get ready Note: This
 	// is dependant on readiness probes. This means our granularity is correlated to
@@ -47,6 +49,21 @@ var (
 		"scrapes_total",
 		"The total number of scrapes.",
 	)
+
+	// appProbes records the number of application probes performed by the agent on behalf of the kubelet.
+	appProbes = monitoring.NewSum(
+		"app_probes_total",
+		"The total number of application probes, by probe, probe type and result.",
+		monitoring.WithLabels(probeTag, typeTag, resultTag),
+	)
+
+	// appProbeDuration records the duration of the application probes.
+	appProbeDuration = monitoring.NewDistribution(
+		"app_probe_duration_seconds",
+		"The duration of application probes, by probe and probe type.",
+		[]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		monitoring.WithLabels(probeTag, typeTag),
+	)
 )
 
 var (
@@ -55,6 +72,12 @@ var (
 	ScrapeTypeAgent = "agent"
 )
 
+var (
+	ProbeTypeHTTPGet   = "httpGet"
+	ProbeTypeTCPSocket = "tcpSocket"
+	ProbeTypeGRPC      = "grpc"
+)
+
 var processStartTime = time.Now()
 
 func RecordStartupTime() {
@@ -63,10 +86,23 @@ func RecordStartupTime() {
 	log.Infof("Initialization took %v", delta)
 }
 
+// RecordAppProbe records the result and duration of an application probe. The probe is the path of the probe on
+// the status server, e.g. /app-health/httpbin/livez.
+func RecordAppProbe(probe, probeType string, success bool, duration time.Duration) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	appProbes.With(probeTag.Value(probe), typeTag.Value(probeType), resultTag.Value(result)).Increment()
+	appProbeDuration.With(probeTag.Value(probe), typeTag.Value(probeType)).Record(duration.Seconds())
+}
+
 func init() {
 	monitoring.MustRegister(
 		ScrapeTotals,
 		scrapeErrors,
 		startupTime,
+		appProbes,
+		appProbeDuration,
 	)
 }
@@ -44,6 +44,9 @@ func NewAgentOptions(proxy *model.Proxy, cfg *meshconfig.ProxyConfig) *istioagen
 		EnvoyPrometheusPort:      envoyPrometheusPortEnv,
 		Platform:                 platform.Discover(),
 	}
+	if wasmInsecureRegistries != "" {
+		o.WASMInsecureRegistries = strings.Split(wasmInsecureRegistries, ",")
+	}
 	extractXDSHeadersFromEnv(o)
 	if proxyXDSViaAgent {
 		o.ProxyXDSViaAgent = true
@@ -51,6 +54,8 @@ func NewAgentOptions(proxy *model.Proxy, cfg *meshconfig.ProxyConfig) *istioagen
 		o.DNSCapture = DNSCaptureByAgent.Get()
 		o.ProxyNamespace = PodNamespaceVar.Get()
 		o.ProxyDomain = proxy.DNSDomain
+		o.DNSRecordTTL = dnsRecordTTLEnv
+		o.DNSUpstreamCacheSize = dnsUpstreamCacheSizeEnv
 	}
 
 	return o
@@ -73,7 +73,11 @@ var (
 	eccSigAlgEnv        = env.RegisterStringVar("ECC_SIGNATURE_ALGORITHM", "", "The type of ECC signature algorithm to use when generating private keys").Get()
 	fileMountedCertsEnv = env.RegisterBoolVar("FILE_MOUNTED_CERTS", false, "").Get()
 	credFetcherTypeEnv  = env.RegisterStringVar("CREDENTIAL_FETCHER_TYPE", "",
-		"The type of the credential fetcher. Currently supported types include GoogleComputeEngine").Get()
+		"The type of the credential fetcher. Currently supported types include GoogleComputeEngine, "+
+			"AmazonWebServices, Azure and OIDCTokenFile").Get()
+	credAudienceEnv = env.RegisterStringVar("CREDENTIAL_AUDIENCE", "",
+		"The audience of the platform credential requested by the credential fetcher, "+
+			"e.g. the application ID URI of the Azure managed identity token. Defaults to the trust domain.").Get()
 	credIdentityProvider = env.RegisterStringVar("CREDENTIAL_IDENTITY_PROVIDER", "GoogleComputeEngine",
 		"The identity provider for credential. Currently default supported identity provider is GoogleComputeEngine").Get()
 	proxyXDSViaAgent = env.RegisterBoolVar("PROXY_XDS_VIA_AGENT", true,
@@ -84,6 +88,11 @@ var (
 	// DNSCaptureByAgent is a copy of the env var in the init code.
 	DNSCaptureByAgent = env.RegisterBoolVar("ISTIO_META_DNS_CAPTURE", false,
 		"If set to true, enable the capture of outgoing DNS packets on port 53, redirecting to istio-agent on :15053")
+	dnsRecordTTLEnv = env.RegisterDurationVar("DNS_PROXY_RECORD_TTL", 30*time.Second,
+		"The TTL of the DNS records of hosts known to the mesh, served by the agent DNS proxy").Get()
+	dnsUpstreamCacheSizeEnv = env.RegisterIntVar("DNS_PROXY_UPSTREAM_CACHE_SIZE", 1024,
+		"The maximum number of responses of upstream DNS servers cached by the agent DNS proxy, "+
+			"according to their TTLs. Set to 0 to disable the cache.").Get()
 
 	// Ability of istio-agent to retrieve proxyConfig via XDS for dynamic configuration updates
 	enableProxyConfigXdsEnv = env.RegisterBoolVar("PROXY_CONFIG_XDS_AGENT", false,
@@ -97,4 +106,7 @@ var (
 		"Envoy health status port value").Get()
 	envoyPrometheusPortEnv = env.RegisterIntVar("ENVOY_PROMETHEUS_PORT", 15090,
 		"Envoy prometheus redirection port value").Get()
+
+	wasmInsecureRegistries = env.RegisterStringVar("WASM_INSECURE_REGISTRIES", "",
+		"Comma separated list of OCI registries from which Wasm modules are fetched over plain HTTP").Get()
 )
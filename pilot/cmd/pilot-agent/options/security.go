@@ -45,6 +45,7 @@ func NewSecurityOptions(proxyConfig *meshconfig.ProxyConfig, stsPort int, tokenM
 		ServiceAccount:                 serviceAccountVar.Get(),
 		XdsAuthProvider:                xdsAuthProvider.Get(),
 		TrustDomain:                    trustDomainEnv,
+		CredAudience:                   credAudienceEnv,
 		Pkcs8Keys:                      pkcs8KeysEnv,
 		ECCSigAlg:                      eccSigAlgEnv,
 		SecretTTL:                      secretTTLEnv,
@@ -90,15 +91,18 @@ func SetupSecurityOptions(proxyConfig *meshconfig.ProxyConfig, secOpt *security.
 		o.CAEndpoint = proxyConfig.DiscoveryAddress
 	}
 
-	// TODO (liminw): CredFetcher is a general interface. In 1.7, we limit the use on GCE only because
-	// GCE is the only supported plugin at the moment.
-	if credFetcherTypeEnv == security.GCE {
+	if credFetcherTypeEnv != "" {
 		o.CredIdentityProvider = credIdentityProvider
-		credFetcher, err := credentialfetcher.NewCredFetcher(credFetcherTypeEnv, o.TrustDomain, jwtPath, o.CredIdentityProvider)
+		audience := o.CredAudience
+		if audience == "" {
+			audience = o.TrustDomain
+		}
+		credFetcher, err := credentialfetcher.NewCredFetcher(credFetcherTypeEnv, audience, jwtPath, o.CredIdentityProvider)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create credential fetcher: %v", err)
 		}
-		log.Infof("using credential fetcher of %s type in %s trust domain", credFetcherTypeEnv, o.TrustDomain)
+		log.Infof("using credential fetcher of %s type in %s trust domain with audience %s",
+			credFetcherTypeEnv, o.TrustDomain, audience)
 		o.CredFetcher = credFetcher
 	}
 	// Default the CA provider where possible
@@ -0,0 +1,158 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// GRPCHealthProbe is a gRPC health check declared by a grpc_health_probe command
+// (https://github.com/grpc-ecosystem/grpc-health-probe), which is how gRPC health checks are declared in probes
+// that do not support them natively.
+type GRPCHealthProbe struct {
+	// Host to connect to, empty for the local host.
+	Host string
+	Port int
+	// Service to check, nil to check the overall health of the server.
+	Service *string
+
+	// TLS settings of the connection.
+	TLS           bool
+	TLSNoVerify   bool
+	TLSCACert     string
+	TLSClientCert string
+	TLSClientKey  string
+	TLSServerName string
+}
+
+// grpcHealthProbeFlags are the supported grpc_health_probe flags, and whether they take a value.
+var grpcHealthProbeFlags = map[string]bool{
+	"addr":            true,
+	"service":         true,
+	"connect-timeout": true,
+	"rpc-timeout":     true,
+	"tls":             false,
+	"tls-no-verify":   false,
+	"tls-ca-cert":     true,
+	"tls-client-cert": true,
+	"tls-client-key":  true,
+	"tls-server-name": true,
+}
+
+// ParseGRPCHealthProbeCommand returns the gRPC health check performed by the command, if it runs grpc_health_probe.
+// It returns false for any other command, or if the command uses options that are not supported, such as ALTS.
+// The timeouts of the command are ignored in favor of the one of the probe.
+func ParseGRPCHealthProbeCommand(command []string) (*GRPCHealthProbe, bool) {
+	if len(command) == 0 || filepath.Base(command[0]) != "grpc_health_probe" {
+		return nil, false
+	}
+	p := &GRPCHealthProbe{}
+	var addr string
+	args := command[1:]
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		value, hasValue := "", false
+		if idx := strings.Index(name, "="); idx >= 0 {
+			name, value, hasValue = name[:idx], name[idx+1:], true
+		}
+		takesValue, ok := grpcHealthProbeFlags[name]
+		if !ok {
+			return nil, false
+		}
+		if takesValue && !hasValue {
+			if i+1 >= len(args) {
+				return nil, false
+			}
+			i++
+			value = args[i]
+		}
+		if !takesValue && hasValue {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, false
+			}
+			if !b {
+				continue
+			}
+		}
+		switch name {
+		case "addr":
+			addr = value
+		case "service":
+			s := value
+			p.Service = &s
+		case "tls":
+			p.TLS = true
+		case "tls-no-verify":
+			p.TLSNoVerify = true
+		case "tls-ca-cert":
+			p.TLSCACert = value
+		case "tls-client-cert":
+			p.TLSClientCert = value
+		case "tls-client-key":
+			p.TLSClientKey = value
+		case "tls-server-name":
+			p.TLSServerName = value
+		}
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, false
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, false
+	}
+	p.Host, p.Port = host, port
+	return p, true
+}
+
+// CheckGRPCHealth calls the grpc.health.v1.Health/Check method of the server at the address, and returns an error
+// if the server or the service is not serving. The connection uses TLS if tlsConfig is not nil.
+func CheckGRPCHealth(ctx context.Context, dialer *net.Dialer, addr string, service string, tlsConfig *tls.Config) error {
+	creds := grpc.WithInsecure()
+	if tlsConfig != nil {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+	conn, err := grpc.DialContext(ctx, addr,
+		creds,
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", addr)
+		}))
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("health status is %v", resp.GetStatus())
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGRPCHealthProbeCommand(t *testing.T) {
+	service := "echo"
+	for _, tc := range []struct {
+		name     string
+		command  []string
+		expected *GRPCHealthProbe
+	}{
+		{"not-grpc-health-probe", []string{"cat", "/tmp/healthy"}, nil},
+		{"port-only", []string{"/bin/grpc_health_probe", "-addr=:5000"}, &GRPCHealthProbe{Port: 5000}},
+		{"host-and-service", []string{"grpc_health_probe", "--addr", "10.0.0.1:5000", "-service=echo", "-rpc-timeout", "1s"},
+			&GRPCHealthProbe{Host: "10.0.0.1", Port: 5000, Service: &service}},
+		{"tls", []string{"grpc_health_probe", "-addr=:5000", "-tls", "-tls-no-verify", "-tls-ca-cert=/etc/ca.pem",
+			"-tls-client-cert", "/etc/cert.pem", "-tls-client-key=/etc/key.pem", "-tls-server-name=app"},
+			&GRPCHealthProbe{Port: 5000, TLS: true, TLSNoVerify: true, TLSCACert: "/etc/ca.pem",
+				TLSClientCert: "/etc/cert.pem", TLSClientKey: "/etc/key.pem", TLSServerName: "app"}},
+		{"tls-disabled", []string{"grpc_health_probe", "-addr=:5000", "-tls=false"}, &GRPCHealthProbe{Port: 5000}},
+		{"unsupported-option", []string{"grpc_health_probe", "-addr=:5000", "-alts"}, nil},
+		{"invalid-port", []string{"grpc_health_probe", "-addr=:http"}, nil},
+		{"missing-addr", []string{"grpc_health_probe", "-service=echo"}, nil},
+		{"missing-value", []string{"grpc_health_probe", "-addr"}, nil},
+	} {
+		got, ok := ParseGRPCHealthProbeCommand(tc.command)
+		if ok != (tc.expected != nil) || !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("[%v] failed, want %+v, got %+v", tc.name, tc.expected, got)
+		}
+	}
+}
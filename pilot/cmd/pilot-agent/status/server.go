@@ -54,7 +54,7 @@ const (
 	// quitPath is to notify the pilot agent to quit.
 	quitPath = "/quitquitquit"
 	// KubeAppProberEnvName is the name of the command line flag for pilot agent to pass app prober config.
-	// The json encoded string to pass app HTTP probe information from injector(istioctl or webhook).
+	// The json encoded string to pass app HTTP, TCP and gRPC probe information from injector(istioctl or webhook).
 	// For example, ISTIO_KUBE_APP_PROBERS='{"/app-health/httpbin/livez":{"httpGet":{"path": "/hello", "port": 8080}}.
 	// indicates that httpbin container liveness prober port is 8080 and probing path is /hello.
 	// This environment variable should never be set manually.
@@ -88,8 +88,10 @@ type KubeAppProbers map[string]*Prober
 
 // Prober represents a single container prober
 type Prober struct {
-	HTTPGet        *apimirror.HTTPGetAction `json:"httpGet"`
-	TimeoutSeconds int32                    `json:"timeoutSeconds,omitempty"`
+	HTTPGet        *apimirror.HTTPGetAction   `json:"httpGet,omitempty"`
+	TCPSocket      *apimirror.TCPSocketAction `json:"tcpSocket,omitempty"`
+	GRPC           *apimirror.GRPCAction      `json:"grpc,omitempty"`
+	TimeoutSeconds int32                      `json:"timeoutSeconds,omitempty"`
 }
 
 // Options for the status server.
@@ -115,6 +117,7 @@ type Server struct {
 	appProbersDestination string
 	appKubeProbers        KubeAppProbers
 	appProbeClient        map[string]*http.Client
+	upstreamLocalAddress  *net.TCPAddr
 	statusPort            uint16
 	lastProbeSuccessful   bool
 	envoyStatsPort        int
@@ -152,6 +155,10 @@ func NewServer(config Options) (*Server, error) {
 		ready:                 probes,
 		appProbersDestination: config.PodIP,
 		envoyStatsPort:        config.EnvoyPrometheusPort,
+		upstreamLocalAddress:  UpstreamLocalAddressIPv4,
+	}
+	if config.IPv6 {
+		s.upstreamLocalAddress = UpstreamLocalAddressIPv6
 	}
 	if LegacyLocalhostProbeDestination.Get() {
 		s.appProbersDestination = "localhost"
@@ -195,18 +202,14 @@ func NewServer(config Options) (*Server, error) {
 		if !appProberPattern.Match([]byte(path)) {
 			return nil, fmt.Errorf(`invalid key, must be in form of regex pattern ^/app-health/[^\/]+/(livez|readyz)$`)
 		}
-		if prober.HTTPGet == nil {
-			return nil, fmt.Errorf(`invalid prober type, must be of type httpGet`)
-		}
-		if prober.HTTPGet.Port.Type != intstr.Int {
-			return nil, fmt.Errorf("invalid prober config for %v, the port must be int type", path)
+		if err := validateAppProber(prober); err != nil {
+			return nil, fmt.Errorf("invalid prober config for %v: %v", path, err)
 		}
-		localAddr := UpstreamLocalAddressIPv4
-		if config.IPv6 {
-			localAddr = UpstreamLocalAddressIPv6
+		if prober.HTTPGet == nil {
+			continue
 		}
 		d := &net.Dialer{
-			LocalAddr: localAddr,
+			LocalAddr: s.upstreamLocalAddress,
 		}
 		// Construct a http client and cache it in order to reuse the connection.
 		s.appProbeClient[path] = &http.Client{
@@ -223,6 +226,30 @@ func NewServer(config Options) (*Server, error) {
 	return s, nil
 }
 
+// validateAppProber checks that the prober has exactly one supported action, targeting a numeric port.
+func validateAppProber(prober *Prober) error {
+	actions := 0
+	if prober.HTTPGet != nil {
+		actions++
+		if prober.HTTPGet.Port.Type != intstr.Int {
+			return fmt.Errorf("the port must be int type")
+		}
+	}
+	if prober.TCPSocket != nil {
+		actions++
+		if prober.TCPSocket.Port.Type != intstr.Int {
+			return fmt.Errorf("the port must be int type")
+		}
+	}
+	if prober.GRPC != nil {
+		actions++
+	}
+	if actions != 1 {
+		return fmt.Errorf("invalid prober type, must be one of httpGet, tcpSocket or grpc")
+	}
+	return nil
+}
+
 // FormatProberURL returns a set of HTTP URLs that pilot agent will serve to take over Kubernetes
 // app probers.
 func FormatProberURL(container string) (string, string, string) {
@@ -520,6 +547,27 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 		_, _ = w.Write([]byte(fmt.Sprintf("app prober config does not exists for %v", path)))
 		return
 	}
+
+	start := time.Now()
+	var probeType string
+	var code int
+	switch {
+	case prober.TCPSocket != nil:
+		probeType = metrics.ProbeTypeTCPSocket
+		code = s.handleAppProbeTCPSocket(path, prober)
+	case prober.GRPC != nil:
+		probeType = metrics.ProbeTypeGRPC
+		code = s.handleAppProbeGRPC(req.Context(), path, prober)
+	default:
+		probeType = metrics.ProbeTypeHTTPGet
+		code = s.handleAppProbeHTTPGet(req, path, prober)
+	}
+	metrics.RecordAppProbe(path, probeType, code >= http.StatusOK && code < http.StatusBadRequest, time.Since(start))
+	w.WriteHeader(code)
+}
+
+// handleAppProbeHTTPGet sends the HTTP request of the prober to the application, and returns the status code.
+func (s *Server) handleAppProbeHTTPGet(req *http.Request, path string, prober *Prober) int {
 	// get the http client must exist because
 	httpClient := s.appProbeClient[path]
 
@@ -536,8 +584,7 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 	appReq, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		log.Errorf("Failed to create request to probe app %v, original url %v", err, path)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError
 	}
 
 	// Forward incoming headers to the application.
@@ -567,8 +614,7 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 	response, err := httpClient.Do(appReq)
 	if err != nil {
 		log.Errorf("Request to probe app failed: %v, original URL path = %v\napp URL path = %v", err, path, proberPath)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError
 	}
 	defer func() {
 		// Drain and close the body to let the Transport reuse the connection
@@ -576,8 +622,55 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 		_ = response.Body.Close()
 	}()
 
-	// We only write the status code to the response.
-	w.WriteHeader(response.StatusCode)
+	// Only the status code is written to the response.
+	return response.StatusCode
+}
+
+// handleAppProbeTCPSocket opens a connection to the port of the prober, and returns the status code of the probe.
+// Like kubelet, it connects to the host of the prober if set, and to the pod otherwise.
+func (s *Server) handleAppProbeTCPSocket(path string, prober *Prober) int {
+	d := &net.Dialer{
+		LocalAddr: s.upstreamLocalAddress,
+		Timeout:   probeTimeout(prober),
+	}
+	host := s.appProbersDestination
+	if prober.TCPSocket.Host != "" {
+		host = prober.TCPSocket.Host
+	}
+	conn, err := d.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(prober.TCPSocket.Port.IntValue())))
+	if err != nil {
+		log.Errorf("TCP probe of app failed: %v, original URL path = %v", err, path)
+		return http.StatusInternalServerError
+	}
+	_ = conn.Close()
+	return http.StatusOK
+}
+
+// handleAppProbeGRPC calls the gRPC health checking service of the application, and returns the status code of
+// the probe.
+func (s *Server) handleAppProbeGRPC(ctx context.Context, path string, prober *Prober) int {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout(prober))
+	defer cancel()
+
+	var service string
+	if prober.GRPC.Service != nil {
+		service = *prober.GRPC.Service
+	}
+	d := &net.Dialer{LocalAddr: s.upstreamLocalAddress}
+	addr := net.JoinHostPort(s.appProbersDestination, strconv.Itoa(int(prober.GRPC.Port)))
+	if err := CheckGRPCHealth(ctx, d, addr, service, nil); err != nil {
+		log.Errorf("gRPC probe of app failed: %v, original URL path = %v", err, path)
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+// probeTimeout returns the timeout of the prober, which defaults to one second like in Kubernetes.
+func probeTimeout(prober *Prober) time.Duration {
+	if prober.TimeoutSeconds <= 0 {
+		return time.Second
+	}
+	return time.Duration(prober.TimeoutSeconds) * time.Second
 }
 
 // notifyExit sends SIGTERM to itself
@@ -32,6 +32,9 @@ import (
 	"time"
 
 	"github.com/prometheus/common/expfmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"istio.io/istio/pilot/cmd/pilot-agent/status/ready"
@@ -86,9 +89,19 @@ func TestNewServer(t *testing.T) {
 		},
 		// invalid probe type
 		{
-			probe: `{"/app-health/hello-world/readyz": {"tcpSocket": {"port": "8888"}}}`,
+			probe: `{"/app-health/hello-world/readyz": {"exec": {"command": ["true"]}}}`,
 			err:   "invalid prober type",
 		},
+		// more than one probe type
+		{
+			probe: `{"/app-health/hello-world/readyz": {"httpGet": {"port": 8888}, "tcpSocket": {"port": 8888}}}`,
+			err:   "invalid prober type",
+		},
+		// TCP port is not Int typed.
+		{
+			probe: `{"/app-health/hello-world/readyz": {"tcpSocket": {"port": "8888"}}}`,
+			err:   "must be int type",
+		},
 		// Port is not Int typed.
 		{
 			probe: `{"/app-health/hello-world/readyz": {"httpGet": {"path": "/hello/sunnyvale", "port": "container-port-dontknow"}}}`,
@@ -109,6 +122,11 @@ func TestNewServer(t *testing.T) {
 			probe: `{"/app-health/hello-world/readyz": {"httpGet": {"path": "/hello/sunnyvale", "port": 8080}},
 "/app-health/business/livez": {"httpGet": {"port": 9090}}}`,
 		},
+		// A valid input with TCP and gRPC probers.
+		{
+			probe: `{"/app-health/hello-world/readyz": {"tcpSocket": {"port": 8888}},` +
+				`"/app-health/business/livez": {"grpc": {"port": 9090, "service": "business"}}}`,
+		},
 		// A valid input without any prober info.
 		{
 			probe: `{}`,
@@ -484,6 +502,147 @@ func TestAppProbe(t *testing.T) {
 	}
 }
 
+// startAppProbeServer starts a status server with the app probers, and returns its port.
+func startAppProbeServer(t *testing.T, probers KubeAppProbers) uint16 {
+	appProber, err := json.Marshal(probers)
+	if err != nil {
+		t.Fatalf("invalid app probers")
+	}
+	server, err := NewServer(Options{KubeAppProbers: string(appProber)})
+	if err != nil {
+		t.Fatalf("failed to create status server %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go server.Run(ctx)
+
+	var statusPort uint16
+	for statusPort == 0 {
+		server.mutex.RLock()
+		statusPort = server.statusPort
+		server.mutex.RUnlock()
+	}
+	return statusPort
+}
+
+func probeStatusCode(t *testing.T, statusPort uint16, path string) int {
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%v%s", statusPort, path))
+	if err != nil {
+		t.Fatal("request failed: ", err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestTCPAppProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to allocate unused port %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	appPort := listener.Addr().(*net.TCPAddr).Port
+
+	// Find a port nothing listens on.
+	closed, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to allocate unused port %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	statusPort := startAppProbeServer(t, KubeAppProbers{
+		"/app-health/hello-world/readyz": &Prober{
+			TCPSocket: &apimirror.TCPSocketAction{Port: intstr.FromInt(appPort)},
+		},
+		"/app-health/hello-world/livez": &Prober{
+			TCPSocket: &apimirror.TCPSocketAction{Port: intstr.FromInt(closedPort)},
+		},
+	})
+	if got := probeStatusCode(t, statusPort, "/app-health/hello-world/readyz"); got != http.StatusOK {
+		t.Errorf("unexpected status code for open port, want = %v, got = %v", http.StatusOK, got)
+	}
+	if got := probeStatusCode(t, statusPort, "/app-health/hello-world/livez"); got != http.StatusInternalServerError {
+		t.Errorf("unexpected status code for closed port, want = %v, got = %v", http.StatusInternalServerError, got)
+	}
+}
+
+func TestTCPAppProbeHost(t *testing.T) {
+	// Listen on a loopback address other than the default destination of the probes.
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("failed to listen on 127.0.0.2: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	appPort := listener.Addr().(*net.TCPAddr).Port
+
+	statusPort := startAppProbeServer(t, KubeAppProbers{
+		"/app-health/hello-world/readyz": &Prober{
+			TCPSocket: &apimirror.TCPSocketAction{Port: intstr.FromInt(appPort), Host: "127.0.0.2"},
+		},
+		"/app-health/hello-world/livez": &Prober{
+			TCPSocket: &apimirror.TCPSocketAction{Port: intstr.FromInt(appPort)},
+		},
+	})
+	if got := probeStatusCode(t, statusPort, "/app-health/hello-world/readyz"); got != http.StatusOK {
+		t.Errorf("unexpected status code for the prober host, want = %v, got = %v", http.StatusOK, got)
+	}
+	if got := probeStatusCode(t, statusPort, "/app-health/hello-world/livez"); got != http.StatusInternalServerError {
+		t.Errorf("unexpected status code for the pod, want = %v, got = %v", http.StatusInternalServerError, got)
+	}
+}
+
+func TestGRPCAppProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to allocate unused port %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("serving", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("not-serving", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+	appPort := int32(listener.Addr().(*net.TCPAddr).Port)
+
+	serving, notServing, unknown := "serving", "not-serving", "unknown"
+	statusPort := startAppProbeServer(t, KubeAppProbers{
+		"/app-health/overall/livez":      &Prober{GRPC: &apimirror.GRPCAction{Port: appPort}},
+		"/app-health/serving/readyz":     &Prober{GRPC: &apimirror.GRPCAction{Port: appPort, Service: &serving}},
+		"/app-health/not-serving/readyz": &Prober{GRPC: &apimirror.GRPCAction{Port: appPort, Service: &notServing}},
+		"/app-health/unknown/readyz":     &Prober{GRPC: &apimirror.GRPCAction{Port: appPort, Service: &unknown}},
+	})
+
+	for path, want := range map[string]int{
+		"/app-health/overall/livez":      http.StatusOK,
+		"/app-health/serving/readyz":     http.StatusOK,
+		"/app-health/not-serving/readyz": http.StatusInternalServerError,
+		"/app-health/unknown/readyz":     http.StatusInternalServerError,
+	} {
+		if got := probeStatusCode(t, statusPort, path); got != want {
+			t.Errorf("[%v] unexpected status code, want = %v, got = %v", path, want, got)
+		}
+	}
+}
+
 func TestHttpsAppProbe(t *testing.T) {
 	// Starts the application first.
 	listener, err := net.Listen("tcp", ":0")
@@ -107,8 +107,10 @@ func init() {
 	// Process commandline args.
 	discoveryCmd.PersistentFlags().StringSliceVar(&serverArgs.RegistryOptions.Registries, "registries",
 		[]string{string(serviceregistry.Kubernetes)},
-		fmt.Sprintf("Comma separated list of platform service registries to read from (choose one or more from {%s, %s})",
-			serviceregistry.Kubernetes, serviceregistry.Mock))
+		fmt.Sprintf("Comma separated list of platform service registries to read from (choose one or more from {%s, %s, %s})",
+			serviceregistry.Kubernetes, serviceregistry.Mock, serviceregistry.File))
+	discoveryCmd.PersistentFlags().StringToStringVar(&serverArgs.RegistryOptions.RegistryArgs, "registryArgs", nil,
+		"Settings of pluggable service registries, as <registry>.<key>=<value> pairs, for example File.path=/etc/istio/registry")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.RegistryOptions.ClusterRegistriesNamespace, "clusterRegistriesNamespace",
 		serverArgs.RegistryOptions.ClusterRegistriesNamespace, "Namespace for ConfigMap which stores clusters configs")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.RegistryOptions.KubeConfig, "kubeconfig", "",
@@ -17,6 +17,7 @@ package bootstrap
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
@@ -160,6 +161,25 @@ func (s *Server) initK8SConfigStore(args *PilotArgs) error {
 	s.ConfigStores = append(s.ConfigStores, configController)
 	if features.EnableServiceApis {
 		s.ConfigStores = append(s.ConfigStores, gateway.NewController(s.kubeClient, configController, args.RegistryOptions.KubeOptions))
+		if features.EnableGatewayAPIDeploymentController {
+			s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+				leaderelection.
+					NewLeaderElection(args.Namespace, args.PodName, leaderelection.GatewayDeploymentController, s.kubeClient.Kube()).
+					AddRunFunction(func(leaderStop <-chan struct{}) {
+						dc := gateway.NewDeploymentController(s.kubeClient, args.Revision)
+						// Start informers again. This fixes the case where informers do not start,
+						// as we create them only after acquiring the leader lock
+						// Note: stop here should be the overall pilot stop, NOT the leader election stop. We are
+						// basically lazy loading the informer, if we stop it when we lose the lock we will never
+						// recreate it again.
+						s.kubeClient.RunAndWait(stop)
+						log.Infof("Starting gateway deployment controller")
+						dc.Run(leaderStop)
+					}).
+					Run(stop)
+				return nil
+			})
+		}
 	}
 	if features.EnableAnalysis {
 		if err := s.initInprocessAnalysisController(args); err != nil {
@@ -242,6 +262,9 @@ func (s *Server) initInprocessAnalysisController(args *PilotArgs) error {
 	processingArgs := settings.DefaultArgs()
 	processingArgs.KubeConfig = args.RegistryOptions.KubeConfig
 	processingArgs.EnableConfigAnalysis = true
+	if features.AnalysisCustomAnalyzers != "" {
+		processingArgs.CustomAnalyzerFiles = strings.Split(features.AnalysisCustomAnalyzers, ",")
+	}
 	meshSource := mesh.NewInmemoryMeshCfg()
 	meshSource.Set(s.environment.Mesh())
 	s.environment.Watcher.AddMeshHandler(func() {
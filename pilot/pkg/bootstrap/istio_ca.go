@@ -47,7 +47,7 @@ import (
 )
 
 type caOptions struct {
-	// Either extCAK8s or extCAGrpc
+	// Either extCAK8s, extCAGrpc or extCAEst
 	ExternalCAType   ra.CaExternalType
 	ExternalCASigner string
 	// domain to use in SPIFFE identity URLs
@@ -131,8 +131,26 @@ var (
 
 	// TODO: Likely to be removed and added to mesh config
 	externalCaType = env.RegisterStringVar("EXTERNAL_CA", "",
-		"External CA Integration Type. Permitted Values are ISTIOD_RA_KUBERNETES_API or "+
-			"ISTIOD_RA_ISTIO_API").Get()
+		"External CA Integration Type. Permitted Values are ISTIOD_RA_KUBERNETES_API, "+
+			"ISTIOD_RA_ISTIO_API or ISTIOD_RA_EST_API").Get()
+
+	externalCaAddress = env.RegisterStringVar("EXTERNAL_CA_ADDRESS", "",
+		"URL of the external CA. With ISTIOD_RA_EST_API, the EST base URL, e.g. https://ca.example.com/.well-known/est").Get()
+
+	externalCaClientCert = env.RegisterStringVar("EXTERNAL_CA_CLIENT_CERT", "",
+		"Path to the client certificate istiod authenticates to the external CA with").Get()
+
+	externalCaClientKey = env.RegisterStringVar("EXTERNAL_CA_CLIENT_KEY", "",
+		"Path to the private key of the client certificate istiod authenticates to the external CA with").Get()
+
+	externalCaUsername = env.RegisterStringVar("EXTERNAL_CA_USERNAME", "",
+		"Username for HTTP basic authentication to the external CA").Get()
+
+	externalCaPassword = env.RegisterStringVar("EXTERNAL_CA_PASSWORD", "",
+		"Password for HTTP basic authentication to the external CA").Get()
+
+	externalCaChainRefreshInterval = env.RegisterDurationVar("EXTERNAL_CA_CHAIN_REFRESH_INTERVAL", ra.DefaultExtCAChainRefreshInterval,
+		"How long the CA certificates fetched from the external CA are cached for").Get()
 
 	// TODO: Likely to be removed and added to mesh config
 	k8sSigner = env.RegisterStringVar("K8S_SIGNER", "",
@@ -353,6 +371,13 @@ func (s *Server) createIstioRA(client kubelib.Client,
 		VerifyAppendCA: true,
 		K8sClient:      client.CertificatesV1beta1(),
 		TrustDomain:    opts.TrustDomain,
+
+		ExternalCAAddress:              externalCaAddress,
+		ExternalCAClientCertFile:       externalCaClientCert,
+		ExternalCAClientKeyFile:        externalCaClientKey,
+		ExternalCAUsername:             externalCaUsername,
+		ExternalCAPassword:             externalCaPassword,
+		ExternalCAChainRefreshInterval: externalCaChainRefreshInterval,
 	}
 	return ra.NewIstioRA(raOpts)
 }
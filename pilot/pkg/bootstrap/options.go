@@ -35,6 +35,8 @@ type RegistryOptions struct {
 	FileDir string
 
 	Registries []string
+	// RegistryArgs are the settings of pluggable registries, keyed by <provider>.<key>.
+	RegistryArgs map[string]string
 
 	// Kubernetes controller options
 	KubeOptions kubecontroller.Options
@@ -64,6 +66,9 @@ type PilotArgs struct {
 	KeepaliveOptions   *keepalive.Options
 	ShutdownDuration   time.Duration
 	JwtRule            string
+	// PlatformAuthConfig is the path of the JSON file configuring the authentication of workloads
+	// with the credentials issued by their platform, e.g. AWS instance identity documents.
+	PlatformAuthConfig string
 }
 
 // DiscoveryServerOptions contains options for create a new discovery server instance.
@@ -116,6 +121,9 @@ var (
 	podNameVar      = env.RegisterStringVar("POD_NAME", "", "")
 	jwtRuleVar      = env.RegisterStringVar("JWT_RULE", "",
 		"The JWT rule used by istiod authentication")
+	platformAuthConfigVar = env.RegisterStringVar("PLATFORM_AUTH_CONFIG", "",
+		"The path of the JSON file configuring the authentication of workloads with platform credentials, "+
+			"such as AWS instance identity documents and Azure managed identity tokens")
 )
 
 // RevisionVar is the value of the Istio control plane revision, e.g. "canary",
@@ -150,6 +158,7 @@ func (p *PilotArgs) applyDefaults() {
 	p.PodName = podNameVar.Get()
 	p.Revision = RevisionVar.Get()
 	p.JwtRule = jwtRuleVar.Get()
+	p.PlatformAuthConfig = platformAuthConfigVar.Get()
 	p.KeepaliveOptions = keepalive.DefaultOption()
 	p.RegistryOptions.DistributionTrackingEnabled = features.EnableDistributionTracking
 	p.RegistryOptions.DistributionCacheRetention = features.DistributionHistoryRetention
@@ -36,6 +36,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
+	"k8s.io/client-go/kubernetes"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -319,6 +320,13 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 		}
 		authenticators = append(authenticators, jwtAuthn)
 	}
+	if args.PlatformAuthConfig != "" {
+		platformAuthn, err := s.initPlatformAuthenticators(args)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing platform authentication: %v", err)
+		}
+		authenticators = append(authenticators, platformAuthn...)
+	}
 	// The k8s JWT authenticator requires the multicluster registry to be initialized,
 	// so we build it later.
 	authenticators = append(authenticators,
@@ -368,6 +376,24 @@ func initOIDC(args *PilotArgs, trustDomain string) (security.Authenticator, erro
 	return jwtAuthn, nil
 }
 
+func (s *Server) initPlatformAuthenticators(args *PilotArgs) ([]security.Authenticator, error) {
+	// PlatformAuthConfig is from the PLATFORM_AUTH_CONFIG environment variable.
+	config, err := ioutil.ReadFile(args.PlatformAuthConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read platform authentication config: %v", err)
+	}
+	var client kubernetes.Interface
+	if s.kubeClient != nil {
+		client = s.kubeClient.Kube()
+	}
+	authenticators, err := authenticate.NewPlatformAuthenticators(config, s.environment.Mesh().TrustDomain, client, args.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Istiod authenticating platform credentials using %s", args.PlatformAuthConfig)
+	return authenticators, nil
+}
+
 func getClusterID(args *PilotArgs) string {
 	clusterID := args.RegistryOptions.KubeOptions.ClusterID
 	if clusterID == "" {
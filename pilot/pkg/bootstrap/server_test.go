@@ -598,3 +598,8 @@ func findFreePort() (int, error) {
 	}
 	return tcpAddr.Port, nil
 }
+
+func TestSupportedRegistries(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(supportedRegistries()).To(Equal([]string{"Kubernetes", "Mock", "File"}))
+}
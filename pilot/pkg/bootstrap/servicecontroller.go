@@ -16,10 +16,13 @@ package bootstrap
 
 import (
 	"fmt"
+	"strings"
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
+	// Register the in-tree pluggable registries.
+	_ "istio.io/istio/pilot/pkg/serviceregistry/file"
 	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
 	"istio.io/istio/pilot/pkg/serviceregistry/mock"
 	"istio.io/istio/pilot/pkg/serviceregistry/serviceentry"
@@ -59,7 +62,13 @@ func (s *Server) initServiceControllers(args *PilotArgs) error {
 		case serviceregistry.Mock:
 			s.initMockRegistry()
 		default:
-			return fmt.Errorf("service registry %s is not supported", r)
+			factory, ok := serviceregistry.GetProviderFactory(serviceRegistry)
+			if !ok {
+				return fmt.Errorf("service registry %s is not supported, must be one of %v", r, supportedRegistries())
+			}
+			if err := s.initProviderRegistry(serviceRegistry, factory, args); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -72,6 +81,15 @@ func (s *Server) initServiceControllers(args *PilotArgs) error {
 	return nil
 }
 
+// supportedRegistries returns the names of the built-in and registered service registries.
+func supportedRegistries() []string {
+	supported := []string{string(serviceregistry.Kubernetes), string(serviceregistry.Mock)}
+	for _, id := range serviceregistry.RegisteredProviders() {
+		supported = append(supported, string(id))
+	}
+	return supported
+}
+
 // initKubeRegistry creates all the k8s service controllers under this pilot
 func (s *Server) initKubeRegistry(args *PilotArgs) (err error) {
 	args.RegistryOptions.KubeOptions.ClusterID = s.clusterID
@@ -123,6 +141,33 @@ func (s *Server) initKubeRegistry(args *PilotArgs) (err error) {
 	return
 }
 
+// initProviderRegistry creates a service registry registered with serviceregistry.RegisterProvider.
+func (s *Server) initProviderRegistry(id serviceregistry.ProviderID, factory serviceregistry.ProviderFactory, args *PilotArgs) error {
+	registry, err := factory(serviceregistry.ProviderOptions{
+		ClusterID:   s.clusterID,
+		XDSUpdater:  s.XDSServer,
+		MeshWatcher: s.environment.Watcher,
+		Args:        providerArgs(id, args.RegistryOptions.RegistryArgs),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s registry: %v", id, err)
+	}
+	s.ServiceController().AddRegistry(registry)
+	return nil
+}
+
+// providerArgs returns the arguments of a provider, given as <provider>.<key>=<value>, keyed by <key>.
+func providerArgs(id serviceregistry.ProviderID, registryArgs map[string]string) map[string]string {
+	out := map[string]string{}
+	prefix := string(id) + "."
+	for k, v := range registryArgs {
+		if strings.HasPrefix(k, prefix) {
+			out[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return out
+}
+
 func (s *Server) initMockRegistry() {
 	// MemServiceDiscovery implementation
 	discovery := mock.NewDiscovery(map[host.Name]*model.Service{}, 2)
@@ -17,12 +17,15 @@ package gateway
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/model/kstatus"
 	controller2 "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
@@ -30,6 +33,7 @@ import (
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/kube"
 	"istio.io/pkg/log"
 )
 
@@ -39,13 +43,71 @@ var (
 )
 
 type controller struct {
-	client kubernetes.Interface
+	client kube.Client
 	cache  model.ConfigStoreCache
 	domain string
+
+	// serviceInformer watches the Services provisioned for Gateways, whose addresses are reported in the Gateway status.
+	serviceInformer cache.SharedIndexInformer
+	gatewayHandlers []func(config.Config, config.Config, model.Event)
+}
+
+func NewController(client kube.Client, c model.ConfigStoreCache, options controller2.Options) model.ConfigStoreCache {
+	ctl := &controller{
+		client: client,
+		cache:  c,
+		domain: options.DomainSuffix,
+	}
+	if features.EnableGatewayAPIDeploymentController {
+		ctl.serviceInformer = client.KubeInformer().Core().V1().Services().Informer()
+		ctl.serviceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: ctl.onServiceEvent,
+			UpdateFunc: func(old, cur interface{}) {
+				// Only address changes affect the Gateway status; skip resyncs and unrelated updates
+				oldSvc, ok := old.(*corev1.Service)
+				if !ok {
+					return
+				}
+				curSvc, ok := cur.(*corev1.Service)
+				if !ok {
+					return
+				}
+				if !reflect.DeepEqual(serviceAddresses(oldSvc), serviceAddresses(curSvc)) {
+					ctl.onServiceEvent(cur)
+				}
+			},
+			DeleteFunc: ctl.onServiceEvent,
+		})
+	}
+	return ctl
 }
 
-func NewController(client kubernetes.Interface, c model.ConfigStoreCache, options controller2.Options) model.ConfigStoreCache {
-	return &controller{client, c, options.DomainSuffix}
+// onServiceEvent triggers an update of the Gateway owning a provisioned Service. The Gateway status is computed
+// when the gateway config is listed, so without this a Service assigned an address after the Gateway was last
+// changed would leave the Gateway reporting no address.
+func (c *controller) onServiceEvent(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		log.Errorf("failed to read object metadata: %v", err)
+		return
+	}
+	if o.GetLabels()[managedLabel] != managedLabelValue {
+		return
+	}
+	name, f := o.GetLabels()[gatewayNameLabel]
+	if !f {
+		return
+	}
+	gw := c.cache.Get(gvk.ServiceApisGateway, name, o.GetNamespace())
+	if gw == nil {
+		return
+	}
+	for _, h := range c.gatewayHandlers {
+		h(*gw, *gw, model.EventUpdate)
+	}
 }
 
 func (c *controller) Schemas() collection.Schemas {
@@ -127,6 +189,20 @@ func (c controller) List(typ config.GroupVersionKind, namespace string) ([]confi
 		namespaces[ns.Name] = &nsl.Items[i]
 	}
 	input.Namespaces = namespaces
+
+	if features.EnableGatewayAPIDeploymentController {
+		svcs, err := c.client.CoreV1().Services(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: managedLabel + "=" + managedLabelValue,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list type Services: %v", err)
+		}
+		services := map[types.NamespacedName]*corev1.Service{}
+		for i, svc := range svcs.Items {
+			services[types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}] = &svcs.Items[i]
+		}
+		input.GatewayServices = services
+	}
 	output := convertResources(input)
 
 	// Handle all status updates
@@ -200,8 +276,12 @@ func (c controller) Delete(typ config.GroupVersionKind, name, namespace string,
 	return errUnsupportedOp
 }
 
-func (c controller) RegisterEventHandler(typ config.GroupVersionKind, handler func(config.Config, config.Config, model.Event)) {
-	// do nothing as c.cache has been registered
+func (c *controller) RegisterEventHandler(typ config.GroupVersionKind, handler func(config.Config, config.Config, model.Event)) {
+	// Changes to the gateway-api resources are handled by c.cache. Gateway handlers are also notified when a
+	// provisioned Service changes, as that changes the Gateway status.
+	if typ == gvk.Gateway {
+		c.gatewayHandlers = append(c.gatewayHandlers, handler)
+	}
 }
 
 func (c controller) Run(stop <-chan struct{}) {
@@ -212,5 +292,8 @@ func (c controller) SetWatchErrorHandler(handler func(r *cache.Reflector, err er
 }
 
 func (c controller) HasSynced() bool {
+	if c.serviceInformer != nil && !c.serviceInformer.HasSynced() {
+		return false
+	}
 	return c.cache.HasSynced()
 }
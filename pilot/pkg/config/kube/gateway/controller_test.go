@@ -15,19 +15,27 @@
 package gateway
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
-	"k8s.io/client-go/kubernetes/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	svc "sigs.k8s.io/gateway-api/apis/v1alpha1"
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/kstatus"
 	controller2 "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/kube"
 )
 
 var (
@@ -85,7 +93,7 @@ var (
 
 func TestListInvalidGroupVersionKind(t *testing.T) {
 	g := NewWithT(t)
-	clientSet := fake.NewSimpleClientset()
+	clientSet := kube.NewFakeClient()
 	store := memory.NewController(memory.Make(collections.All))
 	controller := NewController(clientSet, store, controller2.Options{})
 
@@ -98,7 +106,7 @@ func TestListInvalidGroupVersionKind(t *testing.T) {
 func TestListGatewayResourceType(t *testing.T) {
 	g := NewWithT(t)
 
-	clientSet := fake.NewSimpleClientset()
+	clientSet := kube.NewFakeClient()
 	store := memory.NewController(memory.Make(collections.All))
 	controller := NewController(clientSet, store, controller2.Options{})
 
@@ -147,7 +155,7 @@ func TestListGatewayResourceType(t *testing.T) {
 func TestListVirtualServiceResourceType(t *testing.T) {
 	g := NewWithT(t)
 
-	clientSet := fake.NewSimpleClientset()
+	clientSet := kube.NewFakeClient()
 	store := memory.NewController(memory.Make(collections.All))
 	controller := NewController(clientSet, store, controller2.Options{})
 
@@ -190,3 +198,104 @@ func TestListVirtualServiceResourceType(t *testing.T) {
 		g.Expect(c.Spec).To(Equal(expectedvs))
 	}
 }
+
+func TestGatewayAddressAssignedAfterCreation(t *testing.T) {
+	features.EnableGatewayAPIDeploymentController = true
+	defer func() {
+		features.EnableGatewayAPIDeploymentController = false
+	}()
+	g := NewWithT(t)
+
+	client := kube.NewFakeClient()
+	store := statusStore{memory.NewController(memory.Make(collections.All))}
+	controller := NewController(client, store, controller2.Options{})
+	updates := make(chan config.Config, 10)
+	controller.RegisterEventHandler(gvk.Gateway, func(_, cfg config.Config, _ model.Event) {
+		updates <- cfg
+	})
+
+	if _, err := store.Create(config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.GatewayClass,
+			Name:             "provisioned",
+			Namespace:        "ns1",
+			Annotations:      map[string]string{provisionAnnotation: "true"},
+		},
+		Spec:   gatewayClassSpec,
+		Status: &svc.GatewayClassStatus{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.ServiceApisGateway,
+			Name:             "gw",
+			Namespace:        "ns1",
+		},
+		Spec: &svc.GatewaySpec{
+			GatewayClassName: "provisioned",
+			Listeners:        []svc.Listener{{Port: 80, Protocol: svc.HTTPProtocolType}},
+		},
+		Status: &svc.GatewayStatus{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	service, err := client.CoreV1().Services("ns1").Create(context.TODO(), &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gw-istio",
+			Namespace: "ns1",
+			Labels:    map[string]string{managedLabel: managedLabelValue, gatewayNameLabel: "gw"},
+		},
+		Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, ClusterIP: "10.0.0.1"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	client.RunAndWait(stop)
+	g.Eventually(updates).Should(Receive())
+
+	gatewayStatus := func() *svc.GatewayStatus {
+		if _, err := controller.List(gvk.Gateway, "ns1"); err != nil {
+			t.Fatal(err)
+		}
+		return store.Get(gvk.ServiceApisGateway, "gw", "ns1").Status.(*svc.GatewayStatus)
+	}
+	status := gatewayStatus()
+	g.Expect(status.Addresses).To(BeEmpty())
+	g.Expect(kstatus.GetCondition(status.Conditions, string(svc.GatewayConditionReady)).Reason).
+		To(Equal(string(svc.GatewayReasonAddressNotAssigned)))
+
+	// The load balancer is assigned an address once the Gateway has been processed, which must trigger
+	// a Gateway update so the status is recomputed
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}}
+	if _, err := client.CoreV1().Services("ns1").UpdateStatus(context.TODO(), service, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var update config.Config
+	g.Eventually(updates, time.Second*5).Should(Receive(&update))
+	g.Expect(update.GroupVersionKind).To(Equal(gvk.ServiceApisGateway))
+	g.Expect(update.Name).To(Equal("gw"))
+	g.Expect(update.Namespace).To(Equal("ns1"))
+
+	status = gatewayStatus()
+	g.Expect(status.Addresses).To(HaveLen(1))
+	g.Expect(status.Addresses[0].Value).To(Equal("1.2.3.4"))
+	g.Expect(kstatus.GetCondition(status.Conditions, string(svc.GatewayConditionReady)).Status).To(Equal(metav1.ConditionTrue))
+}
+
+// statusStore only updates the status on UpdateStatus, like the Kubernetes status subresource, so that
+// configs can be listed again after their status has been written.
+type statusStore struct {
+	model.ConfigStoreCache
+}
+
+func (s statusStore) UpdateStatus(cfg config.Config) (string, error) {
+	cur := s.Get(cfg.GroupVersionKind, cfg.Name, cfg.Namespace)
+	if cur == nil {
+		return "", fmt.Errorf("%v %s/%s not found", cfg.GroupVersionKind, cfg.Namespace, cfg.Name)
+	}
+	cur.Status = cfg.Status
+	return s.Update(*cur)
+}
@@ -22,9 +22,11 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	k8s "sigs.k8s.io/gateway-api/apis/v1alpha1"
 
 	istio "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model/kstatus"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
@@ -45,6 +47,9 @@ type KubernetesResources struct {
 	TLSRoute      []config.Config
 	BackendPolicy []config.Config
 	Namespaces    map[string]*corev1.Namespace
+	// GatewayServices holds the Services provisioned for Gateways, keyed by namespace and name.
+	// It is only populated when automated deployment is enabled.
+	GatewayServices map[types.NamespacedName]*corev1.Service
 
 	// Domain for the cluster. Typically cluster.local
 	Domain string
@@ -320,19 +325,15 @@ func buildHTTPVirtualServices(obj config.Config, gateways []string, domain strin
 	result := []config.Config{}
 
 	route := obj.Spec.(*k8s.HTTPRouteSpec)
-	obj.Status.(*kstatus.WrappedStatus).Mutate(func(s config.Status) config.Status {
-		rs := s.(*k8s.HTTPRouteStatus)
-		// TODO report skipped routes
-		rs.Gateways = createRouteStatus(gateways, obj, rs.Gateways)
-		return rs
-	})
 
 	name := fmt.Sprintf("%s-%s", obj.Name, constants.KubernetesGatewayName)
 
 	httproutes := []*istio.HTTPRoute{}
 	hosts := hostnameToStringList(route.Hostnames)
-	for _, r := range route.Rules {
-		// TODO: implement redirect, rewrite, timeout, mirror, corspolicy, retries
+	// Filters that cannot be translated are skipped, and reported in the route status.
+	unsupported := []string{}
+	for i, r := range route.Rules {
+		// TODO: implement redirect, rewrite, timeout, corspolicy, retries
 		vs := &istio.HTTPRoute{}
 		for _, match := range r.Matches {
 			vs.Match = append(vs.Match, &istio.HTTPMatchRequest{
@@ -345,14 +346,37 @@ func buildHTTPVirtualServices(obj config.Config, gateways []string, domain strin
 			switch filter.Type {
 			case k8s.HTTPRouteFilterRequestHeaderModifier:
 				vs.Headers = createHeadersFilter(filter.RequestHeaderModifier)
+			case k8s.HTTPRouteFilterRequestMirror:
+				if vs.Mirror != nil {
+					unsupported = append(unsupported, fmt.Sprintf("rule %d: only a single %s filter is supported", i, filter.Type))
+					continue
+				}
+				mirror, err := createMirrorFilter(filter.RequestMirror, obj.Namespace, domain)
+				if err != nil {
+					unsupported = append(unsupported, fmt.Sprintf("rule %d: %v", i, err))
+					continue
+				}
+				vs.Mirror = mirror
 			default:
 				log.Warnf("unsupported filter type %q", filter.Type)
+				unsupported = append(unsupported, fmt.Sprintf("rule %d: unsupported filter type %q", i, filter.Type))
 			}
 		}
 
-		vs.Route = buildHTTPDestination(r.ForwardTo, obj.Namespace, domain)
+		var unsupportedForward []string
+		vs.Route, unsupportedForward = buildHTTPDestination(r.ForwardTo, obj.Namespace, domain)
+		for _, u := range unsupportedForward {
+			unsupported = append(unsupported, fmt.Sprintf("rule %d: %s", i, u))
+		}
 		httproutes = append(httproutes, vs)
 	}
+	obj.Status.(*kstatus.WrappedStatus).Mutate(func(s config.Status) config.Status {
+		rs := s.(*k8s.HTTPRouteStatus)
+		// TODO report skipped routes
+		rs.Gateways = createRouteStatus(gateways, obj, rs.Gateways)
+		reportUnsupportedFilters(rs.Gateways, obj, unsupported)
+		return rs
+	})
 	vsConfig := config.Config{
 		Meta: config.Meta{
 			CreationTimestamp: obj.CreationTimestamp,
@@ -438,6 +462,30 @@ func createRouteStatus(gateways []string, obj config.Config, current []k8s.Route
 	return gws
 }
 
+// routeConditionDegraded is an Istio specific route condition, reporting the parts of the route that are ignored.
+const routeConditionDegraded = "Degraded"
+
+// reportUnsupportedFilters adds a condition listing the filters that were not translated to the statuses we own.
+// The rest of the route is still applied, so the route remains admitted.
+func reportUnsupportedFilters(gws []k8s.RouteGatewayStatus, obj config.Config, unsupported []string) {
+	if len(unsupported) == 0 {
+		return
+	}
+	for i, gw := range gws {
+		if gw.GatewayRef.Controller == nil || *gw.GatewayRef.Controller != ControllerName {
+			continue
+		}
+		gws[i].Conditions = append(gws[i].Conditions, metav1.Condition{
+			Type:               routeConditionDegraded,
+			Status:             kstatus.StatusTrue,
+			ObservedGeneration: obj.Generation,
+			LastTransitionTime: metav1.Now(),
+			Reason:             "UnsupportedFilter",
+			Message:            "Ignored unsupported filters: " + strings.Join(unsupported, "; "),
+		})
+	}
+}
+
 func hostnameToStringList(h []k8s.Hostname) []string {
 	res := make([]string, 0, len(h))
 	for _, i := range h {
@@ -582,9 +630,11 @@ func intSum(n []int) int {
 	return r
 }
 
-func buildHTTPDestination(action []k8s.HTTPRouteForwardTo, ns string, domain string) []*istio.HTTPRouteDestination {
+// buildHTTPDestination returns the destinations of the route, and a description of the filters that could not be
+// translated.
+func buildHTTPDestination(action []k8s.HTTPRouteForwardTo, ns string, domain string) ([]*istio.HTTPRouteDestination, []string) {
 	if action == nil {
-		return nil
+		return nil, nil
 	}
 
 	weights := []int{}
@@ -597,6 +647,7 @@ func buildHTTPDestination(action []k8s.HTTPRouteForwardTo, ns string, domain str
 	}
 	weights = standardizeWeights(weights)
 	res := []*istio.HTTPRouteDestination{}
+	var unsupported []string
 	for i, fwd := range action {
 		dst := buildDestination(fwd, ns, domain)
 		rd := &istio.HTTPRouteDestination{
@@ -607,13 +658,17 @@ func buildHTTPDestination(action []k8s.HTTPRouteForwardTo, ns string, domain str
 			switch filter.Type {
 			case k8s.HTTPRouteFilterRequestHeaderModifier:
 				rd.Headers = createHeadersFilter(filter.RequestHeaderModifier)
+			case k8s.HTTPRouteFilterRequestMirror:
+				// VirtualService can only mirror all the requests of a route
+				unsupported = append(unsupported, fmt.Sprintf("forwardTo %d: %s filter is only supported on rules", i, filter.Type))
 			default:
 				log.Warnf("unsupported filter type %q", filter.Type)
+				unsupported = append(unsupported, fmt.Sprintf("forwardTo %d: unsupported filter type %q", i, filter.Type))
 			}
 		}
 		res = append(res, rd)
 	}
-	return res
+	return res, unsupported
 }
 
 func buildDestination(to k8s.HTTPRouteForwardTo, ns, domain string) *istio.Destination {
@@ -718,6 +773,23 @@ func createHeadersFilter(filter *k8s.HTTPRequestHeaderFilter) *istio.Headers {
 	}
 }
 
+func createMirrorFilter(filter *k8s.HTTPRequestMirrorFilter, ns, domain string) (*istio.Destination, error) {
+	if filter == nil {
+		return nil, fmt.Errorf("%s filter is missing its configuration", k8s.HTTPRouteFilterRequestMirror)
+	}
+	if filter.ServiceName == nil {
+		// TODO support this
+		return nil, fmt.Errorf("%s filter backendRef is not supported", k8s.HTTPRouteFilterRequestMirror)
+	}
+	res := &istio.Destination{
+		Host: fmt.Sprintf("%s.%s.svc.%s", *filter.ServiceName, ns, domain),
+	}
+	if filter.Port != nil {
+		res.Port = &istio.PortSelector{Number: uint32(*filter.Port)}
+	}
+	return res, nil
+}
+
 func createQueryParamsMatch(match k8s.HTTPRouteMatch) map[string]*istio.StringMatch {
 	if match.QueryParams == nil {
 		return nil
@@ -805,14 +877,20 @@ func createURIMatch(match k8s.HTTPRouteMatch) *istio.StringMatch {
 }
 
 // getGatewayClass finds all gateway class that are owned by Istio
-func getGatewayClasses(r *KubernetesResources) map[string]struct{} {
-	classes := map[string]struct{}{}
+// gatewayClass holds the settings of a GatewayClass handled by this controller.
+type gatewayClass struct {
+	// provisioned indicates the workloads for Gateways of this class are deployed by istiod.
+	provisioned bool
+}
+
+func getGatewayClasses(r *KubernetesResources) map[string]gatewayClass {
+	classes := map[string]gatewayClass{}
 	for _, obj := range r.GatewayClass {
 		gwc := obj.Spec.(*k8s.GatewayClassSpec)
 		if gwc.Controller == ControllerName {
-			// TODO we can add any settings we need here needed for the controller
-			// For now, we have none, so just add a struct
-			classes[obj.Name] = struct{}{}
+			classes[obj.Name] = gatewayClass{
+				provisioned: features.EnableGatewayAPIDeploymentController && gatewayClassProvisioned(obj.Annotations),
+			}
 
 			obj.Status.(*kstatus.WrappedStatus).Mutate(func(s config.Status) config.Status {
 				gcs := s.(*k8s.GatewayClassStatus)
@@ -837,14 +915,23 @@ func convertGateway(r *KubernetesResources) ([]config.Config, map[RouteKey][]str
 	classes := getGatewayClasses(r)
 	for _, obj := range r.Gateway {
 		kgw := obj.Spec.(*k8s.GatewaySpec)
-		if _, f := classes[kgw.GatewayClassName]; !f {
+		class, f := classes[kgw.GatewayClassName]
+		if !f {
 			// No gateway class found, this may be meant for another controller; should be skipped.
 			continue
 		}
+		// TODO derive this from gatewayclass param ref
+		selector := labels.Instance{constants.IstioLabel: "ingressgateway"}
+		addresses := []k8s.GatewayAddress{}
+		var svc *corev1.Service
+		if class.provisioned {
+			selector = gatewaySelector(obj.Name, obj.Namespace)
+			svc = r.GatewayServices[types.NamespacedName{Namespace: obj.Namespace, Name: provisionedName(obj.Name)}]
+			addresses = serviceAddresses(svc)
+		}
 		obj.Status.(*kstatus.WrappedStatus).Mutate(func(s config.Status) config.Status {
 			gs := s.(*k8s.GatewayStatus)
-			// TODO implement addresses
-			gs.Addresses = []k8s.GatewayAddress{}
+			gs.Addresses = addresses
 			// We expect one listener status per listener
 			if len(gs.Listeners) != len(kgw.Listeners) {
 				gs.Listeners = make([]k8s.ListenerStatus, len(kgw.Listeners))
@@ -914,32 +1001,42 @@ func convertGateway(r *KubernetesResources) ([]config.Config, map[RouteKey][]str
 				Domain:            r.Domain,
 			},
 			Spec: &istio.Gateway{
-				Servers: servers,
-				// TODO derive this from gatewayclass param ref
-				Selector: labels.Instance{constants.IstioLabel: "ingressgateway"},
+				Servers:  servers,
+				Selector: selector,
 			},
 		}
 		obj.Status.(*kstatus.WrappedStatus).Mutate(func(s config.Status) config.Status {
 			gs := s.(*k8s.GatewayStatus)
 			// TODO: report invalid configurations
-			gs.Conditions = kstatus.ConditionallyUpdateCondition(gs.Conditions, metav1.Condition{
+			ready := metav1.Condition{
 				Type:               string(k8s.GatewayConditionReady),
 				Status:             kstatus.StatusTrue,
 				ObservedGeneration: obj.Generation,
 				LastTransitionTime: metav1.Now(),
 				Reason:             "ListenersValid",
 				Message:            "Listeners valid",
-			})
-			// TODO: when we implement "address" support in status, we should report unscheduled
-			// if there is no associated Service.
-			gs.Conditions = kstatus.ConditionallyUpdateCondition(gs.Conditions, metav1.Condition{
+			}
+			scheduled := metav1.Condition{
 				Type:               string(k8s.GatewayConditionScheduled),
 				Status:             kstatus.StatusTrue,
 				ObservedGeneration: obj.Generation,
 				LastTransitionTime: metav1.Now(),
 				Reason:             "ResourcesAvailable",
 				Message:            "Resources available",
-			})
+			}
+			if class.provisioned {
+				if svc == nil {
+					scheduled.Status = kstatus.StatusFalse
+					scheduled.Reason = string(k8s.GatewayReasonNoResources)
+					scheduled.Message = fmt.Sprintf("Service %s has not been provisioned", provisionedName(obj.Name))
+				} else if len(addresses) == 0 {
+					ready.Status = kstatus.StatusFalse
+					ready.Reason = string(k8s.GatewayReasonAddressNotAssigned)
+					ready.Message = fmt.Sprintf("Service %s has not been assigned an address", svc.Name)
+				}
+			}
+			gs.Conditions = kstatus.ConditionallyUpdateCondition(gs.Conditions, ready)
+			gs.Conditions = kstatus.ConditionallyUpdateCondition(gs.Conditions, scheduled)
 			return gs
 		})
 		result = append(result, gatewayConfig)
@@ -950,6 +1047,31 @@ func convertGateway(r *KubernetesResources) ([]config.Config, map[RouteKey][]str
 	return result, routeToGateway
 }
 
+// serviceAddresses returns the addresses assigned to a provisioned gateway Service. LoadBalancer Services report
+// their ingress points, which may not be assigned yet; other types report their cluster IP.
+func serviceAddresses(svc *corev1.Service) []k8s.GatewayAddress {
+	addresses := []k8s.GatewayAddress{}
+	if svc == nil {
+		return addresses
+	}
+	ipType := k8s.IPAddressType
+	namedType := k8s.NamedAddressType
+	if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				addresses = append(addresses, k8s.GatewayAddress{Type: &ipType, Value: ingress.IP})
+			} else if ingress.Hostname != "" {
+				addresses = append(addresses, k8s.GatewayAddress{Type: &namedType, Value: ingress.Hostname})
+			}
+		}
+		return addresses
+	}
+	if svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != corev1.ClusterIPNone {
+		addresses = append(addresses, k8s.GatewayAddress{Type: &ipType, Value: svc.Spec.ClusterIP})
+	}
+	return addresses
+}
+
 // experimentalMeshGatewayName defines the magic mesh gateway name.
 // TODO: replace this with a more suitable API. This is just added now to allow early adopters to experiment with the API
 const experimentalMeshGatewayName = "mesh"
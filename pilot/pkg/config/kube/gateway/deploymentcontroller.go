@@ -0,0 +1,422 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	k8s "sigs.k8s.io/gateway-api/apis/v1alpha1"
+	gatewaylister "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1alpha1"
+
+	"istio.io/api/annotation"
+	"istio.io/api/label"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/queue"
+	"istio.io/pkg/log"
+)
+
+const (
+	// provisionAnnotation, when set to "true" on a GatewayClass, opts all Gateways of that class into
+	// automated deployment.
+	provisionAnnotation = "gateway.istio.io/provision"
+	// serviceTypeAnnotation allows a Gateway to override the type of the provisioned Service, which
+	// defaults to LoadBalancer.
+	serviceTypeAnnotation = "networking.istio.io/service-type"
+	// gatewayNameLabel is set on all provisioned resources, and selects the gateway pods.
+	gatewayNameLabel = "istio.io/gateway-name"
+	// gatewayNamespaceLabel is set along with gatewayNameLabel. Istio Gateway selectors match the pods of all
+	// namespaces, so both are needed to tell apart the pods of Gateways with the same name.
+	gatewayNamespaceLabel = "istio.io/gateway-namespace"
+	// gatewayClassLabel records the GatewayClass a resource was provisioned for.
+	gatewayClassLabel = "istio.io/gateway-class"
+	// managedLabel marks resources as owned by this controller. Resources without it are never modified.
+	managedLabel      = "gateway.istio.io/managed"
+	managedLabelValue = "istio.io-gateway-controller"
+
+	statusPort = 15021
+)
+
+// DeploymentController implements a controller that materializes a Gateway into an in cluster gateway proxy
+// to serve requests from. This is implemented with a Deployment, Service, and ServiceAccount per Gateway,
+// all owned by the Gateway so they are garbage collected when it is removed.
+// Provisioning is opt-in per GatewayClass, by setting the gateway.istio.io/provision annotation to "true".
+type DeploymentController struct {
+	client   kube.Client
+	revision string
+	queue    queue.Instance
+
+	gatewayInformer      cache.SharedInformer
+	gatewayClassInformer cache.SharedInformer
+	deploymentInformer   cache.SharedInformer
+	serviceInformer      cache.SharedInformer
+	gatewayLister        gatewaylister.GatewayLister
+	gatewayClassLister   gatewaylister.GatewayClassLister
+	deploymentLister     appslisters.DeploymentLister
+	serviceLister        listerv1.ServiceLister
+}
+
+// NewDeploymentController returns a pointer to a newly constructed DeploymentController instance.
+// Provisioned pods are injected by the control plane with the given revision.
+func NewDeploymentController(client kube.Client, revision string) *DeploymentController {
+	d := &DeploymentController{
+		client:   client,
+		revision: revision,
+		queue:    queue.NewQueue(time.Second),
+	}
+
+	gateways := client.GatewayAPIInformer().Networking().V1alpha1().Gateways()
+	d.gatewayInformer = gateways.Informer()
+	d.gatewayLister = gateways.Lister()
+	gatewayClasses := client.GatewayAPIInformer().Networking().V1alpha1().GatewayClasses()
+	d.gatewayClassInformer = gatewayClasses.Informer()
+	d.gatewayClassLister = gatewayClasses.Lister()
+	d.deploymentInformer = client.KubeInformer().Apps().V1().Deployments().Informer()
+	d.deploymentLister = client.KubeInformer().Apps().V1().Deployments().Lister()
+	d.serviceInformer = client.KubeInformer().Core().V1().Services().Informer()
+	d.serviceLister = client.KubeInformer().Core().V1().Services().Lister()
+
+	d.gatewayInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: d.enqueueGateway,
+		UpdateFunc: func(_, obj interface{}) {
+			d.enqueueGateway(obj)
+		},
+	})
+	d.gatewayClassInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: d.enqueueGatewayClass,
+		UpdateFunc: func(_, obj interface{}) {
+			d.enqueueGatewayClass(obj)
+		},
+	})
+	// Changes to the resources we provision are reverted by reconciling the owning Gateway
+	owned := cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj interface{}) {
+			d.enqueueOwner(obj)
+		},
+		DeleteFunc: d.enqueueOwner,
+	}
+	d.deploymentInformer.AddEventHandler(owned)
+	d.serviceInformer.AddEventHandler(owned)
+
+	return d
+}
+
+// Run starts the DeploymentController until a value is sent to stop.
+func (d *DeploymentController) Run(stop <-chan struct{}) {
+	if !cache.WaitForCacheSync(stop, d.gatewayInformer.HasSynced, d.gatewayClassInformer.HasSynced,
+		d.deploymentInformer.HasSynced, d.serviceInformer.HasSynced) {
+		log.Error("Failed to sync gateway deployment controller cache")
+		return
+	}
+	log.Infof("Gateway deployment controller started")
+	go d.queue.Run(stop)
+}
+
+func (d *DeploymentController) enqueueGateway(obj interface{}) {
+	gw, ok := obj.(*k8s.Gateway)
+	if !ok {
+		return
+	}
+	d.queue.Push(func() error {
+		return d.Reconcile(gw.Namespace, gw.Name)
+	})
+}
+
+func (d *DeploymentController) enqueueGatewayClass(obj interface{}) {
+	gwc, ok := obj.(*k8s.GatewayClass)
+	if !ok {
+		return
+	}
+	gws, err := d.gatewayLister.List(klabels.Everything())
+	if err != nil {
+		log.Errorf("failed to list gateways for class %v: %v", gwc.Name, err)
+		return
+	}
+	for _, gw := range gws {
+		if gw.Spec.GatewayClassName == gwc.Name {
+			d.enqueueGateway(gw)
+		}
+	}
+}
+
+func (d *DeploymentController) enqueueOwner(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		log.Errorf("failed to read object metadata: %v", err)
+		return
+	}
+	if o.GetLabels()[managedLabel] != managedLabelValue {
+		return
+	}
+	name, f := o.GetLabels()[gatewayNameLabel]
+	if !f {
+		return
+	}
+	ns := o.GetNamespace()
+	d.queue.Push(func() error {
+		return d.Reconcile(ns, name)
+	})
+}
+
+// Reconcile ensures the Deployment, Service, and ServiceAccount for the given Gateway match the desired state.
+// Gateways that do not belong to a provisioned GatewayClass are ignored.
+func (d *DeploymentController) Reconcile(namespace, name string) error {
+	gw, err := d.gatewayLister.Gateways(namespace).Get(name)
+	if kerrors.IsNotFound(err) {
+		// Provisioned resources are owned by the Gateway, so Kubernetes will clean them up
+		return nil
+	} else if err != nil {
+		return err
+	}
+	gwc, err := d.gatewayClassLister.Get(gw.Spec.GatewayClassName)
+	if kerrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if gwc.Spec.Controller != ControllerName || !gatewayClassProvisioned(gwc.Annotations) {
+		return nil
+	}
+	log.Debugf("reconciling gateway %s/%s", namespace, name)
+
+	if err := d.applyServiceAccount(d.desiredServiceAccount(gw)); err != nil {
+		return fmt.Errorf("failed to apply ServiceAccount for gateway %s/%s: %v", namespace, name, err)
+	}
+	if err := d.applyDeployment(d.desiredDeployment(gw)); err != nil {
+		return fmt.Errorf("failed to apply Deployment for gateway %s/%s: %v", namespace, name, err)
+	}
+	if err := d.applyService(d.desiredService(gw)); err != nil {
+		return fmt.Errorf("failed to apply Service for gateway %s/%s: %v", namespace, name, err)
+	}
+	return nil
+}
+
+func (d *DeploymentController) objectMeta(gw *k8s.Gateway) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      provisionedName(gw.Name),
+		Namespace: gw.Namespace,
+		Labels: map[string]string{
+			gatewayNameLabel:      gw.Name,
+			gatewayNamespaceLabel: gw.Namespace,
+			gatewayClassLabel:     gw.Spec.GatewayClassName,
+			managedLabel:          managedLabelValue,
+		},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: k8s.GroupVersion.String(),
+			Kind:       "Gateway",
+			Name:       gw.Name,
+			UID:        gw.UID,
+		}},
+	}
+}
+
+func (d *DeploymentController) desiredServiceAccount(gw *k8s.Gateway) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{ObjectMeta: d.objectMeta(gw)}
+}
+
+func (d *DeploymentController) desiredDeployment(gw *k8s.Gateway) *appsv1.Deployment {
+	revision := d.revision
+	if revision == "" {
+		revision = "default"
+	}
+	podLabels := gatewaySelector(gw.Name, gw.Namespace)
+	podLabels[label.IoIstioRev.Name] = revision
+	return &appsv1.Deployment{
+		ObjectMeta: d.objectMeta(gw),
+		Spec: appsv1.DeploymentSpec{
+			// The selector of a Deployment only matches pods of its namespace, and is immutable, so it is left as is.
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{gatewayNameLabel: gw.Name},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: podLabels,
+					// The proxy container is filled in by the gateway injection template
+					Annotations: map[string]string{
+						annotation.InjectTemplates.Name: "gateway",
+					},
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: provisionedName(gw.Name),
+					Containers: []corev1.Container{{
+						Name:  "istio-proxy",
+						Image: "auto",
+					}},
+				},
+			},
+		},
+	}
+}
+
+func (d *DeploymentController) desiredService(gw *k8s.Gateway) *corev1.Service {
+	ports := []corev1.ServicePort{{
+		Name:       "status-port",
+		Port:       statusPort,
+		TargetPort: intstr.FromInt(statusPort),
+		Protocol:   corev1.ProtocolTCP,
+	}}
+	seen := map[k8s.PortNumber]struct{}{statusPort: {}}
+	for _, l := range gw.Spec.Listeners {
+		if _, f := seen[l.Port]; f {
+			continue
+		}
+		seen[l.Port] = struct{}{}
+		protocol := corev1.ProtocolTCP
+		if l.Protocol == k8s.UDPProtocolType {
+			protocol = corev1.ProtocolUDP
+		}
+		ports = append(ports, corev1.ServicePort{
+			Name:       fmt.Sprintf("%s-%d", strings.ToLower(string(l.Protocol)), l.Port),
+			Port:       int32(l.Port),
+			TargetPort: intstr.FromInt(targetPort(l.Port)),
+			Protocol:   protocol,
+		})
+	}
+	serviceType := corev1.ServiceTypeLoadBalancer
+	if t, f := gw.Annotations[serviceTypeAnnotation]; f {
+		serviceType = corev1.ServiceType(t)
+	}
+	return &corev1.Service{
+		ObjectMeta: d.objectMeta(gw),
+		Spec: corev1.ServiceSpec{
+			Ports:    ports,
+			Selector: map[string]string{gatewayNameLabel: gw.Name},
+			Type:     serviceType,
+		},
+	}
+}
+
+func (d *DeploymentController) applyServiceAccount(desired *corev1.ServiceAccount) error {
+	existing, err := d.client.CoreV1().ServiceAccounts(desired.Namespace).Get(context.TODO(), desired.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err = d.client.CoreV1().ServiceAccounts(desired.Namespace).Create(context.TODO(), desired, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+	if !isManaged(existing) {
+		log.Warnf("not updating ServiceAccount %s/%s: it is not managed by the gateway controller", desired.Namespace, desired.Name)
+	}
+	return nil
+}
+
+func (d *DeploymentController) applyDeployment(desired *appsv1.Deployment) error {
+	existing, err := d.deploymentLister.Deployments(desired.Namespace).Get(desired.Name)
+	if kerrors.IsNotFound(err) {
+		_, err = d.client.AppsV1().Deployments(desired.Namespace).Create(context.TODO(), desired, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+	if !isManaged(existing) {
+		log.Warnf("not updating Deployment %s/%s: it is not managed by the gateway controller", desired.Namespace, desired.Name)
+		return nil
+	}
+	// The existing template has defaults filled in by the API server, so only compare the fields we set
+	if equality.Semantic.DeepEqual(desired.Labels, existing.Labels) &&
+		equality.Semantic.DeepEqual(desired.OwnerReferences, existing.OwnerReferences) &&
+		equality.Semantic.DeepDerivative(desired.Spec.Template, existing.Spec.Template) {
+		return nil
+	}
+	updated := existing.DeepCopy()
+	updated.Labels = desired.Labels
+	updated.OwnerReferences = desired.OwnerReferences
+	// Replicas are intentionally left alone, so they can be managed by users or an autoscaler
+	updated.Spec.Template = desired.Spec.Template
+	_, err = d.client.AppsV1().Deployments(desired.Namespace).Update(context.TODO(), updated, metav1.UpdateOptions{})
+	return err
+}
+
+func (d *DeploymentController) applyService(desired *corev1.Service) error {
+	existing, err := d.serviceLister.Services(desired.Namespace).Get(desired.Name)
+	if kerrors.IsNotFound(err) {
+		_, err = d.client.CoreV1().Services(desired.Namespace).Create(context.TODO(), desired, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+	if !isManaged(existing) {
+		log.Warnf("not updating Service %s/%s: it is not managed by the gateway controller", desired.Namespace, desired.Name)
+		return nil
+	}
+	updated := existing.DeepCopy()
+	updated.Labels = desired.Labels
+	updated.OwnerReferences = desired.OwnerReferences
+	updated.Spec.Selector = desired.Spec.Selector
+	updated.Spec.Type = desired.Spec.Type
+	updated.Spec.Ports = desired.Spec.Ports
+	if desired.Spec.Type != corev1.ServiceTypeClusterIP {
+		// Keep previously allocated node ports, otherwise every update would reallocate them
+		for i, p := range updated.Spec.Ports {
+			for _, ep := range existing.Spec.Ports {
+				if ep.Port == p.Port && ep.Protocol == p.Protocol {
+					updated.Spec.Ports[i].NodePort = ep.NodePort
+				}
+			}
+		}
+	}
+	if equality.Semantic.DeepEqual(updated, existing) {
+		return nil
+	}
+	_, err = d.client.CoreV1().Services(desired.Namespace).Update(context.TODO(), updated, metav1.UpdateOptions{})
+	return err
+}
+
+// gatewayClassProvisioned reports whether a GatewayClass with the given annotations opts into automated deployment.
+func gatewayClassProvisioned(annotations map[string]string) bool {
+	return annotations[provisionAnnotation] == "true"
+}
+
+// gatewaySelector returns the labels selecting the pods provisioned for a Gateway, across all namespaces.
+func gatewaySelector(name, namespace string) map[string]string {
+	return map[string]string{
+		gatewayNameLabel:      name,
+		gatewayNamespaceLabel: namespace,
+	}
+}
+
+// provisionedName returns the name of the resources provisioned for a Gateway.
+func provisionedName(gateway string) string {
+	return gateway + "-istio"
+}
+
+// targetPort returns the port the gateway pod listens on for a listener port. Privileged ports are shifted, as
+// the proxy runs as non-root, following the convention of the default ingress gateway (80 -> 8080).
+func targetPort(port k8s.PortNumber) int {
+	if port < 1024 {
+		return int(port) + 8000
+	}
+	return int(port)
+}
+
+func isManaged(o metav1.Object) bool {
+	return o.GetLabels()[managedLabel] == managedLabelValue
+}
@@ -0,0 +1,362 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8s "sigs.k8s.io/gateway-api/apis/v1alpha1"
+
+	istio "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model/kstatus"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestDeploymentController(t *testing.T) {
+	client := kube.NewFakeClient(
+		// A Service not owned by us, which should never be modified
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "manual-istio", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "custom", Port: 1234}}},
+		},
+	)
+	createGatewayAPIObjects(t, client,
+		&k8s.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "provisioned", Annotations: map[string]string{provisionAnnotation: "true"}},
+			Spec:       k8s.GatewayClassSpec{Controller: ControllerName},
+		},
+		&k8s.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "manual"},
+			Spec:       k8s.GatewayClassSpec{Controller: ControllerName},
+		},
+		&k8s.Gateway{
+			ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"},
+			Spec: k8s.GatewaySpec{
+				GatewayClassName: "provisioned",
+				Listeners: []k8s.Listener{
+					{Port: 80, Protocol: k8s.HTTPProtocolType},
+					{Port: 80, Protocol: k8s.HTTPProtocolType, Hostname: hostnamePointer("foo.example.com")},
+					{Port: 9000, Protocol: k8s.TCPProtocolType},
+				},
+			},
+		},
+		&k8s.Gateway{
+			ObjectMeta: metav1.ObjectMeta{Name: "manual", Namespace: "default"},
+			Spec: k8s.GatewaySpec{
+				GatewayClassName: "manual",
+				Listeners:        []k8s.Listener{{Port: 80, Protocol: k8s.HTTPProtocolType}},
+			},
+		},
+	)
+	d := NewDeploymentController(client, "canary")
+	stop := make(chan struct{})
+	defer close(stop)
+	client.RunAndWait(stop)
+
+	if err := d.Reconcile("default", "gw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reconcile("default", "manual"); err != nil {
+		t.Fatal(err)
+	}
+	// Gateway deletion is handled by garbage collection
+	if err := d.Reconcile("default", "missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.CoreV1().ServiceAccounts("default").Get(ctx, "gw-istio", metav1.GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	dep, err := client.AppsV1().Deployments("default").Get(ctx, "gw-istio", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dep.Spec.Template.Labels["istio.io/rev"]; got != "canary" {
+		t.Errorf("expected revision label canary, got %q", got)
+	}
+	if got := dep.Spec.Template.Spec.ServiceAccountName; got != "gw-istio" {
+		t.Errorf("expected service account gw-istio, got %q", got)
+	}
+	if len(dep.OwnerReferences) != 1 || dep.OwnerReferences[0].Name != "gw" {
+		t.Errorf("expected deployment to be owned by the gateway, got %v", dep.OwnerReferences)
+	}
+	svc, err := client.CoreV1().Services("default").Get(ctx, "gw-istio", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		t.Errorf("expected LoadBalancer service, got %v", svc.Spec.Type)
+	}
+	assertPorts(t, svc, []corev1.ServicePort{
+		{Name: "status-port", Port: 15021, TargetPort: intstr.FromInt(15021), Protocol: corev1.ProtocolTCP},
+		{Name: "http-80", Port: 80, TargetPort: intstr.FromInt(8080), Protocol: corev1.ProtocolTCP},
+		{Name: "tcp-9000", Port: 9000, TargetPort: intstr.FromInt(9000), Protocol: corev1.ProtocolTCP},
+	})
+
+	// The Gateway of an unprovisioned class should be left alone
+	if _, err := client.AppsV1().Deployments("default").Get(ctx, "manual-istio", metav1.GetOptions{}); err == nil {
+		t.Errorf("unexpected deployment for unprovisioned gateway")
+	}
+	manual, err := client.CoreV1().Services("default").Get(ctx, "manual-istio", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manual.Spec.Ports) != 1 || manual.Spec.Ports[0].Name != "custom" {
+		t.Errorf("unmanaged service was modified: %v", manual.Spec.Ports)
+	}
+
+	// Reconciling again, once the caches have caught up, should be a no-op
+	retry.UntilSuccessOrFail(t, func() error {
+		if _, err := d.serviceLister.Services("default").Get("gw-istio"); err != nil {
+			return err
+		}
+		_, err := d.deploymentLister.Deployments("default").Get("gw-istio")
+		return err
+	})
+	if err := d.Reconcile("default", "gw"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyServicePreservesNodePorts(t *testing.T) {
+	gw := &k8s.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"},
+		Spec: k8s.GatewaySpec{
+			GatewayClassName: "provisioned",
+			Listeners:        []k8s.Listener{{Port: 443, Protocol: k8s.HTTPSProtocolType}},
+		},
+	}
+	d := &DeploymentController{}
+	existing := d.desiredService(gw)
+	existing.Spec.Ports = []corev1.ServicePort{
+		{Name: "status-port", Port: 15021, TargetPort: intstr.FromInt(15021), Protocol: corev1.ProtocolTCP, NodePort: 30001},
+		{Name: "http-80", Port: 80, TargetPort: intstr.FromInt(8080), Protocol: corev1.ProtocolTCP, NodePort: 30002},
+	}
+	client := kube.NewFakeClient(existing)
+	d = NewDeploymentController(client, "")
+	stop := make(chan struct{})
+	defer close(stop)
+	client.RunAndWait(stop)
+
+	if err := d.applyService(d.desiredService(gw)); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := client.CoreV1().Services("default").Get(context.Background(), "gw-istio", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPorts(t, svc, []corev1.ServicePort{
+		{Name: "status-port", Port: 15021, TargetPort: intstr.FromInt(15021), Protocol: corev1.ProtocolTCP, NodePort: 30001},
+		{Name: "https-443", Port: 443, TargetPort: intstr.FromInt(8443), Protocol: corev1.ProtocolTCP},
+	})
+}
+
+func TestConvertProvisionedGateway(t *testing.T) {
+	features.EnableGatewayAPIDeploymentController = true
+	defer func() {
+		features.EnableGatewayAPIDeploymentController = false
+	}()
+
+	cases := []struct {
+		name       string
+		service    *corev1.Service
+		addresses  []string
+		conditions map[string]metav1.ConditionStatus
+	}{
+		{
+			name:       "not provisioned",
+			conditions: map[string]metav1.ConditionStatus{"Ready": "True", "Scheduled": "False"},
+		},
+		{
+			name: "pending load balancer",
+			service: &corev1.Service{
+				Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, ClusterIP: "10.0.0.1"},
+			},
+			conditions: map[string]metav1.ConditionStatus{"Ready": "False", "Scheduled": "True"},
+		},
+		{
+			name: "load balancer",
+			service: &corev1.Service{
+				Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, ClusterIP: "10.0.0.1"},
+				Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}, {Hostname: "lb.example.com"}},
+				}},
+			},
+			addresses:  []string{"1.2.3.4", "lb.example.com"},
+			conditions: map[string]metav1.ConditionStatus{"Ready": "True", "Scheduled": "True"},
+		},
+		{
+			name: "cluster ip",
+			service: &corev1.Service{
+				Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, ClusterIP: "10.0.0.1"},
+			},
+			addresses:  []string{"10.0.0.1"},
+			conditions: map[string]metav1.ConditionStatus{"Ready": "True", "Scheduled": "True"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &KubernetesResources{
+				GatewayClass: []config.Config{{
+					Meta: config.Meta{
+						GroupVersionKind: gvk.GatewayClass,
+						Name:             "provisioned",
+						Annotations:      map[string]string{provisionAnnotation: "true"},
+					},
+					Spec:   &k8s.GatewayClassSpec{Controller: ControllerName},
+					Status: kstatus.Wrap(&k8s.GatewayClassStatus{}),
+				}},
+				Gateway: []config.Config{{
+					Meta: config.Meta{
+						GroupVersionKind: gvk.ServiceApisGateway,
+						Name:             "gw",
+						Namespace:        "default",
+					},
+					Spec: &k8s.GatewaySpec{
+						GatewayClassName: "provisioned",
+						Listeners:        []k8s.Listener{{Port: 80, Protocol: k8s.HTTPProtocolType}},
+					},
+					Status: kstatus.Wrap(&k8s.GatewayStatus{}),
+				}},
+				GatewayServices: map[types.NamespacedName]*corev1.Service{},
+				Domain:          "cluster.local",
+			}
+			if tt.service != nil {
+				input.GatewayServices[types.NamespacedName{Namespace: "default", Name: "gw-istio"}] = tt.service
+				tt.service.Name = "gw-istio"
+			}
+			output := convertResources(input)
+
+			if len(output.Gateway) != 1 {
+				t.Fatalf("expected one gateway, got %v", output.Gateway)
+			}
+			selector := output.Gateway[0].Spec.(*istio.Gateway).Selector
+			if len(selector) != 2 || selector[gatewayNameLabel] != "gw" || selector[gatewayNamespaceLabel] != "default" {
+				t.Errorf("expected selector for the provisioned gateway, got %v", selector)
+			}
+
+			status := input.Gateway[0].Status.(*kstatus.WrappedStatus).Unwrap().(*k8s.GatewayStatus)
+			addresses := []string{}
+			for _, a := range status.Addresses {
+				addresses = append(addresses, a.Value)
+			}
+			if fmt.Sprint(addresses) != fmt.Sprint(tt.addresses) {
+				t.Errorf("expected addresses %v, got %v", tt.addresses, addresses)
+			}
+			for cond, want := range tt.conditions {
+				if got := kstatus.GetCondition(status.Conditions, cond).Status; got != want {
+					t.Errorf("expected condition %v to be %v, got %v", cond, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertProvisionedGatewaysWithSameName(t *testing.T) {
+	features.EnableGatewayAPIDeploymentController = true
+	defer func() {
+		features.EnableGatewayAPIDeploymentController = false
+	}()
+
+	gateway := func(namespace string) config.Config {
+		return config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.ServiceApisGateway,
+				Name:             "gw",
+				Namespace:        namespace,
+			},
+			Spec: &k8s.GatewaySpec{
+				GatewayClassName: "provisioned",
+				Listeners:        []k8s.Listener{{Port: 80, Protocol: k8s.HTTPProtocolType}},
+			},
+			Status: kstatus.Wrap(&k8s.GatewayStatus{}),
+		}
+	}
+	input := &KubernetesResources{
+		GatewayClass: []config.Config{{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.GatewayClass,
+				Name:             "provisioned",
+				Annotations:      map[string]string{provisionAnnotation: "true"},
+			},
+			Spec:   &k8s.GatewayClassSpec{Controller: ControllerName},
+			Status: kstatus.Wrap(&k8s.GatewayClassStatus{}),
+		}},
+		Gateway:         []config.Config{gateway("ns-a"), gateway("ns-b")},
+		GatewayServices: map[types.NamespacedName]*corev1.Service{},
+		Domain:          "cluster.local",
+	}
+	output := convertResources(input)
+	if len(output.Gateway) != 2 {
+		t.Fatalf("expected two gateways, got %v", output.Gateway)
+	}
+
+	d := &DeploymentController{}
+	for _, gw := range output.Gateway {
+		selector := labels.Instance(gw.Spec.(*istio.Gateway).Selector)
+		for _, ns := range []string{"ns-a", "ns-b"} {
+			dep := d.desiredDeployment(&k8s.Gateway{
+				ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: ns},
+				Spec:       k8s.GatewaySpec{GatewayClassName: "provisioned"},
+			})
+			selected := selector.SubsetOf(dep.Spec.Template.Labels)
+			if want := ns == gw.Namespace; selected != want {
+				t.Errorf("gateway %s/%s selecting the pods of namespace %s: got %v, want %v", gw.Namespace, gw.Name, ns, selected, want)
+			}
+		}
+	}
+}
+
+func createGatewayAPIObjects(t *testing.T, client kube.Client, objs ...interface{}) {
+	t.Helper()
+	ctx := context.Background()
+	for _, obj := range objs {
+		var err error
+		switch o := obj.(type) {
+		case *k8s.GatewayClass:
+			_, err = client.GatewayAPI().NetworkingV1alpha1().GatewayClasses().Create(ctx, o, metav1.CreateOptions{})
+		case *k8s.Gateway:
+			_, err = client.GatewayAPI().NetworkingV1alpha1().Gateways(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+		default:
+			err = fmt.Errorf("unsupported object %T", obj)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertPorts(t *testing.T, svc *corev1.Service, want []corev1.ServicePort) {
+	t.Helper()
+	if fmt.Sprint(svc.Spec.Ports) != fmt.Sprint(want) {
+		t.Errorf("unexpected ports:\ngot  %v\nwant %v", svc.Spec.Ports, want)
+	}
+}
+
+func hostnamePointer(h k8s.Hostname) *k8s.Hostname {
+	return &h
+}
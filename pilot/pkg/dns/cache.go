@@ -0,0 +1,160 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/miekg/dns"
+)
+
+// Upper bounds of the time responses are cached for, regardless of their TTLs.
+// These match the defaults of the CoreDNS cache plugin.
+const (
+	maxCacheTTL         = time.Hour
+	maxNegativeCacheTTL = 30 * time.Minute
+)
+
+type cacheKey struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+	// Upstream responses differ depending on whether the request used EDNS, and on its DNSSEC OK bit.
+	edns bool
+	do   bool
+}
+
+type cacheEntry struct {
+	response *dns.Msg
+	stored   time.Time
+	expires  time.Time
+}
+
+// responseCache is a bounded cache of the responses of upstream DNS servers, which respects their TTLs.
+// Negative responses (NXDOMAIN and NODATA) are cached following RFC 2308.
+type responseCache struct {
+	entries *lru.Cache
+	now     func() time.Time
+}
+
+func newResponseCache(size int) (*responseCache, error) {
+	entries, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &responseCache{entries: entries, now: time.Now}, nil
+}
+
+func newCacheKey(req *dns.Msg) cacheKey {
+	q := req.Question[0]
+	key := cacheKey{name: strings.ToLower(q.Name), qtype: q.Qtype, qclass: q.Qclass}
+	if opt := req.IsEdns0(); opt != nil {
+		key.edns = true
+		key.do = opt.Do()
+	}
+	return key
+}
+
+// get returns the cached response for the request, with the TTLs of its records decreased by the
+// time spent in the cache, or nil if there is none.
+func (c *responseCache) get(req *dns.Msg) *dns.Msg {
+	key := newCacheKey(req)
+	v, f := c.entries.Get(key)
+	if !f {
+		return nil
+	}
+	entry := v.(*cacheEntry)
+	now := c.now()
+	if !now.Before(entry.expires) {
+		c.entries.Remove(key)
+		return nil
+	}
+	elapsed := uint32(now.Sub(entry.stored) / time.Second)
+
+	response := entry.response.Copy()
+	response.Id = req.Id
+	response.RecursionDesired = req.RecursionDesired
+	response.Question = append([]dns.Question(nil), req.Question...)
+	for _, rrs := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
+		for _, rr := range rrs {
+			hdr := rr.Header()
+			if hdr.Rrtype == dns.TypeOPT {
+				continue
+			}
+			if hdr.Ttl > elapsed {
+				hdr.Ttl -= elapsed
+			} else {
+				hdr.Ttl = 0
+			}
+		}
+	}
+	return response
+}
+
+// add stores the response for the request, if it can be cached.
+func (c *responseCache) add(req *dns.Msg, response *dns.Msg) {
+	ttl := cacheTTL(response)
+	if ttl <= 0 {
+		return
+	}
+	now := c.now()
+	c.entries.Add(newCacheKey(req), &cacheEntry{
+		response: response.Copy(),
+		stored:   now,
+		expires:  now.Add(ttl),
+	})
+}
+
+// cacheTTL returns how long a response can be cached for, or zero if it cannot be cached.
+// Positive responses are cached for the minimum TTL of their records. Negative responses are cached
+// for the minimum of the TTL and the MINIMUM field of the SOA record in the authority section, and
+// are not cached without one.
+func cacheTTL(response *dns.Msg) time.Duration {
+	if response.Truncated {
+		return 0
+	}
+	switch {
+	case response.Rcode == dns.RcodeSuccess && len(response.Answer) > 0:
+		ttl, found := uint32(0), false
+		for _, rrs := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
+			for _, rr := range rrs {
+				if hdr := rr.Header(); hdr.Rrtype != dns.TypeOPT && (!found || hdr.Ttl < ttl) {
+					ttl, found = hdr.Ttl, true
+				}
+			}
+		}
+		return minDuration(time.Duration(ttl)*time.Second, maxCacheTTL)
+	case response.Rcode == dns.RcodeSuccess || response.Rcode == dns.RcodeNameError:
+		for _, rr := range response.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				ttl := soa.Hdr.Ttl
+				if soa.Minttl < ttl {
+					ttl = soa.Minttl
+				}
+				return minDuration(time.Duration(ttl)*time.Second, maxNegativeCacheTTL)
+			}
+		}
+	}
+	return 0
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,182 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func newTestCache(t *testing.T, size int) (*responseCache, *time.Time) {
+	c, err := newResponseCache(size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func question(name string, qtype uint16) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	return req
+}
+
+func soa(name string, ttl, minTTL uint32) dns.RR {
+	return &dns.SOA{
+		Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: ttl},
+		Ns:     "ns." + name,
+		Mbox:   "hostmaster." + name,
+		Minttl: minTTL,
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	c, now := newTestCache(t, 10)
+
+	req := question("www.bing.com.", dns.TypeA)
+	response := new(dns.Msg)
+	response.SetReply(req)
+	response.Answer = append(a("www.bing.com.", []net.IP{net.ParseIP("1.1.1.1").To4()}, 60),
+		a("www.bing.com.", []net.IP{net.ParseIP("2.2.2.2").To4()}, 120)...)
+	c.add(req, response)
+
+	*now = now.Add(10 * time.Second)
+	// A different ID and case should still be served from the cache
+	req = question("WWW.bing.com.", dns.TypeA)
+	got := c.get(req)
+	if got == nil {
+		t.Fatalf("expected a cached response")
+	}
+	if got.Id != req.Id || got.Question[0].Name != "WWW.bing.com." {
+		t.Errorf("cached response does not match the request: %v", got)
+	}
+	if ttl := got.Answer[0].Header().Ttl; ttl != 50 {
+		t.Errorf("expected TTL to be decreased to 50, got %v", ttl)
+	}
+	if ttl := response.Answer[0].Header().Ttl; ttl != 60 {
+		t.Errorf("original response was modified, got TTL %v", ttl)
+	}
+
+	if got := c.get(question("www.bing.com.", dns.TypeAAAA)); got != nil {
+		t.Errorf("unexpected cached response for a different type: %v", got)
+	}
+	edns := question("www.bing.com.", dns.TypeA)
+	edns.SetEdns0(dns.DefaultMsgSize, false)
+	if got := c.get(edns); got != nil {
+		t.Errorf("unexpected cached response for an EDNS request: %v", got)
+	}
+
+	// The response expires with the lowest TTL of its records
+	*now = now.Add(50 * time.Second)
+	if got := c.get(question("www.bing.com.", dns.TypeA)); got != nil {
+		t.Errorf("unexpected cached response after expiry: %v", got)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	withRcode := func(rcode int, modify func(*dns.Msg)) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetRcode(question("example.com.", dns.TypeA), rcode)
+		if modify != nil {
+			modify(m)
+		}
+		return m
+	}
+	cases := []struct {
+		name     string
+		response *dns.Msg
+		expected time.Duration
+	}{
+		{
+			name: "positive",
+			response: withRcode(dns.RcodeSuccess, func(m *dns.Msg) {
+				m.Answer = a("example.com.", []net.IP{net.ParseIP("1.1.1.1").To4()}, 300)
+			}),
+			expected: 300 * time.Second,
+		},
+		{
+			name: "positive capped",
+			response: withRcode(dns.RcodeSuccess, func(m *dns.Msg) {
+				m.Answer = a("example.com.", []net.IP{net.ParseIP("1.1.1.1").To4()}, 86400)
+			}),
+			expected: maxCacheTTL,
+		},
+		{
+			name: "nxdomain uses soa minimum",
+			response: withRcode(dns.RcodeNameError, func(m *dns.Msg) {
+				m.Ns = []dns.RR{soa("example.com.", 3600, 60)}
+			}),
+			expected: 60 * time.Second,
+		},
+		{
+			name: "nodata uses soa ttl",
+			response: withRcode(dns.RcodeSuccess, func(m *dns.Msg) {
+				m.Ns = []dns.RR{soa("example.com.", 30, 600)}
+			}),
+			expected: 30 * time.Second,
+		},
+		{
+			name: "negative capped",
+			response: withRcode(dns.RcodeNameError, func(m *dns.Msg) {
+				m.Ns = []dns.RR{soa("example.com.", 86400, 86400)}
+			}),
+			expected: maxNegativeCacheTTL,
+		},
+		{
+			name:     "negative without soa",
+			response: withRcode(dns.RcodeNameError, nil),
+		},
+		{
+			name:     "server failure",
+			response: withRcode(dns.RcodeServerFailure, nil),
+		},
+		{
+			name: "truncated",
+			response: withRcode(dns.RcodeSuccess, func(m *dns.Msg) {
+				m.Answer = a("example.com.", []net.IP{net.ParseIP("1.1.1.1").To4()}, 300)
+				m.Truncated = true
+			}),
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheTTL(tt.response); got != tt.expected {
+				t.Errorf("expected TTL %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	c, _ := newTestCache(t, 1)
+	for _, host := range []string{"a.example.com.", "b.example.com."} {
+		req := question(host, dns.TypeA)
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.Answer = a(host, []net.IP{net.ParseIP("1.1.1.1").To4()}, 300)
+		c.add(req, response)
+	}
+	if got := c.get(question("a.example.com.", dns.TypeA)); got != nil {
+		t.Errorf("expected least recently used response to be evicted, got %v", got)
+	}
+	if got := c.get(question("b.example.com.", dns.TypeA)); got == nil {
+		t.Errorf("expected a cached response")
+	}
+}
@@ -16,13 +16,16 @@ package dns
 
 import (
 	"net"
+	"sort"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/miekg/dns"
 
 	nds "istio.io/istio/pilot/pkg/proto"
+	"istio.io/istio/pkg/config/protocol"
 	istiolog "istio.io/pkg/log"
 )
 
@@ -44,6 +47,20 @@ type LocalDNSServer struct {
 	// Optimizations to save space and time
 	proxyDomain      string
 	proxyDomainParts []string
+
+	// TTL of the records of hosts in the lookup table
+	recordTTL uint32
+	// Cache of the responses of the upstream servers, nil if disabled
+	upstreamCache *responseCache
+}
+
+// Options configures the TTLs and caching of the DNS proxy.
+type Options struct {
+	// RecordTTL is the TTL of the records of hosts known to the mesh. Defaults to 30s if unset.
+	RecordTTL time.Duration
+	// UpstreamCacheSize is the maximum number of responses of the upstream DNS servers that are cached.
+	// Zero disables caching.
+	UpstreamCacheSize int
 }
 
 // Borrowed from https://github.com/coredns/coredns/blob/master/plugin/hosts/hostsfile.go
@@ -63,18 +80,36 @@ type LookupTable struct {
 	// The cname records here (comprised of different variants of the hosts above,
 	// expanded by the search namespaces) pointing to the actual host.
 	cname map[string][]dns.RR
+	// The key is a reverse lookup name (like 1.0.0.10.in-addr.arpa.), the value is the PTR records
+	// pointing to the hosts having that IP.
+	ptr map[string][]dns.RR
+	// The key is a service name (like _http._tcp.productpage.ns1.svc.cluster.local.), the value is the
+	// SRV records pointing to the host and port.
+	srv map[string][]dns.RR
+	// The TTL of all the records above.
+	ttl uint32
 }
 
 const (
 	// In case the client decides to honor the TTL, keep it low so that we can always serve
 	// the latest IP for a host.
-	// TODO: make it configurable
 	defaultTTLInSeconds = 30
 )
 
-func NewLocalDNSServer(proxyNamespace, proxyDomain string) (*LocalDNSServer, error) {
+func NewLocalDNSServer(proxyNamespace, proxyDomain string, opts Options) (*LocalDNSServer, error) {
 	h := &LocalDNSServer{
 		proxyNamespace: proxyNamespace,
+		recordTTL:      defaultTTLInSeconds,
+	}
+	if opts.RecordTTL >= time.Second {
+		h.recordTTL = uint32(opts.RecordTTL / time.Second)
+	}
+	if opts.UpstreamCacheSize > 0 {
+		cache, err := newResponseCache(opts.UpstreamCacheSize)
+		if err != nil {
+			return nil, err
+		}
+		h.upstreamCache = cache
 	}
 
 	// proxyDomain could contain the namespace making it redundant.
@@ -133,6 +168,9 @@ func (h *LocalDNSServer) UpdateLookupTable(nt *nds.NameTable) {
 		name4:    map[string][]dns.RR{},
 		name6:    map[string][]dns.RR{},
 		cname:    map[string][]dns.RR{},
+		ptr:      map[string][]dns.RR{},
+		srv:      map[string][]dns.RR{},
+		ttl:      h.recordTTL,
 	}
 	for host, ni := range nt.Table {
 		// Given a host
@@ -151,6 +189,15 @@ func (h *LocalDNSServer) UpdateLookupTable(nt *nds.NameTable) {
 			continue
 		}
 		lookupTable.buildDNSAnswers(altHosts, ipv4, ipv6, h.searchNamespaces)
+		lookupTable.buildPTRAnswers(host+".", append(ipv4, ipv6...))
+		lookupTable.buildSRVAnswers(altHosts, host+".", ni.Ports)
+	}
+	// Hosts sharing an IP (i.e. a headless service and its pods) are added in random order,
+	// sort them so that reverse lookups are stable.
+	for _, answers := range lookupTable.ptr {
+		sort.Slice(answers, func(i, j int) bool {
+			return answers[i].(*dns.PTR).Ptr < answers[j].(*dns.PTR).Ptr
+		})
 	}
 	h.lookupTable.Store(lookupTable)
 	log.Debugf("updated lookup table with %d hosts", len(lookupTable.allHosts))
@@ -294,6 +341,15 @@ func (h *LocalDNSServer) Close() {
 
 // TODO: Figure out how to send parallel queries to all nameservers
 func (h *LocalDNSServer) queryUpstream(upstreamClient *dns.Client, req *dns.Msg, scope *istiolog.Scope) *dns.Msg {
+	if h.upstreamCache != nil {
+		if response := h.upstreamCache.get(req); response != nil {
+			upstreamCacheHits.Increment()
+			scope.Debugf("found upstream response in cache")
+			return response
+		}
+		upstreamCacheMisses.Increment()
+	}
+
 	var response *dns.Msg
 	for _, upstream := range h.resolvConfServers {
 		cResponse, _, err := upstreamClient.Exchange(req, upstream)
@@ -308,6 +364,8 @@ func (h *LocalDNSServer) queryUpstream(upstreamClient *dns.Client, req *dns.Msg,
 		response = new(dns.Msg)
 		response.SetReply(req)
 		response.Rcode = dns.RcodeServerFailure
+	} else if h.upstreamCache != nil {
+		h.upstreamCache.add(req, response)
 	}
 	return response
 }
@@ -368,25 +426,28 @@ func (table *LookupTable) lookupHost(qtype uint16, hostname string) ([]dns.RR, b
 		// this was a cname match
 		hostname = cn[0].(*dns.CNAME).Target
 	}
-	var ipAnswers []dns.RR
+	var answers []dns.RR
 	switch qtype {
 	case dns.TypeA:
-		ipAnswers = table.name4[hostname]
+		answers = table.name4[hostname]
 	case dns.TypeAAAA:
-		ipAnswers = table.name6[hostname]
+		answers = table.name6[hostname]
+	case dns.TypePTR:
+		answers = table.ptr[hostname]
+	case dns.TypeSRV:
+		answers = table.srv[hostname]
 	default:
-		// TODO: handle PTR records for reverse dns lookups
 		return nil, false
 	}
 
-	if len(ipAnswers) > 0 {
+	if len(answers) > 0 {
 		// We will return a chained response. In a chained response, the first entry is the cname record,
 		// and the second one is the A/AAAA record itself. Some clients do not follow cname redirects
 		// with additional DNS queries. Instead, they expect all the resolved records to be in the same
 		// big DNS response (presumably assuming that a recursive DNS query should do the deed, resolve
 		// cname et al and return the composite response).
 		out = append(out, cn...)
-		out = append(out, ipAnswers...)
+		out = append(out, answers...)
 	}
 	return out, hostFound
 }
@@ -411,10 +472,10 @@ func (table *LookupTable) buildDNSAnswers(altHosts map[string]struct{}, ipv4 []n
 		h = strings.ToLower(h)
 		table.allHosts[h] = struct{}{}
 		if len(ipv4) > 0 {
-			table.name4[h] = a(h, ipv4)
+			table.name4[h] = a(h, ipv4, table.ttl)
 		}
 		if len(ipv6) > 0 {
-			table.name6[h] = aaaa(h, ipv6)
+			table.name6[h] = aaaa(h, ipv6, table.ttl)
 		}
 		if len(searchNamespaces) > 0 {
 			// NOTE: Right now, rather than storing one expanded host for each one of the search namespace
@@ -432,20 +493,54 @@ func (table *LookupTable) buildDNSAnswers(altHosts map[string]struct{}, ipv4 []n
 			// then the expanded host productpage.ns1.svc.cluster.local is a valid hostname
 			// that is likely to be already present in the altHosts
 			if _, exists := altHosts[expandedHost]; !exists {
-				table.cname[expandedHost] = cname(expandedHost, h)
+				table.cname[expandedHost] = cname(expandedHost, h, table.ttl)
 				table.allHosts[expandedHost] = struct{}{}
 			}
 		}
 	}
 }
 
+// buildPTRAnswers stores the PTR records for reverse lookups of the IPs of a host.
+// The host is the name sent by istiod, rather than any of its alternate names.
+func (table *LookupTable) buildPTRAnswers(host string, ips []net.IP) {
+	host = strings.ToLower(host)
+	for _, ip := range ips {
+		reverse, err := dns.ReverseAddr(ip.String())
+		if err != nil {
+			continue
+		}
+		table.allHosts[reverse] = struct{}{}
+		table.ptr[reverse] = append(table.ptr[reverse], ptr(reverse, host, table.ttl))
+	}
+}
+
+// buildSRVAnswers stores the SRV records of the named ports of a host, following the Kubernetes
+// naming convention of _<port name>._<protocol>.<host>, for each of the alternate names of the host.
+func (table *LookupTable) buildSRVAnswers(altHosts map[string]struct{}, host string, ports []*nds.NameTable_Port) {
+	host = strings.ToLower(host)
+	for _, port := range ports {
+		if port.Name == "" || port.Port == 0 {
+			continue
+		}
+		proto := "_tcp."
+		if protocol.Parse(port.Protocol) == protocol.UDP {
+			proto = "_udp."
+		}
+		for h := range altHosts {
+			name := strings.ToLower("_" + port.Name + "." + proto + h)
+			table.allHosts[name] = struct{}{}
+			table.srv[name] = srv(name, host, port.Port, table.ttl)
+		}
+	}
+}
+
 // Borrowed from https://github.com/coredns/coredns/blob/master/plugin/hosts/hosts.go
 // a takes a slice of net.IPs and returns a slice of A RRs.
-func a(host string, ips []net.IP) []dns.RR {
+func a(host string, ips []net.IP, ttl uint32) []dns.RR {
 	answers := make([]dns.RR, len(ips))
 	for i, ip := range ips {
 		r := new(dns.A)
-		r.Hdr = dns.RR_Header{Name: host, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: defaultTTLInSeconds}
+		r.Hdr = dns.RR_Header{Name: host, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl}
 		r.A = ip
 		answers[i] = r
 	}
@@ -453,25 +548,52 @@ func a(host string, ips []net.IP) []dns.RR {
 }
 
 // aaaa takes a slice of net.IPs and returns a slice of AAAA RRs.
-func aaaa(host string, ips []net.IP) []dns.RR {
+func aaaa(host string, ips []net.IP, ttl uint32) []dns.RR {
 	answers := make([]dns.RR, len(ips))
 	for i, ip := range ips {
 		r := new(dns.AAAA)
-		r.Hdr = dns.RR_Header{Name: host, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: defaultTTLInSeconds}
+		r.Hdr = dns.RR_Header{Name: host, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: ttl}
 		r.AAAA = ip
 		answers[i] = r
 	}
 	return answers
 }
 
-func cname(host string, targetHost string) []dns.RR {
+func cname(host string, targetHost string, ttl uint32) []dns.RR {
 	answer := new(dns.CNAME)
 	answer.Hdr = dns.RR_Header{
 		Name:   host,
 		Rrtype: dns.TypeCNAME,
 		Class:  dns.ClassINET,
-		Ttl:    defaultTTLInSeconds,
+		Ttl:    ttl,
+	}
+	answer.Target = targetHost
+	return []dns.RR{answer}
+}
+
+func ptr(reverse string, targetHost string, ttl uint32) dns.RR {
+	answer := new(dns.PTR)
+	answer.Hdr = dns.RR_Header{
+		Name:   reverse,
+		Rrtype: dns.TypePTR,
+		Class:  dns.ClassINET,
+		Ttl:    ttl,
+	}
+	answer.Ptr = targetHost
+	return answer
+}
+
+func srv(name string, targetHost string, port uint32, ttl uint32) []dns.RR {
+	answer := new(dns.SRV)
+	answer.Hdr = dns.RR_Header{
+		Name:   name,
+		Rrtype: dns.TypeSRV,
+		Class:  dns.ClassINET,
+		Ttl:    ttl,
 	}
+	answer.Priority = 0
+	answer.Weight = 100
+	answer.Port = uint16(port)
 	answer.Target = targetHost
 	return []dns.RR{answer}
 }
@@ -38,6 +38,7 @@ func TestDNS(t *testing.T) {
 		host                     string
 		id                       int
 		queryAAAA                bool
+		qtype                    uint16
 		expected                 []dns.RR
 		expectResolutionFailure  int
 		expectExternalResolution bool
@@ -46,13 +47,13 @@ func TestDNS(t *testing.T) {
 		{
 			name:     "success: non k8s host in local cache",
 			host:     "www.google.com.",
-			expected: a("www.google.com.", []net.IP{net.ParseIP("1.1.1.1").To4()}),
+			expected: a("www.google.com.", []net.IP{net.ParseIP("1.1.1.1").To4()}, defaultTTLInSeconds),
 		},
 		{
 			name: "success: non k8s host with search namespace yields cname+A record",
 			host: "www.google.com.ns1.svc.cluster.local.",
-			expected: append(cname("www.google.com.ns1.svc.cluster.local.", "www.google.com."),
-				a("www.google.com.", []net.IP{net.ParseIP("1.1.1.1").To4()})...),
+			expected: append(cname("www.google.com.ns1.svc.cluster.local.", "www.google.com.", defaultTTLInSeconds),
+				a("www.google.com.", []net.IP{net.ParseIP("1.1.1.1").To4()}, defaultTTLInSeconds)...),
 		},
 		{
 			name:                     "success: non k8s host not in local cache",
@@ -62,23 +63,23 @@ func TestDNS(t *testing.T) {
 		{
 			name:     "success: k8s host - fqdn",
 			host:     "productpage.ns1.svc.cluster.local.",
-			expected: a("productpage.ns1.svc.cluster.local.", []net.IP{net.ParseIP("9.9.9.9").To4()}),
+			expected: a("productpage.ns1.svc.cluster.local.", []net.IP{net.ParseIP("9.9.9.9").To4()}, defaultTTLInSeconds),
 		},
 		{
 			name:     "success: k8s host - name.namespace",
 			host:     "productpage.ns1.",
-			expected: a("productpage.ns1.", []net.IP{net.ParseIP("9.9.9.9").To4()}),
+			expected: a("productpage.ns1.", []net.IP{net.ParseIP("9.9.9.9").To4()}, defaultTTLInSeconds),
 		},
 		{
 			name:     "success: k8s host - shortname",
 			host:     "productpage.",
-			expected: a("productpage.", []net.IP{net.ParseIP("9.9.9.9").To4()}),
+			expected: a("productpage.", []net.IP{net.ParseIP("9.9.9.9").To4()}, defaultTTLInSeconds),
 		},
 		{
 			name: "success: k8s host (name.namespace) with search namespace yields cname+A record",
 			host: "productpage.ns1.ns1.svc.cluster.local.",
-			expected: append(cname("productpage.ns1.ns1.svc.cluster.local.", "productpage.ns1."),
-				a("productpage.ns1.", []net.IP{net.ParseIP("9.9.9.9").To4()})...),
+			expected: append(cname("productpage.ns1.ns1.svc.cluster.local.", "productpage.ns1.", defaultTTLInSeconds),
+				a("productpage.ns1.", []net.IP{net.ParseIP("9.9.9.9").To4()}, defaultTTLInSeconds)...),
 		},
 		{
 			name:      "success: AAAA query for IPv4 k8s host (name.namespace) with search namespace",
@@ -88,17 +89,17 @@ func TestDNS(t *testing.T) {
 		{
 			name:     "success: k8s host - non local namespace - name.namespace",
 			host:     "example.ns2.",
-			expected: a("example.ns2.", []net.IP{net.ParseIP("10.10.10.10").To4()}),
+			expected: a("example.ns2.", []net.IP{net.ParseIP("10.10.10.10").To4()}, defaultTTLInSeconds),
 		},
 		{
 			name:     "success: k8s host - non local namespace - fqdn",
 			host:     "example.ns2.svc.cluster.local.",
-			expected: a("example.ns2.svc.cluster.local.", []net.IP{net.ParseIP("10.10.10.10").To4()}),
+			expected: a("example.ns2.svc.cluster.local.", []net.IP{net.ParseIP("10.10.10.10").To4()}, defaultTTLInSeconds),
 		},
 		{
 			name:     "success: k8s host - non local namespace - name.namespace.svc",
 			host:     "example.ns2.svc.",
-			expected: a("example.ns2.svc.", []net.IP{net.ParseIP("10.10.10.10").To4()}),
+			expected: a("example.ns2.svc.", []net.IP{net.ParseIP("10.10.10.10").To4()}, defaultTTLInSeconds),
 		},
 		{
 			name:                    "failure: k8s host - non local namespace - shortname",
@@ -115,7 +116,7 @@ func TestDNS(t *testing.T) {
 					net.ParseIP("14.14.14.14").To4(),
 					net.ParseIP("12.12.12.12").To4(),
 					net.ParseIP("11.11.11.11").To4(),
-				}),
+				}, defaultTTLInSeconds),
 		},
 		{
 			name: "success: remote cluster k8s svc round robin",
@@ -127,7 +128,7 @@ func TestDNS(t *testing.T) {
 					net.ParseIP("14.14.14.14").To4(),
 					net.ParseIP("11.11.11.11").To4(),
 					net.ParseIP("12.12.12.12").To4(),
-				}),
+				}, defaultTTLInSeconds),
 		},
 		{
 			name:                    "failure: remote cluster k8s svc - same ns and different domain - name.namespace",
@@ -137,13 +138,13 @@ func TestDNS(t *testing.T) {
 		{
 			name:     "success: TypeA query returns A records only",
 			host:     "dual.localhost.",
-			expected: a("dual.localhost.", []net.IP{net.ParseIP("2.2.2.2").To4()}),
+			expected: a("dual.localhost.", []net.IP{net.ParseIP("2.2.2.2").To4()}, defaultTTLInSeconds),
 		},
 		{
 			name:      "success: TypeAAAA query returns AAAA records only",
 			host:      "dual.localhost.",
 			queryAAAA: true,
-			expected:  aaaa("dual.localhost.", []net.IP{net.ParseIP("2001:db8:0:0:0:ff00:42:8329")}),
+			expected:  aaaa("dual.localhost.", []net.IP{net.ParseIP("2001:db8:0:0:0:ff00:42:8329")}, defaultTTLInSeconds),
 		},
 		{
 			// This is not a NXDOMAIN, but empty response
@@ -156,6 +157,45 @@ func TestDNS(t *testing.T) {
 			host:      "ipv4.localhost.",
 			queryAAAA: true,
 		},
+		{
+			name:     "success: PTR query for k8s service IP",
+			host:     "9.9.9.9.in-addr.arpa.",
+			qtype:    dns.TypePTR,
+			expected: []dns.RR{ptr("9.9.9.9.in-addr.arpa.", "productpage.ns1.svc.cluster.local.", defaultTTLInSeconds)},
+		},
+		{
+			name:  "success: PTR query for IP shared by multiple hosts",
+			host:  "9.2.3.8.2.4.0.0.0.0.f.f.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+			qtype: dns.TypePTR,
+			expected: []dns.RR{
+				ptr("9.2.3.8.2.4.0.0.0.0.f.f.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", "dual.localhost.", defaultTTLInSeconds),
+				ptr("9.2.3.8.2.4.0.0.0.0.f.f.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", "ipv6.localhost.", defaultTTLInSeconds),
+			},
+		},
+		{
+			name:     "success: SRV query for k8s host - fqdn",
+			host:     "_http._tcp.productpage.ns1.svc.cluster.local.",
+			qtype:    dns.TypeSRV,
+			expected: srv("_http._tcp.productpage.ns1.svc.cluster.local.", "productpage.ns1.svc.cluster.local.", 9080, defaultTTLInSeconds),
+		},
+		{
+			name:     "success: SRV query for k8s host - shortname",
+			host:     "_http._tcp.productpage.",
+			qtype:    dns.TypeSRV,
+			expected: srv("_http._tcp.productpage.", "productpage.ns1.svc.cluster.local.", 9080, defaultTTLInSeconds),
+		},
+		{
+			name:     "success: SRV query for UDP port of non k8s host",
+			host:     "_dns._udp.www.google.com.",
+			qtype:    dns.TypeSRV,
+			expected: srv("_dns._udp.www.google.com.", "www.google.com.", 53, defaultTTLInSeconds),
+		},
+		{
+			// This is not a NXDOMAIN, but empty response
+			name:  "success: SRV query for host without ports",
+			host:  "example.ns2.svc.cluster.local.",
+			qtype: dns.TypeSRV,
+		},
 		{
 			name: "udp: large request",
 			host: "giant.",
@@ -213,6 +253,9 @@ func TestDNS(t *testing.T) {
 				if tt.queryAAAA {
 					q = dns.TypeAAAA
 				}
+				if tt.qtype != 0 {
+					q = tt.qtype
+				}
 				m.SetQuestion(tt.host, q)
 				if tt.modifyReq != nil {
 					tt.modifyReq(m)
@@ -322,7 +365,7 @@ var giantResponse = func() []dns.RR {
 	for i := 0; i < 64; i++ {
 		ips = append(ips, net.ParseIP(fmt.Sprintf("240.0.0.%d", i)).To4())
 	}
-	return a("aaaaaaaaaaaa.aaaaaa.", ips)
+	return a("aaaaaaaaaaaa.aaaaaa.", ips, defaultTTLInSeconds)
 }()
 
 func makeUpstream(t test.Failer, responses map[string]string) string {
@@ -338,7 +381,7 @@ func makeUpstream(t test.Failer, responses map[string]string) string {
 	for hn, desiredResp := range responses {
 		mux.HandleFunc(hn, func(resp dns.ResponseWriter, msg *dns.Msg) {
 			answer := dns.Msg{
-				Answer: a(hn, []net.IP{net.ParseIP(desiredResp).To4()}),
+				Answer: a(hn, []net.IP{net.ParseIP(desiredResp).To4()}, defaultTTLInSeconds),
 			}
 			answer.SetReply(msg)
 			answer.Rcode = dns.RcodeSuccess
@@ -399,7 +442,7 @@ func makeUpstream(t test.Failer, responses map[string]string) string {
 
 func initDNS(t test.Failer) *LocalDNSServer {
 	srv := makeUpstream(t, map[string]string{"www.bing.com.": "1.1.1.1"})
-	testAgentDNS, err := NewLocalDNSServer("ns1", "ns1.svc.cluster.local")
+	testAgentDNS, err := NewLocalDNSServer("ns1", "ns1.svc.cluster.local", Options{UpstreamCacheSize: 100})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -411,12 +454,14 @@ func initDNS(t test.Failer) *LocalDNSServer {
 			"www.google.com": {
 				Ips:      []string{"1.1.1.1"},
 				Registry: "External",
+				Ports:    []*nds.NameTable_Port{{Name: "dns", Port: 53, Protocol: "UDP"}},
 			},
 			"productpage.ns1.svc.cluster.local": {
 				Ips:       []string{"9.9.9.9"},
 				Registry:  "Kubernetes",
 				Namespace: "ns1",
 				Shortname: "productpage",
+				Ports:     []*nds.NameTable_Port{{Name: "http", Port: 9080, Protocol: "HTTP"}},
 			},
 			"example.ns2.svc.cluster.local": {
 				Ips:       []string{"10.10.10.10"},
@@ -0,0 +1,37 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	cacheResultTag = monitoring.MustCreateLabel("result")
+
+	upstreamCacheRequests = monitoring.NewSum(
+		"dns_upstream_cache_requests",
+		"The total number of DNS requests forwarded to upstream servers that were looked up in the agent cache, "+
+			"by result (hit or miss).",
+		monitoring.WithLabels(cacheResultTag),
+	)
+
+	upstreamCacheHits   = upstreamCacheRequests.With(cacheResultTag.Value("hit"))
+	upstreamCacheMisses = upstreamCacheRequests.With(cacheResultTag.Value("miss"))
+)
+
+func init() {
+	monitoring.MustRegister(upstreamCacheRequests)
+}
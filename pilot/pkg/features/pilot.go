@@ -64,6 +64,20 @@ var (
 		"Limits the number of concurrent pushes allowed. On larger machines this can be increased for faster pushes",
 	).Get()
 
+	ProxyPushDebounce = env.RegisterDurationVar(
+		"PILOT_PROXY_PUSH_DEBOUNCE",
+		0,
+		"The minimum time between two pushes to the same proxy. Updates received in between are merged into a "+
+			"single push. Zero disables the per proxy debouncing.",
+	).Get()
+
+	PushRateLimit = env.RegisterFloatVar(
+		"PILOT_PUSH_RATE_LIMIT",
+		0,
+		"Limits the number of pushes per second, across all proxies. When limited, gateways and proxies "+
+			"pending full pushes are pushed first. Zero disables the limit.",
+	).Get()
+
 	// MaxRecvMsgSize The max receive buffer size of gRPC received channel of Pilot in bytes.
 	MaxRecvMsgSize = env.RegisterIntVar(
 		"ISTIO_GPRC_MAXRECVMSGSIZE",
@@ -228,6 +242,13 @@ var (
 			"Istio Resources",
 	).Get()
 
+	AnalysisCustomAnalyzers = env.RegisterStringVar(
+		"PILOT_ANALYSIS_CUSTOM_ANALYZERS",
+		"",
+		"Comma separated list of files, or directories of files such as a mounted ConfigMap, declaring custom "+
+			"analyzers to run in addition to the built-in ones when PILOT_ENABLE_ANALYSIS is enabled.",
+	).Get()
+
 	EnableStatus = env.RegisterBoolVar(
 		"PILOT_ENABLE_STATUS",
 		false,
@@ -275,6 +296,11 @@ var (
 		"If this is set to true, support for Kubernetes gateway-api (github.com/kubernetes-sigs/gateway-api) will "+
 			" be enabled. In addition to this being enabled, the gateway-api CRDs need to be installed.").Get()
 
+	EnableGatewayAPIDeploymentController = env.RegisterBoolVar("PILOT_ENABLE_GATEWAY_API_DEPLOYMENT_CONTROLLER", false,
+		"If this is set to true, istiod will provision a Deployment, Service, and ServiceAccount for each gateway-api "+
+			"Gateway whose GatewayClass has the gateway.istio.io/provision annotation set to true. "+
+			"This requires PILOT_ENABLED_SERVICE_APIS.").Get()
+
 	EnableVirtualServiceDelegate = env.RegisterBoolVar(
 		"PILOT_ENABLE_VIRTUAL_SERVICE_DELEGATE",
 		true,
@@ -333,6 +359,17 @@ var (
 	XDSCacheMaxSize = env.RegisterIntVar("PILOT_XDS_CACHE_SIZE", 20000,
 		"The maximum number of cache entries for the XDS cache.").Get()
 
+	EnablePersistentXDSCache = env.RegisterBoolVar("PILOT_ENABLE_PERSISTENT_XDS_CACHE", false,
+		"If true, Pilot will store cached XDS responses in a second tier store, shared across restarts and, "+
+			"depending on the backend, replicas. Requires PILOT_ENABLE_XDS_CACHE.").Get()
+
+	PersistentXDSCacheBackend = env.RegisterStringVar("PILOT_PERSISTENT_XDS_CACHE_BACKEND", "disk",
+		"The backend of the persistent XDS cache.").Get()
+
+	PersistentXDSCacheDir = env.RegisterStringVar("PILOT_PERSISTENT_XDS_CACHE_DIR", "/var/lib/istio/xds-cache",
+		"The directory storing the persistent XDS cache, when using the disk backend. Mount a volume shared by "+
+			"the Istiod replicas to share the cache between them.").Get()
+
 	// EnableLegacyFSGroupInjection has first-party-jwt as allowed because we only
 	// need the fsGroup configuration for the projected service account volume mount,
 	// which is only used by first-party-jwt. The installer will automatically
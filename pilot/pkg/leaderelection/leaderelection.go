@@ -37,6 +37,8 @@ const (
 	IngressController = "istio-leader"
 	StatusController  = "istio-status-leader"
 	AnalyzeController = "istio-analyze-leader"
+	// GatewayDeploymentController provisions workloads for gateway-api Gateways.
+	GatewayDeploymentController = "istio-gateway-deployment-leader"
 )
 
 type LeaderElection struct {
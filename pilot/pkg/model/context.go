@@ -167,6 +167,9 @@ func (e *Environment) SetLedger(l ledger.Ledger) {
 // Resources is an alias for array of marshaled resources.
 type Resources = []*discovery.Resource
 
+// DeletedResources is an alias for array of strings that represent removed resources in delta.
+type DeletedResources = []string
+
 func AnyToUnnamedResources(r []*any.Any) Resources {
 	a := make(Resources, 0, len(r))
 	for _, rr := range r {
@@ -206,6 +209,16 @@ type XdsResourceGenerator interface {
 	Generate(proxy *Proxy, push *PushContext, w *WatchedResource, updates *PushRequest) (Resources, XdsLogDetails, error)
 }
 
+// XdsDeltaResourceGenerator generates Sotw and delta resources.
+// GenerateDeltas is only invoked for connections using the delta xDS protocol. Generators that cannot compute
+// a delta for the given updates should fall back to generating the full set of resources and return
+// usedDelta=false, in which case removed resources are computed from the WatchedResource by the caller.
+type XdsDeltaResourceGenerator interface {
+	XdsResourceGenerator
+	// GenerateDeltas returns the changed and removed resources, along with whether or not delta was actually used.
+	GenerateDeltas(proxy *Proxy, push *PushContext, updates *PushRequest, w *WatchedResource) (Resources, DeletedResources, XdsLogDetails, bool, error)
+}
+
 // Proxy contains information about an specific instance of a proxy (envoy sidecar, gateway,
 // etc). The Proxy is initialized when a sidecar connects to Pilot, and populated from
 // 'node' info in the protocol as well as data extracted from registries.
@@ -201,6 +201,13 @@ func mergeHTTPRoutes(root *networking.HTTPRoute, delegate []*networking.HTTPRout
 	return out
 }
 
+// MergeHTTPRoute returns the route that results from merging a route of a delegate VirtualService into the root
+// route which delegates to it, or nil if the delegate route's match conflicts with the root and the route is ignored.
+// Neither route is modified.
+func MergeHTTPRoute(root *networking.HTTPRoute, delegate *networking.HTTPRoute) *networking.HTTPRoute {
+	return mergeHTTPRoute(root, delegate.DeepCopy())
+}
+
 // merge the two HTTPRoutes, if there is a conflict with root, the delegate route is ignored
 func mergeHTTPRoute(root *networking.HTTPRoute, delegate *networking.HTTPRoute) *networking.HTTPRoute {
 	// suppose there are N1 match conditions in root, N2 match conditions in delegate
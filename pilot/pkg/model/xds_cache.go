@@ -148,9 +148,19 @@ type XdsCache interface {
 
 // NewXdsCache returns an instance of a cache.
 func NewXdsCache() XdsCache {
+	return NewXdsCacheWithBackend(nil)
+}
+
+// NewXdsCacheWithBackend returns an instance of a cache backed by a second tier store. Entries
+// missing from the in memory cache are looked up in the backend, and entries added to the cache are
+// written through to it. Clear and ClearAll start a new generation of the backend, so that the
+// entries generated before are ignored by all the instances sharing it, including the ones started
+// later. If backend is nil, this is equivalent to NewXdsCache.
+func NewXdsCacheWithBackend(backend XdsCacheBackend) XdsCache {
 	return &lruCache{
 		enableAssertions: features.EnableUnsafeAssertions,
 		store:            newLru(),
+		backend:          backend,
 		configIndex:      map[ConfigKey]sets.Set{},
 		typesIndex:       map[config.GroupVersionKind]sets.Set{},
 		nextToken:        atomic.NewUint64(0),
@@ -171,6 +181,11 @@ func NewLenientXdsCache() XdsCache {
 type lruCache struct {
 	enableAssertions bool
 	store            simplelru.LRUCache
+	// backend is the optional second tier store. Writes and invalidations are only made while
+	// holding mu, so that they reach it in the same order as the in memory store. Reads are made
+	// without holding mu, as they may be slow. It is reset if an invalidation fails, as the entries
+	// of the backend can no longer be trusted.
+	backend XdsCacheBackend
 	// nextToken stores the next token to use. The content here doesn't matter, we just need a cheap
 	// unique identifier.
 	nextToken   *atomic.Uint64
@@ -231,6 +246,8 @@ func (l *lruCache) Add(entry XdsCacheEntry, token CacheToken, value *discovery.R
 		// Otherwise, make sure we write the current token again. We don't change the key on writes; the
 		// same token will be used for a value until its invalidated
 		toWrite.token = cur.(cacheValue).token
+		toWrite.generation = cur.(cacheValue).generation
+		toWrite.persist = cur.(cacheValue).persist
 	} else {
 		// This is our first time seeing this; this means it was invalidated recently and this is our
 		// first write, or we forgot to call Get before.
@@ -245,12 +262,27 @@ func (l *lruCache) Add(entry XdsCacheEntry, token CacheToken, value *discovery.R
 	l.store.Add(k, toWrite)
 	indexConfig(l.configIndex, entry.Key(), entry)
 	indexType(l.typesIndex, entry.Key(), entry)
+	// The token guards against local invalidations, and the generation against the ones of the other
+	// instances sharing the backend, as the backend drops the entries of older generations.
+	if l.backend != nil && toWrite.persist {
+		l.backend.Put(&XdsCacheBackendEntry{
+			Key:              k,
+			DependentConfigs: entry.DependentConfigs(),
+			DependentTypes:   entry.DependentTypes(),
+			Generation:       toWrite.generation,
+			Value:            value,
+		})
+	}
 	size(l.store.Len())
 }
 
 type cacheValue struct {
 	value *discovery.Resource
 	token CacheToken
+	// generation is the generation of the backend when the token was generated. persist is set if
+	// the generation is known and the value can be written to the backend.
+	generation uint64
+	persist    bool
 }
 
 func (l *lruCache) Get(entry XdsCacheEntry) (*discovery.Resource, CacheToken, bool) {
@@ -258,17 +290,22 @@ func (l *lruCache) Get(entry XdsCacheEntry) (*discovery.Resource, CacheToken, bo
 		return nil, 0, false
 	}
 	l.mu.Lock()
-	defer l.mu.Unlock()
 	k := entry.Key()
 	val, ok := l.store.Get(k)
 	if !ok {
-		miss()
 		// If the entry is not found at all, this is our first read of it. We will generate and store
 		// a new token. Subsequent writes must include it.
 		tok := CacheToken(l.nextToken.Inc())
 		l.store.Add(k, cacheValue{token: tok})
+		l.mu.Unlock()
+		if v := l.getFromBackend(entry, tok); v != nil {
+			hit()
+			return v, tok, true
+		}
+		miss()
 		return nil, tok, false
 	}
+	defer l.mu.Unlock()
 	cv := val.(cacheValue)
 	if cv.value == nil {
 		miss()
@@ -283,6 +320,9 @@ func (l *lruCache) Get(entry XdsCacheEntry) (*discovery.Resource, CacheToken, bo
 func (l *lruCache) Clear(configs map[ConfigKey]struct{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	// Entries depending on the configs may have been written to the backend by other instances
+	// without being indexed here, so all of them are invalidated.
+	l.invalidateBackend()
 	for ckey := range configs {
 		referenced := l.configIndex[ckey]
 		delete(l.configIndex, ckey)
@@ -303,9 +343,74 @@ func (l *lruCache) ClearAll() {
 	defer l.mu.Unlock()
 	l.store.Purge()
 	l.configIndex = map[ConfigKey]sets.Set{}
+	l.typesIndex = map[config.GroupVersionKind]sets.Set{}
+	l.invalidateBackend()
 	size(l.store.Len())
 }
 
+// invalidateBackend starts a new generation of the backend. If this fails, the backend is no longer
+// used, as this instance could read or write entries generated before the invalidation.
+func (l *lruCache) invalidateBackend() {
+	if l.backend == nil {
+		return
+	}
+	if err := l.backend.Invalidate(); err != nil {
+		log.Errorf("failed to invalidate xds cache backend, using the in memory cache only: %v", err)
+		l.backend = nil
+	}
+}
+
+// getFromBackend returns the value of the entry stored in the backend in its current generation, if
+// any, and indexes it. The backend is read without holding mu; the value is only used if the entry
+// was not invalidated meanwhile, as tracked by the token returned by Get. The generation is recorded
+// for the token, so that the value generated by the caller on a miss is written in that generation.
+func (l *lruCache) getFromBackend(entry XdsCacheEntry, tok CacheToken) *discovery.Resource {
+	l.mu.RLock()
+	backend := l.backend
+	l.mu.RUnlock()
+	if backend == nil || isSensitive(entry) {
+		return nil
+	}
+	generation, err := backend.Generation()
+	if err != nil {
+		log.Warnf("failed to get the generation of the xds cache backend: %v", err)
+		return nil
+	}
+	be, f := backend.Get(entry.Key())
+	if f && (be.Value == nil || be.Generation != generation) {
+		f = false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cur, found := l.store.Get(entry.Key())
+	if !found || cur.(cacheValue).token != tok || cur.(cacheValue).value != nil || l.backend != backend {
+		return nil
+	}
+	if !f {
+		l.store.Add(entry.Key(), cacheValue{token: tok, generation: generation, persist: true})
+		return nil
+	}
+	// The entry may have been written by another instance, so index it with its stored dependencies.
+	l.index(be)
+	l.store.Add(be.Key, cacheValue{value: be.Value, token: tok, generation: generation})
+	return be.Value
+}
+
+func (l *lruCache) index(be *XdsCacheBackendEntry) {
+	for _, ckey := range be.DependentConfigs {
+		if l.configIndex[ckey] == nil {
+			l.configIndex[ckey] = sets.NewSet()
+		}
+		l.configIndex[ckey].Insert(be.Key)
+	}
+	for _, t := range be.DependentTypes {
+		if l.typesIndex[t] == nil {
+			l.typesIndex[t] = sets.NewSet()
+		}
+		l.typesIndex[t].Insert(be.Key)
+	}
+}
+
 func (l *lruCache) Keys() []string {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -0,0 +1,416 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/config"
+)
+
+// XdsCacheBackendEntry is an entry of a XdsCacheBackend.
+type XdsCacheBackendEntry struct {
+	// Key is the key of the XdsCacheEntry.
+	Key string
+	// DependentConfigs are the configs the entry depends on, used to invalidate it.
+	DependentConfigs []ConfigKey
+	// DependentTypes are the config types the entry depends on, used to invalidate it.
+	DependentTypes []config.GroupVersionKind
+	// Generation is the generation of the backend in which the generation of Value started.
+	Generation uint64
+	// Value is the cached response.
+	Value *discovery.Resource
+}
+
+// XdsCacheBackend is a second tier store for XDS responses, consulted when the in memory cache
+// misses. Unlike the in memory cache, it may outlive Istiod and be shared between replicas, so
+// that they do not all compute the same responses.
+//
+// The entries are only valid in the generation of the backend they were generated in. Every
+// invalidation of an instance starts a new generation, which invalidates all the entries for all the
+// instances sharing the backend, since they cannot tell which entries depend on the invalidated
+// configs. The generation must be shared by these instances rather than derived from their clocks.
+//
+// Put and Invalidate are called while holding the lock of the XdsCache, so they should be fast. Put
+// may persist asynchronously, but Get must reflect all prior calls.
+type XdsCacheBackend interface {
+	// Get returns the entry stored for the key, if any, whatever its generation.
+	Get(key string) (*XdsCacheBackendEntry, bool)
+	// Put stores the entry, unless it was generated in an older generation than the stored entry
+	// with the same key or than the current generation.
+	Put(entry *XdsCacheBackendEntry)
+	// Generation returns the current generation.
+	Generation() (uint64, error)
+	// Invalidate starts a new generation. The generation must have been started when it returns.
+	Invalidate() error
+	// Run runs any background work of the backend until stop is closed.
+	Run(stop <-chan struct{})
+}
+
+// SensitiveXdsCacheEntry is implemented by XdsCacheEntries whose values must not leave the process,
+// such as secrets. They are never stored in a XdsCacheBackend.
+type SensitiveXdsCacheEntry interface {
+	XdsCacheEntry
+	Sensitive() bool
+}
+
+func isSensitive(entry XdsCacheEntry) bool {
+	s, ok := entry.(SensitiveXdsCacheEntry)
+	return ok && s.Sensitive()
+}
+
+// XdsCacheBackendFactory creates a XdsCacheBackend.
+type XdsCacheBackendFactory func() (XdsCacheBackend, error)
+
+var (
+	xdsCacheBackendsMu sync.Mutex
+	xdsCacheBackends   = map[string]XdsCacheBackendFactory{
+		"disk": func() (XdsCacheBackend, error) {
+			return NewDiskXdsCacheBackend(features.PersistentXDSCacheDir)
+		},
+	}
+)
+
+// RegisterXdsCacheBackend registers a factory for the backend with the given name, which can then
+// be selected with PILOT_PERSISTENT_XDS_CACHE_BACKEND. It is meant to be called from init functions,
+// and panics if a backend with the same name is already registered.
+func RegisterXdsCacheBackend(name string, factory XdsCacheBackendFactory) {
+	xdsCacheBackendsMu.Lock()
+	defer xdsCacheBackendsMu.Unlock()
+	if _, f := xdsCacheBackends[name]; f {
+		panic(fmt.Sprintf("xds cache backend %q is already registered", name))
+	}
+	xdsCacheBackends[name] = factory
+}
+
+// NewXdsCacheBackend creates the backend registered with the given name.
+func NewXdsCacheBackend(name string) (XdsCacheBackend, error) {
+	xdsCacheBackendsMu.Lock()
+	factory, f := xdsCacheBackends[name]
+	xdsCacheBackendsMu.Unlock()
+	if !f {
+		return nil, fmt.Errorf("unknown xds cache backend %q", name)
+	}
+	return factory()
+}
+
+// diskXdsCacheBackend stores each entry in a file of a directory, and the current generation in the
+// generation file of the directory, updated under the lock file. Writes are persisted by a background
+// goroutine; until then they are kept in memory, so that Get never reads stale files. The entries of
+// older generations are removed periodically.
+type diskXdsCacheBackend struct {
+	dir string
+
+	mu sync.Mutex
+	// pending holds the writes not yet picked up by the flusher.
+	pending map[string]*XdsCacheBackendEntry
+	// flushing holds the writes being persisted by the flusher.
+	flushing map[string]*XdsCacheBackendEntry
+
+	notify chan struct{}
+}
+
+var _ XdsCacheBackend = &diskXdsCacheBackend{}
+
+const (
+	diskEntrySuffix        = ".json"
+	diskGenerationFile     = "generation"
+	diskGenerationLockFile = "generation.lock"
+
+	// diskLockTimeout is how long Invalidate waits for the lock file.
+	diskLockTimeout = 5 * time.Second
+	// diskStaleLockAge is the age after which the lock file is considered left over by a crashed
+	// instance, and removed. The lock is only held while writing the generation file.
+	diskStaleLockAge = 10 * time.Second
+	// diskCleanupInterval is the interval at which the entries of older generations are removed.
+	diskCleanupInterval = time.Minute
+)
+
+// NewDiskXdsCacheBackend returns a backend storing the entries in files of the given directory,
+// which is created if needed. The directory can be shared by multiple Istiod replicas.
+func NewDiskXdsCacheBackend(dir string) (XdsCacheBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create xds cache directory: %v", err)
+	}
+	return &diskXdsCacheBackend{
+		dir:     dir,
+		pending: map[string]*XdsCacheBackendEntry{},
+		notify:  make(chan struct{}, 1),
+	}, nil
+}
+
+// diskEntry is the format of the files of the disk backend.
+type diskEntry struct {
+	Key              string                    `json:"key"`
+	DependentConfigs []ConfigKey               `json:"dependentConfigs,omitempty"`
+	DependentTypes   []config.GroupVersionKind `json:"dependentTypes,omitempty"`
+	Generation       uint64                    `json:"generation"`
+	// Value is the binary encoded discovery.Resource.
+	Value []byte `json:"value"`
+}
+
+func (d *diskXdsCacheBackend) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+diskEntrySuffix)
+}
+
+func (d *diskXdsCacheBackend) Get(key string) (*XdsCacheBackendEntry, bool) {
+	d.mu.Lock()
+	e, f := d.pending[key]
+	if !f {
+		e, f = d.flushing[key]
+	}
+	d.mu.Unlock()
+	if f {
+		return e, true
+	}
+
+	e, err := d.read(d.path(key), true)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("failed to read xds cache entry %s: %v", key, err)
+		}
+		return nil, false
+	}
+	// Guard against hash collisions.
+	if e.Key != key {
+		return nil, false
+	}
+	return e, true
+}
+
+func (d *diskXdsCacheBackend) Put(entry *XdsCacheBackendEntry) {
+	d.mu.Lock()
+	if cur, f := d.pending[entry.Key]; !f || cur.Generation <= entry.Generation {
+		d.pending[entry.Key] = entry
+	}
+	d.mu.Unlock()
+	d.wake()
+}
+
+func (d *diskXdsCacheBackend) wake() {
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (d *diskXdsCacheBackend) Generation() (uint64, error) {
+	b, err := ioutil.ReadFile(filepath.Join(d.dir, diskGenerationFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	g, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid xds cache generation %q: %v", b, err)
+	}
+	return g, nil
+}
+
+func (d *diskXdsCacheBackend) Invalidate() error {
+	unlock, err := d.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	g, err := d.Generation()
+	if err != nil {
+		return err
+	}
+	return d.writeFile(filepath.Join(d.dir, diskGenerationFile), []byte(strconv.FormatUint(g+1, 10)))
+}
+
+// lock acquires the lock file of the generation, shared by the instances using the directory.
+func (d *diskXdsCacheBackend) lock() (func(), error) {
+	path := filepath.Join(d.dir, diskGenerationLockFile)
+	deadline := time.Now().Add(diskLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_ = f.Close()
+			return func() {
+				_ = os.Remove(path)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock xds cache generation: %v", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > diskStaleLockAge {
+			log.Warnf("removing stale xds cache generation lock %s", path)
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out locking xds cache generation %s", path)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (d *diskXdsCacheBackend) read(path string, withValue bool) (*XdsCacheBackendEntry, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	de := diskEntry{}
+	if err := json.Unmarshal(b, &de); err != nil {
+		return nil, fmt.Errorf("invalid xds cache entry %s: %v", path, err)
+	}
+	e := &XdsCacheBackendEntry{
+		Key:              de.Key,
+		DependentConfigs: de.DependentConfigs,
+		DependentTypes:   de.DependentTypes,
+		Generation:       de.Generation,
+	}
+	if withValue {
+		e.Value = &discovery.Resource{}
+		if err := proto.Unmarshal(de.Value, e.Value); err != nil {
+			return nil, fmt.Errorf("invalid xds cache entry %s: %v", path, err)
+		}
+	}
+	return e, nil
+}
+
+// Run persists the writes and removes the entries of older generations until stop is closed, and
+// then persists the remaining writes.
+func (d *diskXdsCacheBackend) Run(stop <-chan struct{}) {
+	cleanup := time.NewTicker(diskCleanupInterval)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-d.notify:
+			d.flush()
+		case <-cleanup.C:
+			d.cleanup()
+		case <-stop:
+			d.flush()
+			return
+		}
+	}
+}
+
+func (d *diskXdsCacheBackend) flush() {
+	d.mu.Lock()
+	d.flushing = d.pending
+	d.pending = map[string]*XdsCacheBackendEntry{}
+	d.mu.Unlock()
+
+	generation, err := d.Generation()
+	if err != nil {
+		log.Warnf("dropping xds cache writes: %v", err)
+	}
+	for k, e := range d.flushing {
+		if err != nil {
+			break
+		}
+		if err := d.apply(e, generation); err != nil {
+			log.Warnf("failed to persist xds cache entry %s: %v", k, err)
+		}
+	}
+
+	d.mu.Lock()
+	d.flushing = nil
+	d.mu.Unlock()
+}
+
+// apply persists the write, unless it is older than the current generation or than the entry in the
+// file, which may have been written by another instance.
+func (d *diskXdsCacheBackend) apply(e *XdsCacheBackendEntry, generation uint64) error {
+	if e.Generation < generation {
+		return nil
+	}
+	cur, err := d.read(d.path(e.Key), false)
+	if err != nil && !os.IsNotExist(err) {
+		log.Debugf("replacing xds cache entry: %v", err)
+	}
+	if err == nil && cur.Generation > e.Generation {
+		return nil
+	}
+	value, err := proto.Marshal(e.Value)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(diskEntry{
+		Key:              e.Key,
+		DependentConfigs: e.DependentConfigs,
+		DependentTypes:   e.DependentTypes,
+		Generation:       e.Generation,
+		Value:            value,
+	})
+	if err != nil {
+		return err
+	}
+	return d.writeFile(d.path(e.Key), b)
+}
+
+// cleanup removes the entries of older generations, which are never valid again.
+func (d *diskXdsCacheBackend) cleanup() {
+	generation, err := d.Generation()
+	if err != nil {
+		log.Warnf("failed to clean up xds cache: %v", err)
+		return
+	}
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		log.Warnf("failed to clean up xds cache: %v", err)
+		return
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), diskEntrySuffix) {
+			continue
+		}
+		path := filepath.Join(d.dir, f.Name())
+		if e, err := d.read(path, false); err == nil && e.Generation < generation {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Warnf("failed to remove xds cache entry %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// writeFile writes to a temporary file first, so that readers never see partial files.
+func (d *diskXdsCacheBackend) writeFile(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(d.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -34,8 +34,10 @@ import (
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/util/gogo"
 )
 
@@ -70,6 +72,76 @@ func getDefaultCircuitBreakerThresholds() *cluster.CircuitBreakers_Thresholds {
 // Cluster type based on resolution
 // For inbound (sidecar only): Cluster for each inbound endpoint port and for each service port
 func (configgen *ConfigGeneratorImpl) BuildClusters(proxy *model.Proxy, push *model.PushContext) []*cluster.Cluster {
+	return configgen.buildClusters(proxy, push, nil)
+}
+
+// BuildDeltaClusters generates the deltas (add and delete) for a given proxy. Currently, only service changes are
+// reflected with deltas: outbound clusters are only built for the updated services, while inbound and
+// special clusters are always rebuilt. Otherwise, we fall back onto generating everything.
+func (configgen *ConfigGeneratorImpl) BuildDeltaClusters(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+	watched *model.WatchedResource) ([]*cluster.Cluster, []string, bool) {
+	// if we can't use delta, fall back to generate all
+	if !shouldUseDelta(proxy, updates, watched) {
+		return configgen.BuildClusters(proxy, push), nil, false
+	}
+
+	// Clusters we currently know about, keyed by the hostname of the service they belong to.
+	serviceClusters := make(map[host.Name][]string)
+	// Candidates for removal. Anything we rebuild is removed from this set at the end.
+	deleted := sets.NewSet()
+	for _, name := range watched.ResourceNames {
+		dir, _, hostname, _ := model.ParseSubsetKey(name)
+		switch {
+		case dir == model.TrafficDirectionInbound:
+			// Inbound clusters are always rebuilt, so anything not rebuilt is stale.
+			deleted.Insert(name)
+		case hostname != "":
+			serviceClusters[hostname] = append(serviceClusters[hostname], name)
+		}
+	}
+
+	services := make([]*model.Service, 0, len(updates.ConfigsUpdated))
+	for key := range updates.ConfigsUpdated {
+		hostname := host.Name(key.Name)
+		// The service will be nil if it was removed or is no longer visible to this proxy.
+		if svc := push.ServiceForHostname(proxy, hostname); svc != nil {
+			services = append(services, svc)
+		}
+		// All existing clusters for the service, including subsets, are candidates for deletion. The
+		// ones still needed are rebuilt below.
+		deleted.Insert(serviceClusters[hostname]...)
+	}
+
+	clusters := configgen.buildClusters(proxy, push, services)
+	for _, c := range clusters {
+		deleted.Delete(c.Name)
+	}
+	return clusters, deleted.SortedList(), true
+}
+
+// shouldUseDelta returns true if the clusters for the proxy can be computed incrementally for the updates.
+func shouldUseDelta(proxy *model.Proxy, updates *model.PushRequest, watched *model.WatchedResource) bool {
+	if updates == nil || watched == nil || len(updates.ConfigsUpdated) == 0 {
+		return false
+	}
+	if proxy.Type == model.Router &&
+		(features.FilterGatewayClusterConfig || proxy.GetRouterMode() == model.SniDnatRouter) {
+		// The clusters depend on the full set of gateway services, so we cannot build them per service.
+		return false
+	}
+	for key := range updates.ConfigsUpdated {
+		// Service changes are the only updates keyed by hostname; anything else may impact any cluster.
+		if key.Kind != gvk.ServiceEntry {
+			return false
+		}
+	}
+	return true
+}
+
+// buildClusters builds the clusters for the proxy. If services is nil, outbound clusters are built for
+// every service visible to the proxy; otherwise only for the provided services.
+func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, push *model.PushContext,
+	services []*model.Service) []*cluster.Cluster {
 	clusters := make([]*cluster.Cluster, 0)
 	envoyFilterPatches := push.EnvoyFilters(proxy)
 	cb := NewClusterBuilder(proxy, push)
@@ -79,7 +151,7 @@ func (configgen *ConfigGeneratorImpl) BuildClusters(proxy *model.Proxy, push *mo
 	case model.SidecarProxy:
 		// Setup outbound clusters
 		outboundPatcher := clusterPatcher{efw: envoyFilterPatches, pctx: networking.EnvoyFilter_SIDECAR_OUTBOUND}
-		clusters = append(clusters, configgen.buildOutboundClusters(cb, outboundPatcher)...)
+		clusters = append(clusters, configgen.buildOutboundClusters(cb, outboundPatcher, services)...)
 		// Add a blackhole and passthrough cluster for catching traffic to unresolved routes
 		clusters = outboundPatcher.conditionallyAppend(clusters, nil, cb.buildBlackHoleCluster(), cb.buildDefaultPassthroughCluster())
 		clusters = append(clusters, outboundPatcher.insertedClusters()...)
@@ -94,7 +166,7 @@ func (configgen *ConfigGeneratorImpl) BuildClusters(proxy *model.Proxy, push *mo
 		inboundPatcher.incrementFilterMetrics()
 	default: // Gateways
 		patcher := clusterPatcher{efw: envoyFilterPatches, pctx: networking.EnvoyFilter_GATEWAY}
-		clusters = append(clusters, configgen.buildOutboundClusters(cb, patcher)...)
+		clusters = append(clusters, configgen.buildOutboundClusters(cb, patcher, services)...)
 		// Gateways do not require the default passthrough cluster as they do not have original dst listeners.
 		clusters = patcher.conditionallyAppend(clusters, nil, cb.buildBlackHoleCluster())
 		if proxy.Type == model.Router && proxy.GetRouterMode() == model.SniDnatRouter {
@@ -107,15 +179,18 @@ func (configgen *ConfigGeneratorImpl) BuildClusters(proxy *model.Proxy, push *mo
 	return cb.normalizeClusters(clusters)
 }
 
-func (configgen *ConfigGeneratorImpl) buildOutboundClusters(cb *ClusterBuilder, cp clusterPatcher) []*cluster.Cluster {
+// buildOutboundClusters builds the outbound clusters for the given services. If services is nil, all
+// services visible to the proxy are used.
+func (configgen *ConfigGeneratorImpl) buildOutboundClusters(cb *ClusterBuilder, cp clusterPatcher, services []*model.Service) []*cluster.Cluster {
 	clusters := make([]*cluster.Cluster, 0)
 	networkView := cb.proxy.GetNetworkView()
 
-	var services []*model.Service
-	if features.FilterGatewayClusterConfig && cb.proxy.Type == model.Router {
-		services = cb.push.GatewayServices(cb.proxy)
-	} else {
-		services = cb.push.Services(cb.proxy)
+	if services == nil {
+		if features.FilterGatewayClusterConfig && cb.proxy.Type == model.Router {
+			services = cb.push.GatewayServices(cb.proxy)
+		} else {
+			services = cb.push.Services(cb.proxy)
+		}
 	}
 	for _, service := range services {
 		for _, port := range service.Ports {
@@ -17,6 +17,7 @@ package v1alpha3
 
 import (
 	"bytes"
+	"fmt"
 	"sync"
 	"text/template"
 	"time"
@@ -25,6 +26,9 @@ import (
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	configaggregate "istio.io/istio/pilot/pkg/config/aggregate"
@@ -37,12 +41,9 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	memregistry "istio.io/istio/pilot/pkg/serviceregistry/memory"
 	"istio.io/istio/pilot/pkg/serviceregistry/serviceentry"
-	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/collections"
-	"istio.io/istio/pkg/test"
-	"istio.io/istio/pkg/test/util/retry"
 )
 
 type TestOptions struct {
@@ -80,7 +81,6 @@ type TestOptions struct {
 }
 
 type ConfigGenTest struct {
-	t                    test.Failer
 	pushContextLock      *sync.RWMutex
 	store                model.ConfigStoreCache
 	env                  *model.Environment
@@ -92,14 +92,14 @@ type ConfigGenTest struct {
 	stop                 chan struct{}
 }
 
-func NewConfigGenTest(t test.Failer, opts TestOptions) *ConfigGenTest {
-	t.Helper()
+// NewConfigGen creates a config generator over the configs and services of opts without a test, for
+// example to generate the configuration of a proxy offline. Close must be called once it is no longer used.
+func NewConfigGen(opts TestOptions) (*ConfigGenTest, error) {
+	configs, err := getConfigs(opts)
+	if err != nil {
+		return nil, err
+	}
 	stop := make(chan struct{})
-	t.Cleanup(func() {
-		close(stop)
-	})
-
-	configs := getConfigs(t, opts)
 	configStore := memory.MakeSkipValidation(collections.Pilot)
 
 	cc := memory.NewSyncController(configStore)
@@ -147,7 +147,6 @@ func NewConfigGenTest(t test.Failer, opts TestOptions) *ConfigGenTest {
 	}
 
 	fake := &ConfigGenTest{
-		t:                    t,
 		store:                configController,
 		env:                  env,
 		initialConfigs:       configs,
@@ -159,30 +158,51 @@ func NewConfigGenTest(t test.Failer, opts TestOptions) *ConfigGenTest {
 		pushContextLock:      opts.PushContextLock,
 	}
 	if !opts.SkipRun {
-		fake.Run()
+		if err := fake.Run(); err != nil {
+			fake.Close()
+			return nil, err
+		}
 		env.PushContext = model.NewPushContext()
 		if err := env.PushContext.InitContext(env, nil, nil); err != nil {
-			t.Fatalf("Failed to initialize push context: %v", err)
+			fake.Close()
+			return nil, fmt.Errorf("failed to initialize push context: %v", err)
 		}
 	}
-	return fake
+	return fake, nil
 }
 
-func (f *ConfigGenTest) Run() {
+// Close stops the config store of the config generator.
+func (f *ConfigGenTest) Close() {
+	close(f.stop)
+}
+
+// Run starts the config store and creates the initial configs, if this was skipped on creation.
+func (f *ConfigGenTest) Run() error {
 	go f.store.Run(f.stop)
 	// Setup configuration. This should be done after registries are added so they can process events.
 	for _, cfg := range f.initialConfigs {
 		if _, err := f.store.Create(cfg); err != nil {
-			f.t.Fatalf("failed to create config %v: %v", cfg.Name, err)
+			return fmt.Errorf("failed to create config %v: %v", cfg.Name, err)
 		}
 	}
 
 	// TODO allow passing event handlers for controller
 
-	retry.UntilOrFail(f.t, f.store.HasSynced, retry.Delay(time.Millisecond))
-	retry.UntilOrFail(f.t, f.Registry.HasSynced, retry.Delay(time.Millisecond))
+	if err := waitForSync(f.store.HasSynced); err != nil {
+		return fmt.Errorf("config store did not sync: %v", err)
+	}
+	if err := waitForSync(f.Registry.HasSynced); err != nil {
+		return fmt.Errorf("service registry did not sync: %v", err)
+	}
 
 	f.ServiceEntryRegistry.ResyncEDS()
+	return nil
+}
+
+func waitForSync(synced func() bool) error {
+	return wait.PollImmediate(time.Millisecond, 30*time.Second, func() (bool, error) {
+		return synced(), nil
+	})
 }
 
 // SetupProxy initializes a proxy for the current environment. This should generally be used when creating
@@ -239,7 +259,29 @@ func (f *ConfigGenTest) Clusters(p *model.Proxy) []*cluster.Cluster {
 }
 
 func (f *ConfigGenTest) Routes(p *model.Proxy) []*route.RouteConfiguration {
-	return f.ConfigGen.BuildHTTPRoutes(p, f.PushContext(), xdstest.ExtractRoutesFromListeners(f.Listeners(p)))
+	return f.ConfigGen.BuildHTTPRoutes(p, f.PushContext(), extractRoutesFromListeners(f.Listeners(p)))
+}
+
+// extractRoutesFromListeners returns the names of the route configurations the listeners fetch with RDS.
+func extractRoutesFromListeners(ll []*listener.Listener) []string {
+	routes := []string{}
+	for _, l := range ll {
+		for _, fc := range l.FilterChains {
+			for _, filter := range fc.Filters {
+				if filter.Name != wellknown.HTTPConnectionManager {
+					continue
+				}
+				h := &hcm.HttpConnectionManager{}
+				if err := filter.GetTypedConfig().UnmarshalTo(h); err != nil {
+					continue
+				}
+				if rds := h.GetRds(); rds != nil {
+					routes = append(routes, rds.RouteConfigName)
+				}
+			}
+		}
+	}
+	return routes
 }
 
 func (f *ConfigGenTest) PushContext() *model.PushContext {
@@ -260,7 +302,7 @@ func (f *ConfigGenTest) Store() model.ConfigStoreCache {
 
 var _ model.XDSUpdater = &FakeXdsUpdater{}
 
-func getConfigs(t test.Failer, opts TestOptions) []config.Config {
+func getConfigs(opts TestOptions) ([]config.Config, error) {
 	for _, p := range opts.ConfigPointers {
 		if p != nil {
 			opts.Configs = append(opts.Configs, *p)
@@ -268,10 +310,13 @@ func getConfigs(t test.Failer, opts TestOptions) []config.Config {
 	}
 	configStr := opts.ConfigString
 	if opts.ConfigTemplateInput != nil {
-		tmpl := template.Must(template.New("").Funcs(sprig.TxtFuncMap()).Parse(opts.ConfigString))
+		tmpl, err := template.New("").Funcs(sprig.TxtFuncMap()).Parse(opts.ConfigString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template: %v", err)
+		}
 		var buf bytes.Buffer
 		if err := tmpl.Execute(&buf, opts.ConfigTemplateInput); err != nil {
-			t.Fatalf("failed to execute template: %v", err)
+			return nil, fmt.Errorf("failed to execute template: %v", err)
 		}
 		configStr = buf.String()
 	}
@@ -280,7 +325,7 @@ func getConfigs(t test.Failer, opts TestOptions) []config.Config {
 		t0 := time.Now()
 		configs, _, err := crd.ParseInputs(configStr)
 		if err != nil {
-			t.Fatalf("failed to read config: %v", err)
+			return nil, fmt.Errorf("failed to read config: %v", err)
 		}
 		// setup default namespace if not defined
 		for _, c := range configs {
@@ -293,7 +338,7 @@ func getConfigs(t test.Failer, opts TestOptions) []config.Config {
 			cfgs = append(cfgs, c)
 		}
 	}
-	return cfgs
+	return cfgs, nil
 }
 
 type FakeXdsUpdater struct{}
@@ -826,7 +826,7 @@ func runSimulationTest(t *testing.T, proxy *model.Proxy, o xds.FakeOptions, tt s
 		o.ConfigString = tt.config
 		o.KubernetesObjectString = tt.kubeConfig
 		s := xds.NewFakeDiscoveryServer(t, o)
-		sim := simulation.NewSimulation(t, s, s.SetupProxy(proxy))
+		sim := newSimulation(t, s, s.SetupProxy(proxy))
 		sim.RunExpectations(tt.calls)
 		if t.Failed() && debugMode {
 			t.Log(xdstest.MapKeys(xdstest.ExtractClusters(sim.Clusters)))
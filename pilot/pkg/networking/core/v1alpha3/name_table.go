@@ -139,6 +139,7 @@ func (configgen *ConfigGeneratorImpl) BuildNameTable(node *model.Proxy, push *mo
 		nameInfo := &nds.NameTable_NameInfo{
 			Ips:      addressList,
 			Registry: svc.Attributes.ServiceRegistry,
+			Ports:    nameTablePorts(svc.Ports),
 		}
 		if svc.Attributes.ServiceRegistry == string(serviceregistry.Kubernetes) {
 			// The agent will take care of resolving a, a.ns, a.ns.svc, etc.
@@ -150,3 +151,20 @@ func (configgen *ConfigGeneratorImpl) BuildNameTable(node *model.Proxy, push *mo
 	}
 	return out
 }
+
+// nameTablePorts returns the named ports of a service, which the agent uses to answer SRV queries
+// of the form _<port name>._<protocol>.<host>.
+func nameTablePorts(ports model.PortList) []*nds.NameTable_Port {
+	var out []*nds.NameTable_Port
+	for _, port := range ports {
+		if port.Name == "" {
+			continue
+		}
+		out = append(out, &nds.NameTable_Port{
+			Name:     port.Name,
+			Port:     uint32(port.Port),
+			Protocol: string(port.Protocol),
+		})
+	}
+	return out
+}
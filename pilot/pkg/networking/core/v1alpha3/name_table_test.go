@@ -112,6 +112,7 @@ func TestNameTable(t *testing.T) {
 						Registry:  "Kubernetes",
 						Shortname: "headless-svc",
 						Namespace: "testns",
+						Ports:     []*nds.NameTable_Port{{Name: "tcp-port", Port: 9000, Protocol: "TCP"}},
 					},
 				},
 			},
@@ -24,8 +24,10 @@ import (
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
+	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
@@ -67,12 +69,15 @@ func (g *GrpcConfigGenerator) Generate(proxy *model.Proxy, push *model.PushConte
 
 // handleLDSApiType handles a LDS request, returning listeners of ApiListener type.
 // The request may include a list of resource names, using the full_hostname[:port] format to select only
-// specific services.
+// specific services. Names with the ServerListenerNamePrefix select the inbound listeners used by gRPC servers.
 func (g *GrpcConfigGenerator) BuildListeners(node *model.Proxy, push *model.PushContext, names []string) model.Resources {
-	resp := model.Resources{}
-
 	filter := map[string]bool{}
+	var inbound []string
 	for _, name := range names {
+		if strings.HasPrefix(name, ServerListenerNamePrefix) {
+			inbound = append(inbound, name)
+			continue
+		}
 		if strings.Contains(name, ":") {
 			n, _, err := net.SplitHostPort(name)
 			if err == nil {
@@ -82,6 +87,12 @@ func (g *GrpcConfigGenerator) BuildListeners(node *model.Proxy, push *model.Push
 		filter[name] = true
 	}
 
+	resp := buildInboundListeners(node, push, inbound)
+	if len(inbound) > 0 && len(filter) == 0 {
+		// Only inbound listeners were requested.
+		return resp
+	}
+
 	for _, el := range node.SidecarScope.EgressListeners {
 		for _, sv := range el.Services() {
 			shost := string(sv.Hostname)
@@ -159,6 +170,19 @@ func (g *GrpcConfigGenerator) BuildClusters(node *model.Proxy, push *model.PushC
 				},
 			},
 		}
+		if port, err := strconv.Atoi(portn); err == nil && isIstioMutual(node, push, host.Name(hn), port) {
+			var sans []string
+			if sas := push.ServiceAccounts[host.Name(hn)]; sas != nil {
+				sans = sas[port]
+			}
+			rc.TransportSocket = &core.TransportSocket{
+				Name: util.EnvoyTLSSocketName,
+				ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: util.MessageToAny(&tls.UpstreamTlsContext{
+					CommonTlsContext: buildCommonTLSContext(sans),
+					Sni:              n,
+				})},
+			}
+		}
 		resp = append(resp, &discovery.Resource{
 			Name:     n,
 			Resource: util.MessageToAny(rc),
@@ -167,6 +191,37 @@ func (g *GrpcConfigGenerator) BuildClusters(node *model.Proxy, push *model.PushC
 	return resp
 }
 
+// isIstioMutual returns true if the client should use ISTIO_MUTUAL TLS for the service port: either the
+// DestinationRule requires it, or the DestinationRule has no TLS settings and auto mTLS infers that the
+// servers are STRICT. As gRPC servers only enable mTLS in STRICT mode, PERMISSIVE services are plaintext.
+func isIstioMutual(node *model.Proxy, push *model.PushContext, hostname host.Name, port int) bool {
+	svc := push.ServiceForHostname(node, hostname)
+	if svc == nil {
+		return false
+	}
+	var policy *networking.TrafficPolicy
+	if cfg := push.DestinationRule(node, svc); cfg != nil {
+		policy = cfg.Spec.(*networking.DestinationRule).GetTrafficPolicy()
+	}
+	tlsSettings := policy.GetTls()
+	for _, pls := range policy.GetPortLevelSettings() {
+		if int(pls.GetPort().GetNumber()) == port && pls.GetTls() != nil {
+			tlsSettings = pls.GetTls()
+		}
+	}
+	if tlsSettings != nil {
+		return tlsSettings.GetMode() == networking.ClientTLSSettings_ISTIO_MUTUAL
+	}
+	if !push.Mesh.GetEnableAutoMtls().GetValue() {
+		return false
+	}
+	svcPort, ok := svc.Ports.GetByPort(port)
+	if !ok {
+		return false
+	}
+	return push.BestEffortInferServiceMTLSMode(policy, svc, svcPort) == model.MTLSStrict
+}
+
 // handleSplitRDS supports per-VIP routes, as used by GRPC.
 // This mode is indicated by using names containing full host:port instead of just port.
 // Returns true of the request is of this type.
@@ -19,6 +19,8 @@ import (
 	"testing"
 	"time"
 
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/balancer"
@@ -33,6 +35,7 @@ import (
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/grpcgen"
 	"istio.io/istio/pilot/pkg/xds"
 
 	"istio.io/istio/pkg/config"
@@ -196,3 +199,120 @@ func (t *testClientConn) ParseServiceConfig(jsonSC string) *serviceconfig.ParseR
 	//}
 	return &serviceconfig.ParseResult{}
 }
+
+const echoServiceEntry = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: echo
+  namespace: test
+spec:
+  hosts:
+  - echo.test.svc.cluster.local
+  addresses:
+  - 10.10.10.10
+  ports:
+  - number: 7070
+    name: grpc
+    protocol: GRPC
+  location: MESH_INTERNAL
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.1
+`
+
+func TestClusterTLS(t *testing.T) {
+	destinationRule := func(mode string) string {
+		return `
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: echo
+  namespace: test
+spec:
+  host: echo.test.svc.cluster.local
+  trafficPolicy:
+    tls:
+      mode: ` + mode + `
+`
+	}
+	const portLevelDestinationRule = `
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: echo
+  namespace: test
+spec:
+  host: echo.test.svc.cluster.local
+  trafficPolicy:
+    portLevelSettings:
+    - port:
+        number: 7070
+      tls:
+        mode: ISTIO_MUTUAL
+`
+	name := "echo.test.svc.cluster.local:7070"
+	cases := []struct {
+		name    string
+		config  string
+		wantTLS bool
+	}{
+		{
+			name: "auto mTLS without policy",
+		},
+		{
+			name:   "auto mTLS with permissive servers",
+			config: permissivePeerAuthn,
+		},
+		{
+			name:    "auto mTLS with strict servers",
+			config:  strictPeerAuthn,
+			wantTLS: true,
+		},
+		{
+			name:    "destination rule istio mutual",
+			config:  destinationRule("ISTIO_MUTUAL"),
+			wantTLS: true,
+		},
+		{
+			name:    "port level destination rule istio mutual",
+			config:  portLevelDestinationRule,
+			wantTLS: true,
+		},
+		{
+			name:   "destination rule disable overrides auto mTLS",
+			config: strictPeerAuthn + "---" + destinationRule("DISABLE"),
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: echoServiceEntry + "---" + tt.config})
+			proxy := s.SetupProxy(&model.Proxy{
+				ConfigNamespace: "test",
+				Metadata:        &model.NodeMetadata{Namespace: "test", Generator: "grpc"},
+			})
+			g := &grpcgen.GrpcConfigGenerator{}
+			resources := g.BuildClusters(proxy, s.PushContext(), []string{name})
+			if len(resources) != 1 {
+				t.Fatalf("expected 1 cluster, got %d", len(resources))
+			}
+			c := &cluster.Cluster{}
+			if err := resources[0].Resource.UnmarshalTo(c); err != nil {
+				t.Fatal(err)
+			}
+			gotTLS := c.TransportSocket != nil
+			if gotTLS != tt.wantTLS {
+				t.Fatalf("got TLS %v, want %v", gotTLS, tt.wantTLS)
+			}
+			if gotTLS {
+				ctx := &tls.UpstreamTlsContext{}
+				if err := c.TransportSocket.GetTypedConfig().UnmarshalTo(ctx); err != nil {
+					t.Fatal(err)
+				}
+				if ctx.Sni != name {
+					t.Errorf("got SNI %q, want %q", ctx.Sni, name)
+				}
+			}
+		})
+	}
+}
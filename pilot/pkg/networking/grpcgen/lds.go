@@ -0,0 +1,190 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcgen
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/plugin"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/security/authn/factory"
+	"istio.io/istio/pilot/pkg/security/authz/builder"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/proto"
+	"istio.io/istio/pkg/security"
+	"istio.io/pkg/log"
+)
+
+const (
+	// ServerListenerNamePrefix is the prefix of the names of inbound (server side) listeners.
+	// gRPC servers request them by setting the bootstrap "server_listener_resource_name_template"
+	// to "xds.istio.io/grpc/lds/inbound/%s", where %s is replaced with the listening host:port.
+	ServerListenerNamePrefix = "xds.istio.io/grpc/lds/inbound/"
+
+	// certificateProviderInstance is the name of the gRPC certificate provider plugin instance,
+	// declared in the "certificate_providers" section of the gRPC bootstrap, which serves the
+	// workload certificates written by the agent.
+	certificateProviderInstance = "default"
+)
+
+// buildInboundListeners builds the server side listeners for the requested names, all of which
+// are expected to have the ServerListenerNamePrefix. Each listener has a single filter chain,
+// secured according to the PeerAuthentication policies and with RBAC filters translated from the
+// AuthorizationPolicies selecting the workload.
+func buildInboundListeners(node *model.Proxy, push *model.PushContext, names []string) model.Resources {
+	resp := model.Resources{}
+	var httpFilters []*hcm.HttpFilter
+	filtersBuilt := false
+	for _, name := range names {
+		hostPort := strings.TrimPrefix(name, ServerListenerNamePrefix)
+		listenHost, listenPortStr, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			log.Warnf("failed to parse inbound listener name %s: %v", name, err)
+			continue
+		}
+		listenPort, err := strconv.Atoi(listenPortStr)
+		if err != nil {
+			log.Warnf("failed to parse port of inbound listener %s: %v", name, err)
+			continue
+		}
+		if !filtersBuilt {
+			httpFilters = buildInboundHTTPFilters(node, push)
+			filtersBuilt = true
+		}
+
+		ll := &listener.Listener{
+			Name: name,
+			Address: &core.Address{Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Address: listenHost,
+					PortSpecifier: &core.SocketAddress_PortValue{
+						PortValue: uint32(listenPort),
+					},
+				},
+			}},
+			FilterChains: []*listener.FilterChain{
+				buildInboundFilterChain(node, push, name, uint32(listenPort), httpFilters),
+			},
+		}
+		resp = append(resp, &discovery.Resource{
+			Name:     name,
+			Resource: util.MessageToAny(ll),
+		})
+	}
+	return resp
+}
+
+func buildInboundFilterChain(node *model.Proxy, push *model.PushContext, name string, port uint32,
+	httpFilters []*hcm.HttpFilter) *listener.FilterChain {
+	h := &hcm.HttpConnectionManager{
+		StatPrefix: name,
+		RouteSpecifier: &hcm.HttpConnectionManager_RouteConfig{
+			RouteConfig: &route.RouteConfiguration{
+				Name: name,
+				VirtualHosts: []*route.VirtualHost{{
+					Name:    "inbound|http|" + strconv.Itoa(int(port)),
+					Domains: []string{"*"},
+					Routes: []*route.Route{{
+						Match: &route.RouteMatch{
+							PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"},
+						},
+						// gRPC servers require the NonForwardingAction on inbound routes.
+						Action: &route.Route_NonForwardingAction{},
+					}},
+				}},
+			},
+		},
+		HttpFilters: httpFilters,
+	}
+	fc := &listener.FilterChain{
+		Name: name,
+		Filters: []*listener.Filter{{
+			Name:       wellknown.HTTPConnectionManager,
+			ConfigType: &listener.Filter_TypedConfig{TypedConfig: util.MessageToAny(h)},
+		}},
+	}
+	if mtlsMode(node, push, port) == model.MTLSStrict {
+		fc.TransportSocket = &core.TransportSocket{
+			Name: util.EnvoyTLSSocketName,
+			ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: util.MessageToAny(&tls.DownstreamTlsContext{
+				CommonTlsContext:         buildCommonTLSContext(nil),
+				RequireClientCertificate: proto.BoolTrue,
+			})},
+		}
+	}
+	return fc
+}
+
+// mtlsMode returns the mTLS mode the gRPC server should enforce on the given port.
+// gRPC servers can not detect TLS on a plaintext port, so only STRICT enables mTLS; PERMISSIVE
+// keeps accepting the plaintext clients it is meant to allow.
+func mtlsMode(node *model.Proxy, push *model.PushContext, port uint32) model.MutualTLSMode {
+	applier := factory.NewPolicyApplier(push, node.Metadata.Namespace, labels.Collection{node.Metadata.Labels})
+	if mode := applier.GetMutualTLSModeForPort(port); mode == model.MTLSStrict {
+		return mode
+	}
+	return model.MTLSDisable
+}
+
+// buildCommonTLSContext returns a TLS context referencing the certificates from the gRPC certificate
+// provider plugin. If sans is not empty, the peer certificate must match one of them.
+func buildCommonTLSContext(sans []string) *tls.CommonTlsContext {
+	return &tls.CommonTlsContext{
+		TlsCertificateCertificateProviderInstance: &tls.CommonTlsContext_CertificateProviderInstance{
+			InstanceName:    certificateProviderInstance,
+			CertificateName: security.WorkloadKeyCertResourceName,
+		},
+		ValidationContextType: &tls.CommonTlsContext_CombinedValidationContext{
+			CombinedValidationContext: &tls.CommonTlsContext_CombinedCertificateValidationContext{
+				DefaultValidationContext: &tls.CertificateValidationContext{
+					MatchSubjectAltNames: util.StringToExactMatch(sans),
+				},
+				ValidationContextCertificateProviderInstance: &tls.CommonTlsContext_CertificateProviderInstance{
+					InstanceName:    certificateProviderInstance,
+					CertificateName: security.RootCertReqResourceName,
+				},
+			},
+		},
+	}
+}
+
+// buildInboundHTTPFilters returns the RBAC filters for the AuthorizationPolicies selecting the node,
+// followed by the router filter.
+func buildInboundHTTPFilters(node *model.Proxy, push *model.PushContext) []*hcm.HttpFilter {
+	var filters []*hcm.HttpFilter
+	if push.AuthzPolicies != nil {
+		tdBundle := trustdomain.NewBundle(push.Mesh.TrustDomain, push.Mesh.TrustDomainAliases)
+		option := builder.Option{Logger: &builder.AuthzLogger{}}
+		in := &plugin.InputParams{Node: node, Push: push}
+		if b := builder.New(tdBundle, in, option); b != nil {
+			filters = append(filters, b.BuildHTTP()...)
+		}
+		option.Logger.Report(in)
+	}
+	return append(filters, xdsfilters.Router)
+}
@@ -0,0 +1,150 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcgen_test
+
+import (
+	"reflect"
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/grpcgen"
+	authzmodel "istio.io/istio/pilot/pkg/security/authz/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pilot/test/xdstest"
+)
+
+const strictPeerAuthn = `
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: default
+  namespace: test
+spec:
+  mtls:
+    mode: STRICT
+`
+
+const permissivePeerAuthn = `
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: default
+  namespace: test
+spec:
+  mtls:
+    mode: PERMISSIVE
+`
+
+const allowPolicy = `
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: allow-get
+  namespace: test
+spec:
+  action: ALLOW
+  rules:
+  - to:
+    - operation:
+        methods: ["GET"]
+`
+
+func TestInboundListeners(t *testing.T) {
+	name := grpcgen.ServerListenerNamePrefix + "0.0.0.0:8080"
+	cases := []struct {
+		name        string
+		config      string
+		labels      map[string]string
+		wantTLS     bool
+		wantFilters []string
+	}{
+		{
+			name:        "no policy",
+			wantFilters: []string{"envoy.filters.http.router"},
+		},
+		{
+			name:        "strict",
+			config:      strictPeerAuthn,
+			wantTLS:     true,
+			wantFilters: []string{"envoy.filters.http.router"},
+		},
+		{
+			name:        "permissive without istio tls mode",
+			config:      permissivePeerAuthn,
+			wantFilters: []string{"envoy.filters.http.router"},
+		},
+		{
+			name:        "permissive with istio tls mode",
+			config:      permissivePeerAuthn,
+			labels:      map[string]string{"security.istio.io/tlsMode": "istio"},
+			wantFilters: []string{"envoy.filters.http.router"},
+		},
+		{
+			name:        "authorization policy",
+			config:      allowPolicy,
+			wantFilters: []string{authzmodel.RBACHTTPFilterName, "envoy.filters.http.router"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: tt.config})
+			proxy := s.SetupProxy(&model.Proxy{
+				ConfigNamespace: "test",
+				Metadata:        &model.NodeMetadata{Namespace: "test", Labels: tt.labels, Generator: "grpc"},
+			})
+			g := &grpcgen.GrpcConfigGenerator{}
+			resources := g.BuildListeners(proxy, s.PushContext(), []string{name})
+			if len(resources) != 1 {
+				t.Fatalf("expected 1 listener, got %d", len(resources))
+			}
+			l := &listener.Listener{}
+			if err := resources[0].Resource.UnmarshalTo(l); err != nil {
+				t.Fatal(err)
+			}
+			if l.Name != name || l.GetAddress().GetSocketAddress().GetPortValue() != 8080 {
+				t.Fatalf("unexpected listener %v", l)
+			}
+			fc := l.FilterChains[0]
+
+			gotTLS := fc.TransportSocket != nil
+			if gotTLS != tt.wantTLS {
+				t.Fatalf("got TLS %v, want %v", gotTLS, tt.wantTLS)
+			}
+			if gotTLS {
+				ctx := &tls.DownstreamTlsContext{}
+				if err := fc.TransportSocket.GetTypedConfig().UnmarshalTo(ctx); err != nil {
+					t.Fatal(err)
+				}
+				if !ctx.GetRequireClientCertificate().GetValue() {
+					t.Errorf("expected client certificate to be required")
+				}
+				if got := ctx.CommonTlsContext.TlsCertificateCertificateProviderInstance.GetCertificateName(); got != "default" {
+					t.Errorf("got certificate name %q, want default", got)
+				}
+			}
+
+			var gotFilters []string
+			for _, f := range xdstest.ExtractHTTPConnectionManager(t, fc).HttpFilters {
+				gotFilters = append(gotFilters, f.Name)
+			}
+			if !reflect.DeepEqual(gotFilters, tt.wantFilters) {
+				t.Errorf("got filters %v, want %v", gotFilters, tt.wantFilters)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package file implements a service registry backed by a directory of YAML files, each holding
+// ServiceSpecs. It is the reference implementation of a pluggable service registry: it lets
+// inventory systems export VMs and legacy services into the mesh by writing files, and shows how an
+// out of tree registry registers itself with serviceregistry.RegisterProvider.
+//
+// The registry is enabled with the pilot-discovery flags
+//
+//   --registries=Kubernetes,File --registryArgs=File.path=/etc/istio/registry
+package file
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/pkg/log"
+)
+
+var fileLog = log.RegisterScope("fileregistry", "file service registry debugging", 0)
+
+const (
+	// PathArg is the registry argument holding the watched directory.
+	PathArg = "path"
+
+	watchDebounceDelay = 100 * time.Millisecond
+)
+
+func init() {
+	serviceregistry.RegisterProvider(serviceregistry.File, func(opts serviceregistry.ProviderOptions) (serviceregistry.Instance, error) {
+		path := opts.Args[PathArg]
+		if path == "" {
+			return nil, fmt.Errorf("the %s registry requires the %s argument", serviceregistry.File, PathArg)
+		}
+		return NewController(path, opts.ClusterID, opts.XDSUpdater), nil
+	})
+}
+
+var _ serviceregistry.Instance = &Controller{}
+
+// Controller is a service registry serving the services described in a directory of YAML files.
+// Changes to the directory are picked up while running.
+type Controller struct {
+	path       string
+	clusterID  string
+	xdsUpdater model.XDSUpdater
+
+	mutex     sync.RWMutex
+	specs     map[host.Name]ServiceSpec
+	services  map[host.Name]*model.Service
+	instances map[host.Name][]*model.ServiceInstance
+	synced    bool
+
+	handlers []func(*model.Service, model.Event)
+}
+
+// NewController creates a registry for the services in the directory at path.
+func NewController(path, clusterID string, xdsUpdater model.XDSUpdater) *Controller {
+	return &Controller{
+		path:       path,
+		clusterID:  clusterID,
+		xdsUpdater: xdsUpdater,
+		specs:      map[host.Name]ServiceSpec{},
+		services:   map[host.Name]*model.Service{},
+		instances:  map[host.Name][]*model.ServiceInstance{},
+	}
+}
+
+func (c *Controller) Provider() serviceregistry.ProviderID {
+	return serviceregistry.File
+}
+
+func (c *Controller) Cluster() string {
+	return c.clusterID
+}
+
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) {
+	c.handlers = append(c.handlers, f)
+}
+
+// AppendWorkloadHandler is a no-op, as the endpoints of the registry never select services of other registries.
+func (c *Controller) AppendWorkloadHandler(func(*model.WorkloadInstance, model.Event)) {}
+
+// Run loads the directory, then reloads it whenever it changes until stop is closed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	c.Reload()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fileLog.Errorf("failed to watch %s: %v", c.path, err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(c.path); err != nil {
+		fileLog.Errorf("failed to watch %s: %v", c.path, err)
+		return
+	}
+	var debounceC <-chan time.Time
+	for {
+		select {
+		case <-debounceC:
+			debounceC = nil
+			c.Reload()
+		case <-watcher.Events:
+			if debounceC == nil {
+				debounceC = time.After(watchDebounceDelay)
+			}
+		case err := <-watcher.Errors:
+			fileLog.Warnf("error watching %s: %v", c.path, err)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// HasSynced returns true once the directory was loaded for the first time, even if it was invalid.
+func (c *Controller) HasSynced() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.synced
+}
+
+// Reload reads the directory and applies the changes since the last successful load. If any file is
+// invalid, the previous services are kept.
+func (c *Controller) Reload() {
+	specs, err := readSpecs(c.path)
+	c.mutex.Lock()
+	c.synced = true
+	if err != nil {
+		c.mutex.Unlock()
+		fileLog.Errorf("failed to load services from %s, keeping the previous services: %v", c.path, err)
+		return
+	}
+
+	type change struct {
+		svc   *model.Service
+		event model.Event
+		// notify is set if the service itself changed, rather than only its endpoints.
+		notify    bool
+		eds       bool
+		endpoints []*model.IstioEndpoint
+	}
+	var changes []change
+	newSpecs := make(map[host.Name]ServiceSpec, len(specs))
+	for _, spec := range specs {
+		hostname := host.Name(spec.Hostname)
+		newSpecs[hostname] = spec
+		oldSpec, exists := c.specs[hostname]
+		if exists && reflect.DeepEqual(oldSpec, spec) {
+			continue
+		}
+
+		serviceChanged := !exists || !reflect.DeepEqual(withoutEndpoints(oldSpec), withoutEndpoints(spec))
+		endpointsChanged := !exists || !reflect.DeepEqual(oldSpec.Endpoints, spec.Endpoints)
+		svc := c.services[hostname]
+		event := model.EventUpdate
+		if serviceChanged {
+			old := svc
+			svc = convertService(spec, string(serviceregistry.File))
+			if old != nil {
+				// Keep the creation time stable, as it orders conflicting services.
+				svc.CreationTime = old.CreationTime
+			} else {
+				svc.CreationTime = time.Now()
+				event = model.EventAdd
+			}
+		}
+		instances := convertInstances(spec, svc, c.clusterID)
+		c.services[hostname] = svc
+		c.instances[hostname] = instances
+
+		ch := change{svc: svc, event: event, notify: serviceChanged, eds: endpointsChanged || serviceChanged}
+		for _, i := range instances {
+			ch.endpoints = append(ch.endpoints, i.Endpoint)
+		}
+		changes = append(changes, ch)
+	}
+	for hostname, svc := range c.services {
+		if _, f := newSpecs[hostname]; !f {
+			delete(c.services, hostname)
+			delete(c.instances, hostname)
+			changes = append(changes, change{svc: svc, event: model.EventDelete, notify: true})
+		}
+	}
+	c.specs = newSpecs
+	handlers := c.handlers
+	c.mutex.Unlock()
+
+	for _, ch := range changes {
+		hostname, namespace := string(ch.svc.Hostname), ch.svc.Attributes.Namespace
+		fileLog.Debugf("service %s/%s changed: event %v, endpoints changed %v", namespace, hostname, ch.event, ch.eds)
+		if ch.event == model.EventDelete {
+			if c.xdsUpdater != nil {
+				c.xdsUpdater.SvcUpdate(c.clusterID, hostname, namespace, model.EventDelete)
+			}
+		} else if ch.eds && c.xdsUpdater != nil {
+			c.xdsUpdater.EDSUpdate(c.clusterID, hostname, namespace, ch.endpoints)
+		}
+		if ch.notify {
+			for _, h := range handlers {
+				h(ch.svc, ch.event)
+			}
+		}
+	}
+	fileLog.Infof("loaded %d services from %s", len(specs), c.path)
+}
+
+func withoutEndpoints(s ServiceSpec) ServiceSpec {
+	s.Endpoints = nil
+	return s
+}
+
+func (c *Controller) Services() ([]*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	out := make([]*model.Service, 0, len(c.services))
+	for _, svc := range c.services {
+		out = append(out, svc)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Hostname < out[j].Hostname
+	})
+	return out, nil
+}
+
+func (c *Controller) GetService(hostname host.Name) (*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.services[hostname], nil
+}
+
+func (c *Controller) InstancesByPort(svc *model.Service, port int, lbls labels.Collection) []*model.ServiceInstance {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var out []*model.ServiceInstance
+	for _, i := range c.instances[svc.Hostname] {
+		if i.ServicePort.Port == port && lbls.HasSubsetOf(i.Endpoint.Labels) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func (c *Controller) GetProxyServiceInstances(proxy *model.Proxy) []*model.ServiceInstance {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var out []*model.ServiceInstance
+	for _, instances := range c.instances {
+		for _, i := range instances {
+			if proxyHasAddress(proxy, i.Endpoint.Address) {
+				out = append(out, i)
+			}
+		}
+	}
+	return out
+}
+
+func (c *Controller) GetProxyWorkloadLabels(proxy *model.Proxy) labels.Collection {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var out labels.Collection
+	for _, instances := range c.instances {
+		for _, i := range instances {
+			if proxyHasAddress(proxy, i.Endpoint.Address) {
+				out = append(out, i.Endpoint.Labels)
+				// All instances of an endpoint share its labels.
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (c *Controller) GetIstioServiceAccounts(svc *model.Service, ports []int) []string {
+	return model.GetServiceAccounts(svc, ports, c)
+}
+
+func (c *Controller) NetworkGateways() map[string][]*model.Gateway {
+	return nil
+}
+
+func proxyHasAddress(proxy *model.Proxy, address string) bool {
+	for _, ip := range proxy.IPAddresses {
+		if ip == address {
+			return true
+		}
+	}
+	return false
+}
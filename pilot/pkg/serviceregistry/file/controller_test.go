@@ -0,0 +1,226 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+)
+
+// fakeXdsUpdater records the EDS and service updates, in order.
+type fakeXdsUpdater struct {
+	events []string
+}
+
+var _ model.XDSUpdater = &fakeXdsUpdater{}
+
+func (f *fakeXdsUpdater) EDSUpdate(_, hostname string, _ string, entry []*model.IstioEndpoint) {
+	f.events = append(f.events, fmt.Sprintf("eds %s %d", hostname, len(entry)))
+}
+
+func (f *fakeXdsUpdater) EDSCacheUpdate(_, hostname string, _ string, entry []*model.IstioEndpoint) {
+	f.events = append(f.events, fmt.Sprintf("edscache %s %d", hostname, len(entry)))
+}
+
+func (f *fakeXdsUpdater) SvcUpdate(_, hostname string, _ string, event model.Event) {
+	f.events = append(f.events, fmt.Sprintf("svcupdate %s %v", hostname, event))
+}
+
+func (f *fakeXdsUpdater) ConfigUpdate(*model.PushRequest) {}
+
+func (f *fakeXdsUpdater) ProxyUpdate(_, _ string) {}
+
+func (f *fakeXdsUpdater) reset() []string {
+	e := f.events
+	f.events = nil
+	return e
+}
+
+const legacyService = `
+hostname: legacy.example.com
+namespace: legacy
+address: 240.240.0.10
+ports:
+- name: http
+  port: 8080
+  protocol: HTTP
+- name: tcp
+  port: 9000
+endpoints:
+- address: 10.1.0.1
+  ports:
+    http: 9080
+  labels:
+    version: v1
+  serviceAccount: legacy
+- address: 10.1.0.2
+  labels:
+    version: v2
+`
+
+const otherService = `
+hostname: other.example.com
+namespace: other
+ports:
+- name: grpc
+  port: 7070
+  protocol: GRPC
+`
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestController(t *testing.T) {
+	dir := t.TempDir()
+	xdsUpdater := &fakeXdsUpdater{}
+	c := NewController(dir, "cluster1", xdsUpdater)
+	var handled []string
+	c.AppendServiceHandler(func(svc *model.Service, event model.Event) {
+		handled = append(handled, fmt.Sprintf("%s %v", svc.Hostname, event))
+	})
+	expectEvents := func(wantEds, wantHandled []string) {
+		t.Helper()
+		if got := xdsUpdater.reset(); !reflect.DeepEqual(got, wantEds) {
+			t.Errorf("got xds events %v, want %v", got, wantEds)
+		}
+		if !reflect.DeepEqual(handled, wantHandled) {
+			t.Errorf("got service events %v, want %v", handled, wantHandled)
+		}
+		handled = nil
+	}
+
+	writeFile(t, dir, "services.yaml", legacyService+"---"+otherService)
+	writeFile(t, dir, "ignored.txt", "not yaml")
+	c.Reload()
+	if !c.HasSynced() {
+		t.Fatal("expected registry to be synced")
+	}
+	expectEvents([]string{"eds legacy.example.com 4", "eds other.example.com 0"},
+		[]string{"legacy.example.com add", "other.example.com add"})
+
+	svcs, _ := c.Services()
+	if len(svcs) != 2 {
+		t.Fatalf("expected 2 services, got %d", len(svcs))
+	}
+	svc, _ := c.GetService("legacy.example.com")
+	if svc.Address != "240.240.0.10" || svc.Attributes.Namespace != "legacy" ||
+		svc.Attributes.ServiceRegistry != string(serviceregistry.File) || len(svc.Ports) != 2 {
+		t.Fatalf("unexpected service %+v", svc)
+	}
+
+	instances := c.InstancesByPort(svc, 8080, labels.Collection{{"version": "v1"}})
+	if len(instances) != 1 {
+		t.Fatalf("expected 1 instance, got %d", len(instances))
+	}
+	ep := instances[0].Endpoint
+	if ep.Address != "10.1.0.1" || ep.EndpointPort != 9080 || ep.ServiceAccount != "spiffe://cluster.local/ns/legacy/sa/legacy" {
+		t.Fatalf("unexpected endpoint %+v", ep)
+	}
+	if got := c.InstancesByPort(svc, 9000, nil); len(got) != 2 || got[0].Endpoint.EndpointPort != 9000 {
+		t.Fatalf("unexpected instances %v", got)
+	}
+
+	proxy := &model.Proxy{IPAddresses: []string{"10.1.0.2"}}
+	if got := c.GetProxyServiceInstances(proxy); len(got) != 2 {
+		t.Fatalf("expected 2 proxy instances, got %d", len(got))
+	}
+	if got := c.GetProxyWorkloadLabels(proxy); !reflect.DeepEqual(got, labels.Collection{{"version": "v2"}}) {
+		t.Fatalf("unexpected workload labels %v", got)
+	}
+
+	// Changing only endpoints updates EDS, without a service event.
+	writeFile(t, dir, "services.yaml", legacyService+"- address: 10.1.0.3\n---"+otherService)
+	c.Reload()
+	expectEvents([]string{"eds legacy.example.com 6"}, nil)
+
+	// Changing the service triggers a service event, while legacy.example.com only has its endpoints reverted.
+	writeFile(t, dir, "services.yaml", legacyService+"---"+otherService+"labels:\n  app: other\n")
+	c.Reload()
+	expectEvents([]string{"eds legacy.example.com 4", "eds other.example.com 0"},
+		[]string{"other.example.com update"})
+
+	// Invalid files keep the previous services.
+	writeFile(t, dir, "invalid.yaml", "hostname: invalid.example.com\n")
+	c.Reload()
+	expectEvents(nil, nil)
+	if svcs, _ := c.Services(); len(svcs) != 2 {
+		t.Fatalf("expected 2 services, got %d", len(svcs))
+	}
+	if err := os.Remove(filepath.Join(dir, "invalid.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing a service deletes it.
+	writeFile(t, dir, "services.yaml", legacyService)
+	c.Reload()
+	expectEvents([]string{"svcupdate other.example.com delete"}, []string{"other.example.com delete"})
+	if svc, _ := c.GetService(host.Name("other.example.com")); svc != nil {
+		t.Fatalf("expected service to be deleted, got %v", svc)
+	}
+}
+
+func TestReadSpecsValidation(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{"missing namespace", "hostname: a.example.com\nports:\n- name: http\n  port: 80\n"},
+		{"missing ports", "hostname: a.example.com\nnamespace: a\n"},
+		{"invalid protocol", "hostname: a.example.com\nnamespace: a\nports:\n- name: http\n  port: 80\n  protocol: FOO\n"},
+		{"invalid endpoint", "hostname: a.example.com\nnamespace: a\nports:\n- name: http\n  port: 80\nendpoints:\n- address: foo\n"},
+		{"unknown endpoint port", "hostname: a.example.com\nnamespace: a\nports:\n- name: http\n  port: 80\nendpoints:\n- address: 1.1.1.1\n  ports:\n    tcp: 90\n"},
+		{"unknown field", "hostname: a.example.com\nnamespace: a\nports:\n- name: http\n  port: 80\nfoo: bar\n"},
+		{"duplicate service", legacyService + "---" + legacyService},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "services.yaml", tt.content)
+			if _, err := readSpecs(dir); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestProviderRegistered(t *testing.T) {
+	factory, ok := serviceregistry.GetProviderFactory(serviceregistry.File)
+	if !ok {
+		t.Fatal("file registry is not registered")
+	}
+	if _, err := factory(serviceregistry.ProviderOptions{}); err == nil {
+		t.Fatal("expected error without the path argument")
+	}
+	r, err := factory(serviceregistry.ProviderOptions{ClusterID: "cluster1", Args: map[string]string{PathArg: t.TempDir()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Provider() != serviceregistry.File || r.Cluster() != "cluster1" {
+		t.Fatalf("unexpected registry %v/%v", r.Provider(), r.Cluster())
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// ServiceSpec describes a service and its endpoints. Each YAML document in the watched directory
+// holds one ServiceSpec, for example:
+//
+//   hostname: legacy.example.com
+//   namespace: legacy
+//   address: 240.240.0.10
+//   ports:
+//   - name: http
+//     port: 8080
+//     protocol: HTTP
+//   endpoints:
+//   - address: 10.1.0.1
+//     ports:
+//       http: 9080
+//     labels:
+//       version: v1
+//     serviceAccount: legacy
+type ServiceSpec struct {
+	// Hostname of the service. Required.
+	Hostname string `json:"hostname"`
+	// Namespace the service belongs to. Required.
+	Namespace string `json:"namespace"`
+	// Address is the virtual IP of the service, if any.
+	Address string `json:"address,omitempty"`
+	// Labels of the service.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Ports exposed by the service. At least one is required.
+	Ports []PortSpec `json:"ports"`
+	// Endpoints of the service.
+	Endpoints []EndpointSpec `json:"endpoints,omitempty"`
+}
+
+// PortSpec describes a port of a service.
+type PortSpec struct {
+	Name     string `json:"name"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// EndpointSpec describes an endpoint of a service, such as a VM.
+type EndpointSpec struct {
+	// Address is the IP address of the endpoint. Required.
+	Address string `json:"address"`
+	// Ports maps service port names to the port on the endpoint. Unlisted ports use the service port.
+	Ports map[string]int `json:"ports,omitempty"`
+	// Labels of the endpoint. Labeling with security.istio.io/tlsMode=istio enables Istio mTLS.
+	Labels map[string]string `json:"labels,omitempty"`
+	// ServiceAccount the endpoint runs as, in the service namespace.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// Network the endpoint is on.
+	Network string `json:"network,omitempty"`
+	// Locality of the endpoint, in the region/zone/subzone format.
+	Locality string `json:"locality,omitempty"`
+	// Weight of the endpoint for load balancing.
+	Weight uint32 `json:"weight,omitempty"`
+}
+
+// readSpecs reads the service specs from all YAML files in dir. Any invalid spec fails the whole read,
+// so that a partially written inventory never removes services.
+func readSpecs(dir string) ([]ServiceSpec, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var specs []ServiceSpec
+	var errs error
+	seen := map[string]string{}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !(strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")) {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		for i, doc := range bytes.Split(b, []byte("\n---")) {
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+			spec := ServiceSpec{}
+			if err := yaml.UnmarshalStrict(doc, &spec); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("%s[%d]: %v", name, i, err))
+				continue
+			}
+			if err := spec.validate(); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("%s[%d]: %v", name, i, err))
+				continue
+			}
+			if prev, f := seen[spec.Hostname]; f {
+				errs = multierror.Append(errs, fmt.Errorf("%s[%d]: service %s is already defined in %s", name, i, spec.Hostname, prev))
+				continue
+			}
+			seen[spec.Hostname] = name
+			specs = append(specs, spec)
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Hostname < specs[j].Hostname
+	})
+	return specs, nil
+}
+
+func (s ServiceSpec) validate() error {
+	var errs error
+	if s.Hostname == "" {
+		errs = multierror.Append(errs, fmt.Errorf("hostname is required"))
+	}
+	if s.Namespace == "" {
+		errs = multierror.Append(errs, fmt.Errorf("namespace is required"))
+	}
+	if s.Address != "" && net.ParseIP(s.Address) == nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid address %q", s.Address))
+	}
+	if len(s.Ports) == 0 {
+		errs = multierror.Append(errs, fmt.Errorf("at least one port is required"))
+	}
+	portNames := map[string]bool{}
+	for _, p := range s.Ports {
+		if p.Name == "" || portNames[p.Name] {
+			errs = multierror.Append(errs, fmt.Errorf("port names must be unique and not empty"))
+		}
+		portNames[p.Name] = true
+		if p.Port <= 0 || p.Port > 65535 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid port %d", p.Port))
+		}
+		if p.Protocol != "" && protocol.Parse(p.Protocol) == protocol.Unsupported {
+			errs = multierror.Append(errs, fmt.Errorf("invalid protocol %q", p.Protocol))
+		}
+	}
+	for _, ep := range s.Endpoints {
+		if net.ParseIP(ep.Address) == nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid endpoint address %q", ep.Address))
+		}
+		for name, port := range ep.Ports {
+			if !portNames[name] {
+				errs = multierror.Append(errs, fmt.Errorf("endpoint %s references unknown port %q", ep.Address, name))
+			}
+			if port <= 0 || port > 65535 {
+				errs = multierror.Append(errs, fmt.Errorf("endpoint %s has invalid port %d", ep.Address, port))
+			}
+		}
+		if err := labels.Instance(ep.Labels).Validate(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// convertService converts a spec to a service of the given registry.
+func convertService(s ServiceSpec, registry string) *model.Service {
+	address := s.Address
+	if address == "" {
+		address = constants.UnspecifiedIP
+	}
+	ports := make(model.PortList, 0, len(s.Ports))
+	for _, p := range s.Ports {
+		proto := protocol.Parse(p.Protocol)
+		if p.Protocol == "" {
+			proto = protocol.TCP
+		}
+		ports = append(ports, &model.Port{
+			Name:     p.Name,
+			Port:     p.Port,
+			Protocol: proto,
+		})
+	}
+	return &model.Service{
+		Hostname:   host.Name(s.Hostname),
+		Address:    address,
+		Ports:      ports,
+		Resolution: model.ClientSideLB,
+		Attributes: model.ServiceAttributes{
+			ServiceRegistry: registry,
+			Name:            s.Hostname,
+			Namespace:       s.Namespace,
+			Labels:          s.Labels,
+		},
+	}
+}
+
+// convertInstances returns the instances of the service for all endpoints and ports of the spec.
+func convertInstances(s ServiceSpec, svc *model.Service, clusterID string) []*model.ServiceInstance {
+	out := make([]*model.ServiceInstance, 0, len(s.Endpoints)*len(svc.Ports))
+	for _, ep := range s.Endpoints {
+		sa := ""
+		if ep.ServiceAccount != "" {
+			sa = spiffe.MustGenSpiffeURI(s.Namespace, ep.ServiceAccount)
+		}
+		for _, port := range svc.Ports {
+			targetPort := port.Port
+			if p, f := ep.Ports[port.Name]; f {
+				targetPort = p
+			}
+			out = append(out, &model.ServiceInstance{
+				Service:     svc,
+				ServicePort: port,
+				Endpoint: &model.IstioEndpoint{
+					Address:         ep.Address,
+					EndpointPort:    uint32(targetPort),
+					ServicePortName: port.Name,
+					Labels:          ep.Labels,
+					ServiceAccount:  sa,
+					Network:         ep.Network,
+					Locality: model.Locality{
+						Label:     ep.Locality,
+						ClusterID: clusterID,
+					},
+					LbWeight:  ep.Weight,
+					TLSMode:   model.GetTLSModeFromEndpointLabels(ep.Labels),
+					Namespace: s.Namespace,
+				},
+			})
+		}
+	}
+	return out
+}
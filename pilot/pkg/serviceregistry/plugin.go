@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceregistry
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/mesh"
+)
+
+// ProviderOptions are passed to a ProviderFactory when istiod creates a service registry.
+type ProviderOptions struct {
+	// ClusterID of the cluster istiod is running in. Registries usually report it from Cluster().
+	ClusterID string
+	// XDSUpdater must be notified of endpoint changes through EDSUpdate, and of deleted services
+	// through SvcUpdate. Service changes are propagated to XDS through the service handlers.
+	XDSUpdater model.XDSUpdater
+	// MeshWatcher provides the current mesh config.
+	MeshWatcher mesh.Watcher
+	// Args are the provider specific settings, from the --registryArgs flag.
+	Args map[string]string
+}
+
+// ProviderFactory creates a service registry. The returned registry is added to the aggregate
+// controller, which calls Run once istiod starts and waits for HasSynced before serving.
+type ProviderFactory func(opts ProviderOptions) (Instance, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[ProviderID]ProviderFactory{}
+)
+
+// RegisterProvider makes a service registry provider available by name, so it can be enabled with
+// the --registries flag of pilot-discovery. It is meant to be called from an init function of the
+// package implementing the registry, which then only needs to be imported by the istiod binary.
+// RegisterProvider panics if the name is already registered or is a built-in provider.
+func RegisterProvider(id ProviderID, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	switch id {
+	case Kubernetes, Mock, External:
+		panic(fmt.Sprintf("service registry provider %s is built in", id))
+	}
+	if _, f := providers[id]; f {
+		panic(fmt.Sprintf("service registry provider %s registered twice", id))
+	}
+	providers[id] = factory
+}
+
+// GetProviderFactory returns the factory of a registered provider.
+func GetProviderFactory(id ProviderID) (ProviderFactory, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	f, ok := providers[id]
+	return f, ok
+}
+
+// RegisteredProviders returns the names of the registered providers, sorted.
+func RegisteredProviders() []ProviderID {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	out := make([]ProviderID, 0, len(providers))
+	for id := range providers {
+		out = append(out, id)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i] < out[j]
+	})
+	return out
+}
@@ -24,4 +24,6 @@ const (
 	Kubernetes ProviderID = "Kubernetes"
 	// External is a service registry for externally provided ServiceEntries
 	External = "External"
+	// File is a service registry backed by a directory of service YAML files
+	File ProviderID = "File"
 )
@@ -20,26 +20,23 @@ import (
 	"net"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
-	"testing"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/yl2chen/cidranger"
 
 	"istio.io/istio/pilot/pkg/model"
-	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
 	"istio.io/istio/pilot/pkg/util/sets"
-	"istio.io/istio/pilot/pkg/xds"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
-	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/config/host"
-	"istio.io/istio/pkg/test"
 )
 
 type Protocol string
@@ -162,109 +159,88 @@ type Result struct {
 	// if we pass the test. This is to ensure that if the behavior changes, we still capture it; the skip
 	// just ensures we notice a test is wrong
 	Skip string
-	t    test.Failer
-}
 
-func (r Result) Matches(t *testing.T, want Result) {
-	r.StrictMatch = want.StrictMatch // to make diff pass
-	r.Skip = want.Skip               // to make diff pass
-	diff := cmp.Diff(want, r, cmpopts.IgnoreUnexported(Result{}), cmpopts.EquateErrors())
-	if want.StrictMatch && diff != "" {
-		t.Errorf("Diff: %v", diff)
-		return
-	}
-	if want.Error != r.Error {
-		t.Errorf("want error %v got %v", want.Error, r.Error)
-	}
-	if want.ListenerMatched != "" && want.ListenerMatched != r.ListenerMatched {
-		t.Errorf("want listener matched %q got %q", want.ListenerMatched, r.ListenerMatched)
-	}
-	if want.FilterChainMatched != "" && want.FilterChainMatched != r.FilterChainMatched {
-		t.Errorf("want filter chain matched %q got %q", want.FilterChainMatched, r.FilterChainMatched)
-	}
-	if want.RouteMatched != "" && want.RouteMatched != r.RouteMatched {
-		t.Errorf("want route matched %q got %q", want.RouteMatched, r.RouteMatched)
-	}
-	if want.RouteConfigMatched != "" && want.RouteConfigMatched != r.RouteConfigMatched {
-		t.Errorf("want route config matched %q got %q", want.RouteConfigMatched, r.RouteConfigMatched)
-	}
-	if want.VirtualHostMatched != "" && want.VirtualHostMatched != r.VirtualHostMatched {
-		t.Errorf("want virtual host matched %q got %q", want.VirtualHostMatched, r.VirtualHostMatched)
-	}
-	if want.ClusterMatched != "" && want.ClusterMatched != r.ClusterMatched {
-		t.Errorf("want cluster matched %q got %q", want.ClusterMatched, r.ClusterMatched)
-	}
-	if t.Failed() {
-		t.Logf("Diff: %+v", diff)
-	} else if want.Skip != "" {
-		t.Skip(fmt.Sprintf("Known bug: %v", r.Skip))
-	}
+	filterChain *listener.FilterChain
 }
 
+// Simulation runs calls through the listeners, clusters and routes of a proxy. It does not depend on any
+// test code, so it can also be used on the configuration of a running proxy.
 type Simulation struct {
-	t         *testing.T
 	Listeners []*listener.Listener
 	Clusters  []*cluster.Cluster
 	Routes    []*route.RouteConfiguration
 }
 
-func NewSimulationFromConfigGen(t *testing.T, s *v1alpha3.ConfigGenTest, proxy *model.Proxy) *Simulation {
-	sim := &Simulation{
-		t:         t,
-		Listeners: s.Listeners(proxy),
-		Clusters:  s.Clusters(proxy),
-		Routes:    s.Routes(proxy),
+// NewSimulationFromResources creates a simulation over already generated configuration, such as the
+// configuration read from a proxy's config dump.
+func NewSimulationFromResources(listeners []*listener.Listener, clusters []*cluster.Cluster,
+	routes []*route.RouteConfiguration) *Simulation {
+	return &Simulation{
+		Listeners: listeners,
+		Clusters:  clusters,
+		Routes:    routes,
 	}
-	return sim
 }
 
-func NewSimulation(t *testing.T, s *xds.FakeDiscoveryServer, proxy *model.Proxy) *Simulation {
-	return NewSimulationFromConfigGen(t, s.ConfigGenTest, proxy)
-}
-
-// withT swaps out the testing struct. This allows executing sub tests.
-func (sim *Simulation) withT(t *testing.T) *Simulation {
-	cpy := *sim
-	cpy.t = t
-	return &cpy
-}
-
-func (sim *Simulation) RunExpectations(es []Expect) {
-	for _, e := range es {
-		sim.t.Run(e.Name, func(t *testing.T) {
-			sim.withT(t).Run(e.Call).Matches(t, e.Result)
-		})
+func hasFilterOnPort(l *listener.Listener, filter string, port int) (bool, error) {
+	for _, lf := range l.ListenerFilters {
+		if lf.Name != filter {
+			continue
+		}
+		if lf.FilterDisabled == nil {
+			return true, nil
+		}
+		disabled, err := evaluateListenerFilterPredicates(lf.FilterDisabled, port)
+		return !disabled, err
 	}
+	return false, nil
 }
 
-func hasFilterOnPort(l *listener.Listener, filter string, port int) bool {
-	got, f := xdstest.ExtractListenerFilters(l)[filter]
-	if !f {
-		return false
-	}
-	if got.FilterDisabled == nil {
-		return true
+func evaluateListenerFilterPredicates(predicate *listener.ListenerFilterChainMatchPredicate, port int) (bool, error) {
+	if predicate == nil {
+		return false, nil
+	}
+	switch r := predicate.Rule.(type) {
+	case *listener.ListenerFilterChainMatchPredicate_NotMatch:
+		matches, err := evaluateListenerFilterPredicates(r.NotMatch, port)
+		return !matches, err
+	case *listener.ListenerFilterChainMatchPredicate_OrMatch:
+		for _, r := range r.OrMatch.Rules {
+			matches, err := evaluateListenerFilterPredicates(r, port)
+			if err != nil || matches {
+				return matches, err
+			}
+		}
+		return false, nil
+	case *listener.ListenerFilterChainMatchPredicate_DestinationPortRange:
+		return int32(port) >= r.DestinationPortRange.GetStart() && int32(port) < r.DestinationPortRange.GetEnd(), nil
+	default:
+		return false, fmt.Errorf("unsupported listener filter predicate %T", r)
 	}
-	return !xdstest.EvaluateListenerFilterPredicates(got.FilterDisabled, port)
 }
 
-func (sim *Simulation) Run(input Call) (result Result) {
-	result = Result{t: sim.t}
+// Simulate runs the call through the configuration. A call that can not be routed is reported in
+// Result.Error, while an error is returned if the configuration is invalid.
+func (sim *Simulation) Simulate(input Call) (Result, error) {
+	result := Result{}
 	input = input.FillDefaults()
 	if input.Alpn != "" && input.TLS == Plaintext {
 		result.Error = fmt.Errorf("invalid call, ALPN can only be sent in TLS requests")
-		return result
+		return result, nil
 	}
 
 	// First we will match a listener
 	l := matchListener(sim.Listeners, input)
 	if l == nil {
 		result.Error = ErrNoListener
-		return
+		return result, nil
 	}
 	result.ListenerMatched = l.Name
 
-	hasTLSInspector := hasFilterOnPort(l, xdsfilters.TLSInspector.Name, input.Port)
+	hasTLSInspector, err := hasFilterOnPort(l, xdsfilters.TLSInspector.Name, input.Port)
+	if err != nil {
+		return result, err
+	}
 	if !hasTLSInspector {
 		// Without tls inspector, Envoy would not read the ALPN in the TLS handshake
 		// HTTP inspector still may set it though
@@ -272,40 +248,60 @@ func (sim *Simulation) Run(input Call) (result Result) {
 	}
 
 	// Apply listener filters
-	if hasFilterOnPort(l, xdsfilters.HTTPInspector.Name, input.Port) {
+	hasHTTPInspector, err := hasFilterOnPort(l, xdsfilters.HTTPInspector.Name, input.Port)
+	if err != nil {
+		return result, err
+	}
+	if hasHTTPInspector {
 		if alpn := protocolToAlpn(input.Protocol); alpn != "" && input.TLS == Plaintext {
 			input.Alpn = alpn
 		}
 	}
 
 	fc, err := sim.matchFilterChain(l.FilterChains, l.DefaultFilterChain, input, hasTLSInspector)
-	if err != nil {
+	if err == ErrNoFilterChain || err == ErrMultipleFilterChain {
 		result.Error = err
-		return
+		return result, nil
+	}
+	if err != nil {
+		return result, err
 	}
 	result.FilterChainMatched = fc.Name
+	result.filterChain = fc
 	// Plaintext to TLS is an error
 	if fc.TransportSocket != nil && input.TLS == Plaintext {
 		result.Error = ErrTLSError
-		return
+		return result, nil
 	}
 	// mTLS listener will only accept mTLS traffic
-	if fc.TransportSocket != nil && sim.requiresMTLS(fc) != (input.TLS == MTLS) {
+	mtls, err := requiresMTLS(fc)
+	if err != nil {
+		return result, err
+	}
+	if fc.TransportSocket != nil && mtls != (input.TLS == MTLS) {
 		// If there is no tls inspector, then
 		result.Error = ErrMTLSError
-		return
+		return result, nil
 	}
 
-	if hcm := xdstest.ExtractHTTPConnectionManager(sim.t, fc); hcm != nil {
+	hcm, err := extractHTTPConnectionManager(fc)
+	if err != nil {
+		return result, err
+	}
+	tcp, err := extractTCPProxy(fc)
+	if err != nil {
+		return result, err
+	}
+	if hcm != nil {
 		// We matched HCM and didn't terminate TLS, but we are sending TLS traffic - decoding will fail
 		if input.TLS != Plaintext && fc.TransportSocket == nil {
 			result.Error = ErrProtocolError
-			return
+			return result, nil
 		}
 		// TCP to HCM is invalid
 		if input.Protocol != HTTP && input.Protocol != HTTP2 {
 			result.Error = ErrProtocolError
-			return
+			return result, nil
 		}
 
 		// Fetch inline route
@@ -314,7 +310,12 @@ func (sim *Simulation) Run(input Call) (result Result) {
 			// If not set, fallback to RDS
 			routeName := hcm.GetRds().RouteConfigName
 			result.RouteConfigMatched = routeName
-			rc = xdstest.ExtractRouteConfigurations(sim.Routes)[routeName]
+			for _, r := range sim.Routes {
+				if r.Name == routeName {
+					rc = r
+					break
+				}
+			}
 		}
 		hostHeader := ""
 		if len(input.Headers["Host"]) > 0 {
@@ -323,47 +324,117 @@ func (sim *Simulation) Run(input Call) (result Result) {
 		vh := sim.matchVirtualHost(rc, hostHeader)
 		if vh == nil {
 			result.Error = ErrNoVirtualHost
-			return
+			return result, nil
 		}
 		result.VirtualHostMatched = vh.Name
 		if vh.RequireTls == route.VirtualHost_ALL && input.TLS == Plaintext {
 			result.Error = ErrTLSRedirect
-			return
+			return result, nil
 		}
 
-		r := sim.matchRoute(vh, input)
+		r, err := sim.matchRoute(vh, input)
+		if err != nil {
+			return result, err
+		}
 		if r == nil {
 			result.Error = ErrNoRoute
-			return
+			return result, nil
 		}
 		result.RouteMatched = r.Name
 		switch t := r.GetAction().(type) {
 		case *route.Route_Route:
 			result.ClusterMatched = t.Route.GetCluster()
 		}
-	} else if tcp := xdstest.ExtractTCPProxy(sim.t, fc); tcp != nil {
+	} else if tcp != nil {
 		result.ClusterMatched = tcp.GetCluster()
 	}
-	return
+	return result, nil
 }
 
-func (sim *Simulation) requiresMTLS(fc *listener.FilterChain) bool {
-	if fc.TransportSocket == nil {
-		return false
+// RequiresMTLS returns true if the filter chain matched by the result only accepts Istio mTLS.
+func (sim *Simulation) RequiresMTLS(r Result) (bool, error) {
+	return requiresMTLS(r.filterChain)
+}
+
+// ClusterTLSMode describes how the cluster matched by the result originates TLS: ISTIO_MUTUAL, AUTO when
+// Istio mTLS is only used for endpoints labeled as ready for it, SIMPLE for other TLS, or DISABLE.
+func (sim *Simulation) ClusterTLSMode(r Result) (string, error) {
+	var c *cluster.Cluster
+	for _, cc := range sim.Clusters {
+		if cc.Name == r.ClusterMatched {
+			c = cc
+			break
+		}
+	}
+	if c == nil {
+		return "", nil
+	}
+	for _, m := range c.TransportSocketMatches {
+		if m.Name == "tlsMode-"+model.IstioMutualTLSModeLabel {
+			return "AUTO", nil
+		}
+	}
+	if c.TransportSocket == nil {
+		return "DISABLE", nil
+	}
+	t := &tls.UpstreamTlsContext{}
+	if err := c.GetTransportSocket().GetTypedConfig().UnmarshalTo(t); err != nil {
+		return "", fmt.Errorf("failed to unmarshal upstream TLS context of cluster %v: %v", c.Name, err)
+	}
+	sds := t.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs()
+	if len(sds) > 0 && sds[0].Name == "default" {
+		return "ISTIO_MUTUAL", nil
+	}
+	return "SIMPLE", nil
+}
+
+func requiresMTLS(fc *listener.FilterChain) (bool, error) {
+	if fc.GetTransportSocket() == nil {
+		return false, nil
 	}
 	t := &tls.DownstreamTlsContext{}
 	if err := fc.GetTransportSocket().GetTypedConfig().UnmarshalTo(t); err != nil {
-		sim.t.Fatal(err)
+		return false, fmt.Errorf("failed to unmarshal downstream TLS context of filter chain %v: %v", fc.Name, err)
 	}
 
 	if len(t.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs()) == 0 {
-		return false
+		return false, nil
 	}
 	// This is a lazy heuristic, we could check for explicit default resource or spiffe if it becomes necessary
-	return t.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs()[0].Name == "default"
+	return t.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs()[0].Name == "default", nil
+}
+
+func extractHTTPConnectionManager(fc *listener.FilterChain) (*hcmv3.HttpConnectionManager, error) {
+	for _, f := range fc.Filters {
+		if f.Name == wellknown.HTTPConnectionManager {
+			h := &hcmv3.HttpConnectionManager{}
+			if f.GetTypedConfig() != nil {
+				if err := f.GetTypedConfig().UnmarshalTo(h); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal hcm: %v", err)
+				}
+			}
+			return h, nil
+		}
+	}
+	return nil, nil
+}
+
+func extractTCPProxy(fc *listener.FilterChain) (*tcpproxy.TcpProxy, error) {
+	for _, f := range fc.Filters {
+		if f.Name == wellknown.TCPProxy {
+			tcp := &tcpproxy.TcpProxy{}
+			if f.GetTypedConfig() != nil {
+				if err := f.GetTypedConfig().UnmarshalTo(tcp); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal tcp proxy: %v", err)
+				}
+			}
+			return tcp, nil
+		}
+	}
+	return nil, nil
 }
 
-func (sim *Simulation) matchRoute(vh *route.VirtualHost, input Call) *route.Route {
+func (sim *Simulation) matchRoute(vh *route.VirtualHost, input Call) (*route.Route, error) {
 	for _, r := range vh.Routes {
 		// check path
 		switch pt := r.Match.GetPathSpecifier().(type) {
@@ -378,20 +449,82 @@ func (sim *Simulation) matchRoute(vh *route.VirtualHost, input Call) *route.Rout
 		case *route.RouteMatch_SafeRegex:
 			r, err := regexp.Compile(pt.SafeRegex.GetRegex())
 			if err != nil {
-				sim.t.Fatalf("invalid regex %v: %v", r, err)
+				return nil, fmt.Errorf("invalid regex %v: %v", pt.SafeRegex.GetRegex(), err)
 			}
 			if !r.MatchString(input.Path) {
 				continue
 			}
 		default:
-			sim.t.Fatalf("unknown route path type")
+			return nil, fmt.Errorf("unknown route path type")
 		}
 
-		// TODO this only handles path - we need to add headers, query params, etc to be complete.
+		// check headers
+		matches, err := matchHeaders(r.Match.GetHeaders(), input)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			continue
+		}
 
-		return r
+		// TODO this only handles path and headers - we need to add query params, etc to be complete.
+
+		return r, nil
 	}
-	return nil
+	return nil, nil
+}
+
+// matchHeaders returns true if the request matches all of the header matchers, as Envoy would.
+func matchHeaders(matchers []*route.HeaderMatcher, input Call) (bool, error) {
+	for _, h := range matchers {
+		var values []string
+		switch h.Name {
+		case ":authority":
+			values = input.Headers.Values("Host")
+		case ":path":
+			values = []string{input.Path}
+		default:
+			values = input.Headers.Values(h.Name)
+		}
+		if len(values) == 0 {
+			// A missing header only matches an inverted presence match
+			pm, ok := h.GetHeaderMatchSpecifier().(*route.HeaderMatcher_PresentMatch)
+			if !ok || pm.PresentMatch != h.InvertMatch {
+				return false, nil
+			}
+			continue
+		}
+		// Envoy matches the values of a repeated header joined by a comma
+		value := strings.Join(values, ",")
+		var matches bool
+		switch m := h.GetHeaderMatchSpecifier().(type) {
+		case *route.HeaderMatcher_ExactMatch:
+			matches = value == m.ExactMatch
+		case *route.HeaderMatcher_PrefixMatch:
+			matches = strings.HasPrefix(value, m.PrefixMatch)
+		case *route.HeaderMatcher_SuffixMatch:
+			matches = strings.HasSuffix(value, m.SuffixMatch)
+		case *route.HeaderMatcher_ContainsMatch:
+			matches = strings.Contains(value, m.ContainsMatch)
+		case *route.HeaderMatcher_PresentMatch:
+			matches = m.PresentMatch
+		case *route.HeaderMatcher_SafeRegexMatch:
+			r, err := regexp.Compile("^(?:" + m.SafeRegexMatch.GetRegex() + ")$")
+			if err != nil {
+				return false, fmt.Errorf("invalid regex %v: %v", m.SafeRegexMatch.GetRegex(), err)
+			}
+			matches = r.MatchString(value)
+		case *route.HeaderMatcher_RangeMatch:
+			v, err := strconv.ParseInt(value, 10, 64)
+			matches = err == nil && v >= m.RangeMatch.GetStart() && v < m.RangeMatch.GetEnd()
+		default:
+			return false, fmt.Errorf("unknown header match type %T", m)
+		}
+		if matches == h.InvertMatch {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
 func (sim *Simulation) matchVirtualHost(rc *route.RouteConfiguration, host string) *route.VirtualHost {
@@ -455,6 +588,7 @@ func (sim *Simulation) matchVirtualHost(rc *route.RouteConfiguration, host strin
 // matches one criteria but not another.
 func (sim *Simulation) matchFilterChain(chains []*listener.FilterChain, defaultChain *listener.FilterChain,
 	input Call, hasTLSInspector bool) (*listener.FilterChain, error) {
+	var configErr error
 	chains = filter(chains, func(fc *listener.FilterChainMatch) bool {
 		return fc.GetDestinationPort() == nil
 	}, func(fc *listener.FilterChainMatch) bool {
@@ -468,18 +602,24 @@ func (sim *Simulation) matchFilterChain(chains []*listener.FilterChain, defaultC
 			s := fmt.Sprintf("%s/%d", a.AddressPrefix, a.GetPrefixLen().GetValue())
 			_, cidr, err := net.ParseCIDR(s)
 			if err != nil {
-				sim.t.Fatalf("failed to parse cidr %v: %v", s, err)
+				configErr = fmt.Errorf("failed to parse cidr %v: %v", s, err)
+				return false
 			}
 			if err := ranger.Insert(cidranger.NewBasicRangerEntry(*cidr)); err != nil {
-				sim.t.Fatalf("failed to insert cidr %v: %v", cidr, err)
+				configErr = fmt.Errorf("failed to insert cidr %v: %v", cidr, err)
+				return false
 			}
 		}
 		f, err := ranger.Contains(net.ParseIP(input.Address))
 		if err != nil {
-			sim.t.Fatalf("cidr containers %v failed: %v", input.Address, err)
+			configErr = fmt.Errorf("cidr containers %v failed: %v", input.Address, err)
+			return false
 		}
 		return f
 	})
+	if configErr != nil {
+		return nil, configErr
+	}
 	chains = filter(chains, func(fc *listener.FilterChainMatch) bool {
 		return fc.GetServerNames() == nil
 	}, func(fc *listener.FilterChainMatch) bool {
@@ -591,7 +731,12 @@ func protocolToAlpn(s Protocol) string {
 
 func matchListener(listeners []*listener.Listener, input Call) *listener.Listener {
 	if input.CallMode == CallModeInbound {
-		return xdstest.ExtractListener(model.VirtualInboundListenerName, listeners)
+		for _, l := range listeners {
+			if l.Name == model.VirtualInboundListenerName {
+				return l
+			}
+		}
+		return nil
 	}
 	// First find exact match for the IP/Port, then fallback to wildcard IP/Port
 	// There is no wildcard port
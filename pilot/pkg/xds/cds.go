@@ -15,6 +15,7 @@
 package xds
 
 import (
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
 	"istio.io/istio/pilot/pkg/model"
@@ -27,7 +28,7 @@ type CdsGenerator struct {
 	Server *DiscoveryServer
 }
 
-var _ model.XdsResourceGenerator = &CdsGenerator{}
+var _ model.XdsDeltaResourceGenerator = &CdsGenerator{}
 
 // Map of all configs that do not impact CDS
 var skippedCdsConfigs = map[config.GroupVersionKind]struct{}{
@@ -77,6 +78,21 @@ func (c CdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 		return nil, model.DefaultXdsLogDetails, nil
 	}
 	rawClusters := c.Server.ConfigGenerator.BuildClusters(proxy, push)
+	return clustersToResources(rawClusters), model.DefaultXdsLogDetails, nil
+}
+
+// GenerateDeltas for CDS currently only builds deltas when services change. Any other config change will
+// fall back to generating the full set of clusters.
+func (c CdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+	w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	if !cdsNeedsPush(updates, proxy) {
+		return nil, nil, model.DefaultXdsLogDetails, false, nil
+	}
+	rawClusters, removed, usedDelta := c.Server.ConfigGenerator.BuildDeltaClusters(proxy, push, updates, w)
+	return clustersToResources(rawClusters), removed, model.XdsLogDetails{Incremental: usedDelta}, usedDelta, nil
+}
+
+func clustersToResources(rawClusters []*cluster.Cluster) model.Resources {
 	resources := model.Resources{}
 	for _, c := range rawClusters {
 		resources = append(resources, &discovery.Resource{
@@ -84,5 +100,5 @@ func (c CdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 			Resource: util.MessageToAny(c),
 		})
 	}
-	return resources, model.DefaultXdsLogDetails, nil
+	return resources
 }
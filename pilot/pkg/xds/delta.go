@@ -416,8 +416,18 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 
 	t0 := time.Now()
 
-	res, logdata, err := gen.Generate(con.proxy, push, w, req)
-	if err != nil || res == nil {
+	var res model.Resources
+	var deletedRes model.DeletedResources
+	var logdata model.XdsLogDetails
+	var usedDelta bool
+	var err error
+	switch g := gen.(type) {
+	case model.XdsDeltaResourceGenerator:
+		res, deletedRes, logdata, usedDelta, err = g.GenerateDeltas(con.proxy, push, req, w)
+	default:
+		res, logdata, err = g.Generate(con.proxy, push, w, req)
+	}
+	if err != nil || (res == nil && deletedRes == nil) || (usedDelta && len(res) == 0 && len(deletedRes) == 0) {
 		// If we have nothing to send, report that we got an ACK for this version.
 		if s.StatusReporter != nil {
 			s.StatusReporter.RegisterEvent(con.ConID, w.TypeUrl, push.LedgerVersion)
@@ -448,18 +458,31 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 		Nonce:             nonce(push.LedgerVersion),
 		Resources:         res,
 	}
-	// We take the set of watched resources and anything not in the response is sent as RemovedResources
-	// This is similar to SotW, but done on the server side instead of the client.
-	cur := sets.NewSet(w.ResourceNames...)
-	cur.Delete(originalNames...)
-	resp.RemovedResources = cur.SortedList()
+	if usedDelta {
+		// The generator computed exactly what changed, so only the resources it reported are removed.
+		resp.RemovedResources = deletedRes
+	} else if req.Full {
+		// We take the set of watched resources and anything not in the response is sent as RemovedResources
+		// This is similar to SotW, but done on the server side instead of the client.
+		cur := sets.NewSet(w.ResourceNames...)
+		cur.Delete(originalNames...)
+		resp.RemovedResources = cur.SortedList()
+	}
 	if len(resp.RemovedResources) > 0 {
 		log.Infof("ADS:%v REMOVE %v", v3.GetShortType(w.TypeUrl), resp.RemovedResources)
 	}
 	if isWildcardTypeURL(w.TypeUrl) {
 		// this is probably a bad idea...
 		con.proxy.Lock()
-		w.ResourceNames = originalNames
+		if usedDelta {
+			// A delta response only contains the changes, so apply them to the existing set.
+			names := sets.NewSet(w.ResourceNames...)
+			names.Insert(originalNames...)
+			names.Delete(deletedRes...)
+			w.ResourceNames = names.SortedList()
+		} else {
+			w.ResourceNames = originalNames
+		}
 		con.proxy.Unlock()
 	}
 
@@ -15,14 +15,19 @@
 package xds
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/sets"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pilot/test/xdstest"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/tests/util/leak"
 )
 
@@ -58,3 +63,62 @@ func TestDeltaAdsClusterUpdate(t *testing.T) {
 	// TODO: should we just respond with nothing here? Probably...
 	sendEDSReqAndVerify(nil, []string{"outbound|81||local.default.svc.cluster.local"}, []string{"outbound|80||local.default.svc.cluster.local"})
 }
+
+func TestDeltaCDSServiceUpdate(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	const hostname = "delta.test.com"
+	addService := func(ports ...int) {
+		svc := &model.Service{
+			Hostname:   hostname,
+			Address:    "10.11.0.1",
+			Attributes: model.ServiceAttributes{Namespace: "default"},
+		}
+		for _, p := range ports {
+			svc.Ports = append(svc.Ports, &model.Port{Name: fmt.Sprintf("http-%d", p), Port: p, Protocol: protocol.HTTP})
+		}
+		s.Discovery.MemRegistry.AddService(hostname, svc)
+	}
+	pushService := func() {
+		s.Discovery.ConfigUpdate(&model.PushRequest{
+			Full: true,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: hostname, Namespace: "default"}: {},
+			},
+		})
+	}
+	ads := s.ConnectDeltaADS().WithType(v3.ClusterType)
+	ads.RequestResponseAck(nil)
+
+	expectDelta := func(updated, removed []string) {
+		t.Helper()
+		res := ads.ExpectResponse()
+		ads.Request(&discovery.DeltaDiscoveryRequest{ResponseNonce: res.Nonce})
+		got := sets.NewSet()
+		for _, r := range res.Resources {
+			if _, _, h, _ := model.ParseSubsetKey(r.Name); h != "" {
+				got.Insert(r.Name)
+			}
+		}
+		if !reflect.DeepEqual(got.SortedList(), updated) {
+			t.Fatalf("expected updated clusters %v, got %v", updated, got.SortedList())
+		}
+		if !reflect.DeepEqual(res.RemovedResources, removed) {
+			t.Fatalf("expected removed %v, got %v", removed, res.RemovedResources)
+		}
+	}
+
+	// Adding a service should only send the clusters for that service.
+	addService(80, 81)
+	pushService()
+	expectDelta([]string{"outbound|80||delta.test.com", "outbound|81||delta.test.com"}, nil)
+
+	// Removing a port should only remove the cluster for that port.
+	addService(80)
+	pushService()
+	expectDelta([]string{"outbound|80||delta.test.com"}, []string{"outbound|81||delta.test.com"})
+
+	// Removing the service should remove all of its clusters.
+	s.Discovery.MemRegistry.RemoveService(host.Name(hostname))
+	pushService()
+	expectDelta([]string{}, []string{"outbound|80||delta.test.com"})
+}
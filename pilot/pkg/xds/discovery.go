@@ -141,6 +141,9 @@ type DiscoveryServer struct {
 	// Cache for XDS resources
 	Cache model.XdsCache
 
+	// cacheBackend is the second tier store of Cache, if enabled.
+	cacheBackend model.XdsCacheBackend
+
 	// JwtKeyResolver holds a reference to the JWT key resolver instance.
 	JwtKeyResolver *model.JwksResolver
 }
@@ -194,6 +197,15 @@ func NewDiscoveryServer(env *model.Environment, plugins []string, instanceID str
 
 	if features.EnableXDSCaching {
 		out.Cache = model.NewXdsCache()
+		if features.EnablePersistentXDSCache {
+			backend, err := model.NewXdsCacheBackend(features.PersistentXDSCacheBackend)
+			if err != nil {
+				log.Errorf("failed to create persistent xds cache, using the in memory cache only: %v", err)
+			} else {
+				out.cacheBackend = backend
+				out.Cache = model.NewXdsCacheWithBackend(backend)
+			}
+		}
 	}
 
 	out.ConfigGenerator = core.NewConfigGenerator(plugins, out.Cache)
@@ -247,6 +259,9 @@ func (s *DiscoveryServer) Start(stopCh <-chan struct{}) {
 	go s.handleUpdates(stopCh)
 	go s.periodicRefreshMetrics(stopCh)
 	go s.sendPushes(stopCh)
+	if s.cacheBackend != nil {
+		go s.cacheBackend.Run(stopCh)
+	}
 }
 
 func (s *DiscoveryServer) getNonK8sRegistries() []serviceregistry.Instance {
@@ -331,7 +331,7 @@ type EdsGenerator struct {
 	Server *DiscoveryServer
 }
 
-var _ model.XdsResourceGenerator = &EdsGenerator{}
+var _ model.XdsDeltaResourceGenerator = &EdsGenerator{}
 
 // Map of all configs that do not impact EDS
 var skippedEdsConfigs = map[config.GroupVersionKind]struct{}{
@@ -365,7 +365,51 @@ func (eds *EdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w
 	if !req.Full {
 		edsUpdatedServices = model.ConfigNamesOfKind(req.ConfigsUpdated, gvk.ServiceEntry)
 	}
+	resources, _, logDetails := eds.buildEndpoints(proxy, push, w, edsUpdatedServices, false)
+	return resources, logDetails, nil
+}
+
+// GenerateDeltas for EDS only regenerates the endpoints of the services that were updated, for both
+// incremental and full pushes triggered by service changes. Clusters whose service no longer exists
+// are returned as removed.
+func (eds *EdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, req *model.PushRequest,
+	w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	if !edsNeedsPush(req.ConfigsUpdated) {
+		return nil, nil, model.DefaultXdsLogDetails, false, nil
+	}
+	if !shouldUseDeltaEds(req) {
+		resources, _, logDetails := eds.buildEndpoints(proxy, push, w, nil, false)
+		return resources, nil, logDetails, false, nil
+	}
+	edsUpdatedServices := model.ConfigNamesOfKind(req.ConfigsUpdated, gvk.ServiceEntry)
+	resources, removed, logDetails := eds.buildEndpoints(proxy, push, w, edsUpdatedServices, true)
+	return resources, removed, logDetails, true, nil
+}
+
+// shouldUseDeltaEds returns true if only the endpoints of the services in ConfigsUpdated need to be
+// regenerated. This is the case for incremental pushes, and for full pushes where only services changed.
+func shouldUseDeltaEds(req *model.PushRequest) bool {
+	if len(req.ConfigsUpdated) == 0 {
+		return false
+	}
+	if !req.Full {
+		return true
+	}
+	for key := range req.ConfigsUpdated {
+		if key.Kind != gvk.ServiceEntry {
+			return false
+		}
+	}
+	return true
+}
+
+// buildEndpoints generates the endpoints for the watched clusters. If edsUpdatedServices is not nil, only clusters
+// for those services are generated. If detectRemoved is set, clusters for services that no longer exist are
+// returned as removed rather than as empty load assignments.
+func (eds *EdsGenerator) buildEndpoints(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	edsUpdatedServices map[string]struct{}, detectRemoved bool) (model.Resources, model.DeletedResources, model.XdsLogDetails) {
 	resources := make(model.Resources, 0)
+	var removed model.DeletedResources
 	empty := 0
 
 	cached := 0
@@ -380,6 +424,11 @@ func (eds *EdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w
 			}
 		}
 		builder := NewEndpointBuilder(clusterName, proxy, push)
+		if detectRemoved && builder.service == nil {
+			// The service was removed, or is no longer visible to this proxy.
+			removed = append(removed, clusterName)
+			continue
+		}
 		if marshalledEndpoint, token, f := eds.Server.Cache.Get(builder); f && !features.EnableUnsafeAssertions {
 			// We skip cache if assertions are enabled, so that the cache will assert our eviction logic is correct
 			resources = append(resources, marshalledEndpoint)
@@ -402,10 +451,10 @@ func (eds *EdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w
 			eds.Server.Cache.Add(builder, token, resource)
 		}
 	}
-	return resources, model.XdsLogDetails{
+	return resources, removed, model.XdsLogDetails{
 		Incremental:    len(edsUpdatedServices) != 0,
 		AdditionalInfo: fmt.Sprintf("empty:%v cached:%v/%v", empty, cached, cached+regenerated),
-	}, nil
+	}
 }
 
 func getOutlierDetectionAndLoadBalancerSettings(
@@ -166,7 +166,7 @@ func NewFakeDiscoveryServer(t test.Failer, opts FakeOptions) *FakeDiscoveryServe
 	})
 	defaultKubeClient.RunAndWait(stop)
 
-	cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{
+	cg, err := v1alpha3.NewConfigGen(v1alpha3.TestOptions{
 		Configs:             opts.Configs,
 		ConfigString:        opts.ConfigString,
 		ConfigTemplateInput: opts.ConfigTemplateInput,
@@ -177,6 +177,10 @@ func NewFakeDiscoveryServer(t test.Failer, opts FakeOptions) *FakeDiscoveryServe
 		ConfigStoreCaches:   []model.ConfigStoreCache{ingr},
 		SkipRun:             true,
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cg.Close)
 	cg.ServiceEntryRegistry.AppendServiceHandler(serviceHandler)
 	s.updateMutex.Lock()
 	s.Env = cg.Env()
@@ -257,7 +261,9 @@ func NewFakeDiscoveryServer(t test.Failer, opts FakeOptions) *FakeDiscoveryServe
 	s.ConfigUpdate(&model.PushRequest{Full: true})
 
 	// Now that handlers are added, get everything started
-	cg.Run()
+	if err := cg.Run(); err != nil {
+		t.Fatal(err)
+	}
 
 	// Wait until initial updates are committed
 	c := s.InboundUpdates.Load()
@@ -26,10 +26,11 @@ import (
 )
 
 var (
-	errTag     = monitoring.MustCreateLabel("err")
-	nodeTag    = monitoring.MustCreateLabel("node")
-	typeTag    = monitoring.MustCreateLabel("type")
-	versionTag = monitoring.MustCreateLabel("version")
+	errTag      = monitoring.MustCreateLabel("err")
+	nodeTag     = monitoring.MustCreateLabel("node")
+	typeTag     = monitoring.MustCreateLabel("type")
+	versionTag  = monitoring.MustCreateLabel("version")
+	priorityTag = monitoring.MustCreateLabel("priority")
 
 	// pilot_total_xds_rejects should be used instead. This is for backwards compatibility
 	cdsReject = monitoring.NewGauge(
@@ -138,6 +139,13 @@ var (
 		[]float64{.1, .5, 1, 3, 5, 10, 20, 30},
 	)
 
+	queueWaitTime = monitoring.NewDistribution(
+		"pilot_push_queue_wait_time",
+		"Time in seconds, a proxy waits in the push queue since it was added to the queue, labeled by its priority.",
+		[]float64{.01, .1, .5, 1, 3, 5, 10, 20, 30},
+		monitoring.WithLabels(priorityTag),
+	)
+
 	pushTriggers = monitoring.NewSum(
 		"pilot_push_triggers",
 		"Total number of times a push was triggered, labeled by reason for the push.",
@@ -265,6 +273,7 @@ func init() {
 		pushTime,
 		proxiesConvergeDelay,
 		proxiesQueueTime,
+		queueWaitTime,
 		pushContextErrors,
 		totalXDSInternalErrors,
 		inboundUpdates,
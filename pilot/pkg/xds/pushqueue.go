@@ -15,35 +15,136 @@
 package xds
 
 import (
+	"context"
 	"sync"
+	"time"
 
+	"golang.org/x/time/rate"
+
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 )
 
+// pushPriority orders the proxies in the PushQueue. Lower values are dequeued first.
+type pushPriority int
+
+const (
+	// priorityGateway is used for gateways, which serve ingress traffic.
+	priorityGateway pushPriority = iota
+	// priorityFull is used for other proxies with a pending full push.
+	priorityFull
+	// priorityIncremental is used for other proxies with only incremental (EDS) pushes pending.
+	priorityIncremental
+
+	numPushPriorities
+)
+
+func (p pushPriority) String() string {
+	switch p {
+	case priorityGateway:
+		return "gateway"
+	case priorityFull:
+		return "full"
+	default:
+		return "incremental"
+	}
+}
+
+func priorityOf(con *Connection, request *model.PushRequest) pushPriority {
+	if con.proxy != nil && con.proxy.Type == model.Router {
+		return priorityGateway
+	}
+	if request.Full {
+		return priorityFull
+	}
+	return priorityIncremental
+}
+
+// pendingPush is a push waiting in the queue for a connection.
+type pendingPush struct {
+	request  *model.PushRequest
+	priority pushPriority
+	// seq identifies the queue entry of the push. Entries with another seq are stale, left behind when
+	// the priority of the push was raised.
+	seq uint64
+	// enqueued is the time the connection was added to the queue.
+	enqueued time.Time
+	// notBefore is the earliest time the push may be dequeued, to debounce pushes to the connection.
+	notBefore time.Time
+}
+
+type queueEntry struct {
+	con *Connection
+	seq uint64
+}
+
+// PushQueue holds the connections waiting for a push. Each connection is in the queue at most once:
+// if it is enqueued again, the PushRequests are merged. Connections are dequeued by priority (gateways
+// first, then proxies with full pushes pending, then the others), in FIFO order within a priority.
+//
+// Optionally, pushes to a connection are debounced, by not dequeuing it again until proxyDebounce
+// passed since its last push, and pushes are limited to a global rate.
 type PushQueue struct {
 	cond *sync.Cond
 
 	// pending stores all connections in the queue. If the same connection is enqueued again,
 	// the PushRequest will be merged.
-	pending map[*Connection]*model.PushRequest
+	pending map[*Connection]*pendingPush
 
-	// queue maintains ordering of the queue
-	queue []*Connection
+	// queues maintain the ordering of the queue, for each priority.
+	queues [numPushPriorities][]queueEntry
+	// nextSeq is the seq of the next queue entry.
+	nextSeq uint64
 
 	// processing stores all connections that have been Dequeue(), but not MarkDone().
 	// The value stored will be initially be nil, but may be populated if the connection is Enqueue().
 	// If model.PushRequest is not nil, it will be Enqueued again once MarkDone has been called.
 	processing map[*Connection]*model.PushRequest
 
+	// proxyDebounce is the minimum time between pushes to a connection. Zero disables debouncing.
+	proxyDebounce time.Duration
+	// notBefore stores the end of the debounce window of the connections pushed recently.
+	notBefore map[*Connection]time.Time
+	// notBeforePruneSize is the size of notBefore from which expired entries are pruned.
+	notBeforePruneSize int
+	// wakeup is set while a timer is pending to wake up Dequeue at the end of a debounce window.
+	wakeup *time.Time
+
+	// limiter limits the rate of Dequeue, if set.
+	limiter *rate.Limiter
+	ctx     context.Context
+	cancel  context.CancelFunc
+
 	shuttingDown bool
 }
 
+const minNotBeforePruneSize = 1024
+
+// NewPushQueue returns a queue configured by PILOT_PROXY_PUSH_DEBOUNCE and PILOT_PUSH_RATE_LIMIT.
 func NewPushQueue() *PushQueue {
-	return &PushQueue{
-		pending:    make(map[*Connection]*model.PushRequest),
-		processing: make(map[*Connection]*model.PushRequest),
-		cond:       sync.NewCond(&sync.Mutex{}),
+	return newPushQueue(features.ProxyPushDebounce, features.PushRateLimit)
+}
+
+func newPushQueue(proxyDebounce time.Duration, rateLimit float64) *PushQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &PushQueue{
+		pending:            make(map[*Connection]*pendingPush),
+		processing:         make(map[*Connection]*model.PushRequest),
+		proxyDebounce:      proxyDebounce,
+		notBefore:          make(map[*Connection]time.Time),
+		notBeforePruneSize: minNotBeforePruneSize,
+		cond:               sync.NewCond(&sync.Mutex{}),
+		ctx:                ctx,
+		cancel:             cancel,
+	}
+	if rateLimit > 0 {
+		burst := int(rateLimit)
+		if burst < 1 {
+			burst = 1
+		}
+		p.limiter = rate.NewLimiter(rate.Limit(rateLimit), burst)
 	}
+	return p
 }
 
 // Enqueue will mark a proxy as pending a push. If it is already pending, pushInfo will be merged.
@@ -62,36 +163,76 @@ func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
 		return
 	}
 
-	if request, f := p.pending[con]; f {
-		p.pending[con] = request.Merge(pushRequest)
+	if pp, f := p.pending[con]; f {
+		pp.request = pp.request.Merge(pushRequest)
+		// A merged full push raises the priority of an incremental one.
+		if priority := priorityOf(con, pp.request); priority < pp.priority {
+			pp.priority = priority
+			p.push(con, pp)
+		}
 		return
 	}
 
-	p.pending[con] = pushRequest
-	p.queue = append(p.queue, con)
+	p.add(con, pushRequest)
+}
+
+// add adds a connection which is not in the queue.
+func (p *PushQueue) add(con *Connection, request *model.PushRequest) {
+	pp := &pendingPush{
+		request:  request,
+		priority: priorityOf(con, request),
+		enqueued: time.Now(),
+	}
+	if nb, f := p.notBefore[con]; f {
+		pp.notBefore = nb
+		delete(p.notBefore, con)
+	}
+	p.pending[con] = pp
+	p.push(con, pp)
+}
+
+// push appends an entry for the connection to the queue of its priority.
+func (p *PushQueue) push(con *Connection, pp *pendingPush) {
+	p.nextSeq++
+	pp.seq = p.nextSeq
+	p.queues[pp.priority] = append(p.queues[pp.priority], queueEntry{con: con, seq: pp.seq})
 	// Signal waiters on Dequeue that a new item is available
 	p.cond.Signal()
 }
 
 // Remove a proxy from the queue. If there are no proxies ready to be removed, this will block
 func (p *PushQueue) Dequeue() (con *Connection, request *model.PushRequest, shutdown bool) {
+	if p.limiter != nil {
+		// Wait for the push budget before picking the proxy, so that the highest priority proxy
+		// at the time of the push is picked.
+		// An error means the queue is shutting down, in which case the pending pushes are drained
+		// without limit.
+		_ = p.limiter.Wait(p.ctx)
+	}
+
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
 
 	// Block until there is one to remove. Enqueue will signal when one is added.
-	for len(p.queue) == 0 && !p.shuttingDown {
+	var pp *pendingPush
+	for {
+		if p.shuttingDown && len(p.pending) == 0 {
+			return nil, nil, true
+		}
+		var wait time.Duration
+		con, pp, wait = p.next()
+		if pp != nil {
+			break
+		}
+		if wait > 0 {
+			p.wakeupAfter(wait)
+		}
 		p.cond.Wait()
 	}
 
-	if len(p.queue) == 0 {
-		// We must be shutting down.
-		return nil, nil, true
-	}
-
-	con, p.queue = p.queue[0], p.queue[1:]
-
-	request = p.pending[con]
+	request = pp.request
 	delete(p.pending, con)
+	queueWaitTime.With(priorityTag.Value(pp.priority.String())).Record(time.Since(pp.enqueued).Seconds())
 
 	// Mark the connection as in progress
 	p.processing[con] = nil
@@ -99,18 +240,94 @@ func (p *PushQueue) Dequeue() (con *Connection, request *model.PushRequest, shut
 	return con, request, false
 }
 
+// next removes and returns the first connection ready to be pushed, by priority. If none is ready,
+// it returns how long to wait for one to leave its debounce window, or 0 if there are none.
+func (p *PushQueue) next() (*Connection, *pendingPush, time.Duration) {
+	now := time.Now()
+	var wait time.Duration
+	for prio := range p.queues {
+		q := p.queues[prio]
+		for i := 0; i < len(q); i++ {
+			e := q[i]
+			pp, f := p.pending[e.con]
+			if !f || pp.seq != e.seq {
+				// Stale entry, remove it.
+				q = removeEntry(q, i)
+				i--
+				continue
+			}
+			if !p.shuttingDown && now.Before(pp.notBefore) {
+				if d := pp.notBefore.Sub(now); wait == 0 || d < wait {
+					wait = d
+				}
+				continue
+			}
+			p.queues[prio] = removeEntry(q, i)
+			return e.con, pp, 0
+		}
+		p.queues[prio] = q
+	}
+	return nil, nil, wait
+}
+
+func removeEntry(q []queueEntry, i int) []queueEntry {
+	if i == 0 {
+		// Entries are usually removed from the front, avoid copying the queue.
+		return q[1:]
+	}
+	return append(q[:i], q[i+1:]...)
+}
+
+// wakeupAfter wakes up Dequeue after d, unless an earlier wake up is already scheduled.
+func (p *PushQueue) wakeupAfter(d time.Duration) {
+	at := time.Now().Add(d)
+	if p.wakeup != nil && !p.wakeup.After(at) {
+		return
+	}
+	p.wakeup = &at
+	time.AfterFunc(d, func() {
+		p.cond.L.Lock()
+		defer p.cond.L.Unlock()
+		if p.wakeup != nil && p.wakeup.Equal(at) {
+			p.wakeup = nil
+		}
+		p.cond.Broadcast()
+	})
+}
+
 func (p *PushQueue) MarkDone(con *Connection) {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
 	request := p.processing[con]
 	delete(p.processing, con)
 
+	if p.proxyDebounce > 0 {
+		p.notBefore[con] = time.Now().Add(p.proxyDebounce)
+		p.pruneNotBefore()
+	}
+
 	// If the info is present, that means Enqueue was called while connection was not yet marked done.
 	// This means we need to add it back to the queue.
 	if request != nil {
-		p.pending[con] = request
-		p.queue = append(p.queue, con)
-		p.cond.Signal()
+		p.add(con, request)
+	}
+}
+
+// pruneNotBefore removes the expired debounce windows, which would otherwise be kept for connections
+// that are never pushed again. The work is amortized by pruning only when the map doubled in size.
+func (p *PushQueue) pruneNotBefore() {
+	if len(p.notBefore) < p.notBeforePruneSize {
+		return
+	}
+	now := time.Now()
+	for con, nb := range p.notBefore {
+		if now.After(nb) {
+			delete(p.notBefore, con)
+		}
+	}
+	p.notBeforePruneSize = 2 * len(p.notBefore)
+	if p.notBeforePruneSize < minNotBeforePruneSize {
+		p.notBeforePruneSize = minNotBeforePruneSize
 	}
 }
 
@@ -118,7 +335,7 @@ func (p *PushQueue) MarkDone(con *Connection) {
 func (p *PushQueue) Pending() int {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
-	return len(p.queue)
+	return len(p.pending)
 }
 
 // ShutDown will cause queue to ignore all new items added to it. As soon as the
@@ -128,5 +345,6 @@ func (p *PushQueue) ShutDown() {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
 	p.shuttingDown = true
+	p.cancel()
 	p.cond.Broadcast()
 }
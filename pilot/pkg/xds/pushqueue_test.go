@@ -294,3 +294,83 @@ func TestProxyQueue(t *testing.T) {
 		}
 	})
 }
+
+func TestProxyQueuePriority(t *testing.T) {
+	leak.Check(t)
+	gateway := &Connection{ConID: "gateway", proxy: &model.Proxy{Type: model.Router}}
+	sidecars := make([]*Connection, 0, 3)
+	for p := 0; p < 3; p++ {
+		sidecars = append(sidecars, &Connection{ConID: fmt.Sprintf("sidecar-%d", p), proxy: &model.Proxy{Type: model.SidecarProxy}})
+	}
+
+	t.Run("gateways and full pushes first", func(t *testing.T) {
+		t.Parallel()
+		p := NewPushQueue()
+		defer p.ShutDown()
+
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		p.Enqueue(sidecars[1], &model.PushRequest{Full: true})
+		p.Enqueue(gateway, &model.PushRequest{})
+		p.Enqueue(sidecars[2], &model.PushRequest{})
+
+		ExpectDequeue(t, p, gateway)
+		ExpectDequeue(t, p, sidecars[1])
+		ExpectDequeue(t, p, sidecars[0])
+		ExpectDequeue(t, p, sidecars[2])
+		ExpectTimeout(t, p)
+	})
+
+	t.Run("merged full push raises priority", func(t *testing.T) {
+		t.Parallel()
+		p := NewPushQueue()
+		defer p.ShutDown()
+
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		p.Enqueue(sidecars[1], &model.PushRequest{})
+		p.Enqueue(sidecars[1], &model.PushRequest{Full: true})
+
+		ExpectDequeue(t, p, sidecars[1])
+		ExpectDequeue(t, p, sidecars[0])
+		ExpectTimeout(t, p)
+	})
+
+	t.Run("proxy debounce", func(t *testing.T) {
+		t.Parallel()
+		p := newPushQueue(300*time.Millisecond, 0)
+		defer p.ShutDown()
+
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		ExpectDequeue(t, p, sidecars[0])
+		p.MarkDone(sidecars[0])
+		start := time.Now()
+		p.Enqueue(sidecars[0], &model.PushRequest{})
+		p.Enqueue(sidecars[1], &model.PushRequest{})
+
+		// Other proxies are not delayed.
+		ExpectDequeue(t, p, sidecars[1])
+		ExpectDequeue(t, p, sidecars[0])
+		if d := time.Since(start); d < 250*time.Millisecond {
+			t.Fatalf("expected push to be debounced, got push after %v", d)
+		}
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		t.Parallel()
+		p := newPushQueue(0, 10)
+		defer p.ShutDown()
+
+		start := time.Now()
+		for i := 0; i < 15; i++ {
+			p.Enqueue(&Connection{ConID: fmt.Sprintf("proxy-%d", i)}, &model.PushRequest{})
+		}
+		for i := 0; i < 15; i++ {
+			if con, _, _ := p.Dequeue(); con == nil {
+				t.Fatal("expected a proxy")
+			}
+		}
+		// The burst of 10 is followed by 5 pushes at 10 per second.
+		if d := time.Since(start); d < 400*time.Millisecond {
+			t.Fatalf("expected pushes to be rate limited, got 15 pushes in %v", d)
+		}
+	})
+}
@@ -61,7 +61,12 @@ func (sr SecretResource) Cacheable() bool {
 	return true
 }
 
-var _ model.XdsCacheEntry = SecretResource{}
+// Sensitive is always true, as secrets hold private keys and must not be persisted.
+func (sr SecretResource) Sensitive() bool {
+	return true
+}
+
+var _ model.SensitiveXdsCacheEntry = SecretResource{}
 
 func parseResourceName(resource, defaultNamespace string) (SecretResource, error) {
 	sep := "/"
@@ -16,7 +16,10 @@ package xds
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -217,3 +220,161 @@ func TestXdsCache(t *testing.T) {
 		}
 	})
 }
+
+func TestXdsCacheBackend(t *testing.T) {
+	ep1 := EndpointBuilder{
+		clusterName: "outbound|1||foo.com",
+		service:     &model.Service{Hostname: "foo.com"},
+	}
+	ep2 := EndpointBuilder{
+		clusterName: "outbound|1||bar.com",
+		service:     &model.Service{Hostname: "bar.com"},
+	}
+	secret := SecretResource{Name: "secret", Namespace: "default", ResourceName: "kubernetes://secret"}
+	addWithToken := func(c model.XdsCache, entry model.XdsCacheEntry, value *discovery.Resource) {
+		_, tok, _ := c.Get(entry)
+		c.Add(entry, tok, value)
+	}
+	expectFound := func(t *testing.T, c model.XdsCache, entry model.XdsCacheEntry, want *discovery.Resource) {
+		t.Helper()
+		got, _, f := c.Get(entry)
+		if want == nil {
+			if f {
+				t.Fatalf("unexpected result for %s: %v", entry.Key(), got)
+			}
+			return
+		}
+		if !f || got.Resource.TypeUrl != want.Resource.TypeUrl {
+			t.Fatalf("unexpected result for %s: %v, want %v", entry.Key(), got, want)
+		}
+	}
+	// newInstance simulates a restart of Istiod with the same directory.
+	newInstance := func(t *testing.T, dir string) (model.XdsCache, func()) {
+		backend, err := model.NewDiskXdsCacheBackend(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			backend.Run(stop)
+			close(done)
+		}()
+		return model.NewXdsCacheWithBackend(backend), func() {
+			close(stop)
+			<-done
+		}
+	}
+
+	t.Run("restart", func(t *testing.T) {
+		dir := t.TempDir()
+		c, stop := newInstance(t, dir)
+		addWithToken(c, ep1, any1)
+		addWithToken(c, ep2, any2)
+		addWithToken(c, secret, any1)
+		stop()
+
+		c, stop = newInstance(t, dir)
+		defer stop()
+		if len(c.Keys()) != 0 {
+			t.Fatalf("expected values to be loaded lazily, got keys %v", c.Keys())
+		}
+		expectFound(t, c, ep1, any1)
+		expectFound(t, c, ep2, any2)
+		// Secrets are never persisted.
+		expectFound(t, c, secret, nil)
+	})
+
+	t.Run("invalidations are shared", func(t *testing.T) {
+		dir := t.TempDir()
+		c, stop := newInstance(t, dir)
+		addWithToken(c, ep1, any1)
+		addWithToken(c, ep2, any2)
+		stop()
+
+		// Instances cannot tell which shared entries depend on the config, so all of them are invalidated.
+		c, stop = newInstance(t, dir)
+		c.Clear(map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "foo.com"}: {}})
+		expectFound(t, c, ep1, nil)
+		expectFound(t, c, ep2, nil)
+		addWithToken(c, ep2, any2)
+		stop()
+
+		c, stop = newInstance(t, dir)
+		defer stop()
+		expectFound(t, c, ep1, nil)
+		expectFound(t, c, ep2, any2)
+	})
+
+	t.Run("clear all", func(t *testing.T) {
+		dir := t.TempDir()
+		c1, stop1 := newInstance(t, dir)
+		c2, stop2 := newInstance(t, dir)
+		addWithToken(c1, ep1, any1)
+		c2.ClearAll()
+		// The in memory entries of other instances are left to their own invalidations.
+		expectFound(t, c1, ep1, any1)
+		// But the shared entries are invalidated.
+		expectFound(t, c2, ep1, nil)
+		addWithToken(c2, ep2, any2)
+		stop1()
+		stop2()
+
+		c3, stop3 := newInstance(t, dir)
+		defer stop3()
+		expectFound(t, c3, ep1, nil)
+		expectFound(t, c3, ep2, any2)
+	})
+
+	t.Run("stale writes of other instances", func(t *testing.T) {
+		dir := t.TempDir()
+		c1, stop1 := newInstance(t, dir)
+		c2, stop2 := newInstance(t, dir)
+		defer stop2()
+		// c1 starts generating before c2 invalidates the config, and writes afterwards.
+		_, tok, _ := c1.Get(ep1)
+		c2.Clear(map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "foo.com"}: {}})
+		c1.Add(ep1, tok, any1)
+		stop1()
+		expectFound(t, c2, ep1, nil)
+	})
+
+	t.Run("older writes do not replace newer ones", func(t *testing.T) {
+		dir := t.TempDir()
+		c1, stop1 := newInstance(t, dir)
+		c2, stop2 := newInstance(t, dir)
+		_, tok1, _ := c1.Get(ep1)
+		c2.ClearAll()
+		_, tok2, _ := c2.Get(ep1)
+		c2.Add(ep1, tok2, any2)
+		stop2()
+		c1.Add(ep1, tok1, any1)
+		stop1()
+
+		c3, stop3 := newInstance(t, dir)
+		defer stop3()
+		expectFound(t, c3, ep1, any2)
+	})
+
+	t.Run("stale generation lock", func(t *testing.T) {
+		dir := t.TempDir()
+		backend, err := model.NewDiskXdsCacheBackend(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lock := filepath.Join(dir, "generation.lock")
+		if err := ioutil.WriteFile(lock, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-time.Minute)
+		if err := os.Chtimes(lock, old, old); err != nil {
+			t.Fatal(err)
+		}
+		if err := backend.Invalidate(); err != nil {
+			t.Fatalf("failed to invalidate with a stale lock: %v", err)
+		}
+		if g, err := backend.Generation(); err != nil || g != 1 {
+			t.Fatalf("got generation %d and error %v, want 1", g, err)
+		}
+	})
+}
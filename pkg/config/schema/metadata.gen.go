@@ -298,6 +298,7 @@ snapshots:
       - "istio/networking/v1alpha3/sidecars"
       - "istio/networking/v1alpha3/virtualservices"
       - "istio/security/v1beta1/authorizationpolicies"
+      - "istio/security/v1beta1/peerauthentications"
       - "k8s/apiextensions.k8s.io/v1/customresourcedefinitions"
       - "k8s/admissionregistration.k8s.io/v1/mutatingwebhookconfigurations"
       - "k8s/apps/v1/deployments"
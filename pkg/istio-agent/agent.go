@@ -137,6 +137,10 @@ type AgentOptions struct {
 	// ProxyDomain is the DNS domain associated with the proxy (assumed
 	// to include the namespace as well) (for local dns resolution)
 	ProxyDomain string
+	// DNSRecordTTL is the TTL of the DNS records of hosts known to the mesh
+	DNSRecordTTL time.Duration
+	// DNSUpstreamCacheSize is the maximum number of upstream DNS responses cached by the agent, 0 disables the cache
+	DNSUpstreamCacheSize int
 	// Node identifier used by Envoy
 	ServiceNode string
 
@@ -176,6 +180,9 @@ type AgentOptions struct {
 
 	// Cloud platform
 	Platform platform.Environment
+
+	// OCI registries which are accessed over plain HTTP when fetching Wasm modules.
+	WASMInsecureRegistries []string
 }
 
 // NewAgent hosts the functionality for local SDS and XDS. This consists of the local SDS server and
@@ -421,7 +428,10 @@ func (a *Agent) Run(ctx context.Context) (func(), error) {
 func (a *Agent) initLocalDNSServer() (err error) {
 	// we dont need dns server on gateways
 	if a.cfg.DNSCapture && a.cfg.ProxyXDSViaAgent && a.cfg.ProxyType == model.SidecarProxy {
-		if a.localDNSServer, err = dns.NewLocalDNSServer(a.cfg.ProxyNamespace, a.cfg.ProxyDomain); err != nil {
+		if a.localDNSServer, err = dns.NewLocalDNSServer(a.cfg.ProxyNamespace, a.cfg.ProxyDomain, dns.Options{
+			RecordTTL:         a.cfg.DNSRecordTTL,
+			UpstreamCacheSize: a.cfg.DNSUpstreamCacheSize,
+		}); err != nil {
 			return err
 		}
 		a.localDNSServer.StartDNS()
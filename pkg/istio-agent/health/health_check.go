@@ -70,16 +70,37 @@ func fillInDefaults(cfg *v1alpha3.ReadinessProbe, ipAddresses []string) *v1alpha
 		}
 		h.HttpGet.Scheme = strings.ToLower(h.HttpGet.Scheme)
 		if h.HttpGet.Host == "" {
-			if len(ipAddresses) == 0 || status.LegacyLocalhostProbeDestination.Get() {
-				h.HttpGet.Host = "localhost"
-			} else {
-				h.HttpGet.Host = ipAddresses[0]
-			}
+			h.HttpGet.Host = defaultProbeHost(ipAddresses)
 		}
 	}
 	return cfg
 }
 
+func defaultProbeHost(ipAddresses []string) string {
+	if len(ipAddresses) == 0 || status.LegacyLocalhostProbeDestination.Get() {
+		return "localhost"
+	}
+	return ipAddresses[0]
+}
+
+// newExecProber returns a gRPC prober if the command runs grpc_health_probe, so that gRPC health checks
+// do not require the binary on the workload, or an ExecProber running the command otherwise.
+func newExecProber(cfg *v1alpha3.ExecHealthCheckConfig, proxyAddrs []string, ipv6 bool) Prober {
+	probe, ok := status.ParseGRPCHealthProbeCommand(cfg.Command)
+	if !ok {
+		return &ExecProber{Config: cfg}
+	}
+	if probe.Host == "" {
+		probe.Host = defaultProbeHost(proxyAddrs)
+	}
+	prober, err := NewGRPCProber(probe, ipv6)
+	if err != nil {
+		healthCheckLog.Warnf("failed to configure gRPC health check, running the command instead: %v", err)
+		return &ExecProber{Config: cfg}
+	}
+	return prober
+}
+
 func NewWorkloadHealthChecker(cfg *v1alpha3.ReadinessProbe, envoyProbe ready.Prober, proxyAddrs []string, ipv6 bool) *WorkloadHealthChecker {
 	// if a config does not exist return a no-op prober
 	if cfg == nil {
@@ -93,7 +114,7 @@ func NewWorkloadHealthChecker(cfg *v1alpha3.ReadinessProbe, envoyProbe ready.Pro
 	case *v1alpha3.ReadinessProbe_TcpSocket:
 		prober = &TCPProber{Config: healthCheckMethod.TcpSocket}
 	case *v1alpha3.ReadinessProbe_Exec:
-		prober = &ExecProber{Config: healthCheckMethod.Exec}
+		prober = newExecProber(healthCheckMethod.Exec, proxyAddrs, ipv6)
 	default:
 		prober = nil
 	}
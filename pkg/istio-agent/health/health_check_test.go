@@ -172,3 +172,29 @@ func TestWorkloadHealthChecker_PerformApplicationHealthCheck(t *testing.T) {
 		}, retry.Delay(time.Millisecond*10), retry.Timeout(time.Second))
 	})
 }
+
+func TestNewWorkloadHealthCheckerGRPC(t *testing.T) {
+	newProber := func(command ...string) Prober {
+		checker := NewWorkloadHealthChecker(&v1alpha3.ReadinessProbe{
+			HealthCheckMethod: &v1alpha3.ReadinessProbe_Exec{
+				Exec: &v1alpha3.ExecHealthCheckConfig{Command: command},
+			},
+		}, nil, []string{"10.0.0.1"}, false)
+		return checker.prober.(AggregateProber).Probes[0]
+	}
+
+	grpcProber, ok := newProber("grpc_health_probe", "-addr=:5000", "-service=echo", "-tls").(*GRPCProber)
+	if !ok {
+		t.Fatalf("expected a gRPC prober")
+	}
+	if grpcProber.Config.Host != "10.0.0.1" || grpcProber.Config.Port != 5000 || *grpcProber.Config.Service != "echo" ||
+		grpcProber.TLSConfig == nil {
+		t.Errorf("unexpected gRPC prober config %+v", grpcProber.Config)
+	}
+	if _, ok := newProber("grpc_health_probe", "-addr=:5000", "-alts").(*ExecProber); !ok {
+		t.Errorf("expected an exec prober for unsupported grpc_health_probe options")
+	}
+	if _, ok := newProber("cat", "/tmp/healthy").(*ExecProber); !ok {
+		t.Errorf("expected an exec prober")
+	}
+}
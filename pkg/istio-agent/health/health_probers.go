@@ -17,7 +17,9 @@ package health
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
@@ -162,6 +164,66 @@ func (t *TCPProber) Probe(timeout time.Duration) (ProbeResult, error) {
 	return Healthy, nil
 }
 
+// GRPCProber checks a gRPC server with the gRPC health checking protocol. It is configured from a
+// grpc_health_probe exec command, as readiness probes have no native gRPC health check.
+type GRPCProber struct {
+	Config    *status.GRPCHealthProbe
+	TLSConfig *tls.Config
+	dialer    *net.Dialer
+}
+
+var _ Prober = &GRPCProber{}
+
+func NewGRPCProber(cfg *status.GRPCHealthProbe, ipv6 bool) (*GRPCProber, error) {
+	g := &GRPCProber{
+		Config: cfg,
+		dialer: &net.Dialer{LocalAddr: status.UpstreamLocalAddressIPv4},
+	}
+	if ipv6 {
+		g.dialer.LocalAddr = status.UpstreamLocalAddressIPv6
+	}
+	if !cfg.TLS {
+		return g, nil
+	}
+	g.TLSConfig = &tls.Config{
+		InsecureSkipVerify: cfg.TLSNoVerify,
+		ServerName:         cfg.TLSServerName,
+	}
+	if cfg.TLSCACert != "" {
+		caCert, err := ioutil.ReadFile(cfg.TLSCACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %v", cfg.TLSCACert)
+		}
+		g.TLSConfig.RootCAs = pool
+	}
+	if cfg.TLSClientCert != "" || cfg.TLSClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSClientCert, cfg.TLSClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		g.TLSConfig.Certificates = []tls.Certificate{cert}
+	}
+	return g, nil
+}
+
+func (g *GRPCProber) Probe(timeout time.Duration) (ProbeResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var service string
+	if g.Config.Service != nil {
+		service = *g.Config.Service
+	}
+	addr := net.JoinHostPort(g.Config.Host, strconv.Itoa(g.Config.Port))
+	if err := status.CheckGRPCHealth(ctx, g.dialer, addr, service, g.TLSConfig); err != nil {
+		return Unhealthy, err
+	}
+	return Healthy, nil
+}
+
 type ExecProber struct {
 	Config *v1alpha3.ExecHealthCheckConfig
 }
@@ -24,7 +24,12 @@ import (
 	"testing"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
 	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/cmd/pilot-agent/status"
 	"istio.io/istio/tests/util/leak"
 )
 
@@ -176,6 +181,96 @@ func TestExecProber(t *testing.T) {
 	}
 }
 
+func TestGRPCProber(t *testing.T) {
+	serving, notServing, unknown := "serving", "not-serving", "unknown"
+	tests := []struct {
+		desc                string
+		service             *string
+		closed              bool
+		expectedProbeResult ProbeResult
+	}{
+		{
+			desc:                "Healthy - server",
+			expectedProbeResult: Healthy,
+		},
+		{
+			desc:                "Healthy - service",
+			service:             &serving,
+			expectedProbeResult: Healthy,
+		},
+		{
+			desc:                "Unhealthy - service not serving",
+			service:             &notServing,
+			expectedProbeResult: Unhealthy,
+		},
+		{
+			desc:                "Unhealthy - unknown service",
+			service:             &unknown,
+			expectedProbeResult: Unhealthy,
+		},
+		{
+			desc:                "Unhealthy - Could not connect to server",
+			closed:              true,
+			expectedProbeResult: Unhealthy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			server, port := createGRPCServer(t)
+			defer server.Stop()
+			healthServer := health.NewServer()
+			healthServer.SetServingStatus(serving, grpc_health_v1.HealthCheckResponse_SERVING)
+			healthServer.SetServingStatus(notServing, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+			grpc_health_v1.RegisterHealthServer(server, healthServer)
+			if tt.closed {
+				server.Stop()
+			}
+
+			grpcProber, err := NewGRPCProber(&status.GRPCHealthProbe{
+				Host:    "127.0.0.1",
+				Port:    port,
+				Service: tt.service,
+			}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := grpcProber.Probe(time.Second)
+			if got != tt.expectedProbeResult || (got == Healthy) != (err == nil) {
+				t.Errorf("%s: got: %v, expected: %v, got error: %v", tt.desc, got, tt.expectedProbeResult, err)
+			}
+		})
+	}
+}
+
+func TestNewGRPCProberTLS(t *testing.T) {
+	g, err := NewGRPCProber(&status.GRPCHealthProbe{Port: 5000, TLS: true, TLSServerName: "app.example.com"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.TLSConfig == nil || g.TLSConfig.ServerName != "app.example.com" || g.TLSConfig.InsecureSkipVerify {
+		t.Errorf("unexpected TLS config %+v", g.TLSConfig)
+	}
+	if g, _ := NewGRPCProber(&status.GRPCHealthProbe{Port: 5000}, false); g.TLSConfig != nil {
+		t.Errorf("unexpected TLS config %+v", g.TLSConfig)
+	}
+	if _, err := NewGRPCProber(&status.GRPCHealthProbe{Port: 5000, TLS: true, TLSCACert: "/does/not/exist"}, false); err == nil {
+		t.Errorf("expected an error for a missing CA certificate")
+	}
+}
+
+func createGRPCServer(t *testing.T) (*grpc.Server, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go func() {
+		_ = server.Serve(l)
+	}()
+	return server, l.Addr().(*net.TCPAddr).Port
+}
+
 func createHTTPServer(statusCode int) (*httptest.Server, uint32) {
 	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		writer.WriteHeader(statusCode)
@@ -137,6 +137,8 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 		AdminPort:     uint16(ia.proxyConfig.ProxyAdminPort),
 		LocalHostAddr: localHostAddr,
 	}
+	wasmCache := wasm.NewLocalFileCache(constants.IstioDataDir, wasm.DefaultWasmModulePurgeInteval, wasm.DefaultWasmModuleExpiry,
+		ia.cfg.WASMInsecureRegistries)
 	proxy := &XdsProxy{
 		istiodAddress:  ia.proxyConfig.DiscoveryAddress,
 		clusterID:      ia.secOpts.ClusterID,
@@ -145,7 +147,7 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 		healthChecker:  health.NewWorkloadHealthChecker(ia.proxyConfig.ReadinessProbe, envoyProbe, ia.cfg.ProxyIPAddresses, ia.cfg.IsIPv6),
 		xdsHeaders:     ia.cfg.XDSHeaders,
 		xdsUdsPath:     ia.cfg.XdsUdsPath,
-		wasmCache:      wasm.NewLocalFileCache(constants.IstioDataDir, wasm.DefaultWasmModulePurgeInteval, wasm.DefaultWasmModuleExpiry),
+		wasmCache:      wasmCache,
 		proxyAddresses: ia.cfg.ProxyIPAddresses,
 	}
 
@@ -26,6 +26,7 @@ import (
 
 	"istio.io/api/annotation"
 	"istio.io/istio/pilot/cmd/pilot-agent/status"
+	"istio.io/istio/pkg/kube/apimirror"
 	"istio.io/pkg/log"
 )
 
@@ -61,7 +62,22 @@ func FindContainer(name string, containers []corev1.Container) *corev1.Container
 
 // convertAppProber returns an overwritten `Probe` for pilot agent to take over.
 func convertAppProber(probe *corev1.Probe, newURL string, statusPort int) *corev1.Probe {
-	if probe == nil || probe.HTTPGet == nil {
+	if probe == nil {
+		return nil
+	}
+	if probe.TCPSocket != nil || grpcHealthProbeAction(probe.Exec) != nil {
+		// The TCP connection or gRPC health check is performed by the pilot agent.
+		// Kubelet -> HTTP -> Pilot Agent -> TCP or gRPC -> Application
+		p := probe.DeepCopy()
+		p.TCPSocket = nil
+		p.Exec = nil
+		p.HTTPGet = &corev1.HTTPGetAction{
+			Path: newURL,
+			Port: intstr.FromInt(statusPort),
+		}
+		return p
+	}
+	if probe.HTTPGet == nil {
 		return nil
 	}
 	p := probe.DeepCopy()
@@ -81,8 +97,13 @@ type KubeAppProbers map[string]*Prober
 
 // Prober represents a single container prober
 type Prober struct {
-	HTTPGet        *corev1.HTTPGetAction `json:"httpGet"`
-	TimeoutSeconds int32                 `json:"timeoutSeconds,omitempty"`
+	HTTPGet   *corev1.HTTPGetAction   `json:"httpGet,omitempty"`
+	TCPSocket *corev1.TCPSocketAction `json:"tcpSocket,omitempty"`
+	GRPC      *apimirror.GRPCAction   `json:"grpc,omitempty"`
+	// Exec is the exec action a GRPC prober was converted from. It is ignored by the pilot agent, and only kept so
+	// that uninjection restores the original probe.
+	Exec           *corev1.ExecAction `json:"exec,omitempty"`
+	TimeoutSeconds int32              `json:"timeoutSeconds,omitempty"`
 }
 
 // DumpAppProbers returns a json encoded string as `status.KubeAppProbers`.
@@ -90,9 +111,22 @@ type Prober struct {
 func DumpAppProbers(podspec *corev1.PodSpec, targetPort int32) string {
 	out := KubeAppProbers{}
 	updateNamedPort := func(p *Prober, portMap map[string]int32) *Prober {
-		if p == nil || p.HTTPGet == nil {
+		if p == nil {
 			return nil
 		}
+		if p.TCPSocket != nil {
+			if p.TCPSocket.Port.Type == intstr.String {
+				port, exists := portMap[p.TCPSocket.Port.StrVal]
+				if !exists {
+					return nil
+				}
+				p.TCPSocket.Port = intstr.FromInt(int(port))
+			}
+			return p
+		}
+		if p.HTTPGet == nil {
+			return p
+		}
 		if p.HTTPGet.Port.Type == intstr.String {
 			port, exists := portMap[p.HTTPGet.Port.StrVal]
 			if !exists {
@@ -182,12 +216,44 @@ func kubeProbeToInternalProber(probe *corev1.Probe) *Prober {
 		return nil
 	}
 
-	if probe.HTTPGet == nil {
-		return nil
+	switch {
+	case probe.HTTPGet != nil:
+		return &Prober{
+			HTTPGet:        probe.HTTPGet,
+			TimeoutSeconds: probe.TimeoutSeconds,
+		}
+	case probe.TCPSocket != nil:
+		return &Prober{
+			TCPSocket:      probe.TCPSocket,
+			TimeoutSeconds: probe.TimeoutSeconds,
+		}
+	}
+	if grpc := grpcHealthProbeAction(probe.Exec); grpc != nil {
+		return &Prober{
+			GRPC:           grpc,
+			Exec:           probe.Exec,
+			TimeoutSeconds: probe.TimeoutSeconds,
+		}
 	}
+	return nil
+}
 
-	return &Prober{
-		HTTPGet:        probe.HTTPGet,
-		TimeoutSeconds: probe.TimeoutSeconds,
+// grpcHealthProbeAction returns the gRPC health check performed by an exec probe running grpc_health_probe
+// (https://github.com/grpc-ecosystem/grpc-health-probe) against a local port, which is how gRPC health checks are
+// declared before Kubernetes supports them natively. It returns nil for any other command, or if the probe uses
+// options that the pilot agent does not support, such as TLS.
+func grpcHealthProbeAction(exec *corev1.ExecAction) *apimirror.GRPCAction {
+	if exec == nil {
+		return nil
+	}
+	probe, ok := status.ParseGRPCHealthProbeCommand(exec.Command)
+	if !ok || probe.TLS {
+		return nil
+	}
+	switch probe.Host {
+	case "", "localhost", "127.0.0.1", "::1":
+	default:
+		return nil
 	}
+	return &apimirror.GRPCAction{Port: int32(probe.Port), Service: probe.Service}
 }
@@ -14,12 +14,15 @@
 package inject
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/gogo/protobuf/types"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"istio.io/api/annotation"
+	"istio.io/istio/pkg/kube/apimirror"
 )
 
 func TestFindSidecar(t *testing.T) {
@@ -110,3 +113,66 @@ func TestShouldRewriteAppHTTPProbers(t *testing.T) {
 		}
 	}
 }
+
+func TestGRPCHealthProbeAction(t *testing.T) {
+	service := "echo"
+	for _, tc := range []struct {
+		name     string
+		command  []string
+		expected *apimirror.GRPCAction
+	}{
+		{"not-grpc-health-probe", []string{"cat", "/tmp/healthy"}, nil},
+		{"port-only", []string{"/bin/grpc_health_probe", "-addr=:5000"}, &apimirror.GRPCAction{Port: 5000}},
+		{"localhost-and-service", []string{"grpc_health_probe", "--addr", "localhost:5000", "-service=echo"},
+			&apimirror.GRPCAction{Port: 5000, Service: &service}},
+		{"timeouts", []string{"grpc_health_probe", "-addr=127.0.0.1:5000", "-connect-timeout=250ms", "-rpc-timeout", "1s"},
+			&apimirror.GRPCAction{Port: 5000}},
+		{"remote-host", []string{"grpc_health_probe", "-addr=example.com:5000"}, nil},
+		{"tls", []string{"grpc_health_probe", "-addr=:5000", "-tls"}, nil},
+		{"missing-addr", []string{"grpc_health_probe", "-service=echo"}, nil},
+		{"missing-value", []string{"grpc_health_probe", "-addr"}, nil},
+	} {
+		got := grpcHealthProbeAction(&corev1.ExecAction{Command: tc.command})
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("[%v] failed, want %+v, got %+v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestRewriteTCPAndGRPCProbes(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{
+			Name:  "app",
+			Ports: []corev1.ContainerPort{{Name: "tcp", ContainerPort: 9000}},
+			ReadinessProbe: &corev1.Probe{
+				Handler:        corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("tcp"), Host: "127.0.0.2"}},
+				TimeoutSeconds: 2,
+			},
+			LivenessProbe: &corev1.Probe{
+				Handler: corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"grpc_health_probe", "-addr=:5000"}}},
+			},
+		},
+		{Name: ProxyContainerName},
+	}}}
+
+	probers := DumpAppProbers(&pod.Spec, 15020)
+	want := `{"/app-health/app/livez":{"grpc":{"port":5000,"service":null},"exec":{"command":["grpc_health_probe","-addr=:5000"]}},` +
+		`"/app-health/app/readyz":{"tcpSocket":{"port":9000,"host":"127.0.0.2"},"timeoutSeconds":2}}`
+	if probers != want {
+		t.Errorf("unexpected app probers, want %v, got %v", want, probers)
+	}
+
+	patchRewriteProbe(nil, pod, 15020)
+	app := pod.Spec.Containers[0]
+	for _, probe := range []*corev1.Probe{app.ReadinessProbe, app.LivenessProbe} {
+		if probe.TCPSocket != nil || probe.Exec != nil || probe.HTTPGet == nil || probe.HTTPGet.Port.IntValue() != 15020 {
+			t.Errorf("probe was not rewritten to the status port: %+v", probe)
+		}
+	}
+	if app.ReadinessProbe.HTTPGet.Path != "/app-health/app/readyz" || app.ReadinessProbe.TimeoutSeconds != 2 {
+		t.Errorf("unexpected readiness probe %+v", app.ReadinessProbe)
+	}
+	if app.LivenessProbe.HTTPGet.Path != "/app-health/app/livez" {
+		t.Errorf("unexpected liveness probe %+v", app.LivenessProbe)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// awsCredentialPrefix is the prefix of the credentials of AWS EC2 instances.
+const awsCredentialPrefix = "aws-iid."
+
+// EncodeAWSCredential returns the credential of an AWS EC2 instance, made of its instance identity
+// document, the RSA-SHA256 signature of the document and the nonce the instance is bound to on its
+// first authentication, so that it can be sent as a bearer token.
+func EncodeAWSCredential(document, signature, nonce []byte) string {
+	return awsCredentialPrefix + base64.RawURLEncoding.EncodeToString(document) + "." +
+		base64.RawURLEncoding.EncodeToString(signature) + "." + base64.RawURLEncoding.EncodeToString(nonce)
+}
+
+// IsAWSCredential returns true if the credential is the credential of an AWS EC2 instance.
+func IsAWSCredential(credential string) bool {
+	return strings.HasPrefix(credential, awsCredentialPrefix)
+}
+
+// DecodeAWSCredential returns the instance identity document, its signature and the nonce from the
+// credential of an AWS EC2 instance.
+func DecodeAWSCredential(credential string) (document, signature, nonce []byte, err error) {
+	if !IsAWSCredential(credential) {
+		return nil, nil, nil, fmt.Errorf("not an AWS instance identity credential")
+	}
+	parts := strings.Split(strings.TrimPrefix(credential, awsCredentialPrefix), ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, fmt.Errorf("malformed AWS instance identity credential")
+	}
+	if document, err = base64.RawURLEncoding.DecodeString(parts[0]); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode the instance identity document: %v", err)
+	}
+	if signature, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode the instance identity signature: %v", err)
+	}
+	if nonce, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode the instance identity nonce: %v", err)
+	}
+	if len(nonce) == 0 {
+		return nil, nil, nil, fmt.Errorf("the instance identity nonce is missing")
+	}
+	return document, signature, nonce, nil
+}
@@ -51,8 +51,11 @@ const (
 	WorkloadKeyCertResourceName = "default"
 
 	// Credential fetcher type
-	GCE  = "GoogleComputeEngine"
-	Mock = "Mock" // testing only
+	GCE      = "GoogleComputeEngine"
+	AWS      = "AmazonWebServices"
+	Azure    = "Azure"
+	OIDCFile = "OIDCTokenFile"
+	Mock     = "Mock" // testing only
 
 	// GoogleCAProvider uses the Google CA for workload certificate signing
 	GoogleCAProvider = "GoogleCA"
@@ -156,6 +159,10 @@ type Options struct {
 	// credential identity provider
 	CredIdentityProvider string
 
+	// The audience of the platform credential requested by the credential fetcher.
+	// Defaults to the trust domain if unset.
+	CredAudience string
+
 	// Namespace corresponding to workload
 	WorkloadNamespace string
 
@@ -257,7 +264,8 @@ type CredFetcher interface {
 	// GetPlatformCredential fetches workload credential provided by the platform.
 	GetPlatformCredential() (string, error)
 
-	// GetType returns credential fetcher type. Currently the supported type is "GoogleComputeEngine".
+	// GetType returns credential fetcher type. Currently the supported types are "GoogleComputeEngine",
+	// "AmazonWebServices", "Azure" and "OIDCTokenFile".
 	GetType() string
 
 	// The name of the IdentityProvider that can authenticate the workload credential.
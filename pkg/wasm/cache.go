@@ -16,12 +16,14 @@ package wasm
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -40,7 +42,9 @@ const (
 
 // Cache models a Wasm module cache.
 type Cache interface {
-	Get(url, checksum string, timeout time.Duration) (string, error)
+	// Get returns the path of the local Wasm module file for the given URL. For OCI images, pullSecret is an
+	// optional docker config json used to authenticate with the registry.
+	Get(url, checksum string, timeout time.Duration, pullSecret []byte) (string, error)
 	Cleanup()
 }
 
@@ -52,6 +56,9 @@ type LocalFileCache struct {
 	// http fetcher fetches Wasm module with HTTP get.
 	httpFetcher *HTTPFetcher
 
+	// Registries which are accessed over plain HTTP when fetching OCI images.
+	insecureRegistries map[string]struct{}
+
 	// directory path used to store Wasm module.
 	dir string
 
@@ -83,14 +90,19 @@ type cacheEntry struct {
 }
 
 // NewLocalFileCache create a new Wasm module cache which downloads and stores Wasm module files locally.
-func NewLocalFileCache(dir string, purgeInterval, moduleExpiry time.Duration) *LocalFileCache {
+// OCI images hosted on insecureRegistries are fetched over plain HTTP.
+func NewLocalFileCache(dir string, purgeInterval, moduleExpiry time.Duration, insecureRegistries []string) *LocalFileCache {
 	cache := &LocalFileCache{
-		httpFetcher:      NewHTTPFetcher(),
-		modules:          make(map[cacheKey]cacheEntry),
-		dir:              dir,
-		purgeInterval:    purgeInterval,
-		wasmModuleExpiry: moduleExpiry,
-		stopChan:         make(chan struct{}),
+		httpFetcher:        NewHTTPFetcher(),
+		insecureRegistries: make(map[string]struct{}),
+		modules:            make(map[cacheKey]cacheEntry),
+		dir:                dir,
+		purgeInterval:      purgeInterval,
+		wasmModuleExpiry:   moduleExpiry,
+		stopChan:           make(chan struct{}),
+	}
+	for _, r := range insecureRegistries {
+		cache.insecureRegistries[r] = struct{}{}
 	}
 	go func() {
 		cache.purge()
@@ -99,17 +111,22 @@ func NewLocalFileCache(dir string, purgeInterval, moduleExpiry time.Duration) *L
 }
 
 // Get returns path the local Wasm module file.
-func (c *LocalFileCache) Get(downloadURL, checksum string, timeout time.Duration) (string, error) {
+func (c *LocalFileCache) Get(downloadURL, checksum string, timeout time.Duration, pullSecret []byte) (string, error) {
 	url, err := url.Parse(downloadURL)
 	if err != nil {
 		return "", fmt.Errorf("fail to parse Wasm module fetch url: %s", downloadURL)
 	}
+	// Modules are indexed by their bare hex encoded sha256 checksum, so strip the optional algorithm prefix.
+	checksum = strings.TrimPrefix(checksum, "sha256:")
 	// Construct Wasm cache key with downloading URL and provided checksum of the module.
 	key := cacheKey{
 		downloadURL: downloadURL,
 		checksum:    checksum,
 	}
 
+	var b []byte
+	// Checksum of the fetched module. For OCI images, this is the digest of the image manifest.
+	var dChecksum string
 	switch url.Scheme {
 	case "http", "https":
 		// First check if the cache entry is already downloaded.
@@ -118,34 +135,55 @@ func (c *LocalFileCache) Get(downloadURL, checksum string, timeout time.Duration
 		}
 
 		// If the module is not available locally, download the Wasm module with http fetcher.
-		b, err := c.httpFetcher.Fetch(downloadURL, timeout)
+		b, err = c.httpFetcher.Fetch(downloadURL, timeout)
 		if err != nil {
 			wasmRemoteFetchCount.With(resultTag.Value(downloadFailure)).Increment()
 			return "", err
 		}
 
 		// Get sha256 checksum and check if it is the same as provided one.
-		dChecksum := fmt.Sprintf("%x", sha256.Sum256(b))
+		dChecksum = fmt.Sprintf("%x", sha256.Sum256(b))
 		if checksum != "" && dChecksum != checksum {
 			wasmRemoteFetchCount.With(resultTag.Value(checksumMismatch)).Increment()
 			return "", fmt.Errorf("module downloaded from %v has checksum %v, which does not match: %v", downloadURL, dChecksum, checksum)
 		}
+	case "oci":
+		// First check if the cache entry is already downloaded.
+		if modulePath := c.getEntry(key); modulePath != "" {
+			return modulePath, nil
+		}
 
-		wasmRemoteFetchCount.With(resultTag.Value(fetchSuccess)).Increment()
-
-		// TODO(bianpengyuan): Add sanity check on downloaded file to make sure it is a valid Wasm module.
-
-		key.checksum = dChecksum
-		f := filepath.Join(c.dir, fmt.Sprintf("%s.wasm", dChecksum))
-
-		if err := c.addEntry(key, b, f); err != nil {
+		_, insecure := c.insecureRegistries[url.Host]
+		fetcher, err := NewImageFetcher(ImageFetcherOption{PullSecret: pullSecret, Insecure: insecure}, timeout)
+		if err != nil {
+			wasmRemoteFetchCount.With(resultTag.Value(downloadFailure)).Increment()
+			return "", err
+		}
+		b, dChecksum, err = fetcher.Fetch(url.Host+url.Path, checksum)
+		if err != nil {
+			if errors.Is(err, errDigestMismatch) {
+				wasmRemoteFetchCount.With(resultTag.Value(checksumMismatch)).Increment()
+			} else {
+				wasmRemoteFetchCount.With(resultTag.Value(downloadFailure)).Increment()
+			}
 			return "", err
 		}
-
-		return f, nil
 	default:
 		return "", fmt.Errorf("unsupported Wasm module downloading URL scheme: %v", url.Scheme)
 	}
+
+	wasmRemoteFetchCount.With(resultTag.Value(fetchSuccess)).Increment()
+
+	// TODO(bianpengyuan): Add sanity check on downloaded file to make sure it is a valid Wasm module.
+
+	key.checksum = dChecksum
+	f := filepath.Join(c.dir, fmt.Sprintf("%s.wasm", dChecksum))
+
+	if err := c.addEntry(key, b, f); err != nil {
+		return "", err
+	}
+
+	return f, nil
 }
 
 // Cleanup closes background Wasm module purge routine.
@@ -78,12 +78,12 @@ func TestWasmCache(t *testing.T) {
 		{
 			name:                 "invalid scheme",
 			initialCachedModules: map[cacheKey]cacheEntry{},
-			fetchURL:             "oci://abc",
+			fetchURL:             "ftp://abc",
 			purgeInterval:        DefaultWasmModulePurgeInteval,
 			wasmModuleExpiry:     DefaultWasmModuleExpiry,
 			checksum:             dataCheckSum,
 			wantFileName:         fmt.Sprintf("%x.wasm", dataCheckSum),
-			wantErrorMsgPrefix:   "unsupported Wasm module downloading URL scheme: oci",
+			wantErrorMsgPrefix:   "unsupported Wasm module downloading URL scheme: ftp",
 			wantServerReqNum:     0,
 		},
 		{
@@ -138,7 +138,7 @@ func TestWasmCache(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			tmpDir := t.TempDir()
-			cache := NewLocalFileCache(tmpDir, c.purgeInterval, c.wasmModuleExpiry)
+			cache := NewLocalFileCache(tmpDir, c.purgeInterval, c.wasmModuleExpiry, nil)
 			defer close(cache.stopChan)
 			tsNumRequest = 0
 
@@ -168,7 +168,7 @@ func TestWasmCache(t *testing.T) {
 				}
 			}
 
-			gotFilePath, gotErr := cache.Get(c.fetchURL, fmt.Sprintf("%x", c.checksum), 0)
+			gotFilePath, gotErr := cache.Get(c.fetchURL, fmt.Sprintf("%x", c.checksum), 0, nil)
 			wantFilePath := filepath.Join(tmpDir, c.wantFileName)
 			if c.wantErrorMsgPrefix != "" {
 				if gotErr == nil {
@@ -191,7 +191,7 @@ func TestWasmCache(t *testing.T) {
 
 func TestWasmCacheMissChecksum(t *testing.T) {
 	tmpDir := t.TempDir()
-	cache := NewLocalFileCache(tmpDir, DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry)
+	cache := NewLocalFileCache(tmpDir, DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry, nil)
 	defer close(cache.stopChan)
 
 	gotNumRequest := 0
@@ -210,7 +210,7 @@ func TestWasmCacheMissChecksum(t *testing.T) {
 
 	// Get wasm module three times, since checksum is not specified, it will be fetched from module server every time.
 	// 1st time
-	gotFilePath, err := cache.Get(ts.URL, "", 0)
+	gotFilePath, err := cache.Get(ts.URL, "", 0, nil)
 	if err != nil {
 		t.Fatalf("failed to download Wasm module: %v", err)
 	}
@@ -219,7 +219,7 @@ func TestWasmCacheMissChecksum(t *testing.T) {
 	}
 
 	// 2nd time
-	gotFilePath, err = cache.Get(ts.URL, "", 0)
+	gotFilePath, err = cache.Get(ts.URL, "", 0, nil)
 	if err != nil {
 		t.Fatalf("failed to download Wasm module: %v", err)
 	}
@@ -228,7 +228,7 @@ func TestWasmCacheMissChecksum(t *testing.T) {
 	}
 
 	// 3rd time
-	gotFilePath, err = cache.Get(ts.URL, "", 0)
+	gotFilePath, err = cache.Get(ts.URL, "", 0, nil)
 	if err != nil {
 		t.Fatalf("failed to download Wasm module: %v", err)
 	}
@@ -241,3 +241,32 @@ func TestWasmCacheMissChecksum(t *testing.T) {
 		t.Errorf("wasm download call got %v want %v", gotNumRequest, wantNumRequest)
 	}
 }
+
+func TestWasmCacheChecksumWithAlgorithmPrefix(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache := NewLocalFileCache(tmpDir, DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry, nil)
+	defer close(cache.stopChan)
+
+	gotNumRequest := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotNumRequest++
+		fmt.Fprintln(w, "data")
+	}))
+	defer ts.Close()
+	dataCheckSum := sha256.Sum256([]byte("data\n"))
+	wantFilePath := filepath.Join(tmpDir, fmt.Sprintf("%x.wasm", dataCheckSum))
+
+	// The module is fetched once and then served from the cache, although the checksum has the "sha256:" prefix.
+	for i := 0; i < 2; i++ {
+		gotFilePath, err := cache.Get(ts.URL, fmt.Sprintf("sha256:%x", dataCheckSum), 0, nil)
+		if err != nil {
+			t.Fatalf("failed to download Wasm module: %v", err)
+		}
+		if gotFilePath != wantFilePath {
+			t.Errorf("wasm download path got %v want %v", gotFilePath, wantFilePath)
+		}
+	}
+	if gotNumRequest != 1 {
+		t.Errorf("wasm download call got %v want 1", gotNumRequest)
+	}
+}
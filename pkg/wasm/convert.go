@@ -15,6 +15,7 @@
 package wasm
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -33,6 +34,10 @@ const (
 	apiTypePrefix      = "type.googleapis.com/"
 	typedStructType    = apiTypePrefix + "udpa.type.v1.TypedStruct"
 	wasmHTTPFilterType = apiTypePrefix + "envoy.extensions.filters.http.wasm.v3.Wasm"
+
+	// WasmSecretEnv is the Wasm VM environment variable carrying the docker config json used to pull the
+	// module from an OCI registry. It is consumed by the agent and never passed on to Envoy.
+	WasmSecretEnv = "ISTIO_META_WASM_IMAGE_PULL_SECRET"
 )
 
 // MaybeConvertWasmExtensionConfig converts any presence of module remote download to local file.
@@ -52,11 +57,11 @@ func MaybeConvertWasmExtensionConfig(resources []*any.Any, cache Cache) bool {
 			defer wg.Done()
 
 			newExtensionConfig, nack := convert(resources[i], cache)
+			// Replace the resource even on Nack, so that a pull secret stripped from it is not logged.
+			resources[i] = newExtensionConfig
 			if nack {
 				sendNack.Store(true)
-				return
 			}
-			resources[i] = newExtensionConfig
 		}(i)
 	}
 
@@ -81,11 +86,11 @@ func MaybeConvertWasmExtensionConfigDelta(resources []*discovery.Resource, cache
 			defer wg.Done()
 
 			newExtensionConfig, nack := convert(resources[i].Resource, cache)
+			// Replace the resource even on Nack, so that a pull secret stripped from it is not logged.
+			resources[i].Resource = newExtensionConfig
 			if nack {
 				sendNack.Store(true)
-				return
 			}
-			resources[i].Resource = newExtensionConfig
 		}(i)
 	}
 
@@ -109,7 +114,6 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	}
 
 	// Currently Wasm filter can only be configured using typed struct via EnvoyFilter.
-	wasmLog.Debugf("original extension config resource %+v", ec)
 	if ec.GetTypedConfig() == nil && ec.GetTypedConfig().TypeUrl != typedStructType {
 		wasmLog.Debugf("cannot find typed struct in %+v", ec)
 		return
@@ -129,10 +133,30 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 
 	wasmHTTPFilterConfig := &wasm.Wasm{}
 	if err := conversion.StructToMessage(wasmStruct.Value, wasmHTTPFilterConfig); err != nil {
-		wasmLog.Debugf("failed to convert extension config struct %+v to Wasm HTTP filter", wasmStruct)
+		wasmLog.Debugf("failed to convert extension config struct to Wasm HTTP filter: %v", err)
 		return
 	}
 
+	// Strip the pull secret from the VM config before anything else, so that it is neither logged nor
+	// forwarded to Envoy and exposed to the Wasm module, whatever the outcome of the conversion.
+	pullSecret := stripPullSecret(wasmHTTPFilterConfig)
+	if pullSecret != nil {
+		defer func() {
+			if newExtensionConfig != resource {
+				return
+			}
+			stripped, err := marshalExtensionConfig(ec, wasmHTTPFilterConfig)
+			if err != nil {
+				status = marshalFailure
+				wasmLog.Errorf("failed to marshal extension config %v without the pull secret: %v", ec.GetName(), err)
+				newExtensionConfig = nil
+				sendNack = true
+				return
+			}
+			newExtensionConfig = stripped
+		}()
+	}
+
 	if wasmHTTPFilterConfig.Config.GetVmConfig().GetCode().GetRemote() == nil {
 		wasmLog.Debugf("no remote load found in Wasm HTTP filter %+v", wasmHTTPFilterConfig)
 		return
@@ -156,7 +180,7 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	if remote.GetHttpUri().Timeout != nil {
 		timeout = remote.GetHttpUri().Timeout.AsDuration()
 	}
-	f, err := cache.Get(httpURI.GetUri(), remote.GetSha256(), timeout)
+	f, err := cache.Get(httpURI.GetUri(), remote.GetSha256(), timeout, pullSecret)
 	if err != nil {
 		status = fetchFailure
 		wasmLog.Errorf("cannot fetch Wasm module %v: %v", remote.GetHttpUri().GetUri(), err)
@@ -174,21 +198,13 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 		},
 	}
 
-	wasmTypedConfig, err = anypb.New(wasmHTTPFilterConfig)
-	if err != nil {
-		status = marshalFailure
-		wasmLog.Errorf("failed to marshal new wasm HTTP filter %+v to protobuf Any: %v", wasmHTTPFilterConfig, err)
-		return
-	}
-	ec.TypedConfig = wasmTypedConfig
-	wasmLog.Debugf("new extension config resource %+v", ec)
-
-	nec, err := anypb.New(ec)
+	nec, err := marshalExtensionConfig(ec, wasmHTTPFilterConfig)
 	if err != nil {
 		status = marshalFailure
 		wasmLog.Errorf("failed to marshal new extension config resource: %v", err)
 		return
 	}
+	wasmLog.Debugf("new extension config resource %+v", ec)
 
 	// At this point, we are certain that wasm module has been downloaded and config is rewritten.
 	// ECDS has been rewritten successfully and should not nack.
@@ -196,3 +212,28 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	sendNack = false
 	return
 }
+
+// stripPullSecret removes the pull secret from the environment variables of the Wasm VM, and returns it.
+func stripPullSecret(wasmHTTPFilterConfig *wasm.Wasm) []byte {
+	envs := wasmHTTPFilterConfig.GetConfig().GetVmConfig().GetEnvironmentVariables()
+	if envs == nil {
+		return nil
+	}
+	secret, ok := envs.KeyValues[WasmSecretEnv]
+	if !ok {
+		return nil
+	}
+	delete(envs.KeyValues, WasmSecretEnv)
+	return []byte(secret)
+}
+
+// marshalExtensionConfig sets the Wasm HTTP filter as the typed config of the extension config, and
+// marshals the extension config.
+func marshalExtensionConfig(ec *core.TypedExtensionConfig, wasmHTTPFilterConfig *wasm.Wasm) (*any.Any, error) {
+	wasmTypedConfig, err := anypb.New(wasmHTTPFilterConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal wasm HTTP filter to protobuf Any: %v", err)
+	}
+	ec.TypedConfig = wasmTypedConfig
+	return anypb.New(ec)
+}
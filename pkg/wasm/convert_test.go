@@ -16,6 +16,7 @@ package wasm
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
 	"testing"
 	"time"
@@ -34,7 +35,7 @@ import (
 
 type mockCache struct{}
 
-func (c *mockCache) Get(downloadURL, checksum string, timeout time.Duration) (string, error) {
+func (c *mockCache) Get(downloadURL, checksum string, timeout time.Duration, pullSecret []byte) (string, error) {
 	url, _ := url.Parse(downloadURL)
 	query := url.Query()
 
@@ -44,6 +45,9 @@ func (c *mockCache) Get(downloadURL, checksum string, timeout time.Duration) (st
 	if errMsg != "" {
 		err = errors.New(errMsg)
 	}
+	if secret := query.Get("secret"); secret != string(pullSecret) {
+		err = fmt.Errorf("got pull secret %q, want %q", pullSecret, secret)
+	}
 
 	return module, err
 }
@@ -66,6 +70,16 @@ func TestWasmConvert(t *testing.T) {
 			},
 			wantNack: false,
 		},
+		{
+			name: "remote load with pull secret",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret"],
+			},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret-local-file"],
+			},
+			wantNack: false,
+		},
 		{
 			name: "remote load fail",
 			input: []*core.TypedExtensionConfig{
@@ -98,6 +112,26 @@ func TestWasmConvert(t *testing.T) {
 			},
 			wantNack: false,
 		},
+		{
+			name: "remote load fail open with pull secret",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret-fail-open"],
+			},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret-fail-open-stripped"],
+			},
+			wantNack: false,
+		},
+		{
+			name: "remote load fail with pull secret",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret-fail"],
+			},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret-fail-stripped"],
+			},
+			wantNack: true,
+		},
 		{
 			name: "no typed struct",
 			input: []*core.TypedExtensionConfig{
@@ -257,6 +291,42 @@ var extensionConfigMap = map[string]*core.TypedExtensionConfig{
 			},
 		},
 	}),
+	"remote-load-secret": buildTypedStructExtensionConfig("remote-load-success", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{
+				VmConfig: &v3.VmConfig{
+					Code: &core.AsyncDataSource{Specifier: &core.AsyncDataSource_Remote{
+						Remote: &core.RemoteDataSource{
+							HttpUri: &core.HttpUri{
+								Uri: "oci://test?module=test.wasm&secret=secret",
+							},
+						},
+					}},
+					EnvironmentVariables: &v3.EnvironmentVariables{
+						KeyValues: map[string]string{WasmSecretEnv: "secret", "FOO": "bar"},
+					},
+				},
+			},
+		},
+	}),
+	"remote-load-secret-local-file": buildWasmExtensionConfig("remote-load-success", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{
+				VmConfig: &v3.VmConfig{
+					Code: &core.AsyncDataSource{Specifier: &core.AsyncDataSource_Local{
+						Local: &core.DataSource{
+							Specifier: &core.DataSource_Filename{
+								Filename: "test.wasm",
+							},
+						},
+					}},
+					EnvironmentVariables: &v3.EnvironmentVariables{
+						KeyValues: map[string]string{"FOO": "bar"},
+					},
+				},
+			},
+		},
+	}),
 	"remote-load-fail": buildTypedStructExtensionConfig("remote-load-fail", &wasm.Wasm{
 		Config: &v3.PluginConfig{
 			Vm: &v3.PluginConfig_VmConfig{
@@ -288,4 +358,38 @@ var extensionConfigMap = map[string]*core.TypedExtensionConfig{
 			FailOpen: true,
 		},
 	}),
+	"remote-load-secret-fail-open":          remoteLoadSecretFail(true, false),
+	"remote-load-secret-fail-open-stripped": remoteLoadSecretFail(true, true),
+	"remote-load-secret-fail":               remoteLoadSecretFail(false, false),
+	"remote-load-secret-fail-stripped":      remoteLoadSecretFail(false, true),
+}
+
+// remoteLoadSecretFail returns the extension config of a remote load with a pull secret that fails,
+// or the expected output without the pull secret if stripped is set.
+func remoteLoadSecretFail(failOpen, stripped bool) *core.TypedExtensionConfig {
+	envs := map[string]string{"FOO": "bar"}
+	if !stripped {
+		envs[WasmSecretEnv] = "secret"
+	}
+	w := &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{
+				VmConfig: &v3.VmConfig{
+					Code: &core.AsyncDataSource{Specifier: &core.AsyncDataSource_Remote{
+						Remote: &core.RemoteDataSource{
+							HttpUri: &core.HttpUri{
+								Uri: "oci://test?module=test.wasm&secret=secret&error=download-error",
+							},
+						},
+					}},
+					EnvironmentVariables: &v3.EnvironmentVariables{KeyValues: envs},
+				},
+			},
+			FailOpen: failOpen,
+		},
+	}
+	if stripped {
+		return buildWasmExtensionConfig("remote-load-secret-fail", w)
+	}
+	return buildTypedStructExtensionConfig("remote-load-secret-fail", w)
 }
@@ -60,8 +60,7 @@ func (f *HTTPFetcher) Fetch(url string, timeout time.Duration) ([]byte, error) {
 		}
 		if resp.StatusCode == http.StatusOK {
 			defer resp.Body.Close()
-			body, err := ioutil.ReadAll(resp.Body)
-			return body, err
+			return readAtMost(resp.Body, maxModuleSize)
 		}
 		lastError = fmt.Errorf("wasm module download request failed: status code %v", resp.StatusCode)
 		if retryable(resp.StatusCode) {
@@ -0,0 +1,567 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// Media type of the layer holding the Wasm binary in Wasm-specific OCI images.
+	// See https://github.com/solo-io/wasm/blob/master/spec/README.md.
+	wasmLayerMediaType = "application/vnd.module.wasm.content.layer.v1+wasm"
+
+	// Media types of manifests and manifest lists we accept from registries.
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexMediaType       = "application/vnd.oci.image.index.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerListMediaType     = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	// Name of the Wasm binary in the single layer of "compat" images, which are regular container images
+	// with a single layer containing the module.
+	compatWasmFileName = "plugin.wasm"
+
+	// Registry used when the image reference does not include one.
+	dockerHubRegistry = "index.docker.io"
+	dockerHubHost     = "registry-1.docker.io"
+	defaultImageTag   = "latest"
+
+	// Maximum sizes of the manifests and Wasm modules fetched from registries, and of the decompressed
+	// layers of compat images.
+	maxManifestSize          = 4 << 20
+	maxModuleSize            = 256 << 20
+	maxDecompressedLayerSize = 1 << 30
+)
+
+var (
+	// errDigestMismatch is returned when the digest of a fetched manifest or blob does not match the expected one.
+	errDigestMismatch = errors.New("digest mismatch")
+
+	wasmMagicNumber = []byte{0x00, 0x61, 0x73, 0x6d}
+)
+
+// ImageFetcherOption contains the options for fetching Wasm modules from OCI registries.
+type ImageFetcherOption struct {
+	// PullSecret is the content of a docker config json (either the `.dockerconfigjson` or legacy `.dockercfg`
+	// format) used to authenticate with the registry.
+	PullSecret []byte
+	// Insecure allows fetching from the registry over plain HTTP.
+	Insecure bool
+}
+
+// ImageFetcher fetches Wasm modules packaged as OCI images. Both the Wasm-specific image format, where
+// the module is stored in a layer of type `application/vnd.module.wasm.content.layer.v1+wasm`, and the
+// compat format, where a regular single layer image contains a `plugin.wasm` file, are supported.
+type ImageFetcher struct {
+	client *http.Client
+	scheme string
+	// Credentials keyed by registry host.
+	credentials map[string]registryCredential
+	// Bearer tokens obtained from registry token services, keyed by registry host.
+	tokens map[string]string
+}
+
+type registryCredential struct {
+	username string
+	password string
+}
+
+// NewImageFetcher creates a new ImageFetcher. A timeout of zero uses the default timeout.
+func NewImageFetcher(opt ImageFetcherOption, timeout time.Duration) (*ImageFetcher, error) {
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	credentials, err := parsePullSecret(opt.PullSecret)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "https"
+	if opt.Insecure {
+		scheme = "http"
+	}
+	return &ImageFetcher{
+		client:      &http.Client{Timeout: timeout},
+		scheme:      scheme,
+		credentials: credentials,
+		tokens:      map[string]string{},
+	}, nil
+}
+
+// Fetch downloads the Wasm module referenced by the image reference, in the form of `registry/repository[:tag|@digest]`.
+// If expectedDigest is set, the digest of the image manifest must match it. The Wasm module and the hex encoded
+// sha256 digest of the image manifest are returned.
+func (f *ImageFetcher) Fetch(reference, expectedDigest string) ([]byte, string, error) {
+	ref, err := parseImageReference(reference)
+	if err != nil {
+		return nil, "", err
+	}
+	expectedDigest = strings.TrimPrefix(expectedDigest, "sha256:")
+	if ref.digest != "" && expectedDigest != "" && ref.digest != "sha256:"+expectedDigest {
+		return nil, "", fmt.Errorf("%w: image %v is pinned to %v, which does not match: %v",
+			errDigestMismatch, reference, ref.digest, expectedDigest)
+	}
+
+	body, mediaType, err := f.fetchManifest(ref, ref.reference())
+	if err != nil {
+		return nil, "", err
+	}
+	digest := fmt.Sprintf("%x", sha256.Sum256(body))
+	if ref.digest != "" && ref.digest != "sha256:"+digest {
+		return nil, "", fmt.Errorf("%w: manifest of image %v has digest sha256:%v", errDigestMismatch, reference, digest)
+	}
+	if expectedDigest != "" && expectedDigest != digest {
+		return nil, "", fmt.Errorf("%w: manifest of image %v has digest %v, which does not match: %v",
+			errDigestMismatch, reference, digest, expectedDigest)
+	}
+
+	m := &imageManifest{}
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, "", fmt.Errorf("failed to parse manifest of image %v: %v", reference, err)
+	}
+	if mediaType == "" {
+		mediaType = m.MediaType
+	}
+	if mediaType == ociIndexMediaType || mediaType == dockerListMediaType {
+		// Wasm modules are platform independent, so just take the first image of the index.
+		if len(m.Manifests) == 0 {
+			return nil, "", fmt.Errorf("image index %v has no manifests", reference)
+		}
+		desc := m.Manifests[0]
+		if body, _, err = f.fetchManifest(ref, desc.Digest); err != nil {
+			return nil, "", err
+		}
+		if err := verifyDigest(body, desc.Digest); err != nil {
+			return nil, "", err
+		}
+		m = &imageManifest{}
+		if err := json.Unmarshal(body, m); err != nil {
+			return nil, "", fmt.Errorf("failed to parse manifest %v of image %v: %v", desc.Digest, reference, err)
+		}
+	}
+
+	module, err := f.extractModule(ref, m)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to extract Wasm module from image %v: %v", reference, err)
+	}
+	return module, digest, nil
+}
+
+func (f *ImageFetcher) extractModule(ref imageReference, m *imageManifest) ([]byte, error) {
+	for _, l := range m.Layers {
+		if l.MediaType == wasmLayerMediaType {
+			b, err := f.fetchBlob(ref, l)
+			if err != nil {
+				return nil, err
+			}
+			return b, checkWasmModule(b)
+		}
+	}
+
+	// Otherwise this should be a compat image with a single layer containing the module.
+	if len(m.Layers) != 1 {
+		return nil, fmt.Errorf("expected a single layer or a layer of type %v, found %d layers", wasmLayerMediaType, len(m.Layers))
+	}
+	b, err := f.fetchBlob(ref, m.Layers[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err = extractFromTar(b, compatWasmFileName)
+	if err != nil {
+		return nil, err
+	}
+	return b, checkWasmModule(b)
+}
+
+func (f *ImageFetcher) fetchManifest(ref imageReference, reference string) ([]byte, string, error) {
+	accept := []string{ociManifestMediaType, dockerManifestMediaType, ociIndexMediaType, dockerListMediaType}
+	resp, err := f.get(ref, "manifests/"+reference, accept)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := readAtMost(resp.Body, maxManifestSize)
+	if err != nil {
+		return nil, "", err
+	}
+	mediaType := resp.Header.Get("Content-Type")
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = mediaType[:i]
+	}
+	return body, strings.TrimSpace(mediaType), nil
+}
+
+func (f *ImageFetcher) fetchBlob(ref imageReference, desc descriptor) ([]byte, error) {
+	resp, err := f.get(ref, "blobs/"+desc.Digest, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := readAtMost(resp.Body, maxModuleSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyDigest(body, desc.Digest); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// get performs a GET request against the registry API of the image repository, following the
+// registry token authentication flow if the registry requires it.
+func (f *ImageFetcher) get(ref imageReference, p string, accept []string) (*http.Response, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/%s", f.scheme, ref.host, ref.repository, p)
+	resp, err := f.do(u, accept, f.authorization(ref))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		auth, err := f.authorize(ref, challenge)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = f.do(u, accept, auth); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("request to %v failed: status code %v", u, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (f *ImageFetcher) do(u string, accept []string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return f.client.Do(req)
+}
+
+// authorization returns the Authorization header to use for the registry, if any.
+func (f *ImageFetcher) authorization(ref imageReference) string {
+	if token, ok := f.tokens[ref.host]; ok {
+		return "Bearer " + token
+	}
+	return ""
+}
+
+// authorize handles an authentication challenge from the registry, returning the Authorization header to retry with.
+func (f *ImageFetcher) authorize(ref imageReference, challenge string) (string, error) {
+	cred, hasCred := f.credentials[ref.registry]
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCred {
+			return "", fmt.Errorf("registry %v requires authentication, but no pull secret was provided", ref.registry)
+		}
+		return "Basic " + basicAuth(cred), nil
+	case "bearer":
+		realm, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return "", fmt.Errorf("invalid bearer challenge from registry %v: %q", ref.registry, challenge)
+		}
+		q := realm.Query()
+		if params["service"] != "" {
+			q.Set("service", params["service"])
+		}
+		scope := params["scope"]
+		if scope == "" {
+			scope = fmt.Sprintf("repository:%s:pull", ref.repository)
+		}
+		q.Set("scope", scope)
+		realm.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if hasCred {
+			req.SetBasicAuth(cred.username, cred.password)
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to get token for registry %v: status code %v", ref.registry, resp.StatusCode)
+		}
+		tr := struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+		if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+			return "", fmt.Errorf("failed to parse token response from registry %v: %v", ref.registry, err)
+		}
+		token := tr.Token
+		if token == "" {
+			token = tr.AccessToken
+		}
+		if token == "" {
+			return "", fmt.Errorf("registry %v returned an empty token", ref.registry)
+		}
+		f.tokens[ref.host] = token
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge from registry %v: %q", ref.registry, challenge)
+	}
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// imageManifest covers the fields we need from both image manifests and image indexes.
+type imageManifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+type imageReference struct {
+	// registry is the name of the registry, used to look up credentials.
+	registry string
+	// host is the address used to reach the registry API.
+	host       string
+	repository string
+	tag        string
+	digest     string
+}
+
+// reference returns the tag or digest to resolve the manifest with.
+func (r imageReference) reference() string {
+	if r.digest != "" {
+		return r.digest
+	}
+	return r.tag
+}
+
+// parseImageReference parses an image reference in the form of `[registry/]repository[:tag][@digest]`.
+func parseImageReference(s string) (imageReference, error) {
+	ref := imageReference{}
+	if i := strings.Index(s, "@"); i >= 0 {
+		ref.digest = s[i+1:]
+		s = s[:i]
+		if !strings.HasPrefix(ref.digest, "sha256:") {
+			return ref, fmt.Errorf("unsupported digest in image reference %v, only sha256 is supported", ref.digest)
+		}
+	}
+	// A colon after the last slash separates the tag, otherwise it is part of the registry host.
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		ref.tag = s[i+1:]
+		s = s[:i]
+	}
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = defaultImageTag
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.registry = parts[0]
+		ref.repository = parts[1]
+	} else {
+		ref.registry = dockerHubRegistry
+		ref.repository = s
+	}
+	if ref.registry == dockerHubRegistry || ref.registry == "docker.io" {
+		ref.registry = dockerHubRegistry
+		ref.host = dockerHubHost
+		if !strings.Contains(ref.repository, "/") {
+			ref.repository = "library/" + ref.repository
+		}
+	} else {
+		ref.host = ref.registry
+	}
+	if ref.repository == "" {
+		return ref, fmt.Errorf("invalid image reference %v: missing repository", s)
+	}
+	return ref, nil
+}
+
+// parsePullSecret parses a docker config json into credentials keyed by registry host.
+func parsePullSecret(secret []byte) (map[string]registryCredential, error) {
+	credentials := map[string]registryCredential{}
+	if len(secret) == 0 {
+		return credentials, nil
+	}
+	type authEntry struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	config := struct {
+		Auths map[string]authEntry `json:"auths"`
+	}{}
+	if err := json.Unmarshal(secret, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse image pull secret: %v", err)
+	}
+	if config.Auths == nil {
+		// Legacy .dockercfg format, where registries are at the top level.
+		if err := json.Unmarshal(secret, &config.Auths); err != nil {
+			return nil, fmt.Errorf("failed to parse image pull secret: %v", err)
+		}
+	}
+	for registry, entry := range config.Auths {
+		cred := registryCredential{username: entry.Username, password: entry.Password}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode auth for registry %v in image pull secret: %v", registry, err)
+			}
+			userPass := strings.SplitN(string(decoded), ":", 2)
+			if len(userPass) != 2 {
+				return nil, fmt.Errorf("invalid auth for registry %v in image pull secret", registry)
+			}
+			cred.username, cred.password = userPass[0], userPass[1]
+		}
+		credentials[normalizeRegistry(registry)] = cred
+	}
+	return credentials, nil
+}
+
+// normalizeRegistry converts the registry keys found in docker config files, such as
+// `https://index.docker.io/v1/`, to registry host names.
+func normalizeRegistry(registry string) string {
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	if i := strings.Index(registry, "/"); i >= 0 {
+		registry = registry[:i]
+	}
+	if registry == "docker.io" || registry == dockerHubHost {
+		return dockerHubRegistry
+	}
+	return registry
+}
+
+// parseChallenge parses a WWW-Authenticate header such as `Bearer realm="https://auth",service="registry"`.
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+	challenge = strings.TrimSpace(challenge)
+	i := strings.Index(challenge, " ")
+	if i < 0 {
+		return challenge, params
+	}
+	scheme, rest := challenge[:i], challenge[i+1:]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+	return scheme, params
+}
+
+func basicAuth(cred registryCredential) string {
+	return base64.StdEncoding.EncodeToString([]byte(cred.username + ":" + cred.password))
+}
+
+func verifyDigest(b []byte, digest string) error {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return fmt.Errorf("unsupported digest %v, only sha256 is supported", digest)
+	}
+	if got := fmt.Sprintf("sha256:%x", sha256.Sum256(b)); got != digest {
+		return fmt.Errorf("%w: content has digest %v, expected %v", errDigestMismatch, got, digest)
+	}
+	return nil
+}
+
+// extractFromTar returns the content of the named file in the, optionally gzipped, tar archive.
+func extractFromTar(b []byte, name string) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(b)
+	if len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = io.LimitReader(gr, maxDecompressedLayerSize)
+	}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%v not found in image layer", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image layer: %v", err)
+		}
+		if h.Typeflag == tar.TypeReg && path.Clean("/"+h.Name) == "/"+name {
+			return readAtMost(tr, maxModuleSize)
+		}
+	}
+}
+
+// readAtMost reads all of r, failing if it holds more than limit bytes.
+func readAtMost(r io.Reader, limit int64) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("content exceeds the maximum size of %d bytes", limit)
+	}
+	return b, nil
+}
+
+// checkWasmModule performs a basic sanity check that the binary is a Wasm module.
+func checkWasmModule(b []byte) error {
+	if !bytes.HasPrefix(b, wasmMagicNumber) {
+		return errors.New("fetched content is not a Wasm module")
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRegistry is an in-process stand-in for an OCI registry, serving manifests and blobs
+// for a single repository. If username is set, it requires token authentication.
+type fakeRegistry struct {
+	server    *httptest.Server
+	manifests map[string][]byte
+	blobs     map[string][]byte
+	username  string
+	password  string
+	requests  int
+}
+
+func newFakeRegistry(t *testing.T, username, password string) *fakeRegistry {
+	r := &fakeRegistry{
+		manifests: map[string][]byte{},
+		blobs:     map[string][]byte{},
+		username:  username,
+		password:  password,
+	}
+	const token = "fake-token"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		if u, p, ok := req.BasicAuth(); !ok || u != r.username || p != r.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"token": %q}`, token)
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, req *http.Request) {
+		r.requests++
+		if r.username != "" && req.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="repository:test/plugin:pull"`, r.server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := strings.TrimPrefix(req.URL.Path, "/v2/test/plugin/")
+		switch {
+		case strings.HasPrefix(p, "manifests/"):
+			m, ok := r.manifests[strings.TrimPrefix(p, "manifests/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", ociManifestMediaType)
+			_, _ = w.Write(m)
+		case strings.HasPrefix(p, "blobs/"):
+			b, ok := r.blobs[strings.TrimPrefix(p, "blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(b)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	r.server = httptest.NewServer(mux)
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *fakeRegistry) host() string {
+	u, _ := url.Parse(r.server.URL)
+	return u.Host
+}
+
+func (r *fakeRegistry) addBlob(b []byte) descriptor {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+	r.blobs[digest] = b
+	return descriptor{Digest: digest, Size: int64(len(b))}
+}
+
+// addImage pushes an image with the given layers under the tag, and returns the manifest digest.
+func (r *fakeRegistry) addImage(t *testing.T, tag string, layers ...descriptor) string {
+	config := r.addBlob([]byte("{}"))
+	config.MediaType = "application/vnd.oci.image.config.v1+json"
+	m, err := json.Marshal(imageManifest{MediaType: ociManifestMediaType, Config: config, Layers: layers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(m))
+	r.manifests[tag] = m
+	r.manifests[digest] = m
+	return digest
+}
+
+func compatLayer(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageFetcher(t *testing.T) {
+	module := append([]byte{0x00, 0x61, 0x73, 0x6d}, []byte("module")...)
+	reg := newFakeRegistry(t, "", "")
+
+	wasmLayer := reg.addBlob(module)
+	wasmLayer.MediaType = wasmLayerMediaType
+	wasmDigest := reg.addImage(t, "wasm", wasmLayer)
+
+	compat := reg.addBlob(compatLayer(t, map[string][]byte{"./plugin.wasm": module, "README": []byte("readme")}))
+	compat.MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+	compatDigest := reg.addImage(t, "compat", compat)
+
+	noModule := reg.addBlob(compatLayer(t, map[string][]byte{"README": []byte("readme")}))
+	noModule.MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+	reg.addImage(t, "no-module", noModule)
+
+	notWasm := reg.addBlob(compatLayer(t, map[string][]byte{"plugin.wasm": []byte("not wasm")}))
+	notWasm.MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+	reg.addImage(t, "not-wasm", notWasm)
+
+	cases := []struct {
+		name           string
+		reference      string
+		expectedDigest string
+		wantDigest     string
+		wantErr        string
+	}{
+		{
+			name:       "wasm image",
+			reference:  reg.host() + "/test/plugin:wasm",
+			wantDigest: wasmDigest,
+		},
+		{
+			name:       "compat image",
+			reference:  reg.host() + "/test/plugin:compat",
+			wantDigest: compatDigest,
+		},
+		{
+			name:       "pinned digest",
+			reference:  reg.host() + "/test/plugin@" + wasmDigest,
+			wantDigest: wasmDigest,
+		},
+		{
+			name:           "expected digest",
+			reference:      reg.host() + "/test/plugin:compat",
+			expectedDigest: strings.TrimPrefix(compatDigest, "sha256:"),
+			wantDigest:     compatDigest,
+		},
+		{
+			name:           "expected digest mismatch",
+			reference:      reg.host() + "/test/plugin:compat",
+			expectedDigest: strings.TrimPrefix(wasmDigest, "sha256:"),
+			wantErr:        "digest mismatch",
+		},
+		{
+			name:           "pinned digest conflicts with expected digest",
+			reference:      reg.host() + "/test/plugin@" + wasmDigest,
+			expectedDigest: compatDigest,
+			wantErr:        "digest mismatch",
+		},
+		{
+			name:      "missing module",
+			reference: reg.host() + "/test/plugin:no-module",
+			wantErr:   "plugin.wasm not found",
+		},
+		{
+			name:      "not a wasm module",
+			reference: reg.host() + "/test/plugin:not-wasm",
+			wantErr:   "not a Wasm module",
+		},
+		{
+			name:      "unknown tag",
+			reference: reg.host() + "/test/plugin:unknown",
+			wantErr:   "status code 404",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fetcher, err := NewImageFetcher(ImageFetcherOption{Insecure: true}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, digest, err := fetcher.Fetch(c.reference, c.expectedDigest)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v, want error containing %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, module) {
+				t.Errorf("got module %q, want %q", got, module)
+			}
+			if "sha256:"+digest != c.wantDigest {
+				t.Errorf("got digest %v, want %v", digest, c.wantDigest)
+			}
+		})
+	}
+}
+
+func TestImageFetcherPullSecret(t *testing.T) {
+	module := append([]byte{0x00, 0x61, 0x73, 0x6d}, []byte("module")...)
+	reg := newFakeRegistry(t, "user", "pass")
+	layer := reg.addBlob(module)
+	layer.MediaType = wasmLayerMediaType
+	reg.addImage(t, "latest", layer)
+
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	wrongAuth := base64.StdEncoding.EncodeToString([]byte("user:wrong"))
+	cases := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{
+			name:   "docker config json",
+			secret: fmt.Sprintf(`{"auths":{"http://%s":{"auth":%q}}}`, reg.host(), auth),
+		},
+		{
+			name:   "legacy docker config",
+			secret: fmt.Sprintf(`{"%s":{"username":"user","password":"pass"}}`, reg.host()),
+		},
+		{
+			name:    "wrong credentials",
+			secret:  fmt.Sprintf(`{"auths":{"%s":{"auth":%q}}}`, reg.host(), wrongAuth),
+			wantErr: true,
+		},
+		{
+			name:    "no secret",
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fetcher, err := NewImageFetcher(ImageFetcherOption{PullSecret: []byte(c.secret), Insecure: true}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := fetcher.Fetch(reg.host()+"/test/plugin", "")
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, module) {
+				t.Errorf("got module %q, want %q", got, module)
+			}
+		})
+	}
+}
+
+func TestWasmCacheOCI(t *testing.T) {
+	module := append([]byte{0x00, 0x61, 0x73, 0x6d}, []byte("module")...)
+	reg := newFakeRegistry(t, "", "")
+	layer := reg.addBlob(module)
+	layer.MediaType = wasmLayerMediaType
+	digest := strings.TrimPrefix(reg.addImage(t, "v1", layer), "sha256:")
+
+	tmpDir := t.TempDir()
+	cache := NewLocalFileCache(tmpDir, DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry, []string{reg.host()})
+	defer close(cache.stopChan)
+
+	ociURL := fmt.Sprintf("oci://%s/test/plugin:v1", reg.host())
+	wantPath := filepath.Join(tmpDir, digest+".wasm")
+	for i := 0; i < 2; i++ {
+		got, err := cache.Get(ociURL, digest, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != wantPath {
+			t.Fatalf("got module path %v, want %v", got, wantPath)
+		}
+	}
+	// The second lookup should be served from the cache.
+	if reg.requests != 2 {
+		t.Errorf("got %d registry requests, want 2", reg.requests)
+	}
+	b, err := ioutil.ReadFile(wantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, module) {
+		t.Errorf("got module %q, want %q", b, module)
+	}
+
+	// Registries not configured as insecure are accessed over HTTPS, which the fake registry does not serve.
+	secureCache := NewLocalFileCache(t.TempDir(), DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry, nil)
+	defer close(secureCache.stopChan)
+	if _, err := secureCache.Get(ociURL, digest, 0, nil); err == nil {
+		t.Errorf("expected error fetching from an insecure registry over HTTPS")
+	}
+}
+
+func TestReadAtMost(t *testing.T) {
+	if b, err := readAtMost(strings.NewReader("wasm"), 4); err != nil || string(b) != "wasm" {
+		t.Errorf("got %q and error %v, expected %q", b, err, "wasm")
+	}
+	if _, err := readAtMost(strings.NewReader("wasm!"), 4); err == nil {
+		t.Errorf("expected an error for content exceeding the maximum size")
+	}
+}
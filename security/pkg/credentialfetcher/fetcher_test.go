@@ -40,6 +40,33 @@ func TestNewCredFetcher(t *testing.T) {
 			expectedToken:    "",
 			expectedIdp:      "GoogleComputeEngine",
 		},
+		"aws test": {
+			fetcherType:      security.AWS,
+			trustdomain:      "cluster.local",
+			jwtPath:          "/var/run/secrets/tokens/istio-token",
+			identityProvider: "AWS",
+			expectedErr:      "",
+			expectedToken:    "",
+			expectedIdp:      "AWS",
+		},
+		"azure test": {
+			fetcherType:      security.Azure,
+			trustdomain:      "cluster.local",
+			jwtPath:          "/var/run/secrets/tokens/istio-token",
+			identityProvider: "AzureAD",
+			expectedErr:      "",
+			expectedToken:    "",
+			expectedIdp:      "AzureAD",
+		},
+		"oidc token file test": {
+			fetcherType:      security.OIDCFile,
+			trustdomain:      "cluster.local",
+			jwtPath:          "/var/run/secrets/tokens/istio-token",
+			identityProvider: "OIDC",
+			expectedErr:      "",
+			expectedToken:    "",
+			expectedIdp:      "OIDC",
+		},
 		"mock test": {
 			fetcherType:      security.Mock,
 			trustdomain:      "",
@@ -63,6 +90,7 @@ func TestNewCredFetcher(t *testing.T) {
 	// Disable token refresh for GCE VM credential fetcher.
 	plugin.SetTokenRotation(false)
 	for id, tc := range testCases {
+		id, tc := id, tc
 		t.Run(id, func(t *testing.T) {
 			t.Parallel()
 			cf, err := NewCredFetcher(
@@ -0,0 +1,194 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This is AWS plugin of credentialfetcher.
+package plugin
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/pkg/log"
+)
+
+var awscredLog = log.RegisterScope("awscred", "AWS credential fetcher for istio agent", 0)
+
+const (
+	// defaultAWSMetadataEndpoint is the address of the EC2 instance metadata service.
+	defaultAWSMetadataEndpoint = "http://169.254.169.254"
+	// awsMetadataEndpointEnv overrides the address of the instance metadata service, as in the AWS SDKs.
+	awsMetadataEndpointEnv = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+	// awsMetadataTokenTTL is the lifetime in seconds of the session tokens of the instance metadata service.
+	awsMetadataTokenTTL = "60"
+	// awsNonceFileSuffix is the suffix of the file keeping the nonce of the instance, next to jwtPath.
+	awsNonceFileSuffix = ".nonce"
+	// awsNonceSize is the size in bytes of the nonce of the instance.
+	awsNonceSize = 32
+)
+
+// The plugin object.
+type AWSPlugin struct {
+	// endpoint is the address of the instance metadata service.
+	endpoint string
+
+	// The location to save the identity credential
+	jwtPath string
+
+	// identity provider
+	identityProvider string
+
+	client *http.Client
+}
+
+// CreateAWSPlugin creates an AWS credential fetcher plugin. Return the pointer to the created plugin.
+func CreateAWSPlugin(jwtPath, identityProvider string) *AWSPlugin {
+	endpoint := defaultAWSMetadataEndpoint
+	if e := os.Getenv(awsMetadataEndpointEnv); e != "" {
+		endpoint = strings.TrimSuffix(e, "/")
+	}
+	return &AWSPlugin{
+		endpoint:         endpoint,
+		jwtPath:          jwtPath,
+		identityProvider: identityProvider,
+		client:           &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// GetPlatformCredential fetches the instance identity document of the EC2 instance and its signature
+// from the instance metadata service (IMDSv2), and write the credential made of both and the nonce of
+// the instance to jwtPath. The CA verifies the signature with the AWS public certificate of the region
+// of the instance, and binds the instance to the nonce on its first authentication.
+// Note: this function only works in an AWS EC2 environment.
+func (p *AWSPlugin) GetPlatformCredential() (string, error) {
+	if p.jwtPath == "" {
+		return "", fmt.Errorf("jwtPath is unset")
+	}
+	token, err := p.sessionToken()
+	if err != nil {
+		awscredLog.Errorf("Failed to get session token from instance metadata service: %v", err)
+		return "", err
+	}
+	document, err := p.get(token, "/latest/dynamic/instance-identity/document")
+	if err != nil {
+		awscredLog.Errorf("Failed to get instance identity document from instance metadata service: %v", err)
+		return "", err
+	}
+	encodedSignature, err := p.get(token, "/latest/dynamic/instance-identity/signature")
+	if err != nil {
+		awscredLog.Errorf("Failed to get instance identity signature from instance metadata service: %v", err)
+		return "", err
+	}
+	// The signature is base64 encoded, possibly over several lines.
+	signature, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(encodedSignature)), ""))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode instance identity signature: %v", err)
+	}
+	nonce, err := p.nonce()
+	if err != nil {
+		awscredLog.Errorf("Failed to get the nonce of the instance: %v", err)
+		return "", err
+	}
+	credential := security.EncodeAWSCredential(document, signature, nonce)
+	awscredLog.Debugf("Got AWS instance identity credential: %d", len(credential))
+	// The credential holds the nonce, so it is only readable by the agent like the nonce file. The mode
+	// is also set on existing files, which are not changed by WriteFile.
+	if err := ioutil.WriteFile(p.jwtPath, []byte(credential), 0600); err != nil {
+		awscredLog.Errorf("Encountered error when writing instance identity credential: %v", err)
+		return "", err
+	}
+	if err := os.Chmod(p.jwtPath, 0600); err != nil {
+		awscredLog.Errorf("Encountered error when restricting the instance identity credential file: %v", err)
+		return "", err
+	}
+	return credential, nil
+}
+
+// nonce returns the nonce of the instance, generated on first use and kept next to jwtPath so that
+// the credentials of the instance keep the same nonce across restarts of the agent.
+func (p *AWSPlugin) nonce() ([]byte, error) {
+	nonceFile := p.jwtPath + awsNonceFileSuffix
+	nonce, err := ioutil.ReadFile(nonceFile)
+	if err == nil && len(nonce) > 0 {
+		return nonce, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	nonce = make([]byte, awsNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(nonceFile, nonce, 0600); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// sessionToken returns a session token of the instance metadata service.
+func (p *AWSPlugin) sessionToken() (string, error) {
+	req, err := http.NewRequest(http.MethodPut, p.endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", awsMetadataTokenTTL)
+	token, err := p.do(req)
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+func (p *AWSPlugin) get(token, path string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, p.endpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	return p.do(req)
+}
+
+func (p *AWSPlugin) do(req *http.Request) ([]byte, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s returned status %d", req.Method, req.URL.Path, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// GetType returns credential fetcher type.
+func (p *AWSPlugin) GetType() string {
+	return security.AWS
+}
+
+// GetIdentityProvider returns the name of the identity provider that can authenticate the workload credential.
+func (p *AWSPlugin) GetIdentityProvider() string {
+	return p.identityProvider
+}
+
+func (p *AWSPlugin) Stop() {}
@@ -0,0 +1,121 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"istio.io/istio/pkg/security"
+)
+
+// awsMetadataServer is a local stand-in of the EC2 instance metadata service.
+func awsMetadataServer(document, signature string) *httptest.Server {
+	const sessionToken = "session-token"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			if r.Method != http.MethodPut || r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, sessionToken)
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != sessionToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/dynamic/instance-identity/document":
+			fmt.Fprint(w, document)
+		case "/latest/dynamic/instance-identity/signature":
+			fmt.Fprint(w, signature)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestAWSPlugin(t *testing.T) {
+	document := `{"accountId":"123456789012","instanceId":"i-1234567890abcdef0","region":"us-west-2"}`
+	signature := []byte("signature-of-the-document")
+	// The signature is served base64 encoded and wrapped over several lines.
+	encoded := base64.StdEncoding.EncodeToString(signature)
+	ms := awsMetadataServer(document, encoded[:8]+"\n"+encoded[8:])
+	defer ms.Close()
+
+	jwtPath := filepath.Join(t.TempDir(), "istio-token")
+	if err := os.Setenv(awsMetadataEndpointEnv, ms.URL+"/"); err != nil {
+		t.Fatal(err)
+	}
+	p := CreateAWSPlugin(jwtPath, "idp")
+	os.Unsetenv(awsMetadataEndpointEnv)
+	if p.GetType() != security.AWS || p.GetIdentityProvider() != "idp" {
+		t.Errorf("unexpected type %s or identity provider %s", p.GetType(), p.GetIdentityProvider())
+	}
+
+	credential, err := p.GetPlatformCredential()
+	if err != nil {
+		t.Fatalf("failed to get credential: %v", err)
+	}
+	gotDocument, gotSignature, nonce, err := security.DecodeAWSCredential(credential)
+	if err != nil {
+		t.Fatalf("failed to decode credential %s: %v", credential, err)
+	}
+	if string(gotDocument) != document || string(gotSignature) != string(signature) {
+		t.Errorf("got document %s and signature %s, expected %s and %s", gotDocument, gotSignature, document, signature)
+	}
+	if len(nonce) != awsNonceSize {
+		t.Errorf("got a nonce of %d bytes, expected %d", len(nonce), awsNonceSize)
+	}
+	written, err := ioutil.ReadFile(jwtPath)
+	if err != nil {
+		t.Fatalf("failed to read credential file: %v", err)
+	}
+	if string(written) != credential {
+		t.Errorf("credential file has %s, expected %s", written, credential)
+	}
+	if info, err := os.Stat(jwtPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("credential file has mode %v and error %v, expected mode 0600", info.Mode().Perm(), err)
+	}
+
+	// The nonce is kept across restarts of the agent, and the mode of existing credential files is restricted.
+	if err := os.Chmod(jwtPath, 0640); err != nil {
+		t.Fatal(err)
+	}
+	restarted := CreateAWSPlugin(jwtPath, "idp")
+	restarted.endpoint = p.endpoint
+	again, err := restarted.GetPlatformCredential()
+	if err != nil {
+		t.Fatalf("failed to get credential after restart: %v", err)
+	}
+	if again != credential {
+		t.Errorf("got credential %s after restart, expected %s", again, credential)
+	}
+	if info, err := os.Stat(jwtPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("credential file has mode %v and error %v after restart, expected mode 0600", info.Mode().Perm(), err)
+	}
+
+	p.endpoint = ms.URL + "/unknown"
+	if _, err := p.GetPlatformCredential(); err == nil {
+		t.Errorf("expected an error when the metadata service is unavailable")
+	}
+}
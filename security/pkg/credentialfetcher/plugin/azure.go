@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This is Azure plugin of credentialfetcher.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/util"
+	"istio.io/pkg/log"
+)
+
+var azurecredLog = log.RegisterScope("azurecred", "Azure credential fetcher for istio agent", 0)
+
+// defaultAzureMetadataEndpoint is the address of the Azure instance metadata service.
+const defaultAzureMetadataEndpoint = "http://169.254.169.254"
+
+// The plugin object.
+type AzurePlugin struct {
+	// aud is the resource the managed identity token is requested for. It must be the application ID URI
+	// of an application registered in Azure AD and accepted by the system verifying the token.
+	aud string
+
+	// endpoint is the address of the instance metadata service.
+	endpoint string
+
+	// The location to save the identity token
+	jwtPath string
+
+	// identity provider
+	identityProvider string
+
+	client     *http.Client
+	tokenCache string
+	// mutex lock is required to avoid race condition when updating token file and token cache.
+	tokenMutex sync.Mutex
+}
+
+// CreateAzurePlugin creates an Azure credential fetcher plugin. Return the pointer to the created plugin.
+func CreateAzurePlugin(audience, jwtPath, identityProvider string) *AzurePlugin {
+	return &AzurePlugin{
+		aud:              audience,
+		endpoint:         defaultAzureMetadataEndpoint,
+		jwtPath:          jwtPath,
+		identityProvider: identityProvider,
+		client:           &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// GetPlatformCredential fetches the Azure AD token of the managed identity of the VM from the
+// instance metadata service, and write it to jwtPath. The token is cached until it is about to expire.
+// Note: this function only works in an Azure VM environment with a managed identity.
+func (p *AzurePlugin) GetPlatformCredential() (string, error) {
+	p.tokenMutex.Lock()
+	defer p.tokenMutex.Unlock()
+
+	if p.jwtPath == "" {
+		return "", fmt.Errorf("jwtPath is unset")
+	}
+	if p.tokenCache != "" {
+		if exp, err := util.GetExp(p.tokenCache); err == nil && time.Now().Before(exp.Add(-gracePeriod)) {
+			return p.tokenCache, nil
+		}
+	}
+	token, err := p.fetchToken()
+	if err != nil {
+		azurecredLog.Errorf("Failed to get managed identity token from instance metadata service: %v", err)
+		return "", err
+	}
+	// Update token cache.
+	p.tokenCache = token
+	azurecredLog.Debugf("Got Azure managed identity token: %d", len(token))
+	if err := ioutil.WriteFile(p.jwtPath, []byte(token), 0640); err != nil {
+		azurecredLog.Errorf("Encountered error when writing managed identity token: %v", err)
+		return "", err
+	}
+	return token, nil
+}
+
+func (p *AzurePlugin) fetchToken() (string, error) {
+	query := url.Values{}
+	query.Set("api-version", "2018-02-01")
+	query.Set("resource", p.aud)
+	req, err := http.NewRequest(http.MethodGet, p.endpoint+"/metadata/identity/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata", "true")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("instance metadata service returned status %d: %s", resp.StatusCode, body)
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse managed identity token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("no access token in managed identity token response")
+	}
+	return token.AccessToken, nil
+}
+
+// GetType returns credential fetcher type.
+func (p *AzurePlugin) GetType() string {
+	return security.Azure
+}
+
+// GetIdentityProvider returns the name of the identity provider that can authenticate the workload credential.
+func (p *AzurePlugin) GetIdentityProvider() string {
+	return p.identityProvider
+}
+
+func (p *AzurePlugin) Stop() {}
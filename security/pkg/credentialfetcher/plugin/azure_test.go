@@ -0,0 +1,118 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/security"
+)
+
+// fakeJWT returns an unsigned JWT expiring at exp.
+func fakeJWT(sub string, exp time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":%q,"exp":%d}`, sub, exp.Unix())))
+	return header + "." + payload + ".signature"
+}
+
+// azureMetadataServer is a local stand-in of the Azure instance metadata service, which issues
+// tokens expiring after lifetime.
+type azureMetadataServer struct {
+	*httptest.Server
+	lifetime time.Duration
+
+	mutex    sync.Mutex
+	requests int
+}
+
+func newAzureMetadataServer(lifetime time.Duration) *azureMetadataServer {
+	ms := &azureMetadataServer{lifetime: lifetime}
+	ms.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata/identity/oauth2/token" || r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ms.mutex.Lock()
+		ms.requests++
+		sub := fmt.Sprintf("%s-%d", r.URL.Query().Get("resource"), ms.requests)
+		ms.mutex.Unlock()
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer"}`, fakeJWT(sub, time.Now().Add(ms.lifetime)))
+	}))
+	return ms
+}
+
+func (ms *azureMetadataServer) numRequests() int {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.requests
+}
+
+func TestAzurePlugin(t *testing.T) {
+	testCases := map[string]struct {
+		lifetime         time.Duration
+		expectedRequests int
+	}{
+		"token is cached": {
+			lifetime:         time.Hour,
+			expectedRequests: 1,
+		},
+		"token expiring within grace period is refreshed": {
+			lifetime:         gracePeriod / 2,
+			expectedRequests: 2,
+		},
+	}
+	for id, tc := range testCases {
+		t.Run(id, func(t *testing.T) {
+			ms := newAzureMetadataServer(tc.lifetime)
+			defer ms.Close()
+			jwtPath := filepath.Join(t.TempDir(), "istio-token")
+			p := CreateAzurePlugin("api://istio-ca", jwtPath, "idp")
+			p.endpoint = ms.URL
+			if p.GetType() != security.Azure {
+				t.Errorf("unexpected type %s", p.GetType())
+			}
+
+			first, err := p.GetPlatformCredential()
+			if err != nil {
+				t.Fatalf("failed to get credential: %v", err)
+			}
+			second, err := p.GetPlatformCredential()
+			if err != nil {
+				t.Fatalf("failed to get credential: %v", err)
+			}
+			if got := ms.numRequests(); got != tc.expectedRequests {
+				t.Errorf("metadata service got %d requests, expected %d", got, tc.expectedRequests)
+			}
+			if (first == second) != (tc.expectedRequests == 1) {
+				t.Errorf("unexpected tokens %s and %s", first, second)
+			}
+			written, err := ioutil.ReadFile(jwtPath)
+			if err != nil {
+				t.Fatalf("failed to read token file: %v", err)
+			}
+			if string(written) != second {
+				t.Errorf("token file has %s, expected %s", written, second)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This is OIDC token file plugin of credentialfetcher.
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/util"
+	"istio.io/pkg/log"
+)
+
+var oidccredLog = log.RegisterScope("oidccred", "OIDC token file credential fetcher for istio agent", 0)
+
+// The plugin object.
+type OIDCFilePlugin struct {
+	// The location of the OIDC token, which is refreshed periodically by another process,
+	// e.g. a platform agent or a cron job.
+	jwtPath string
+
+	// identity provider
+	identityProvider string
+}
+
+// CreateOIDCFilePlugin creates an OIDC token file credential fetcher plugin. Return the pointer to the created plugin.
+func CreateOIDCFilePlugin(jwtPath, identityProvider string) *OIDCFilePlugin {
+	return &OIDCFilePlugin{
+		jwtPath:          jwtPath,
+		identityProvider: identityProvider,
+	}
+}
+
+// GetPlatformCredential reads the OIDC token from jwtPath. The file is read on every call so that
+// the latest token written by the process refreshing it is used.
+func (p *OIDCFilePlugin) GetPlatformCredential() (string, error) {
+	if p.jwtPath == "" {
+		return "", fmt.Errorf("jwtPath is unset")
+	}
+	b, err := ioutil.ReadFile(p.jwtPath)
+	if err != nil {
+		oidccredLog.Errorf("Failed to read OIDC token file: %v", err)
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("OIDC token file %s is empty", p.jwtPath)
+	}
+	if exp, err := util.GetExp(token); err == nil && !exp.IsZero() && time.Now().After(exp) {
+		return "", fmt.Errorf("OIDC token in %s expired at %s", p.jwtPath, exp)
+	}
+	return token, nil
+}
+
+// GetType returns credential fetcher type.
+func (p *OIDCFilePlugin) GetType() string {
+	return security.OIDCFile
+}
+
+// GetIdentityProvider returns the name of the identity provider that can authenticate the workload credential.
+func (p *OIDCFilePlugin) GetIdentityProvider() string {
+	return p.identityProvider
+}
+
+func (p *OIDCFilePlugin) Stop() {}
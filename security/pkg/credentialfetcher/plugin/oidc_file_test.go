@@ -0,0 +1,84 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOIDCFilePlugin(t *testing.T) {
+	valid := fakeJWT("vm", time.Now().Add(time.Hour))
+	testCases := map[string]struct {
+		content       string
+		expectedToken string
+		expectedErr   string
+	}{
+		"valid token": {
+			content:       valid + "\n",
+			expectedToken: valid,
+		},
+		"token without expiration": {
+			content:       firstPartyJwt,
+			expectedToken: firstPartyJwt,
+		},
+		"expired token": {
+			content:     fakeJWT("vm", time.Now().Add(-time.Hour)),
+			expectedErr: "expired",
+		},
+		"empty file": {
+			content:     "\n",
+			expectedErr: "is empty",
+		},
+	}
+	for id, tc := range testCases {
+		t.Run(id, func(t *testing.T) {
+			jwtPath := filepath.Join(t.TempDir(), "istio-token")
+			if err := ioutil.WriteFile(jwtPath, []byte(tc.content), 0640); err != nil {
+				t.Fatal(err)
+			}
+			p := CreateOIDCFilePlugin(jwtPath, "idp")
+			token, err := p.GetPlatformCredential()
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("got error %v, expected %s", err, tc.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if token != tc.expectedToken {
+				t.Errorf("got token %s, expected %s", token, tc.expectedToken)
+			}
+		})
+	}
+
+	// The token is read again after the file is refreshed.
+	jwtPath := filepath.Join(t.TempDir(), "istio-token")
+	p := CreateOIDCFilePlugin(jwtPath, "idp")
+	if _, err := p.GetPlatformCredential(); err == nil {
+		t.Errorf("expected an error when the token file does not exist")
+	}
+	if err := ioutil.WriteFile(jwtPath, []byte(valid), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := p.GetPlatformCredential(); err != nil || token != valid {
+		t.Errorf("got token %s and error %v, expected %s", token, err, valid)
+	}
+}
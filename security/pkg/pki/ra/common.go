@@ -50,6 +50,18 @@ type IstioRAOptions struct {
 	K8sClient certificatesv1beta1.CertificatesV1beta1Interface
 	// TrustDomain
 	TrustDomain string
+	// ExternalCAAddress : URL of the external CA API, e.g. the EST base URL https://ca.example.com/.well-known/est
+	ExternalCAAddress string
+	// ExternalCAClientCertFile : File containing the PEM encoded client certificate to authenticate to the external CA
+	ExternalCAClientCertFile string
+	// ExternalCAClientKeyFile : File containing the PEM encoded private key of the client certificate
+	ExternalCAClientKeyFile string
+	// ExternalCAUsername : Username for HTTP basic authentication to the external CA, if any
+	ExternalCAUsername string
+	// ExternalCAPassword : Password for HTTP basic authentication to the external CA
+	ExternalCAPassword string
+	// ExternalCAChainRefreshInterval : Duration the CA certificates fetched from the external CA are cached for
+	ExternalCAChainRefreshInterval time.Duration
 }
 
 const (
@@ -59,6 +71,9 @@ const (
 	// ExtCAGrpc : Integration with external CA using Istio CA gRPC API
 	ExtCAGrpc CaExternalType = "ISTIOD_RA_ISTIO_API"
 
+	// ExtCAEst : Integration with external CA using the Enrollment over Secure Transport protocol (RFC 7030)
+	ExtCAEst CaExternalType = "ISTIOD_RA_EST_API"
+
 	// DefaultExtCACertDir : Location of external CA certificate
 	DefaultExtCACertDir string = "./etc/external-ca-cert"
 )
@@ -99,6 +114,13 @@ func NewIstioRA(opts *IstioRAOptions) (RegistrationAuthority, error) {
 		}
 		return istioRA, err
 	}
+	if opts.ExternalCAType == ExtCAEst {
+		istioRA, err := NewEstRA(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an EST CA: %v", err)
+		}
+		return istioRA, err
+	}
 	return nil, fmt.Errorf("invalid CA Name %s", opts.ExternalCAType)
 }
 
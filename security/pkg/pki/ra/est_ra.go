@@ -0,0 +1,399 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ra
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/ca"
+	raerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/pkg/log"
+)
+
+var estRaLog = log.RegisterScope("estra", "EST registration authority log", 0)
+
+const (
+	// DefaultExtCAChainRefreshInterval : Default duration the CA certificates fetched from the external CA are cached for
+	DefaultExtCAChainRefreshInterval = time.Hour
+
+	estSimpleEnrollPath = "/simpleenroll"
+	estCACertsPath      = "/cacerts"
+	estRequestTimeout   = 30 * time.Second
+	// estMaxResponseSize bounds the size of the responses read from the EST server.
+	estMaxResponseSize = 1 << 20
+	// estMinCACertsRefreshInterval rate limits the fetches of the CA certificates, which are otherwise triggered by
+	// every certificate failing to verify.
+	estMinCACertsRefreshInterval = 10 * time.Second
+)
+
+// oidSignedData is the PKCS #7 signed data content type, used by EST for certs-only responses.
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// EstRA integrated with an external CA using the Enrollment over Secure Transport protocol (RFC 7030)
+type EstRA struct {
+	client *http.Client
+	// roots are the trust anchors of the external CA, configured in CaCertFile. The CA certificates served
+	// by the EST server are only used as intermediates, and never trusted as roots.
+	roots  *x509.CertPool
+	raOpts *IstioRAOptions
+	// minRefreshInterval is the minimum interval between two fetches of the CA certificates.
+	minRefreshInterval time.Duration
+
+	// refreshMutex serializes the fetches of the CA certificates, so that concurrent callers share a fetch. It is
+	// held across the request to the EST server, unlike mutex.
+	refreshMutex sync.Mutex
+	// mutex protects the cached CA certificates and the key cert bundle.
+	mutex sync.Mutex
+	// intermediates are the CA certificates last fetched from the EST server.
+	intermediates *x509.CertPool
+	fetched       time.Time
+	// lastRefresh is the time of the last fetch attempt of the CA certificates, successful or not.
+	lastRefresh   time.Time
+	keyCertBundle *util.KeyCertBundle
+}
+
+// NewEstRA : Create a RA that interfaces with an external CA through an EST server
+func NewEstRA(raOpts *IstioRAOptions) (*EstRA, error) {
+	u, err := url.Parse(raOpts.ExternalCAAddress)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, raerror.NewError(raerror.CAIllegalConfig,
+			fmt.Errorf("invalid EST server address %q, an https URL is required", raOpts.ExternalCAAddress))
+	}
+	keyCertBundle, err := util.NewKeyCertBundleWithRootCertFromFile(raOpts.CaCertFile)
+	if err != nil {
+		return nil, raerror.NewError(raerror.CAInitFail, fmt.Errorf("error processing Certificate Bundle for EST RA"))
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(keyCertBundle.GetRootCertPem()) {
+		return nil, raerror.NewError(raerror.CAInitFail, fmt.Errorf("no root certificate found in %s", raOpts.CaCertFile))
+	}
+
+	// The EST server certificate is commonly issued by the external CA itself.
+	serverRoots, err := x509.SystemCertPool()
+	if err != nil {
+		serverRoots = x509.NewCertPool()
+	}
+	serverRoots.AppendCertsFromPEM(keyCertBundle.GetRootCertPem())
+	tlsConfig := &tls.Config{RootCAs: serverRoots, MinVersion: tls.VersionTLS12}
+	if raOpts.ExternalCAClientCertFile != "" {
+		clientCert, err := tls.LoadX509KeyPair(raOpts.ExternalCAClientCertFile, raOpts.ExternalCAClientKeyFile)
+		if err != nil {
+			return nil, raerror.NewError(raerror.CAInitFail, fmt.Errorf("failed to load the EST client certificate: %v", err))
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	if raOpts.ExternalCAChainRefreshInterval <= 0 {
+		raOpts.ExternalCAChainRefreshInterval = DefaultExtCAChainRefreshInterval
+	}
+	return &EstRA{
+		client: &http.Client{
+			Timeout:   estRequestTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		roots:              roots,
+		raOpts:             raOpts,
+		minRefreshInterval: estMinCACertsRefreshInterval,
+		keyCertBundle:      keyCertBundle,
+	}, nil
+}
+
+// Sign takes a PEM-encoded CSR and cert opts, and returns a certificate signed by the external CA.
+func (r *EstRA) Sign(csrPEM []byte, certOpts ca.CertOpts) ([]byte, error) {
+	if _, err := preSign(r.raOpts, csrPEM, certOpts.SubjectIDs, certOpts.TTL, certOpts.ForCA); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	certPEM, err := r.estSign(csrPEM, certOpts.SubjectIDs)
+	externalSignDuration.Record(time.Since(start).Seconds())
+	if err != nil {
+		result := "UNKNOWN"
+		var raErr *raerror.Error
+		if errors.As(err, &raErr) {
+			result = raErr.ErrorType()
+		}
+		externalSignCounts.With(resultTag.Value(result)).Increment()
+		return nil, err
+	}
+	externalSignCounts.With(resultTag.Value("success")).Increment()
+	return certPEM, nil
+}
+
+// SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
+func (r *EstRA) SignWithCertChain(csrPEM []byte, certOpts ca.CertOpts) ([]byte, error) {
+	cert, err := r.Sign(csrPEM, certOpts)
+	if err != nil {
+		return nil, err
+	}
+	chainPem := r.GetCAKeyCertBundle().GetCertChainPem()
+	if len(chainPem) > 0 {
+		cert = append(cert, chainPem...)
+	}
+	return cert, nil
+}
+
+// GetCAKeyCertBundle returns the KeyCertBundle for the CA. Its cert chain holds the intermediate CA certificates
+// of the last certificate issued.
+func (r *EstRA) GetCAKeyCertBundle() *util.KeyCertBundle {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.keyCertBundle
+}
+
+// estSign enrolls the CSR with the EST server, and validates the issued certificate before returning it.
+func (r *EstRA) estSign(csrPEM []byte, subjectIDs []string) ([]byte, error) {
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, raerror.NewError(raerror.CSRError, err)
+	}
+	body := base64.StdEncoding.EncodeToString(csr.Raw)
+	req, err := http.NewRequest(http.MethodPost, r.endpoint(estSimpleEnrollPath), strings.NewReader(body))
+	if err != nil {
+		return nil, raerror.NewError(raerror.CertGenError, err)
+	}
+	req.Header.Set("Content-Type", "application/pkcs10")
+	req.Header.Set("Content-Transfer-Encoding", "base64")
+	certs, err := r.doCertsRequest(req)
+	if err != nil {
+		return nil, raerror.NewError(raerror.CertGenError, fmt.Errorf("failed to enroll with the EST server: %v", err))
+	}
+
+	var leaf *x509.Certificate
+	for _, c := range certs {
+		if bytes.Equal(c.RawSubjectPublicKeyInfo, csr.RawSubjectPublicKeyInfo) {
+			leaf = c
+			break
+		}
+	}
+	if leaf == nil {
+		return nil, raerror.NewError(raerror.CertGenError, fmt.Errorf("the EST server response has no certificate for the CSR key"))
+	}
+	// The external CA may apply its own profile, so check it did not add identities the caller was not
+	// authenticated for.
+	if err := validateCertIDs(leaf, subjectIDs); err != nil {
+		return nil, raerror.NewError(raerror.CertGenError, err)
+	}
+	if err := r.verify(leaf); err != nil {
+		return nil, raerror.NewError(raerror.CertGenError, err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}), nil
+}
+
+// verify verifies the certificate chains to the configured roots. The CA certificates are fetched from the EST
+// server when they are stale, or once more when the verification fails, as the external CA may have rotated its
+// intermediates.
+func (r *EstRA) verify(leaf *x509.Certificate) error {
+	r.mutex.Lock()
+	intermediates := r.intermediates
+	stale := intermediates == nil || time.Since(r.fetched) > r.raOpts.ExternalCAChainRefreshInterval
+	r.mutex.Unlock()
+
+	refreshed := false
+	if stale {
+		var err error
+		if intermediates, err = r.refreshCACerts(); err != nil {
+			return err
+		}
+		refreshed = true
+	}
+	chains, err := leaf.Verify(r.verifyOptions(intermediates))
+	if err != nil && !refreshed {
+		if intermediates, err = r.refreshCACerts(); err != nil {
+			return err
+		}
+		chains, err = leaf.Verify(r.verifyOptions(intermediates))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify the certificate chain: %v", err)
+	}
+
+	// Keep the intermediates of the verified chain, from the issuer of the leaf up to the root excluded.
+	chain := chains[0]
+	var chainPem []byte
+	expiry := time.Time{}
+	for i, c := range chain {
+		if i > 0 && i < len(chain)-1 {
+			chainPem = append(chainPem, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+		}
+		if i > 0 && (expiry.IsZero() || c.NotAfter.Before(expiry)) {
+			expiry = c.NotAfter
+		}
+	}
+	r.mutex.Lock()
+	if !bytes.Equal(chainPem, r.keyCertBundle.GetCertChainPem()) {
+		estRaLog.Infof("updating the CA certificate chain from the EST server")
+		r.keyCertBundle = util.NewKeyCertBundleFromPem(nil, nil, chainPem, r.keyCertBundle.GetRootCertPem())
+	}
+	r.mutex.Unlock()
+	externalCAChainExpiryTimestamp.Record(float64(expiry.Unix()))
+	return nil
+}
+
+func (r *EstRA) verifyOptions(intermediates *x509.CertPool) x509.VerifyOptions {
+	return x509.VerifyOptions{
+		Roots:         r.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+}
+
+// refreshCACerts fetches the CA certificates from the EST server, and returns them. Fetches are rate limited:
+// within minRefreshInterval of the previous one, the cached CA certificates are returned instead.
+func (r *EstRA) refreshCACerts() (*x509.CertPool, error) {
+	r.refreshMutex.Lock()
+	defer r.refreshMutex.Unlock()
+
+	r.mutex.Lock()
+	current := r.intermediates
+	recent := time.Since(r.lastRefresh) < r.minRefreshInterval
+	if !recent {
+		r.lastRefresh = time.Now()
+	}
+	r.mutex.Unlock()
+	if recent {
+		// The caller may have waited for a concurrent fetch, whose result is as fresh as it gets.
+		if current == nil {
+			return nil, fmt.Errorf("failed to fetch the CA certificates from the EST server, retrying in at most %v",
+				r.minRefreshInterval)
+		}
+		return current, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, r.endpoint(estCACertsPath), nil)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := r.doCertsRequest(req)
+	if err != nil {
+		externalCAChainRefreshCounts.With(resultTag.Value("error")).Increment()
+		return nil, fmt.Errorf("failed to fetch the CA certificates from the EST server: %v", err)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		intermediates.AddCert(c)
+	}
+	r.mutex.Lock()
+	r.intermediates = intermediates
+	r.fetched = time.Now()
+	r.mutex.Unlock()
+	externalCAChainRefreshCounts.With(resultTag.Value("success")).Increment()
+	estRaLog.Debugf("fetched %d CA certificates from the EST server", len(certs))
+	return intermediates, nil
+}
+
+func (r *EstRA) endpoint(operation string) string {
+	return strings.TrimSuffix(r.raOpts.ExternalCAAddress, "/") + operation
+}
+
+// doCertsRequest sends an EST request, and parses the base64 encoded PKCS #7 certs-only response.
+func (r *EstRA) doCertsRequest(req *http.Request) ([]*x509.Certificate, error) {
+	if r.raOpts.ExternalCAUsername != "" {
+		req.SetBasicAuth(r.raOpts.ExternalCAUsername, r.raOpts.ExternalCAPassword)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, estMaxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > estMaxResponseSize {
+		return nil, fmt.Errorf("the response exceeds the maximum size of %d bytes", estMaxResponseSize)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusAccepted:
+		// Manual approval is pending on the CA. The workload retries the CSR later on.
+		return nil, fmt.Errorf("the request is pending approval, retry after %q", resp.Header.Get("Retry-After"))
+	default:
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the response: %v", err)
+	}
+	return parseCertsOnlyPKCS7(der)
+}
+
+// parseCertsOnlyPKCS7 extracts the certificates of a degenerate PKCS #7 signed data structure (RFC 2315), which
+// is how EST conveys certificates.
+func parseCertsOnlyPKCS7(der []byte) ([]*x509.Certificate, error) {
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+	}
+	if _, err := asn1.Unmarshal(der, &contentInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse the PKCS #7 content: %v", err)
+	}
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unexpected PKCS #7 content type %v", contentInfo.ContentType)
+	}
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+		CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+		SignerInfos      asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("failed to parse the PKCS #7 signed data: %v", err)
+	}
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate in the PKCS #7 content")
+	}
+	return certs, nil
+}
+
+// validateCertIDs checks all SAN identities of the certificate match the authenticated identities.
+func validateCertIDs(cert *x509.Certificate, subjectIDs []string) error {
+	ids, err := util.ExtractIDs(cert.Extensions)
+	if err != nil {
+		return fmt.Errorf("failed to extract the identities of the issued certificate: %v", err)
+	}
+	for _, id := range ids {
+		found := false
+		for _, s := range subjectIDs {
+			if id == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("the issued certificate has the unexpected identity %q", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ra
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/ca"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
+)
+
+// fakeEstServer is an EST server signing with an intermediate CA of a test root CA.
+type fakeEstServer struct {
+	t       *testing.T
+	server  *httptest.Server
+	rootPem []byte
+
+	mu             sync.Mutex
+	rootCert       *x509.Certificate
+	rootKey        interface{}
+	interCert      *x509.Certificate
+	interKey       interface{}
+	extraIDs       []string
+	pending        bool
+	oversized      bool
+	caCertsFetches int
+}
+
+func newFakeEstServer(t *testing.T) *fakeEstServer {
+	rootPem, rootKeyPem, err := pkiutil.GenCertKeyFromOptions(pkiutil.CertOptions{
+		Host:         "root.example.com",
+		TTL:          time.Hour,
+		Org:          "example",
+		IsCA:         true,
+		IsSelfSigned: true,
+		ECSigAlg:     pkiutil.EcdsaSigAlg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeEstServer{t: t, rootPem: rootPem}
+	s.rootCert, s.rootKey = parseCertAndKey(t, rootPem, rootKeyPem)
+	s.rotateIntermediate()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/est/cacerts", s.handleCACerts)
+	mux.HandleFunc("/.well-known/est/simpleenroll", s.handleSimpleEnroll)
+	s.server = httptest.NewTLSServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func parseCertAndKey(t *testing.T, certPem, keyPem []byte) (*x509.Certificate, interface{}) {
+	cert, err := pkiutil.ParsePemEncodedCertificate(certPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := pkiutil.ParsePemEncodedKey(keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func (s *fakeEstServer) rotateIntermediate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	interPem, interKeyPem, err := pkiutil.GenCertKeyFromOptions(pkiutil.CertOptions{
+		Host:       "intermediate.example.com",
+		TTL:        time.Hour,
+		Org:        "example",
+		IsCA:       true,
+		SignerCert: s.rootCert,
+		SignerPriv: s.rootKey,
+		ECSigAlg:   pkiutil.EcdsaSigAlg,
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.interCert, s.interKey = parseCertAndKey(s.t, interPem, interKeyPem)
+}
+
+func (s *fakeEstServer) handleCACerts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caCertsFetches++
+	writeCertsOnlyPKCS7(s.t, w, s.interCert.Raw, s.rootCert.Raw)
+}
+
+func (s *fakeEstServer) handleSimpleEnroll(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if s.oversized {
+		_, _ = w.Write(bytes.Repeat([]byte("A"), estMaxResponseSize+1))
+		return
+	}
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/pkcs10" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	der, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ids, err := pkiutil.ExtractIDs(csr.Extensions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	leaf, err := pkiutil.GenCertFromCSR(csr, s.interCert, csr.PublicKey, s.interKey, append(ids, s.extraIDs...), time.Hour, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeCertsOnlyPKCS7(s.t, w, leaf)
+}
+
+// writeCertsOnlyPKCS7 writes the certificates as a base64 encoded degenerate PKCS #7 signed data structure.
+func writeCertsOnlyPKCS7(t *testing.T, w http.ResponseWriter, certs ...[]byte) {
+	emptySet := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+	dataContentInfo, err := asn1.Marshal(struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var certsBytes []byte
+	for _, c := range certs {
+		certsBytes = append(certsBytes, c...)
+	}
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		ContentInfo:      asn1.RawValue{FullBytes: dataContentInfo},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certsBytes},
+		SignerInfos:      emptySet,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentInfo, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Header().Set("Content-Type", "application/pkcs7-mime; smime-type=certs-only")
+	w.Header().Set("Content-Transfer-Encoding", "base64")
+	// EST servers commonly wrap the base64 content in lines.
+	encoded := base64.StdEncoding.EncodeToString(contentInfo)
+	for len(encoded) > 64 {
+		_, _ = w.Write([]byte(encoded[:64] + "\r\n"))
+		encoded = encoded[64:]
+	}
+	_, _ = w.Write([]byte(encoded))
+}
+
+func (s *fakeEstServer) fetches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.caCertsFetches
+}
+
+func createFakeEstRA(t *testing.T, s *fakeEstServer) *EstRA {
+	caCertFile := filepath.Join(t.TempDir(), "root-cert.pem")
+	if err := ioutil.WriteFile(caCertFile, s.rootPem, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewEstRA(&IstioRAOptions{
+		ExternalCAType:    ExtCAEst,
+		DefaultCertTTL:    30 * time.Minute,
+		MaxCertTTL:        time.Hour,
+		CaCertFile:        caCertFile,
+		ExternalCAAddress: s.server.URL + "/.well-known/est/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Trust the test server certificate.
+	r.client = s.server.Client()
+	return r
+}
+
+func TestEstSign(t *testing.T) {
+	s := newFakeEstServer(t)
+	r := createFakeEstRA(t, s)
+	certOpts := ca.CertOpts{SubjectIDs: []string{testCsrHostName}, TTL: time.Hour}
+
+	certPem, err := r.Sign(createFakeCsr(t), certOpts)
+	if err != nil {
+		t.Fatalf("EST signing failed: %v", err)
+	}
+	cert, err := pkiutil.ParsePemEncodedCertificate(certPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.CheckSignatureFrom(s.interCert); err != nil {
+		t.Errorf("the certificate is not issued by the intermediate CA: %v", err)
+	}
+	interPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.interCert.Raw})
+	if got := r.GetCAKeyCertBundle().GetCertChainPem(); string(got) != string(interPem) {
+		t.Errorf("unexpected cert chain %s", got)
+	}
+	if got := r.GetCAKeyCertBundle().GetRootCertPem(); string(got) != string(s.rootPem) {
+		t.Errorf("unexpected root cert %s", got)
+	}
+
+	chainPem, err := r.SignWithCertChain(createFakeCsr(t), certOpts)
+	if err != nil {
+		t.Fatalf("EST signing failed: %v", err)
+	}
+	if !strings.HasSuffix(string(chainPem), string(interPem)) {
+		t.Errorf("the intermediate CA is not appended to the certificate: %s", chainPem)
+	}
+	if s.fetches() != 1 {
+		t.Errorf("expected the CA certificates to be fetched once, got %d", s.fetches())
+	}
+
+	// After the intermediate is rotated, the cached chain no longer verifies. It is not refreshed right away, to
+	// rate limit the fetches.
+	s.rotateIntermediate()
+	if _, err := r.Sign(createFakeCsr(t), certOpts); err == nil {
+		t.Fatalf("expected EST signing to fail until the CA certificates are refreshed")
+	}
+	if s.fetches() != 1 {
+		t.Errorf("expected the CA certificates to be fetched once, got %d", s.fetches())
+	}
+
+	// Once the rate limit allows it, the chain is refreshed.
+	r.minRefreshInterval = 0
+	if _, err := r.Sign(createFakeCsr(t), certOpts); err != nil {
+		t.Fatalf("EST signing failed after the intermediate rotation: %v", err)
+	}
+	if s.fetches() != 2 {
+		t.Errorf("expected the CA certificates to be fetched twice, got %d", s.fetches())
+	}
+	interPem = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.interCert.Raw})
+	if got := r.GetCAKeyCertBundle().GetCertChainPem(); string(got) != string(interPem) {
+		t.Errorf("the cert chain was not updated: %s", got)
+	}
+}
+
+func TestEstSignErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		subjectIDs []string
+		forCA      bool
+		pending    bool
+		oversized  bool
+		extraIDs   []string
+		err        string
+	}{
+		{
+			name:       "CSR identity not authenticated",
+			subjectIDs: []string{"spiffe://cluster.local/ns/default/sa/other"},
+			err:        "unable to validate SAN Identities in CSR",
+		},
+		{
+			name:       "CA certificate",
+			subjectIDs: []string{testCsrHostName},
+			forCA:      true,
+			err:        "unable to generate CA certifificates",
+		},
+		{
+			name:       "pending approval",
+			subjectIDs: []string{testCsrHostName},
+			pending:    true,
+			err:        "pending approval",
+		},
+		{
+			name:       "oversized response",
+			subjectIDs: []string{testCsrHostName},
+			oversized:  true,
+			err:        "exceeds the maximum size",
+		},
+		{
+			name:       "identity added by the CA",
+			subjectIDs: []string{testCsrHostName},
+			extraIDs:   []string{"spiffe://cluster.local/ns/istio-system/sa/istiod"},
+			err:        "unexpected identity",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeEstServer(t)
+			s.pending = tt.pending
+			s.oversized = tt.oversized
+			s.extraIDs = tt.extraIDs
+			r := createFakeEstRA(t, s)
+			_, err := r.Sign(createFakeCsr(t), ca.CertOpts{SubjectIDs: tt.subjectIDs, TTL: time.Hour, ForCA: tt.forCA})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestNewEstRAInvalidAddress(t *testing.T) {
+	for _, address := range []string{"", "http://ca.example.com/.well-known/est", "ca.example.com"} {
+		_, err := NewEstRA(&IstioRAOptions{ExternalCAType: ExtCAEst, CaCertFile: TestCACertFile, ExternalCAAddress: address})
+		if err == nil {
+			t.Errorf("expected an error for the address %q", address)
+		}
+	}
+}
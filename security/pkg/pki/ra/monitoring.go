@@ -0,0 +1,55 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ra
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	resultTag = monitoring.MustCreateLabel("result")
+
+	externalSignCounts = monitoring.NewSum(
+		"citadel_ra_external_sign_count",
+		"The number of certificates requested from the external CA, including renewals, by result.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	externalSignDuration = monitoring.NewDistribution(
+		"citadel_ra_external_sign_duration_seconds",
+		"The time, in seconds, taken by the external CA to sign a certificate.",
+		[]float64{.01, .1, .5, 1, 3, 5, 10, 30},
+	)
+
+	externalCAChainRefreshCounts = monitoring.NewSum(
+		"citadel_ra_external_ca_chain_refresh_count",
+		"The number of times the CA certificate chain was fetched from the external CA, by result.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	externalCAChainExpiryTimestamp = monitoring.NewGauge(
+		"citadel_ra_external_ca_chain_expiry_timestamp",
+		"The unix timestamp, in seconds, when the first certificate of the cached external CA chain will expire.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		externalSignCounts,
+		externalSignDuration,
+		externalCAChainRefreshCounts,
+		externalCAChainExpiryTimestamp,
+	)
+}
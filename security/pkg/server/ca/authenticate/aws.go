@@ -0,0 +1,190 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pkg/security"
+)
+
+const (
+	AWSAuthenticatorType = "AWSInstanceIdentityAuthenticator"
+
+	// defaultAWSMaxDocumentAge is the default maximum time since the start of an instance for which
+	// its instance identity document is accepted.
+	defaultAWSMaxDocumentAge = time.Hour
+	// awsClockSkew is the tolerated skew between the clocks of AWS and Istiod.
+	awsClockSkew = 5 * time.Minute
+)
+
+// AWSAuthenticator authenticates AWS EC2 instances with their instance identity documents, signed by
+// AWS with the RSA key of the region of the instance. The documents do not expire and can be read by
+// any process of the instance, so they are only accepted to bootstrap the instances:
+//   - a document is rejected once its pendingTime, the time the instance was started, is older than the
+//     maximum document age.
+//   - the first credential accepted for an instance binds the instance to the nonce of the credential,
+//     generated and kept by the Istio agent. Until the instance is restarted, credentials of the
+//     instance with another nonce are rejected. The bindings are kept in a ConfigMap shared by the
+//     Istiod replicas, so that they survive restarts of Istiod.
+type AWSAuthenticator struct {
+	trustDomain    string
+	keys           []*rsa.PublicKey
+	identities     platformIdentities
+	maxDocumentAge time.Duration
+	bindings       *awsInstanceBindings
+}
+
+var _ security.Authenticator = &AWSAuthenticator{}
+
+// NewAWSAuthenticator creates an authenticator verifying the instance identity documents with the
+// PEM encoded AWS public certificates. The certificates file of the config is ignored. The instance
+// bindings are kept in the AWSInstanceBindingsConfigMap of the namespace of Istiod.
+func NewAWSAuthenticator(certificatesPEM []byte, cfg *AWSAuthConfig, trustDomain string,
+	client kubernetes.Interface, namespace string) (*AWSAuthenticator, error) {
+	if client == nil {
+		return nil, fmt.Errorf("a Kubernetes client is required to keep the AWS instance bindings")
+	}
+	var keys []*rsa.PublicKey
+	for block, rest := pem.Decode(certificatesPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse AWS certificate: %v", err)
+		}
+		key, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("AWS certificate %v does not have an RSA public key", cert.Subject)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no AWS certificates are found")
+	}
+	ids, err := newPlatformIdentities(cfg.Identities)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAWSPrincipals(ids, cfg.AllowAccountWildcards); err != nil {
+		return nil, err
+	}
+	maxDocumentAge := defaultAWSMaxDocumentAge
+	if cfg.MaxDocumentAge != "" {
+		if maxDocumentAge, err = time.ParseDuration(cfg.MaxDocumentAge); err != nil || maxDocumentAge <= 0 {
+			return nil, fmt.Errorf("invalid maximum document age %q", cfg.MaxDocumentAge)
+		}
+	}
+	return &AWSAuthenticator{
+		trustDomain:    trustDomain,
+		keys:           keys,
+		identities:     ids,
+		maxDocumentAge: maxDocumentAge,
+		bindings:       &awsInstanceBindings{client: client, namespace: namespace},
+	}, nil
+}
+
+// validateAWSPrincipals rejects the principals with a wildcard in the account ID, and the ones with a
+// wildcard in the instance ID unless allowAccountWildcards is set.
+func validateAWSPrincipals(identities platformIdentities, allowAccountWildcards bool) error {
+	for _, id := range identities {
+		if !strings.Contains(id.Principal, "*") {
+			continue
+		}
+		parts := strings.SplitN(id.Principal, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.Contains(parts[0], "*") {
+			return fmt.Errorf("principal %q matches the instances of several accounts", id.Principal)
+		}
+		if !allowAccountWildcards {
+			return fmt.Errorf("principal %q matches several instances of the account, "+
+				"which is only allowed with allowAccountWildcards", id.Principal)
+		}
+	}
+	return nil
+}
+
+type awsInstanceIdentity struct {
+	AccountID  string `json:"accountId"`
+	InstanceID string `json:"instanceId"`
+	Region     string `json:"region"`
+	// PendingTime is the time the instance was started.
+	PendingTime time.Time `json:"pendingTime"`
+}
+
+// Authenticate verifies the instance identity document in the bearer token and maps the
+// "<account ID>/<instance ID>" principal of the instance to a workload identity.
+func (a *AWSAuthenticator) Authenticate(ctx context.Context) (*security.Caller, error) {
+	credential, err := security.ExtractBearerToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ID token extraction error: %v", err)
+	}
+	document, signature, nonce, err := security.DecodeAWSCredential(credential)
+	if err != nil {
+		return nil, err
+	}
+	if !a.verify(document, signature) {
+		return nil, fmt.Errorf("failed to verify the signature of the instance identity document")
+	}
+	instance := &awsInstanceIdentity{}
+	if err := json.Unmarshal(document, instance); err != nil {
+		return nil, fmt.Errorf("failed to parse the instance identity document: %v", err)
+	}
+	if instance.AccountID == "" || instance.InstanceID == "" {
+		return nil, fmt.Errorf("account or instance ID is missing in the instance identity document")
+	}
+	now := time.Now()
+	if instance.PendingTime.IsZero() || now.Sub(instance.PendingTime) > a.maxDocumentAge ||
+		instance.PendingTime.After(now.Add(awsClockSkew)) {
+		return nil, fmt.Errorf("the instance identity document of instance %s was issued at %v, "+
+			"outside of the maximum document age %v", instance.InstanceID, instance.PendingTime, a.maxDocumentAge)
+	}
+	identity, err := a.identities.identity(instance.AccountID+"/"+instance.InstanceID, a.trustDomain)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.bindings.bind(ctx, instance, sha256.Sum256(nonce), now, a.maxDocumentAge); err != nil {
+		return nil, err
+	}
+	return &security.Caller{
+		AuthSource: security.AuthSourceIDToken,
+		Identities: []string{identity},
+	}, nil
+}
+
+func (a *AWSAuthenticator) verify(document, signature []byte) bool {
+	digest := sha256.Sum256(document)
+	for _, key := range a.keys {
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AWSAuthenticator) AuthenticatorType() string {
+	return AWSAuthenticatorType
+}
@@ -0,0 +1,129 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// AWSInstanceBindingsConfigMap is the name of the ConfigMap, in the namespace of Istiod, keeping the
+// nonces the AWS instances are bound to. Its data maps the instance IDs to the pendingTime of the
+// bound instance identity document and the SHA-256 hash of the nonce.
+const AWSInstanceBindingsConfigMap = "istio-aws-instance-bindings"
+
+// awsInstanceBindings keeps the instance bindings of the AWSAuthenticator in a ConfigMap, so that they
+// are shared by the Istiod replicas. Concurrent bindings are resolved with optimistic concurrency.
+type awsInstanceBindings struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+// awsInstanceBinding is the first credential accepted for an instance since it was started.
+type awsInstanceBinding struct {
+	pendingTime time.Time
+	nonceHash   [sha256.Size]byte
+}
+
+func (b awsInstanceBinding) String() string {
+	return b.pendingTime.UTC().Format(time.RFC3339Nano) + " " + hex.EncodeToString(b.nonceHash[:])
+}
+
+func parseAWSInstanceBinding(s string) (awsInstanceBinding, error) {
+	b := awsInstanceBinding{}
+	parts := strings.Split(s, " ")
+	if len(parts) != 2 {
+		return b, fmt.Errorf("malformed instance binding %q", s)
+	}
+	var err error
+	if b.pendingTime, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return b, fmt.Errorf("malformed instance binding %q: %v", s, err)
+	}
+	hash, err := hex.DecodeString(parts[1])
+	if err != nil || len(hash) != sha256.Size {
+		return b, fmt.Errorf("malformed instance binding %q", s)
+	}
+	copy(b.nonceHash[:], hash)
+	return b, nil
+}
+
+// bind binds the instance to the nonce of its first accepted credential since it was started, and
+// rejects the credentials of the instance with another nonce or an older document. The bindings older
+// than maxAge are pruned, as the documents they were created for are rejected anyway.
+func (b *awsInstanceBindings) bind(ctx context.Context, instance *awsInstanceIdentity, nonceHash [sha256.Size]byte,
+	now time.Time, maxAge time.Duration) error {
+	binding := awsInstanceBinding{pendingTime: instance.PendingTime, nonceHash: nonceHash}
+	conflict := func(err error) bool {
+		return kerrors.IsConflict(err) || kerrors.IsAlreadyExists(err)
+	}
+	return retry.OnError(retry.DefaultRetry, conflict, func() error {
+		cm, err := b.client.CoreV1().ConfigMaps(b.namespace).Get(ctx, AWSInstanceBindingsConfigMap, metav1.GetOptions{})
+		notFound := kerrors.IsNotFound(err)
+		if err != nil && !notFound {
+			return fmt.Errorf("failed to get the AWS instance bindings: %v", err)
+		}
+		if notFound {
+			cm = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: AWSInstanceBindingsConfigMap, Namespace: b.namespace}}
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+
+		if value, found := cm.Data[instance.InstanceID]; found {
+			seen, err := parseAWSInstanceBinding(value)
+			if err != nil {
+				return err
+			}
+			switch {
+			case instance.PendingTime.Before(seen.pendingTime):
+				return fmt.Errorf("the instance identity document of instance %s predates the last start of the instance",
+					instance.InstanceID)
+			case instance.PendingTime.Equal(seen.pendingTime):
+				if subtle.ConstantTimeCompare(nonceHash[:], seen.nonceHash[:]) != 1 {
+					return fmt.Errorf("instance %s is already authenticated with another nonce", instance.InstanceID)
+				}
+				return nil
+			}
+			// Otherwise the instance was restarted since it was bound.
+		}
+
+		for id, value := range cm.Data {
+			if seen, err := parseAWSInstanceBinding(value); err != nil || now.Sub(seen.pendingTime) > maxAge {
+				delete(cm.Data, id)
+			}
+		}
+		cm.Data[instance.InstanceID] = binding.String()
+		if notFound {
+			_, err = b.client.CoreV1().ConfigMaps(b.namespace).Create(ctx, cm, metav1.CreateOptions{})
+		} else {
+			_, err = b.client.CoreV1().ConfigMaps(b.namespace).Update(ctx, cm, metav1.UpdateOptions{})
+		}
+		if err != nil && !conflict(err) {
+			return fmt.Errorf("failed to bind instance %s: %v", instance.InstanceID, err)
+		}
+		return err
+	})
+}
@@ -0,0 +1,311 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/security"
+)
+
+// awsSigner signs instance identity documents like AWS does for a region.
+type awsSigner struct {
+	key            *rsa.PrivateKey
+	certificatePEM []byte
+}
+
+func newAWSSigner(t *testing.T) *awsSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate a private key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"Amazon Web Services LLC"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create a certificate: %v", err)
+	}
+	return &awsSigner{
+		key:            key,
+		certificatePEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (s *awsSigner) credential(t *testing.T, document, nonce string) string {
+	digest := sha256.Sum256([]byte(document))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign the document: %v", err)
+	}
+	return security.EncodeAWSCredential([]byte(document), signature, []byte(nonce))
+}
+
+// awsDocument returns the instance identity document of an instance started at pendingTime.
+func awsDocument(account, instance string, pendingTime time.Time) string {
+	return fmt.Sprintf(`{"accountId":%q,"instanceId":%q,"region":"us-west-2","pendingTime":%q}`,
+		account, instance, pendingTime.UTC().Format(time.RFC3339))
+}
+
+func authenticateAWS(authenticator *AWSAuthenticator, token string) (*security.Caller, error) {
+	md := metadata.MD{}
+	md.Append("authorization", bearerTokenPrefix+token)
+	return authenticator.Authenticate(metadata.NewIncomingContext(context.Background(), md))
+}
+
+func TestAWSAuthenticate(t *testing.T) {
+	signer := newAWSSigner(t)
+	otherSigner := newAWSSigner(t)
+	cfg := &AWSAuthConfig{
+		Identities: []PlatformIdentity{
+			{Principal: "123456789012/i-1234567890abcdef0", Namespace: "vm", ServiceAccount: "db"},
+			{Principal: "123456789012/*", Namespace: "vm", ServiceAccount: "legacy"},
+		},
+		AllowAccountWildcards: true,
+	}
+	authenticator, err := NewAWSAuthenticator(signer.certificatePEM, cfg, "cluster.local", fake.NewSimpleClientset(), "istio-system")
+	if err != nil {
+		t.Fatalf("failed to create the AWS authenticator: %v", err)
+	}
+	started := time.Now().Add(-10 * time.Minute)
+	document := func(account, instance string) string {
+		return awsDocument(account, instance, started)
+	}
+
+	tests := map[string]struct {
+		token      string
+		expectErr  bool
+		expectedID string
+	}{
+		"Instance identity": {
+			token:      signer.credential(t, document("123456789012", "i-1234567890abcdef0"), "nonce"),
+			expectedID: fmt.Sprintf(IdentityTemplate, "cluster.local", "vm", "db"),
+		},
+		"Account identity": {
+			token:      signer.credential(t, document("123456789012", "i-0fedcba0987654321"), "nonce"),
+			expectedID: fmt.Sprintf(IdentityTemplate, "cluster.local", "vm", "legacy"),
+		},
+		"Unmapped account": {
+			token:     signer.credential(t, document("210987654321", "i-1234567890abcdef0"), "nonce"),
+			expectErr: true,
+		},
+		"Document signed by another key": {
+			token:     otherSigner.credential(t, document("123456789012", "i-1234567890abcdef0"), "nonce"),
+			expectErr: true,
+		},
+		"Tampered document": {
+			token: security.EncodeAWSCredential([]byte(document("123456789012", "i-1234567890abcdef0")),
+				[]byte("signature"), []byte("nonce")),
+			expectErr: true,
+		},
+		"Document older than the maximum age": {
+			token: signer.credential(t,
+				awsDocument("123456789012", "i-0000000000000000a", time.Now().Add(-2*time.Hour)), "nonce"),
+			expectErr: true,
+		},
+		"Document from the future": {
+			token: signer.credential(t,
+				awsDocument("123456789012", "i-0000000000000000b", time.Now().Add(time.Hour)), "nonce"),
+			expectErr: true,
+		},
+		"Document without pending time": {
+			token: signer.credential(t,
+				`{"accountId":"123456789012","instanceId":"i-0000000000000000c","region":"us-west-2"}`, "nonce"),
+			expectErr: true,
+		},
+		"JWT": {
+			token:     "header.payload.signature",
+			expectErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actualCaller, err := authenticateAWS(authenticator, tc.token)
+			gotErr := err != nil
+			if gotErr != tc.expectErr {
+				t.Fatalf("gotErr (%v) whereas expectErr (%v): %v", gotErr, tc.expectErr, err)
+			}
+			if gotErr {
+				return
+			}
+			expectedCaller := &security.Caller{
+				AuthSource: security.AuthSourceIDToken,
+				Identities: []string{tc.expectedID},
+			}
+			if !reflect.DeepEqual(actualCaller, expectedCaller) {
+				t.Errorf("%v: unexpected caller (want %v but got %v)", name, expectedCaller, actualCaller)
+			}
+		})
+	}
+}
+
+func TestAWSAuthenticateTrustOnFirstUse(t *testing.T) {
+	signer := newAWSSigner(t)
+	cfg := &AWSAuthConfig{
+		Identities: []PlatformIdentity{{Principal: "123456789012/i-1234567890abcdef0", Namespace: "vm", ServiceAccount: "db"}},
+	}
+	expired := awsInstanceBinding{pendingTime: time.Now().Add(-2 * time.Hour)}
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: AWSInstanceBindingsConfigMap, Namespace: "istio-system"},
+		Data:       map[string]string{"i-0000000000000000a": expired.String()},
+	})
+	// The replicas of Istiod share the instance bindings.
+	var replicas []*AWSAuthenticator
+	for i := 0; i < 2; i++ {
+		authenticator, err := NewAWSAuthenticator(signer.certificatePEM, cfg, "cluster.local", client, "istio-system")
+		if err != nil {
+			t.Fatalf("failed to create the AWS authenticator: %v", err)
+		}
+		replicas = append(replicas, authenticator)
+	}
+	started := time.Now().Add(-10 * time.Minute)
+	steps := []struct {
+		name        string
+		replica     int
+		pendingTime time.Time
+		nonce       string
+		expectErr   bool
+	}{
+		{name: "first use", pendingTime: started, nonce: "agent"},
+		{name: "same nonce", pendingTime: started, nonce: "agent"},
+		{name: "same nonce on another replica", replica: 1, pendingTime: started, nonce: "agent"},
+		{name: "replayed document", pendingTime: started, nonce: "attacker", expectErr: true},
+		{name: "replayed document on another replica", replica: 1, pendingTime: started, nonce: "attacker", expectErr: true},
+		{name: "document before the start", pendingTime: started.Add(-time.Minute), nonce: "attacker", expectErr: true},
+		{name: "restarted instance", replica: 1, pendingTime: started.Add(time.Minute), nonce: "restarted"},
+		{name: "nonce before the restart", pendingTime: started.Add(time.Minute), nonce: "agent", expectErr: true},
+	}
+	for _, step := range steps {
+		token := signer.credential(t, awsDocument("123456789012", "i-1234567890abcdef0", step.pendingTime), step.nonce)
+		_, err := authenticateAWS(replicas[step.replica], token)
+		if gotErr := err != nil; gotErr != step.expectErr {
+			t.Fatalf("%s: gotErr (%v) whereas expectErr (%v): %v", step.name, gotErr, step.expectErr, err)
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("istio-system").Get(context.TODO(), AWSInstanceBindingsConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the instance bindings: %v", err)
+	}
+	if _, found := cm.Data["i-0000000000000000a"]; found || len(cm.Data) != 1 {
+		t.Errorf("expected only the binding of the authenticated instance, got %v", cm.Data)
+	}
+}
+
+func TestNewAWSAuthenticator(t *testing.T) {
+	signer := newAWSSigner(t)
+	identities := func(principal string) []PlatformIdentity {
+		return []PlatformIdentity{{Principal: principal, Namespace: "vm", ServiceAccount: "legacy"}}
+	}
+	tests := map[string]struct {
+		certificatesPEM []byte
+		cfg             *AWSAuthConfig
+		noClient        bool
+		expectErr       bool
+	}{
+		"instance": {
+			cfg: &AWSAuthConfig{Identities: identities("123456789012/i-1234567890abcdef0"), MaxDocumentAge: "30m"},
+		},
+		"allowed account wildcard": {
+			cfg: &AWSAuthConfig{Identities: identities("123456789012/*"), AllowAccountWildcards: true},
+		},
+		"allowed instance prefix": {
+			cfg: &AWSAuthConfig{Identities: identities("123456789012/i-*"), AllowAccountWildcards: true},
+		},
+		"account wildcard": {
+			cfg:       &AWSAuthConfig{Identities: identities("123456789012/*")},
+			expectErr: true,
+		},
+		"instance prefix": {
+			cfg:       &AWSAuthConfig{Identities: identities("123456789012/i-*")},
+			expectErr: true,
+		},
+		"instance prefix without dash": {
+			cfg:       &AWSAuthConfig{Identities: identities("123456789012/i*")},
+			expectErr: true,
+		},
+		"wildcard across accounts": {
+			cfg:       &AWSAuthConfig{Identities: identities("1234*"), AllowAccountWildcards: true},
+			expectErr: true,
+		},
+		"wildcard": {
+			cfg:       &AWSAuthConfig{Identities: identities("*"), AllowAccountWildcards: true},
+			expectErr: true,
+		},
+		"wildcard account": {
+			cfg:       &AWSAuthConfig{Identities: identities("*/i-1234567890abcdef0"), AllowAccountWildcards: true},
+			expectErr: true,
+		},
+		"invalid maximum document age": {
+			cfg:       &AWSAuthConfig{Identities: identities("123456789012/i-1234567890abcdef0"), MaxDocumentAge: "1 hour"},
+			expectErr: true,
+		},
+		"no kubernetes client": {
+			cfg:       &AWSAuthConfig{Identities: identities("123456789012/i-1234567890abcdef0")},
+			noClient:  true,
+			expectErr: true,
+		},
+		"no certificates": {
+			certificatesPEM: []byte("not a certificate"),
+			cfg:             &AWSAuthConfig{Identities: identities("123456789012/i-1234567890abcdef0")},
+			expectErr:       true,
+		},
+		"no identities": {
+			cfg:       &AWSAuthConfig{},
+			expectErr: true,
+		},
+		"identity without service account": {
+			cfg:       &AWSAuthConfig{Identities: []PlatformIdentity{{Principal: "123456789012/i-1234567890abcdef0", Namespace: "vm"}}},
+			expectErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			certificatesPEM := tc.certificatesPEM
+			if certificatesPEM == nil {
+				certificatesPEM = signer.certificatePEM
+			}
+			var client kubernetes.Interface = fake.NewSimpleClientset()
+			if tc.noClient {
+				client = nil
+			}
+			_, err := NewAWSAuthenticator(certificatesPEM, tc.cfg, "cluster.local", client, "istio-system")
+			if gotErr := err != nil; gotErr != tc.expectErr {
+				t.Errorf("gotErr (%v) whereas expectErr (%v): %v", gotErr, tc.expectErr, err)
+			}
+		})
+	}
+}
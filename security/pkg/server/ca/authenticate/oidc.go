@@ -33,6 +33,9 @@ type JwtAuthenticator struct {
 	trustDomain string
 	audiences   []string
 	verifier    *oidc.IDTokenVerifier
+	// identities map the subjects of tokens that are not issued to Kubernetes service accounts,
+	// e.g. the tokens of cloud platform identities, to workload identities.
+	identities platformIdentities
 }
 
 var _ security.Authenticator = &JwtAuthenticator{}
@@ -67,6 +70,22 @@ func NewJwtAuthenticator(jwtRule *v1beta1.JWTRule, trustDomain string) (*JwtAuth
 	}, nil
 }
 
+// NewPlatformJwtAuthenticator creates a JWT authenticator for the tokens issued to platform identities,
+// whose subjects are mapped to workload identities.
+func NewPlatformJwtAuthenticator(jwtRule *v1beta1.JWTRule, identities []PlatformIdentity,
+	trustDomain string) (*JwtAuthenticator, error) {
+	ids, err := newPlatformIdentities(identities)
+	if err != nil {
+		return nil, err
+	}
+	j, err := NewJwtAuthenticator(jwtRule, trustDomain)
+	if err != nil {
+		return nil, err
+	}
+	j.identities = ids
+	return j, nil
+}
+
 // Authenticate - based on the old OIDC authenticator for mesh expansion.
 func (j *JwtAuthenticator) Authenticate(ctx context.Context) (*security.Caller, error) {
 	bearerToken, err := security.ExtractBearerToken(ctx)
@@ -78,6 +97,9 @@ func (j *JwtAuthenticator) Authenticate(ctx context.Context) (*security.Caller,
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify the JWT token (error %v)", err)
 	}
+	if j.identities != nil {
+		return j.authenticatePlatformIdentity(idToken)
+	}
 
 	sa := &JwtPayload{}
 	// "aud" for trust domain, "sub" has "system:serviceaccount:$namespace:$serviceaccount".
@@ -101,6 +123,22 @@ func (j *JwtAuthenticator) Authenticate(ctx context.Context) (*security.Caller,
 	}, nil
 }
 
+// authenticatePlatformIdentity maps the subject of a token issued to a platform identity to a workload identity.
+// The standard claims of the ID token are used since platforms may set "aud" to a single string.
+func (j *JwtAuthenticator) authenticatePlatformIdentity(idToken *oidc.IDToken) (*security.Caller, error) {
+	if !checkAudience(idToken.Audience, j.audiences) {
+		return nil, fmt.Errorf("invalid audiences %v", idToken.Audience)
+	}
+	identity, err := j.identities.identity(idToken.Subject, j.trustDomain)
+	if err != nil {
+		return nil, err
+	}
+	return &security.Caller{
+		AuthSource: security.AuthSourceIDToken,
+		Identities: []string{identity},
+	}, nil
+}
+
 // checkAudience() returns true if the audiences to check are in
 // the expected audiences. Otherwise, return false.
 func checkAudience(audToCheck []string, audExpected []string) bool {
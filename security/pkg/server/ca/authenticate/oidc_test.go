@@ -246,3 +246,85 @@ func generateJWT(key *jose.JSONWebKey, claims []byte) (string, error) {
 	}
 	return jwt, nil
 }
+
+func TestPlatformOIDCAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate a private key: %v", err)
+	}
+	key := jose.JSONWebKey{Algorithm: string(jose.RS256), Key: rsaKey}
+	keySet := jose.JSONWebKeySet{}
+	keySet.Keys = append(keySet.Keys, key.Public())
+	server := httptest.NewServer(&jwksServer{key: keySet})
+	defer server.Close()
+
+	jwtRule := &v1beta1.JWTRule{Issuer: server.URL, JwksUri: server.URL, Audiences: []string{"api://istio-ca"}}
+	identities := []PlatformIdentity{
+		{Principal: "managed-identity", Namespace: "vm", ServiceAccount: "legacy"},
+		{Principal: "spot-*", Namespace: "batch", ServiceAccount: "worker"},
+	}
+	authenticator, err := NewPlatformJwtAuthenticator(jwtRule, identities, "cluster.local")
+	if err != nil {
+		t.Fatalf("failed to create the JWT authenticator: %v", err)
+	}
+	if _, err := NewPlatformJwtAuthenticator(jwtRule, nil, "cluster.local"); err == nil {
+		t.Errorf("expected an error when no identities are configured")
+	}
+
+	expStr := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	tokenFor := func(aud, sub string) string {
+		// Platforms such as Azure AD set "aud" to a single string.
+		claims := `{"iss": "` + server.URL + `", "aud": "` + aud + `", "sub": "` + sub + `", "exp": ` + expStr + `}`
+		token, err := generateJWT(&key, []byte(claims))
+		if err != nil {
+			t.Fatalf("failed to generate JWT: %v", err)
+		}
+		return token
+	}
+
+	tests := map[string]struct {
+		token      string
+		expectErr  bool
+		expectedID string
+	}{
+		"Mapped subject": {
+			token:      tokenFor("api://istio-ca", "managed-identity"),
+			expectedID: fmt.Sprintf(IdentityTemplate, "cluster.local", "vm", "legacy"),
+		},
+		"Subject matching prefix": {
+			token:      tokenFor("api://istio-ca", "spot-1234"),
+			expectedID: fmt.Sprintf(IdentityTemplate, "cluster.local", "batch", "worker"),
+		},
+		"Unmapped subject": {
+			token:     tokenFor("api://istio-ca", "system:serviceaccount:bar:foo"),
+			expectErr: true,
+		},
+		"Wrong audience": {
+			token:     tokenFor("wrong-audience", "managed-identity"),
+			expectErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			md := metadata.MD{}
+			md.Append("authorization", bearerTokenPrefix+tc.token)
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			actualCaller, err := authenticator.Authenticate(ctx)
+			gotErr := err != nil
+			if gotErr != tc.expectErr {
+				t.Fatalf("gotErr (%v) whereas expectErr (%v): %v", gotErr, tc.expectErr, err)
+			}
+			if gotErr {
+				return
+			}
+			expectedCaller := &security.Caller{
+				AuthSource: security.AuthSourceIDToken,
+				Identities: []string{tc.expectedID},
+			}
+			if !reflect.DeepEqual(actualCaller, expectedCaller) {
+				t.Errorf("%v: unexpected caller (want %v but got %v)", name, expectedCaller, actualCaller)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/pkg/security"
+)
+
+// PlatformAuthConfig configures the authentication of workloads, typically VMs, with the credentials
+// issued by their platform rather than Kubernetes service account tokens. The identities map the
+// principals of the credentials to workload identities. An example of JSON configuration is:
+//
+//	{
+//	  "aws": {
+//	    "certificatesFile": "/etc/istio/platform-auth/aws-certificates.pem",
+//	    "maxDocumentAge": "30m",
+//	    "identities": [{"principal": "123456789012/i-1234567890abcdef0", "namespace": "vm", "serviceAccount": "db"}]
+//	  },
+//	  "jwt": [{
+//	    "issuer": "https://sts.windows.net/<tenant ID>/",
+//	    "audiences": ["api://istio-ca"],
+//	    "identities": [{"principal": "<managed identity object ID>", "namespace": "vm", "serviceAccount": "legacy"}]
+//	  }]
+//	}
+type PlatformAuthConfig struct {
+	AWS *AWSAuthConfig      `json:"aws,omitempty"`
+	JWT []PlatformJwtConfig `json:"jwt,omitempty"`
+}
+
+// AWSAuthConfig configures the authentication of AWS EC2 instances with their instance identity documents.
+type AWSAuthConfig struct {
+	// CertificatesFile is the PEM file of the AWS public certificates of the regions of the instances,
+	// used to verify the signatures of the instance identity documents.
+	CertificatesFile string `json:"certificatesFile"`
+	// Identities are matched against the principal "<account ID>/<instance ID>" of the instances.
+	Identities []PlatformIdentity `json:"identities"`
+	// MaxDocumentAge is the maximum time since the start of an instance for which its instance identity
+	// document is accepted, as a duration such as "30m". Defaults to 1h.
+	MaxDocumentAge string `json:"maxDocumentAge,omitempty"`
+	// AllowAccountWildcards allows principals with a wildcard in the instance ID, such as "123456789012/*",
+	// matching several instances of an account. Principals with a wildcard in the account ID are always rejected.
+	AllowAccountWildcards bool `json:"allowAccountWildcards,omitempty"`
+}
+
+// PlatformJwtConfig configures the authentication of workloads with the JWTs of an OIDC issuer,
+// such as the Azure AD tokens of managed identities.
+type PlatformJwtConfig struct {
+	Issuer    string   `json:"issuer"`
+	JwksURI   string   `json:"jwksUri,omitempty"`
+	Audiences []string `json:"audiences"`
+	// Identities are matched against the subject of the tokens.
+	Identities []PlatformIdentity `json:"identities"`
+}
+
+// PlatformIdentity maps a platform principal to a workload identity. A principal ending with "*"
+// matches any principal with the same prefix.
+type PlatformIdentity struct {
+	Principal      string `json:"principal"`
+	Namespace      string `json:"namespace"`
+	ServiceAccount string `json:"serviceAccount"`
+}
+
+type platformIdentities []PlatformIdentity
+
+func newPlatformIdentities(identities []PlatformIdentity) (platformIdentities, error) {
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no identities are configured")
+	}
+	for _, id := range identities {
+		if id.Principal == "" || id.Namespace == "" || id.ServiceAccount == "" {
+			return nil, fmt.Errorf("principal, namespace and service account must be set in identity %+v", id)
+		}
+	}
+	return identities, nil
+}
+
+// identity returns the workload identity of the first entry matching the principal.
+func (p platformIdentities) identity(principal, trustDomain string) (string, error) {
+	for _, id := range p {
+		if id.Principal == principal ||
+			(strings.HasSuffix(id.Principal, "*") && strings.HasPrefix(principal, strings.TrimSuffix(id.Principal, "*"))) {
+			return fmt.Sprintf(IdentityTemplate, trustDomain, id.Namespace, id.ServiceAccount), nil
+		}
+	}
+	return "", fmt.Errorf("no identity is configured for principal %v", principal)
+}
+
+// NewPlatformAuthenticators creates the authenticators of the platform credentials configured by the
+// JSON PlatformAuthConfig. The Kubernetes client and the namespace of Istiod are used to keep the state
+// shared by the Istiod replicas.
+func NewPlatformAuthenticators(config []byte, trustDomain string, client kubernetes.Interface,
+	namespace string) ([]security.Authenticator, error) {
+	cfg := PlatformAuthConfig{}
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal platform authentication config: %v", err)
+	}
+	var authenticators []security.Authenticator
+	if cfg.AWS != nil {
+		certificates, err := ioutil.ReadFile(cfg.AWS.CertificatesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AWS certificates: %v", err)
+		}
+		awsAuthn, err := NewAWSAuthenticator(certificates, cfg.AWS, trustDomain, client, namespace)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, awsAuthn)
+	}
+	for _, jwtCfg := range cfg.JWT {
+		jwtRule := &v1beta1.JWTRule{Issuer: jwtCfg.Issuer, JwksUri: jwtCfg.JwksURI, Audiences: jwtCfg.Audiences}
+		jwtAuthn, err := NewPlatformJwtAuthenticator(jwtRule, jwtCfg.Identities, trustDomain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the JWT authenticator of %v: %v", jwtCfg.Issuer, err)
+		}
+		authenticators = append(authenticators, jwtAuthn)
+	}
+	return authenticators, nil
+}
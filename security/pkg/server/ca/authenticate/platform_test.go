@@ -0,0 +1,112 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewPlatformAuthenticators(t *testing.T) {
+	certificatesFile := filepath.Join(t.TempDir(), "aws-certificates.pem")
+	if err := ioutil.WriteFile(certificatesFile, newAWSSigner(t).certificatePEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		config        string
+		expectErr     bool
+		expectedTypes []string
+	}{
+		"aws and jwt": {
+			config: `{
+				"aws": {"certificatesFile": "` + certificatesFile + `", "maxDocumentAge": "30m",
+					"identities": [{"principal": "123456789012/i-1234567890abcdef0", "namespace": "vm", "serviceAccount": "db"}]},
+				"jwt": [{"issuer": "https://sts.windows.net/tenant/", "jwksUri": "https://login.microsoftonline.com/keys",
+					"audiences": ["api://istio-ca"],
+					"identities": [{"principal": "managed-identity", "namespace": "vm", "serviceAccount": "legacy"}]}]
+			}`,
+			expectedTypes: []string{AWSAuthenticatorType, IDTokenAuthenticatorType},
+		},
+		"aws account wildcard": {
+			config: `{"aws": {"certificatesFile": "` + certificatesFile + `", "allowAccountWildcards": true,
+				"identities": [{"principal": "123456789012/*", "namespace": "vm", "serviceAccount": "legacy"}]}}`,
+			expectedTypes: []string{AWSAuthenticatorType},
+		},
+		"aws account wildcard without opt-in": {
+			config: `{"aws": {"certificatesFile": "` + certificatesFile + `",
+				"identities": [{"principal": "123456789012/*", "namespace": "vm", "serviceAccount": "legacy"}]}}`,
+			expectErr: true,
+		},
+		"empty": {
+			config: `{}`,
+		},
+		"invalid json": {
+			config:    `{"aws": [}`,
+			expectErr: true,
+		},
+		"missing aws certificates": {
+			config: `{"aws": {"certificatesFile": "/nonexistent",
+				"identities": [{"principal": "123456789012/*", "namespace": "vm", "serviceAccount": "legacy"}]}}`,
+			expectErr: true,
+		},
+		"jwt without identities": {
+			config:    `{"jwt": [{"issuer": "https://sts.windows.net/tenant/", "jwksUri": "https://login.microsoftonline.com/keys"}]}`,
+			expectErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			authenticators, err := NewPlatformAuthenticators([]byte(tc.config), "cluster.local", fake.NewSimpleClientset(), "istio-system")
+			gotErr := err != nil
+			if gotErr != tc.expectErr {
+				t.Fatalf("gotErr (%v) whereas expectErr (%v): %v", gotErr, tc.expectErr, err)
+			}
+			var types []string
+			for _, a := range authenticators {
+				types = append(types, a.AuthenticatorType())
+			}
+			if strings.Join(types, ",") != strings.Join(tc.expectedTypes, ",") {
+				t.Errorf("got authenticators %v, expected %v", types, tc.expectedTypes)
+			}
+		})
+	}
+}
+
+func TestPlatformIdentities(t *testing.T) {
+	identities := platformIdentities{
+		{Principal: "exact", Namespace: "ns1", ServiceAccount: "sa1"},
+		{Principal: "prefix-*", Namespace: "ns2", ServiceAccount: "sa2"},
+		{Principal: "*", Namespace: "ns3", ServiceAccount: "sa3"},
+	}
+	tests := map[string]string{
+		"exact":     "spiffe://td/ns/ns1/sa/sa1",
+		"prefix-":   "spiffe://td/ns/ns2/sa/sa2",
+		"prefix-42": "spiffe://td/ns/ns2/sa/sa2",
+		"exactly":   "spiffe://td/ns/ns3/sa/sa3",
+	}
+	for principal, expected := range tests {
+		got, err := identities.identity(principal, "td")
+		if err != nil || got != expected {
+			t.Errorf("%s: got identity %s and error %v, expected %s", principal, got, err, expected)
+		}
+	}
+	if _, err := identities[:2].identity("other", "td"); err == nil {
+		t.Errorf("expected an error for an unmapped principal")
+	}
+}
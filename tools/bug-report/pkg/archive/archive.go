@@ -17,6 +17,7 @@ package archive
 import (
 	"archive/tar"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -31,6 +32,11 @@ const (
 	clusterInfoSubdir      = "cluster"
 	analyzeSubdir          = "analyze"
 	operatorLogsPathSubdir = "operator"
+
+	// maxExtractedFileSize and maxExtractedSize bound the size of a file extracted by Extract, and of all of them,
+	// so that a corrupted or malicious archive cannot fill the disk.
+	maxExtractedFileSize = 1 << 30
+	maxExtractedSize     = 4 << 30
 )
 
 var (
@@ -114,6 +120,79 @@ func Create(srcDir, outPath string) error {
 	})
 }
 
+// Extract extracts the gzipped tar file at archivePath, as created by Create, into dstDir. It returns the output
+// root dir of the archive, which holds the cluster, proxy and istiod subdirs.
+func Extract(archivePath, dstDir string) (string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a gzipped archive: %v", archivePath, err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	topDirs := map[string]struct{}{}
+	var total int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("archive entry %s is outside of the archive root", header.Name)
+		}
+		topDirs[strings.SplitN(name, string(filepath.Separator), 2)[0]] = struct{}{}
+		n, err := extractFile(tr, filepath.Join(dstDir, name), os.FileMode(header.Mode))
+		if err != nil {
+			return "", fmt.Errorf("failed to extract %s: %v", header.Name, err)
+		}
+		if total += n; total > maxExtractedSize {
+			return "", fmt.Errorf("the archive exceeds the maximum extracted size of %d bytes", int64(maxExtractedSize))
+		}
+	}
+	// Create places all files under a single bug-report subdir.
+	if len(topDirs) == 1 {
+		for d := range topDirs {
+			if fi, err := os.Stat(filepath.Join(dstDir, d)); err == nil && fi.IsDir() {
+				return filepath.Join(dstDir, d), nil
+			}
+		}
+	}
+	return dstDir, nil
+}
+
+// extractFile writes the contents of r to path, up to maxExtractedFileSize bytes, and returns the number of bytes
+// written.
+func extractFile(r io.Reader, path string, mode os.FileMode) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(r, maxExtractedFileSize+1))
+	if err != nil {
+		return n, err
+	}
+	if n > maxExtractedFileSize {
+		return n, fmt.Errorf("the file exceeds the maximum extracted size of %d bytes", int64(maxExtractedFileSize))
+	}
+	return n, nil
+}
+
 func getRootDir(rootDir string) string {
 	if rootDir != "" {
 		return rootDir
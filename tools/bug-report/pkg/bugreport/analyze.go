@@ -0,0 +1,400 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bugreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	kubeyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"istio.io/istio/galley/pkg/config/analysis/analyzers"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/analysis/local"
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/istioctl/pkg/util/formatting"
+	"istio.io/istio/istioctl/pkg/writer/pilot"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/tools/bug-report/pkg/archive"
+	"istio.io/istio/tools/bug-report/pkg/common"
+	"istio.io/istio/tools/bug-report/pkg/config"
+	"istio.io/istio/tools/bug-report/pkg/processlog"
+	"istio.io/pkg/log"
+)
+
+var analyzeTopErrors int
+
+func analyzeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "analyze <archive>",
+		Short: "Analyze a bug-report archive offline.",
+		Long: `analyze inspects an archive created by bug-report without access to the cluster it was collected from.
+It runs the Istio configuration analyzers against the collected resources, reports the xDS sync status of each proxy
+from the istiod debug output, summarizes the proxy config dumps including any rejected configuration, and
+summarizes the errors found in the collected logs.`,
+		Example: `  istioctl bug-report analyze bug-report.tar.gz`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return analyzeArchive(cmd.OutOrStdout(), args[0], gConfig, analyzeTopErrors)
+		},
+	}
+	cmd.Flags().IntVar(&analyzeTopErrors, "top-errors", 5,
+		"Maximum number of distinct error messages to show for each log.")
+	return cmd
+}
+
+// analyzeArchive extracts the bug-report archive at archivePath and writes the offline analysis of its contents to w.
+func analyzeArchive(w io.Writer, archivePath string, cfg *config.BugReportConfig, topErrors int) error {
+	dir, err := ioutil.TempDir("", "bug-report-analyze")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// The archive is extracted in a subdir, so that the files written for the analysis do not clash with its contents.
+	root, err := archive.Extract(archivePath, filepath.Join(dir, "archive"))
+	if err != nil {
+		return err
+	}
+
+	if b, err := ioutil.ReadFile(filepath.Join(archive.OutputRootDir(root), "versions")); err == nil {
+		_, _ = fmt.Fprintf(w, "%s\n", strings.TrimSpace(string(b)))
+	}
+	sections := []struct {
+		title string
+		fn    func() error
+	}{
+		{"Analysis Report", func() error { return analyzeResources(w, root, dir, cfg.IstioNamespace) }},
+		{"Proxy Sync Status", func() error { return analyzeSyncStatus(w, root) }},
+		{"Proxy Config Summary", func() error { return analyzeConfigDumps(w, root) }},
+		{"Log Summary", func() error { return analyzeLogs(w, root, cfg, topErrors) }},
+	}
+	// A failure in one section should not prevent the others from being reported.
+	for _, s := range sections {
+		_, _ = fmt.Fprintf(w, "\n%s:\n\n", s.title)
+		if err := s.fn(); err != nil {
+			_, _ = fmt.Fprintf(w, "Error: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// analyzeResources runs the configuration analyzers against the resources collected in the archive. Intermediate
+// files are written to workDir.
+func analyzeResources(w io.Writer, root, workDir, istioNamespace string) error {
+	var docs []string
+	meshConfig := ""
+	for _, f := range []string{"k8s-resources", "crs"} {
+		b, err := ioutil.ReadFile(filepath.Join(archive.ClusterInfoPath(root), f))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		fileDocs, err := splitResources(string(b))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", f, err)
+		}
+		for _, d := range fileDocs {
+			if mc := meshConfigFromConfigMap(d, istioNamespace); mc != "" {
+				meshConfig = mc
+			}
+		}
+		docs = append(docs, fileDocs...)
+	}
+	if len(docs) == 0 {
+		_, _ = fmt.Fprintln(w, "No resources found in the archive.")
+		return nil
+	}
+
+	sa := local.NewSourceAnalyzer(schema.MustGet(), analyzers.AllCombined(),
+		resource.Namespace(common.NamespaceAll), resource.Namespace(istioNamespace), nil, true, 5*time.Minute)
+	if meshConfig != "" {
+		meshFile := filepath.Join(workDir, "mesh-config.yaml")
+		if err := ioutil.WriteFile(meshFile, []byte(meshConfig), 0o644); err != nil {
+			return err
+		}
+		if err := sa.AddFileKubeMeshConfig(meshFile); err != nil {
+			log.Warnf("failed to read mesh config from the archive, using defaults: %v", err)
+		}
+	}
+	if err := sa.AddReaderKubeSource([]local.ReaderSource{{
+		Name:   "bug-report",
+		Reader: strings.NewReader(strings.Join(docs, "\n---\n")),
+	}}); err != nil {
+		// Resources which cannot be parsed are skipped, and the rest are still analyzed.
+		log.Warnf("some resources in the archive could not be read: %v", err)
+	}
+	result, err := sa.Analyze(make(chan struct{}))
+	if err != nil {
+		return err
+	}
+	messages := result.Messages.SetDocRef("istioctl-analyze").FilterOutLowerThan(diag.Info)
+	if len(messages) == 0 {
+		_, _ = fmt.Fprintln(w, "No validation issues found.")
+		return nil
+	}
+	out, err := formatting.Print(messages, formatting.LogFormat, false)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(w, out)
+	return nil
+}
+
+// splitResources splits the YAML output of kubectl get, which may hold List kinds, into a document per resource.
+func splitResources(text string) ([]string, error) {
+	var out []string
+	reader := kubeyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(text)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		js, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return nil, err
+		}
+		list := struct {
+			Kind  string            `json:"kind"`
+			Items []json.RawMessage `json:"items"`
+		}{}
+		if err := json.Unmarshal(js, &list); err != nil || list.Kind != "List" {
+			out = append(out, string(doc))
+			continue
+		}
+		for _, item := range list.Items {
+			y, err := yaml.JSONToYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, string(y))
+		}
+	}
+	return out, nil
+}
+
+// meshConfigFromConfigMap returns the mesh config if doc is the istio ConfigMap in the given namespace.
+func meshConfigFromConfigMap(doc, istioNamespace string) string {
+	cm := struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Data map[string]string `json:"data"`
+	}{}
+	if err := yaml.Unmarshal([]byte(doc), &cm); err != nil {
+		return ""
+	}
+	if cm.Kind != "ConfigMap" || cm.Metadata.Name != "istio" || cm.Metadata.Namespace != istioNamespace {
+		return ""
+	}
+	return cm.Data["mesh"]
+}
+
+// analyzeSyncStatus reports the xDS sync status of all proxies, in the format of proxy-status, from the istiod
+// debug/syncz output in the archive.
+func analyzeSyncStatus(w io.Writer, root string) error {
+	files, err := filepath.Glob(filepath.Join(archive.IstiodPath(root, "*", "*"), "debug", "syncz"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		_, _ = fmt.Fprintln(w, "No istiod sync status found in the archive.")
+		return nil
+	}
+	statuses := map[string][]byte{}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		// The file is stored under istio/<namespace>/<pod>/debug/syncz.
+		pod := filepath.Base(filepath.Dir(filepath.Dir(f)))
+		statuses[pod] = b
+	}
+	sw := pilot.StatusWriter{Writer: w}
+	return sw.PrintAll(statuses)
+}
+
+// analyzeConfigDumps summarizes the config dump of each proxy in the archive, including any configuration that
+// the proxy rejected.
+func analyzeConfigDumps(w io.Writer, root string) error {
+	files, err := filepath.Glob(filepath.Join(archive.ProxyOutputPath(root, "*", "*"), "config_dump*"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		_, _ = fmt.Fprintln(w, "No proxy config dumps found in the archive.")
+		return nil
+	}
+	sort.Strings(files)
+	tw := new(tabwriter.Writer).Init(w, 0, 8, 5, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tLISTENERS\tCLUSTERS\tROUTES\tWARMING\tREJECTED")
+	var rejected []string
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		name := filepath.Base(filepath.Dir(dir)) + "/" + filepath.Base(dir)
+		s, err := summarizeConfigDump(f)
+		if err != nil {
+			_, _ = fmt.Fprintf(tw, "%s\terror: %v\n", name, err)
+			continue
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", name, s.listeners, s.clusters, s.routes, s.warming, len(s.rejected))
+		for _, r := range s.rejected {
+			rejected = append(rejected, fmt.Sprintf("%s: %s", name, r))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(rejected) > 0 {
+		_, _ = fmt.Fprintf(w, "\nRejected configuration:\n  %s\n", strings.Join(rejected, "\n  "))
+	}
+	return nil
+}
+
+type configDumpSummary struct {
+	listeners int
+	clusters  int
+	routes    int
+	warming   int
+	rejected  []string
+}
+
+func summarizeConfigDump(file string) (*configDumpSummary, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	cd := &configdump.Wrapper{}
+	if err := cd.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	out := &configDumpSummary{}
+	if listeners, err := cd.GetListenerConfigDump(); err == nil {
+		out.listeners = len(listeners.StaticListeners)
+		for _, l := range listeners.DynamicListeners {
+			if l.ActiveState != nil {
+				out.listeners++
+			}
+			if l.WarmingState != nil {
+				out.warming++
+			}
+			if l.ErrorState != nil {
+				out.rejected = append(out.rejected, fmt.Sprintf("listener %s: %s", l.Name, l.ErrorState.Details))
+			}
+		}
+	}
+	if clusters, err := cd.GetClusterConfigDump(); err == nil {
+		out.clusters = len(clusters.StaticClusters) + len(clusters.DynamicActiveClusters)
+		out.warming += len(clusters.DynamicWarmingClusters)
+		for _, c := range append(clusters.DynamicActiveClusters, clusters.DynamicWarmingClusters...) {
+			if c.ErrorState != nil {
+				out.rejected = append(out.rejected, fmt.Sprintf("cluster: %s", c.ErrorState.Details))
+			}
+		}
+	}
+	if routes, err := cd.GetRouteConfigDump(); err == nil {
+		out.routes = len(routes.StaticRouteConfigs) + len(routes.DynamicRouteConfigs)
+	}
+	return out, nil
+}
+
+// analyzeLogs summarizes the fatals, errors and warnings in each log in the archive, most important first.
+func analyzeLogs(w io.Writer, root string, cfg *config.BugReportConfig, topErrors int) error {
+	var files []string
+	for _, dir := range []string{
+		archive.ProxyOutputPath(root, "*", "*"),
+		archive.IstiodPath(root, "*", "*"),
+		archive.OperatorPath(root, "*", "*"),
+	} {
+		f, err := filepath.Glob(filepath.Join(dir, "*.log"))
+		if err != nil {
+			return err
+		}
+		files = append(files, f...)
+	}
+	if len(files) == 0 {
+		_, _ = fmt.Fprintln(w, "No logs found in the archive.")
+		return nil
+	}
+
+	type logSummary struct {
+		name   string
+		stats  *processlog.Stats
+		errors []processlog.ErrorCount
+	}
+	summaries := make([]logSummary, 0, len(files))
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(archive.OutputRootDir(root), f)
+		if err != nil {
+			name = f
+		}
+		summaries = append(summaries, logSummary{
+			name:   name,
+			stats:  processlog.GetStats(cfg, string(b)),
+			errors: processlog.TopErrors(cfg, string(b), topErrors),
+		})
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if summaries[i].stats.Importance() != summaries[j].stats.Importance() {
+			return summaries[i].stats.Importance() > summaries[j].stats.Importance()
+		}
+		return summaries[i].name < summaries[j].name
+	})
+
+	tw := new(tabwriter.Writer).Init(w, 0, 8, 5, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "LOG\tFATALS\tERRORS\tWARNINGS")
+	for _, s := range summaries {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", s.name, s.stats.Fatals(), s.stats.Errors(), s.stats.Warnings())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		if len(s.errors) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "\nMost frequent errors in %s:\n", s.name)
+		for _, e := range s.errors {
+			_, _ = fmt.Fprintf(w, "  %d\t%s\n", e.Count, e.Text)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bugreport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"istio.io/istio/tools/bug-report/pkg/archive"
+	"istio.io/istio/tools/bug-report/pkg/config"
+)
+
+const (
+	testResources = `apiVersion: v1
+kind: List
+items:
+- apiVersion: networking.istio.io/v1alpha3
+  kind: VirtualService
+  metadata:
+    name: reviews
+    namespace: default
+  spec:
+    hosts:
+    - reviews.example.com
+    gateways:
+    - missing-gateway
+    http:
+    - route:
+      - destination:
+          host: reviews
+`
+
+	testSyncz = `[{"proxy":"productpage-v1-abc.default","istio_version":"1.10.0",` +
+		`"cluster_sent":"1","cluster_acked":"1","listener_sent":"2","listener_acked":"1",` +
+		`"route_sent":"1","route_acked":"1","endpoint_sent":"1","endpoint_acked":"1"}]`
+
+	testConfigDump = `{"configs":[{"@type":"type.googleapis.com/envoy.admin.v3.ListenersConfigDump",` +
+		`"dynamic_listeners":[{"name":"0.0.0.0_8080","active_state":{"listener":` +
+		`{"@type":"type.googleapis.com/envoy.config.listener.v3.Listener","name":"0.0.0.0_8080"}}},` +
+		`{"name":"0.0.0.0_9080","error_state":{"details":"duplicate listener"}}]}]}`
+
+	testLog = "2021-01-01T00:00:00.000000Z\terror\tfailed to fetch secret\n" +
+		"2021-01-01T00:00:01.000000Z\terror\tfailed to fetch secret\n" +
+		"2021-01-01T00:00:02.000000Z\twarn\tslow response\n" +
+		"2021-01-01T00:00:03.000000Z\tinfo\tready\n"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnalyzeArchive(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "bug-report")
+	writeTestFile(t, filepath.Join(root, "versions"), "client version: 1.10.0")
+	writeTestFile(t, filepath.Join(archive.ClusterInfoPath(root), "crs"), testResources)
+	writeTestFile(t, filepath.Join(archive.IstiodPath(root, "istio-system", "istiod-abc"), "debug", "syncz"), testSyncz)
+	writeTestFile(t, filepath.Join(archive.ProxyOutputPath(root, "default", "productpage-v1-abc"), "config_dump"), testConfigDump)
+	writeTestFile(t, filepath.Join(archive.ProxyOutputPath(root, "default", "productpage-v1-abc"), "istio-proxy.log"), testLog)
+
+	archivePath := filepath.Join(t.TempDir(), "bug-report.tgz")
+	if err := archive.Create(dir, archivePath); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	cfg := &config.BugReportConfig{IstioNamespace: "istio-system"}
+	if err := analyzeArchive(out, archivePath, cfg, 5); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"client version: 1.10.0",
+		// Analysis Report
+		"IST0101",
+		"missing-gateway",
+		// Proxy Sync Status
+		"productpage-v1-abc.default",
+		"STALE",
+		"istiod-abc",
+		// Proxy Config Summary
+		"default/productpage-v1-abc",
+		"listener 0.0.0.0_9080: duplicate listener",
+		// Log Summary
+		"2\tfailed to fetch secret",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestAnalyzeArchiveNotFound(t *testing.T) {
+	err := analyzeArchive(&bytes.Buffer{}, filepath.Join(t.TempDir(), "missing.tgz"), &config.BugReportConfig{}, 5)
+	if err == nil {
+		t.Fatal("expected error for a missing archive")
+	}
+}
@@ -16,6 +16,7 @@ package bugreport
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -45,6 +46,7 @@ import (
 	"istio.io/istio/tools/bug-report/pkg/kubeclient"
 	"istio.io/istio/tools/bug-report/pkg/kubectlcmd"
 	"istio.io/istio/tools/bug-report/pkg/processlog"
+	"istio.io/istio/tools/bug-report/pkg/redact"
 	"istio.io/pkg/log"
 	"istio.io/pkg/version"
 )
@@ -52,6 +54,7 @@ import (
 const (
 	bugReportDefaultTimeout = 30 * time.Minute
 	istioRevisionLabel      = "istio.io/rev"
+	redactionManifestFile   = "redaction-manifest.json"
 )
 
 var (
@@ -84,6 +87,7 @@ e.g.
 		},
 	}
 	rootCmd.AddCommand(version.CobraCommand())
+	rootCmd.AddCommand(analyzeCmd())
 	addFlags(rootCmd, gConfig)
 
 	return rootCmd
@@ -98,6 +102,8 @@ var (
 	// Aggregated errors for all fetch operations.
 	gErrors util.Errors
 	lock    = sync.RWMutex{}
+	// redactor scrubs sensitive data from every file written to the archive.
+	redactor *redact.Redactor
 )
 
 func runBugReportCommand(_ *cobra.Command, logOpts *log.Options) error {
@@ -108,6 +114,10 @@ func runBugReportCommand(_ *cobra.Command, logOpts *log.Options) error {
 	if err != nil {
 		return err
 	}
+	redactor, err = redact.New(&config.Redaction)
+	if err != nil {
+		return err
+	}
 	clusterCtxStr := ""
 	if config.Context == "" {
 		var err error
@@ -159,6 +169,7 @@ func runBugReportCommand(_ *cobra.Command, logOpts *log.Options) error {
 		}
 		writeFile(filepath.Join(archive.ProxyOutputPath(tempDir, namespace, pod), common.ProxyContainerName+".log"), text)
 	}
+	writeRedactionManifest()
 
 	outDir, err := os.Getwd()
 	if err != nil {
@@ -420,6 +431,26 @@ func runAnalyze(config *config.BugReportConfig, params *content.Params) {
 	writeFiles(archive.AnalyzePath(tempDir, common.StrNamespaceAll), out)
 }
 
+// writeRedactionManifest writes the list of redactions made to the archive. The manifest is written directly, as
+// user supplied patterns listed in it could otherwise redact themselves.
+func writeRedactionManifest() {
+	m := redactor.Manifest()
+	if m == nil {
+		return
+	}
+	common.LogAndPrintf("\n%s\n", m)
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		log.Errorf(err.Error())
+		return
+	}
+	path := filepath.Join(archive.OutputRootDir(tempDir), redactionManifestFile)
+	mkdirOrExit(path)
+	if err := ioutil.WriteFile(path, b, 0o644); err != nil {
+		log.Errorf(err.Error())
+	}
+}
+
 func writeFiles(dir string, files map[string]string) {
 	for fname, text := range files {
 		writeFile(filepath.Join(dir, fname), text)
@@ -430,6 +461,11 @@ func writeFile(path, text string) {
 	if strings.TrimSpace(text) == "" {
 		return
 	}
+	name, err := filepath.Rel(archive.OutputRootDir(tempDir), path)
+	if err != nil {
+		name = path
+	}
+	text = redactor.Redact(name, text)
 	mkdirOrExit(path)
 	if err := ioutil.WriteFile(path, []byte(text), 0o644); err != nil {
 		log.Errorf(err.Error())
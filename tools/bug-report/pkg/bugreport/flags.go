@@ -88,6 +88,14 @@ func addFlags(cmd *cobra.Command, args *config2.BugReportConfig) {
 		"List of comma separated glob patterns to match against log error strings. "+
 			"Any error matching these patterns is ignored when calculating the log importance heuristic.")
 
+	// redaction
+	cmd.PersistentFlags().BoolVar(&args.Redaction.Disabled, "disable-redaction", false,
+		"If set, private keys, JWTs, Authorization headers and credentials are not redacted from the archive.")
+	cmd.PersistentFlags().BoolVar(&args.Redaction.IPAddresses, "redact-ips", false,
+		"If set, IP addresses are redacted from the archive.")
+	cmd.PersistentFlags().StringSliceVar(&args.Redaction.Patterns, "redact-patterns", nil,
+		"List of comma separated regular expressions. All matches are redacted from the archive.")
+
 	// output/working dir
 	cmd.PersistentFlags().StringVar(&tempDir, "dir", "",
 		"Set a specific directory for temporary artifact storage.")
@@ -156,6 +156,24 @@ type BugReportConfig struct {
 	// IgnoredErrors are glob error patterns which are ignored when
 	// calculating the error heuristic for a log.
 	IgnoredErrors []string `json:"ignoredErrors,omitempty"`
+
+	// Redaction controls the scrubbing of sensitive data from every file
+	// written to the archive.
+	Redaction RedactionConfig `json:"redaction,omitempty"`
+}
+
+// RedactionConfig controls which sensitive data is removed from the archive.
+// Private keys, JWTs, Authorization headers and credential values are
+// always redacted unless redaction is disabled.
+type RedactionConfig struct {
+	// Disabled turns off all redaction.
+	Disabled bool `json:"disabled,omitempty"`
+	// IPAddresses controls whether IPv4 and IPv6 addresses are redacted.
+	// These are often needed for debugging, so are kept by default.
+	IPAddresses bool `json:"ipAddresses,omitempty"`
+	// Patterns is a list of additional regular expressions. All matches
+	// are redacted.
+	Patterns []string `json:"patterns,omitempty"`
 }
 
 func (b *BugReportConfig) String() string {
@@ -178,6 +196,7 @@ func (b *BugReportConfig) String() string {
 	if b.Since != 0 {
 		out += fmt.Sprintf("since: %v\n", b.Since)
 	}
+	out += fmt.Sprintf("redaction: %v\n", !b.Redaction.Disabled)
 	return out
 }
 
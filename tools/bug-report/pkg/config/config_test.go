@@ -46,6 +46,10 @@ criticalErrors:
 ignoredErrors:
   - e3
   - e4
+redaction:
+  ipAddresses: true
+  patterns:
+    - customer-[0-9]+
 `
 	wantTime, err := time.Parse(time.RFC3339, "2002-10-02T10:00:00-05:00")
 	if err != nil {
@@ -109,6 +113,10 @@ ignoredErrors:
 		Since:          Duration(time.Minute),
 		CriticalErrors: []string{"e1", "e2"},
 		IgnoredErrors:  []string{"e3", "e4"},
+		Redaction: RedactionConfig{
+			IPAddresses: true,
+			Patterns:    []string{"customer-[0-9]+"},
+		},
 	}
 
 	got := &BugReportConfig{}
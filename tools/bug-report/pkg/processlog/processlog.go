@@ -15,6 +15,7 @@
 package processlog
 
 import (
+	"sort"
 	"strings"
 	"time"
 
@@ -47,10 +48,70 @@ func (s *Stats) Importance() int {
 	return 1000*s.numFatals + 100*s.numErrors + 10*s.numWarnings
 }
 
+// Fatals returns the number of fatal entries in the log.
+func (s *Stats) Fatals() int {
+	if s == nil {
+		return 0
+	}
+	return s.numFatals
+}
+
+// Errors returns the number of error entries in the log.
+func (s *Stats) Errors() int {
+	if s == nil {
+		return 0
+	}
+	return s.numErrors
+}
+
+// Warnings returns the number of warning entries in the log.
+func (s *Stats) Warnings() int {
+	if s == nil {
+		return 0
+	}
+	return s.numWarnings
+}
+
+// ErrorCount is the number of times an error message occurs in a log.
+type ErrorCount struct {
+	Text  string
+	Count int
+}
+
+// TopErrors returns up to max of the most frequent fatal and error messages in logStr, most frequent first.
+// Errors matching config.IgnoredErrors are skipped.
+func TopErrors(config *config.BugReportConfig, logStr string, max int) []ErrorCount {
+	counts := make(map[string]int)
+	for _, l := range strings.Split(logStr, "\n") {
+		_, level, text, valid := processLogLine(l)
+		if !valid || (level != levelFatal && level != levelError) {
+			continue
+		}
+		if isIgnored(config, text) {
+			continue
+		}
+		counts[text]++
+	}
+	out := make([]ErrorCount, 0, len(counts))
+	for text, count := range counts {
+		out = append(out, ErrorCount{Text: text, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Text < out[j].Text
+	})
+	if len(out) > max {
+		out = out[:max]
+	}
+	return out
+}
+
 // Process processes logStr based on the supplied config and returns the processed log along with statistics on it.
 func Process(config *config.BugReportConfig, logStr string) (string, *Stats) {
 	out := getTimeRange(logStr, config.StartTime, config.EndTime)
-	return out, getStats(config, out)
+	return out, GetStats(config, out)
 }
 
 // getTimeRange returns the log lines that fall inside the start to end time range, inclusive.
@@ -74,8 +135,8 @@ func getTimeRange(logStr string, start, end time.Time) string {
 	return sb.String()
 }
 
-// getStats returns statistics for the given log string.
-func getStats(config *config.BugReportConfig, logStr string) *Stats {
+// GetStats returns statistics for the given log string.
+func GetStats(config *config.BugReportConfig, logStr string) *Stats {
 	out := &Stats{}
 	for _, l := range strings.Split(logStr, "\n") {
 		_, level, text, valid := processLogLine(l)
@@ -84,7 +145,7 @@ func getStats(config *config.BugReportConfig, logStr string) *Stats {
 		}
 		switch level {
 		case levelFatal, levelError, levelWarn:
-			if match.MatchesGlobs(text, config.IgnoredErrors) {
+			if isIgnored(config, text) {
 				continue
 			}
 			switch level {
@@ -101,6 +162,12 @@ func getStats(config *config.BugReportConfig, logStr string) *Stats {
 	return out
 }
 
+// isIgnored reports whether the error text matches any of the configured ignored errors. Note that
+// match.MatchesGlobs matches everything for an empty pattern list, which must not ignore all errors.
+func isIgnored(config *config.BugReportConfig, text string) bool {
+	return len(config.IgnoredErrors) > 0 && match.MatchesGlobs(text, config.IgnoredErrors)
+}
+
 func processLogLine(line string) (timeStamp *time.Time, level string, text string, valid bool) {
 	lv := strings.Split(line, "\t")
 	if len(lv) < 3 {
@@ -16,11 +16,13 @@ package processlog
 
 import (
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	"istio.io/istio/pilot/test/util"
 	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/tools/bug-report/pkg/config"
 )
 
 func TestTimeRangeFilter(t *testing.T) {
@@ -83,3 +85,30 @@ func TestTimeRangeFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestTopErrors(t *testing.T) {
+	logStr := `2020-06-29T23:37:27.285053Z	error	xds	push failed
+2020-06-29T23:37:27.285054Z	info	ads	connected
+2020-06-29T23:37:27.285055Z	error	xds	push failed
+2020-06-29T23:37:27.285056Z	fatal	cache	out of memory
+2020-06-29T23:37:27.285057Z	warn	ads	slow push
+2020-06-29T23:37:27.285058Z	error	ads	ignored error
+2020-06-29T23:37:27.285059Z	error	xds	push failed`
+	cfg := &config.BugReportConfig{IgnoredErrors: []string{"*ignored*"}}
+
+	want := []ErrorCount{
+		{Text: "xds\tpush failed", Count: 3},
+		{Text: "cache\tout of memory", Count: 1},
+	}
+	if got := TopErrors(cfg, logStr, 5); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := TopErrors(cfg, logStr, 1); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("got %v, want %v", got, want[:1])
+	}
+
+	_, stats := Process(&config.BugReportConfig{EndTime: time.Now()}, logStr)
+	if stats.Fatals() != 1 || stats.Errors() != 4 || stats.Warnings() != 1 {
+		t.Errorf("got stats %+v", stats)
+	}
+}
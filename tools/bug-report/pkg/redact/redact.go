@@ -0,0 +1,329 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+
+	"istio.io/istio/tools/bug-report/pkg/config"
+)
+
+const (
+	secretDataRule    = "secret-data"
+	privateKeyRule    = "private-key"
+	jwtRule           = "jwt"
+	authorizationRule = "authorization"
+	credentialRule    = "credential"
+	ipAddressRule     = "ip-address"
+)
+
+// Rule is a single redaction rule.
+type Rule struct {
+	// Name identifies the rule in the manifest and in the replacement text.
+	Name string
+	// Pattern matches the text to redact.
+	Pattern *regexp.Regexp
+	// Replacement is substituted for each match, with $1 style references expanded as in regexp.Expand.
+	Replacement string
+	// valid, if set, further filters matches of Pattern given the full text and the match bounds.
+	valid func(text string, start, end int) bool
+}
+
+// builtinRules are always applied, unless redaction is disabled.
+var builtinRules = []*Rule{
+	{
+		Name:        privateKeyRule,
+		Pattern:     regexp.MustCompile(`-----BEGIN[A-Z ]*PRIVATE KEY-----[\s\S]*?-----END[A-Z ]*PRIVATE KEY-----`),
+		Replacement: "<redacted:" + privateKeyRule + ">",
+	},
+	{
+		// Base64 encoded PEM private keys, as found in the inline_bytes of config dumps and in secrets.
+		Name: privateKeyRule,
+		Pattern: regexp.MustCompile(
+			`LS0tLS1CRUdJTiB(?:SU0EgUFJJVkFURSBLRVkt|QUklWQVRFIEtFWS0|FQyBQUklWQVRFIEtFWS0|FTkNSWVBURUQgUFJJVkFURSBLRVkt)[A-Za-z0-9+/=]*`),
+		Replacement: "<redacted:" + privateKeyRule + ">",
+	},
+	{
+		Name:        jwtRule,
+		Pattern:     regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		Replacement: "<redacted:" + jwtRule + ">",
+	},
+	{
+		// The authentication scheme, if any, is kept.
+		Name: authorizationRule,
+		Pattern: regexp.MustCompile(
+			`(?i)((?:proxy-)?authorization["']?[ \t]*[:=][ \t]*["']?(?:(?:bearer|basic|digest|negotiate)[ \t]+)?)[^\s"',;]+`),
+		Replacement: "${1}<redacted:" + authorizationRule + ">",
+	},
+	{
+		Name: credentialRule,
+		Pattern: regexp.MustCompile(
+			`(?i)(\b(?:password|passwd|client[_-]?secret|api[_-]?key|access[_-]?token|refresh[_-]?token)["']?[ \t]*[:=][ \t]*["']?)[^\s"',;&]+`),
+		Replacement: "${1}<redacted:" + credentialRule + ">",
+	},
+}
+
+// ipRules are applied when IP address redaction is enabled. Candidates are validated with net.ParseIP, so that
+// timestamps, versions and MAC addresses are left alone.
+var ipRules = []*Rule{
+	{
+		Name:        ipAddressRule,
+		Pattern:     regexp.MustCompile(`\d{1,3}(?:\.\d{1,3}){3}`),
+		Replacement: "<redacted:" + ipAddressRule + ">",
+		valid:       validIP,
+	},
+	{
+		Name:        ipAddressRule,
+		Pattern:     regexp.MustCompile(`(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}(?:\.\d{1,3}){0,3}`),
+		Replacement: "<redacted:" + ipAddressRule + ">",
+		valid:       validIP,
+	},
+}
+
+// Manifest records what was redacted from each file, without the redacted values themselves.
+type Manifest struct {
+	// Rules lists the names of all rules that were applied.
+	Rules []string `json:"rules"`
+	// Files maps each file to the number of redactions made by each rule.
+	Files map[string]map[string]int `json:"files,omitempty"`
+}
+
+// Redactor scrubs sensitive data from text and records what was removed. It is safe for concurrent use.
+// A nil Redactor leaves all text unchanged.
+type Redactor struct {
+	rules []*Rule
+
+	mu    sync.Mutex
+	files map[string]map[string]int
+}
+
+// New returns a Redactor for the given config, or nil if redaction is disabled.
+func New(cfg *config.RedactionConfig) (*Redactor, error) {
+	if cfg.Disabled {
+		return nil, nil
+	}
+	rules := append([]*Rule{}, builtinRules...)
+	if cfg.IPAddresses {
+		rules = append(rules, ipRules...)
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad redaction pattern %q: %v", p, err)
+		}
+		rules = append(rules, &Rule{
+			Name:        p,
+			Pattern:     re,
+			Replacement: "<redacted>",
+		})
+	}
+	return &Redactor{
+		rules: rules,
+		files: map[string]map[string]int{},
+	}, nil
+}
+
+// Redact returns text with all matches of the rules replaced, and records the redactions under the given file name.
+// The values of Kubernetes Secrets in the text are redacted first, whatever their content.
+func (r *Redactor) Redact(file, text string) string {
+	if r == nil {
+		return text
+	}
+	counts := map[string]int{}
+	var n int
+	if text, n = redactSecrets(text); n > 0 {
+		counts[secretDataRule] = n
+	}
+	for _, rule := range r.rules {
+		var n int
+		text, n = rule.apply(text)
+		if n > 0 {
+			counts[rule.Name] += n
+		}
+	}
+	if len(counts) > 0 {
+		r.mu.Lock()
+		r.files[file] = counts
+		r.mu.Unlock()
+	}
+	return text
+}
+
+// Manifest returns a manifest of all redactions made so far.
+func (r *Redactor) Manifest() *Manifest {
+	if r == nil {
+		return nil
+	}
+	out := &Manifest{Files: map[string]map[string]int{}, Rules: []string{secretDataRule}}
+	seen := map[string]bool{}
+	for _, rule := range r.rules {
+		if !seen[rule.Name] {
+			seen[rule.Name] = true
+			out.Rules = append(out.Rules, rule.Name)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for f, counts := range r.files {
+		c := map[string]int{}
+		for k, v := range counts {
+			c[k] = v
+		}
+		out.Files[f] = c
+	}
+	return out
+}
+
+// String returns a human readable summary of the manifest.
+func (m *Manifest) String() string {
+	if m == nil {
+		return "Redaction is disabled.\n"
+	}
+	files := make([]string, 0, len(m.Files))
+	for f := range m.Files {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Redacted %d files using rules: %s\n", len(files), strings.Join(m.Rules, ", ")))
+	for _, f := range files {
+		rules := make([]string, 0, len(m.Files[f]))
+		for rule, n := range m.Files[f] {
+			rules = append(rules, fmt.Sprintf("%s=%d", rule, n))
+		}
+		sort.Strings(rules)
+		sb.WriteString(fmt.Sprintf("  %s: %s\n", f, strings.Join(rules, ", ")))
+	}
+	return sb.String()
+}
+
+// apply replaces all valid matches of the rule in text, returning the new text and number of replacements.
+func (r *Rule) apply(text string) (string, int) {
+	var sb strings.Builder
+	n, last := 0, 0
+	for _, m := range r.Pattern.FindAllStringSubmatchIndex(text, -1) {
+		if r.valid != nil && !r.valid(text, m[0], m[1]) {
+			continue
+		}
+		sb.WriteString(text[last:m[0]])
+		sb.Write(r.Pattern.ExpandString(nil, r.Replacement, text, m))
+		last = m[1]
+		n++
+	}
+	if n == 0 {
+		return text, 0
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), n
+}
+
+// lastAppliedAnnotation holds a copy of the whole object, including the Secret values, when applied with kubectl.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// redactSecrets replaces the data and stringData values of the Kubernetes Secrets of a YAML or JSON document, which
+// may be a single object or a List, keeping their keys and lengths. Values do not necessarily match any rule, for
+// instance when they are base64 encoded, so they are redacted structurally. Text that is not a document holding
+// Secrets is returned unchanged.
+func redactSecrets(text string) (string, int) {
+	if !strings.Contains(text, "Secret") {
+		return text, 0
+	}
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(text), &obj); err != nil {
+		return text, 0
+	}
+	n := redactSecretObject(obj)
+	if n == 0 {
+		return text, 0
+	}
+	out, err := yaml.Marshal(obj)
+	if err != nil {
+		return text, 0
+	}
+	if strings.HasPrefix(strings.TrimSpace(text), "{") {
+		if out, err = yaml.YAMLToJSON(out); err != nil {
+			return text, 0
+		}
+	}
+	return string(out), n
+}
+
+func redactSecretObject(obj map[string]interface{}) int {
+	n := 0
+	if items, ok := obj["items"].([]interface{}); ok {
+		for _, item := range items {
+			if o, ok := item.(map[string]interface{}); ok {
+				n += redactSecretObject(o)
+			}
+		}
+	}
+	if obj["kind"] != "Secret" {
+		return n
+	}
+	for _, field := range []string{"data", "stringData"} {
+		data, ok := obj[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range data {
+			data[k] = fmt.Sprintf("<redacted:%s len=%d>", secretDataRule, len(fmt.Sprint(v)))
+			n++
+		}
+	}
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := meta["annotations"].(map[string]interface{}); ok {
+			if _, f := annotations[lastAppliedAnnotation]; f {
+				annotations[lastAppliedAnnotation] = "<redacted:" + secretDataRule + ">"
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// validIP reports whether text[start:end] is a standalone IP address, rather than part of a longer token such as
+// a version number or identifier.
+func validIP(text string, start, end int) bool {
+	// A trailing colon may introduce a port, but a leading one means this is part of an IPv6 address
+	if start > 0 && (isAddressChar(text[start-1]) || text[start-1] == ':') {
+		return false
+	}
+	if end < len(text) && isAddressChar(text[end]) {
+		return false
+	}
+	// Allow a trailing full stop, but not a dot that continues a dotted sequence such as 1.2.3.4.5
+	if start > 1 && text[start-1] == '.' && isDigit(text[start-2]) {
+		return false
+	}
+	if end < len(text)-1 && text[end] == '.' && isDigit(text[end+1]) {
+		return false
+	}
+	return net.ParseIP(text[start:end]) != nil
+}
+
+func isAddressChar(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
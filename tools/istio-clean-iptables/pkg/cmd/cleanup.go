@@ -54,6 +54,16 @@ func removeOldChains(cfg *config.Config, ext dep.Dependencies, cmd string) {
 	flushAndDeleteChains(ext, cmd, constants.NAT, chains)
 }
 
+// removeNftTables deletes the tables holding the rules applied with the nftables backend. All the rules
+// live in these tables, so no other cleanup is needed.
+func removeNftTables(ext dep.Dependencies) {
+	for _, family := range []string{constants.NftablesFamilyV4, constants.NftablesFamilyV6} {
+		for _, table := range []string{constants.NAT, constants.MANGLE} {
+			ext.RunQuietlyAndIgnore(constants.NFT, "delete", "table", family, constants.NftablesTablePrefix+table)
+		}
+	}
+}
+
 func cleanup(cfg *config.Config) {
 	var ext dep.Dependencies
 	if cfg.DryRun {
@@ -62,6 +72,15 @@ func cleanup(cfg *config.Config) {
 		ext = &dep.RealDependencies{}
 	}
 
+	if cfg.Backend == constants.NftablesBackend {
+		defer func() {
+			// nft list is best efforts
+			_ = ext.Run(constants.NFT, "list", "ruleset")
+		}()
+		removeNftTables(ext)
+		return
+	}
+
 	defer func() {
 		for _, cmd := range []string{constants.IPTABLESSAVE, constants.IP6TABLESSAVE} {
 			// iptables-save is best efforts
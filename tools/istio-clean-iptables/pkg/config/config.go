@@ -31,6 +31,7 @@ type Config struct {
 	DNSServersV4  []string `json:"DNS_SERVERS_V4"`
 	DNSServersV6  []string `json:"DNS_SERVERS_V6"`
 	CaptureAllDNS bool     `json:"CAPTURE_ALL_DNS"`
+	Backend       string   `json:"BACKEND"`
 }
 
 func (c *Config) String() string {
@@ -49,5 +50,6 @@ func (c *Config) Print() {
 	fmt.Printf("DNS_CAPTURE=%t\n", c.RedirectDNS)
 	fmt.Printf("CAPTURE_ALL_DNS=%t\n", c.CaptureAllDNS)
 	fmt.Printf("DNS_SERVERS=%s,%s\n", c.DNSServersV4, c.DNSServersV6)
+	fmt.Printf("BACKEND=%s\n", c.Backend)
 	fmt.Println("")
 }
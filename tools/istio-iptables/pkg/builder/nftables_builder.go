@@ -0,0 +1,308 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// nftablesBaseChains defines the hook and priority of the nftables base chains equivalent to the
+// built-in iptables chains, for each table.
+var nftablesBaseChains = map[string]map[string]string{
+	constants.NAT: {
+		constants.PREROUTING:  "type nat hook prerouting priority -100; policy accept;",
+		constants.INPUT:       "type nat hook input priority 100; policy accept;",
+		constants.OUTPUT:      "type nat hook output priority -100; policy accept;",
+		constants.POSTROUTING: "type nat hook postrouting priority 100; policy accept;",
+	},
+	constants.MANGLE: {
+		constants.PREROUTING:  "type filter hook prerouting priority -150; policy accept;",
+		constants.INPUT:       "type filter hook input priority -150; policy accept;",
+		constants.FORWARD:     "type filter hook forward priority -150; policy accept;",
+		constants.OUTPUT:      "type route hook output priority -150; policy accept;",
+		constants.POSTROUTING: "type filter hook postrouting priority -150; policy accept;",
+	},
+	constants.FILTER: {
+		constants.INPUT:   "type filter hook input priority 0; policy accept;",
+		constants.FORWARD: "type filter hook forward priority 0; policy accept;",
+		constants.OUTPUT:  "type filter hook output priority 0; policy accept;",
+	},
+}
+
+// nftablesTableOrder is the order in which the tables are written.
+var nftablesTableOrder = []string{constants.NAT, constants.MANGLE, constants.FILTER}
+
+// BuildV4Nft returns an nftables script, to be applied with `nft -f`, equivalent to the IPv4 rules.
+func (rb *IptablesBuilderImpl) BuildV4Nft() (string, error) {
+	return buildNft(constants.NftablesFamilyV4, rb.rules.rulesv4)
+}
+
+// BuildV6Nft returns an nftables script, to be applied with `nft -f`, equivalent to the IPv6 rules.
+func (rb *IptablesBuilderImpl) BuildV6Nft() (string, error) {
+	return buildNft(constants.NftablesFamilyV6, rb.rules.rulesv6)
+}
+
+// nftChain is a chain of an nftables table, with its rules in their final order.
+type nftChain struct {
+	name  string
+	rules []string
+}
+
+// buildNft translates the rules to nftables. Each iptables table becomes an nftables table of the given
+// family, named with the NftablesTablePrefix, which is replaced as a whole so that the script can be
+// applied repeatedly and atomically.
+func buildNft(family string, rules []*Rule) (string, error) {
+	tables := map[string][]*nftChain{}
+	for _, r := range rules {
+		if _, f := nftablesBaseChains[r.table]; !f {
+			return "", fmt.Errorf("unsupported table %q", r.table)
+		}
+		var chain *nftChain
+		for _, c := range tables[r.table] {
+			if c.name == r.chain {
+				chain = c
+				break
+			}
+		}
+		if chain == nil {
+			chain = &nftChain{name: r.chain}
+			tables[r.table] = append(tables[r.table], chain)
+		}
+
+		// The params start with "-A <chain>" or "-I <chain> <position>".
+		if len(r.params) < 2 {
+			return "", fmt.Errorf("invalid rule %v", r.params)
+		}
+		position := -1
+		params := r.params[2:]
+		if r.params[0] == "-I" {
+			if len(r.params) < 3 {
+				return "", fmt.Errorf("invalid rule %v", r.params)
+			}
+			p, err := strconv.Atoi(r.params[2])
+			if err != nil || p < 1 {
+				return "", fmt.Errorf("invalid position in rule %v", r.params)
+			}
+			position = p - 1
+			params = r.params[3:]
+		}
+		rule, err := translateRule(family, params)
+		if err != nil {
+			return "", fmt.Errorf("failed to translate rule %q: %v", strings.Join(r.params, " "), err)
+		}
+		if position < 0 || position >= len(chain.rules) {
+			chain.rules = append(chain.rules, rule)
+		} else {
+			chain.rules = append(chain.rules[:position], append([]string{rule}, chain.rules[position:]...)...)
+		}
+	}
+
+	var b strings.Builder
+	for _, table := range nftablesTableOrder {
+		chains := tables[table]
+		if len(chains) == 0 {
+			continue
+		}
+		name := constants.NftablesTablePrefix + table
+		// Adding the table first makes the deletion succeed if it does not exist yet.
+		fmt.Fprintf(&b, "add table %s %s\n", family, name)
+		fmt.Fprintf(&b, "delete table %s %s\n", family, name)
+		fmt.Fprintf(&b, "add table %s %s\n", family, name)
+		// Declare all chains before the rules jumping to them.
+		for _, c := range chains {
+			if hook, f := nftablesBaseChains[table][c.name]; f {
+				fmt.Fprintf(&b, "add chain %s %s %s { %s }\n", family, name, c.name, hook)
+			} else {
+				fmt.Fprintf(&b, "add chain %s %s %s\n", family, name, c.name)
+			}
+		}
+		for _, c := range chains {
+			for _, r := range c.rules {
+				fmt.Fprintf(&b, "add rule %s %s %s %s\n", family, name, c.name, r)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// translateRule translates the matches and target of an iptables rule to an nftables rule. Only the
+// options used by istio-iptables are supported.
+func translateRule(family string, params []string) (string, error) {
+	var out []string
+	var proto, module string
+	portMatched := false
+	negate := false
+	i := 0
+	next := func() (string, error) {
+		if i+1 >= len(params) {
+			return "", fmt.Errorf("missing value for %s", params[i])
+		}
+		i++
+		return params[i], nil
+	}
+	op := func() string {
+		if negate {
+			return "!= "
+		}
+		return ""
+	}
+	for ; i < len(params); i++ {
+		p := params[i]
+		if p == "!" {
+			negate = true
+			continue
+		}
+		var v string
+		var err error
+		if p != "-j" {
+			if v, err = next(); err != nil {
+				return "", err
+			}
+		}
+		switch p {
+		case "-p":
+			if negate {
+				return "", fmt.Errorf("negated protocol is not supported")
+			}
+			proto = v
+		case "--dport":
+			if proto == "" {
+				return "", fmt.Errorf("--dport requires a protocol")
+			}
+			out = append(out, fmt.Sprintf("%s dport %s%s", proto, op(), v))
+			portMatched = true
+		case "-d":
+			out = append(out, fmt.Sprintf("%s daddr %s%s", family, op(), v))
+		case "-s":
+			out = append(out, fmt.Sprintf("%s saddr %s%s", family, op(), v))
+		case "-o":
+			out = append(out, fmt.Sprintf("oifname %s%q", op(), v))
+		case "-i":
+			out = append(out, fmt.Sprintf("iifname %s%q", op(), v))
+		case "-m":
+			module = v
+		case "--uid-owner":
+			out = append(out, fmt.Sprintf("meta skuid %s%s", op(), v))
+		case "--gid-owner":
+			out = append(out, fmt.Sprintf("meta skgid %s%s", op(), v))
+		case "--ctstate":
+			out = append(out, fmt.Sprintf("ct state %s%s", op(), strings.ToLower(v)))
+		case "--mark":
+			switch module {
+			case "mark":
+				out = append(out, fmt.Sprintf("meta mark %s%s", op(), v))
+			case "connmark":
+				out = append(out, fmt.Sprintf("ct mark %s%s", op(), v))
+			default:
+				return "", fmt.Errorf("--mark is not supported for module %q", module)
+			}
+		case "-j":
+			target, err := translateTarget(params[i+1:])
+			if err != nil {
+				return "", err
+			}
+			if proto != "" && !portMatched {
+				out = append([]string{"meta l4proto " + proto}, out...)
+			}
+			return strings.Join(append(out, target), " "), nil
+		default:
+			return "", fmt.Errorf("unsupported option %s", p)
+		}
+		negate = false
+	}
+	return "", fmt.Errorf("missing target")
+}
+
+// translateTarget translates an iptables target and its options, starting with the target name.
+func translateTarget(params []string) (string, error) {
+	if len(params) == 0 {
+		return "", fmt.Errorf("missing target")
+	}
+	target, opts := params[0], map[string]string{}
+	for i := 1; i < len(params); i++ {
+		if !strings.HasPrefix(params[i], "--") {
+			return "", fmt.Errorf("unexpected target option %s", params[i])
+		}
+		if i+1 < len(params) && !strings.HasPrefix(params[i+1], "--") {
+			opts[params[i]] = params[i+1]
+			i++
+		} else {
+			opts[params[i]] = ""
+		}
+	}
+	expectOpts := func(names ...string) error {
+		if len(opts) != len(names) {
+			return fmt.Errorf("unsupported options for target %s: %v", target, params[1:])
+		}
+		for _, n := range names {
+			if _, f := opts[n]; !f {
+				return fmt.Errorf("missing option %s for target %s", n, target)
+			}
+		}
+		return nil
+	}
+	switch target {
+	case constants.RETURN, constants.ACCEPT:
+		if err := expectOpts(); err != nil {
+			return "", err
+		}
+		return strings.ToLower(target), nil
+	case constants.REDIRECT:
+		port, f := opts["--to-ports"]
+		if !f {
+			port = opts["--to-port"]
+		}
+		if port == "" || len(opts) != 1 {
+			return "", fmt.Errorf("unsupported options for target %s: %v", target, params[1:])
+		}
+		return "redirect to :" + port, nil
+	case constants.MARK:
+		if err := expectOpts("--set-mark"); err != nil {
+			return "", err
+		}
+		return "meta mark set " + opts["--set-mark"], nil
+	case constants.TPROXY:
+		if err := expectOpts("--tproxy-mark", "--on-port"); err != nil {
+			return "", err
+		}
+		mark := opts["--tproxy-mark"]
+		if m := strings.SplitN(mark, "/", 2); len(m) == 2 {
+			if m[1] != "0xffffffff" {
+				return "", fmt.Errorf("unsupported tproxy mark mask %s", m[1])
+			}
+			mark = m[0]
+		}
+		// Like the TPROXY target, accept the packet once redirected.
+		return fmt.Sprintf("tproxy to :%s meta mark set %s accept", opts["--on-port"], mark), nil
+	case "CONNMARK":
+		if _, f := opts["--save-mark"]; f && len(opts) == 1 {
+			return "ct mark set meta mark", nil
+		}
+		if _, f := opts["--restore-mark"]; f && len(opts) == 1 {
+			return "meta mark set ct mark", nil
+		}
+		return "", fmt.Errorf("unsupported options for target %s: %v", target, params[1:])
+	default:
+		if len(opts) != 0 {
+			return "", fmt.Errorf("unsupported target %s", target)
+		}
+		// A user defined chain.
+		return "jump " + target, nil
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+func TestBuildV4NftEmpty(t *testing.T) {
+	iptables := NewIptablesBuilder()
+	actual, err := iptables.BuildV4Nft()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != "" {
+		t.Errorf("Output didn't match: Got: %s, Expected empty output", actual)
+	}
+}
+
+func TestBuildV4Nft(t *testing.T) {
+	iptables := NewIptablesBuilder()
+	iptables.AppendRuleV4(constants.PREROUTING, constants.NAT, "-p", constants.TCP, "-j", constants.ISTIOINBOUND)
+	iptables.AppendRuleV4(constants.ISTIOINBOUND, constants.NAT, "-p", constants.TCP, "--dport", "15008", "-j", constants.RETURN)
+	iptables.AppendRuleV4(constants.ISTIOINBOUND, constants.NAT, "-p", constants.TCP, "-j", constants.ISTIOINREDIRECT)
+	iptables.InsertRuleV4(constants.ISTIOINBOUND, constants.NAT, 1, "-p", constants.TCP, "--dport", "22", "-j", constants.RETURN)
+	iptables.AppendRuleV4(constants.ISTIOINREDIRECT, constants.NAT, "-p", constants.TCP, "-j", constants.REDIRECT, "--to-ports", "15006")
+	iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT,
+		"-o", "lo", "!", "-d", "127.0.0.1/32", "-p", constants.TCP, "!", "--dport", "53", "-m", "owner", "--uid-owner", "1337",
+		"-j", constants.ISTIOINREDIRECT)
+	iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-m", "owner", "!", "--gid-owner", "1337", "-j", constants.RETURN)
+	iptables.AppendRuleV4(constants.OUTPUT, constants.NAT, "-p", "udp", "--dport", "53", "-d", "10.0.0.10/32",
+		"-j", constants.REDIRECT, "--to-port", "15053")
+	actual, err := iptables.BuildV4Nft()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `add table ip istio_nat
+delete table ip istio_nat
+add table ip istio_nat
+add chain ip istio_nat PREROUTING { type nat hook prerouting priority -100; policy accept; }
+add chain ip istio_nat ISTIO_INBOUND
+add chain ip istio_nat ISTIO_IN_REDIRECT
+add chain ip istio_nat ISTIO_OUTPUT
+add chain ip istio_nat OUTPUT { type nat hook output priority -100; policy accept; }
+add rule ip istio_nat PREROUTING meta l4proto tcp jump ISTIO_INBOUND
+add rule ip istio_nat ISTIO_INBOUND tcp dport 22 return
+add rule ip istio_nat ISTIO_INBOUND tcp dport 15008 return
+add rule ip istio_nat ISTIO_INBOUND meta l4proto tcp jump ISTIO_IN_REDIRECT
+add rule ip istio_nat ISTIO_IN_REDIRECT meta l4proto tcp redirect to :15006
+add rule ip istio_nat ISTIO_OUTPUT oifname "lo" ip daddr != 127.0.0.1/32 tcp dport != 53 meta skuid 1337 jump ISTIO_IN_REDIRECT
+add rule ip istio_nat ISTIO_OUTPUT meta skgid != 1337 return
+add rule ip istio_nat OUTPUT udp dport 53 ip daddr 10.0.0.10/32 redirect to :15053
+`
+	if actual != expected {
+		t.Errorf("Output didn't match: Got: \n%s\nExpected: \n%s", actual, expected)
+	}
+	// V6 rules should be empty
+	if actual, err := iptables.BuildV6Nft(); err != nil || actual != "" {
+		t.Errorf("Expected empty V6 output; but got %q, %v", actual, err)
+	}
+}
+
+func TestBuildV6NftTproxy(t *testing.T) {
+	iptables := NewIptablesBuilder()
+	iptables.AppendRuleV6(constants.ISTIODIVERT, constants.MANGLE, "-j", constants.MARK, "--set-mark", "1337")
+	iptables.AppendRuleV6(constants.ISTIODIVERT, constants.MANGLE, "-j", constants.ACCEPT)
+	iptables.AppendRuleV6(constants.ISTIOTPROXY, constants.MANGLE,
+		"!", "-d", "::1/128", "-p", constants.TCP, "-j", constants.TPROXY, "--tproxy-mark", "1337/0xffffffff", "--on-port", "15006")
+	iptables.AppendRuleV6(constants.PREROUTING, constants.MANGLE,
+		"-p", constants.TCP, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", constants.ISTIODIVERT)
+	iptables.AppendRuleV6(constants.PREROUTING, constants.MANGLE, "-p", constants.TCP, "-m", "mark", "--mark", "1337", "-j", "CONNMARK", "--save-mark")
+	iptables.AppendRuleV6(constants.OUTPUT, constants.MANGLE, "-p", constants.TCP, "-m", "connmark", "--mark", "1337", "-j", "CONNMARK", "--restore-mark")
+	actual, err := iptables.BuildV6Nft()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `add table ip6 istio_mangle
+delete table ip6 istio_mangle
+add table ip6 istio_mangle
+add chain ip6 istio_mangle ISTIO_DIVERT
+add chain ip6 istio_mangle ISTIO_TPROXY
+add chain ip6 istio_mangle PREROUTING { type filter hook prerouting priority -150; policy accept; }
+add chain ip6 istio_mangle OUTPUT { type route hook output priority -150; policy accept; }
+add rule ip6 istio_mangle ISTIO_DIVERT meta mark set 1337
+add rule ip6 istio_mangle ISTIO_DIVERT accept
+add rule ip6 istio_mangle ISTIO_TPROXY meta l4proto tcp ip6 daddr != ::1/128 tproxy to :15006 meta mark set 1337 accept
+add rule ip6 istio_mangle PREROUTING meta l4proto tcp ct state related,established jump ISTIO_DIVERT
+add rule ip6 istio_mangle PREROUTING meta l4proto tcp meta mark 1337 ct mark set meta mark
+add rule ip6 istio_mangle OUTPUT meta l4proto tcp ct mark 1337 meta mark set ct mark
+`
+	if actual != expected {
+		t.Errorf("Output didn't match: Got: \n%s\nExpected: \n%s", actual, expected)
+	}
+}
+
+func TestBuildNftUnsupported(t *testing.T) {
+	cases := []struct {
+		name   string
+		table  string
+		params []string
+		err    string
+	}{
+		{"unsupported table", "raw", []string{"-j", constants.RETURN}, "unsupported table"},
+		{"unsupported option", constants.NAT, []string{"-m", "multiport", "--dports", "1,2", "-j", constants.RETURN}, "unsupported option --dports"},
+		{"port without protocol", constants.NAT, []string{"--dport", "80", "-j", constants.RETURN}, "--dport requires a protocol"},
+		{"missing target", constants.NAT, []string{"-p", constants.TCP}, "missing target"},
+		{"tproxy mask", constants.MANGLE, []string{"-j", constants.TPROXY, "--tproxy-mark", "1/0xff", "--on-port", "1"}, "unsupported tproxy mark mask"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			iptables := NewIptablesBuilder()
+			iptables.AppendRuleV4(constants.ISTIOOUTPUT, tt.table, tt.params...)
+			_, err := iptables.BuildV4Nft()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q; but got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -55,6 +55,10 @@ var rootCmd = &cobra.Command{
 			ext = &dep.RealDependencies{}
 		}
 
+		if cfg.Backend != constants.IptablesBackend && cfg.Backend != constants.NftablesBackend {
+			handleError(fmt.Errorf("invalid backend %q, must be %q or %q", cfg.Backend, constants.IptablesBackend, constants.NftablesBackend))
+		}
+
 		iptConfigurator := NewIptablesConfigurator(cfg, ext)
 		if !cfg.SkipRuleApply {
 			iptConfigurator.run()
@@ -78,6 +82,7 @@ func constructConfig() *config.Config {
 	cfg := &config.Config{
 		DryRun:                  viper.GetBool(constants.DryRun),
 		RestoreFormat:           viper.GetBool(constants.RestoreFormat),
+		Backend:                 viper.GetString(constants.Backend),
 		ProxyPort:               viper.GetString(constants.EnvoyPort),
 		InboundCapturePort:      viper.GetString(constants.InboundCapturePort),
 		InboundTunnelPort:       viper.GetString(constants.InboundTunnelPort),
@@ -289,6 +294,11 @@ func bindFlags(cmd *cobra.Command, args []string) {
 		handleError(err)
 	}
 	viper.SetDefault(constants.CaptureAllDNS, false)
+
+	if err := viper.BindPFlag(constants.Backend, cmd.Flags().Lookup(constants.Backend)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.Backend, constants.IptablesBackend)
 }
 
 // https://github.com/spf13/viper/issues/233.
@@ -357,6 +367,10 @@ func init() {
 
 	rootCmd.Flags().Bool(constants.CaptureAllDNS, false,
 		"Instead of only capturing DNS traffic to DNS server IP, capture all DNS traffic at port 53. This setting is only effective when redirect dns is enabled.")
+
+	rootCmd.Flags().String(constants.Backend, constants.IptablesBackend,
+		"The backend applying the rules, either \"iptables\" or \"nftables\". With nftables, the rules are applied atomically with nft, "+
+			"for hosts without iptables")
 }
 
 func GetCommand() *cobra.Command {
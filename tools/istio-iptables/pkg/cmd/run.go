@@ -371,6 +371,10 @@ func SplitV4V6(ips []string) (ipv4 []string, ipv6 []string) {
 func (iptConfigurator *IptablesConfigurator) run() {
 	defer func() {
 		// Best effort since we don't know if the commands exist
+		if iptConfigurator.cfg.Backend == constants.NftablesBackend {
+			_ = iptConfigurator.ext.Run(constants.NFT, "list", "ruleset")
+			return
+		}
 		_ = iptConfigurator.ext.Run(constants.IPTABLESSAVE)
 		if iptConfigurator.cfg.EnableInboundIPv6 {
 			_ = iptConfigurator.ext.Run(constants.IP6TABLESSAVE)
@@ -725,7 +729,36 @@ func (iptConfigurator *IptablesConfigurator) executeIptablesRestoreCommand(isIpv
 	return nil
 }
 
+// executeNftCommand applies the IPv4 and IPv6 rules with a single `nft -f`, so that they are applied atomically.
+func (iptConfigurator *IptablesConfigurator) executeNftCommand() error {
+	v4, err := iptConfigurator.iptables.BuildV4Nft()
+	if err != nil {
+		return err
+	}
+	v6, err := iptConfigurator.iptables.BuildV6Nft()
+	if err != nil {
+		return err
+	}
+	rulesFile, err := ioutil.TempFile("", fmt.Sprintf("nftables-rules-%d.txt", time.Now().UnixNano()))
+	if err != nil {
+		return fmt.Errorf("unable to create nftables rules file: %v", err)
+	}
+	defer os.Remove(rulesFile.Name())
+	if err := iptConfigurator.createRulesFile(rulesFile, v4+v6); err != nil {
+		return err
+	}
+	iptConfigurator.ext.RunOrFail(constants.NFT, "-f", rulesFile.Name())
+	return nil
+}
+
 func (iptConfigurator *IptablesConfigurator) executeCommands() {
+	if iptConfigurator.cfg.Backend == constants.NftablesBackend {
+		if err := iptConfigurator.executeNftCommand(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 	if iptConfigurator.cfg.RestoreFormat {
 		// Execute iptables-restore
 		err := iptConfigurator.executeIptablesRestoreCommand(true)
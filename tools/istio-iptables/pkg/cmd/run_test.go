@@ -17,6 +17,7 @@ package cmd
 import (
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 
 	"istio.io/istio/tools/istio-iptables/pkg/config"
@@ -809,3 +810,51 @@ func TestRulesWithLoopbackIpInOutboundIpRanges(t *testing.T) {
 		t.Errorf("Output mismatch. Expected: \n%#v ; Actual: \n%#v", expected, actual)
 	}
 }
+
+func TestRulesWithTproxyNftables(t *testing.T) {
+	cfg := constructTestConfig()
+	cfg.Backend = constants.NftablesBackend
+	cfg.InboundInterceptionMode = constants.TPROXY
+	cfg.InboundPortsInclude = "*"
+	cfg.OutboundIPRangesExclude = "1.1.0.0/16,2001:db8::/32"
+	cfg.OutboundIPRangesInclude = "9.9.0.0/16"
+	cfg.DryRun = true
+	cfg.RedirectDNS = true
+	cfg.DNSServersV4 = []string{"127.0.0.53"}
+	cfg.EnableInboundIPv6 = true
+	iptConfigurator := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+	iptConfigurator.cfg.ProxyGID = "1337"
+	iptConfigurator.cfg.ProxyUID = "1337"
+	iptConfigurator.run()
+
+	v4, err := iptConfigurator.iptables.BuildV4Nft()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v6, err := iptConfigurator.iptables.BuildV6Nft()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"add chain ip istio_nat OUTPUT { type nat hook output priority -100; policy accept; }",
+		"add rule ip istio_nat ISTIO_OUTPUT oifname \"lo\" ip daddr != 127.0.0.1/32 tcp dport != 53 meta skuid 1337 jump ISTIO_IN_REDIRECT",
+		"add rule ip istio_nat ISTIO_OUTPUT ip daddr 1.1.0.0/16 return",
+		"add rule ip istio_nat OUTPUT udp dport 53 ip daddr 127.0.0.53/32 redirect to :15053",
+		"add rule ip istio_mangle ISTIO_TPROXY meta l4proto tcp ip daddr != 127.0.0.1/32 tproxy to :15006 meta mark set 1337 accept",
+		"add rule ip istio_mangle ISTIO_INBOUND meta l4proto tcp meta mark 1337 return",
+		"add rule ip6 istio_nat ISTIO_OUTPUT ip6 daddr 2001:db8::/32 return",
+	}
+	actual := strings.Split(v4+v6, "\n")
+	for _, e := range expected {
+		found := false
+		for _, a := range actual {
+			if a == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected rule %q not found in:\n%s", e, v4+v6)
+		}
+	}
+}
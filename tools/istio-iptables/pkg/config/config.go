@@ -44,6 +44,7 @@ type Config struct {
 	ProbeTimeout            time.Duration `json:"PROBE_TIMEOUT"`
 	DryRun                  bool          `json:"DRY_RUN"`
 	RestoreFormat           bool          `json:"RESTORE_FORMAT"`
+	Backend                 string        `json:"BACKEND"`
 	SkipRuleApply           bool          `json:"SKIP_RULE_APPLY"`
 	RunValidation           bool          `json:"RUN_VALIDATION"`
 	RedirectDNS             bool          `json:"REDIRECT_DNS"`
@@ -83,5 +84,6 @@ func (c *Config) Print() {
 	fmt.Printf("DNS_CAPTURE=%t\n", c.RedirectDNS)
 	fmt.Printf("CAPTURE_ALL_DNS=%t\n", c.CaptureAllDNS)
 	fmt.Printf("DNS_SERVERS=%s,%s\n", c.DNSServersV4, c.DNSServersV6)
+	fmt.Printf("BACKEND=%s\n", c.Backend)
 	fmt.Println("")
 }
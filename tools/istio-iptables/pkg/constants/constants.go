@@ -89,6 +89,13 @@ const (
 	ProbeTimeout              = "probe-timeout"
 	RedirectDNS               = "redirect-dns"
 	CaptureAllDNS             = "capture-all-dns"
+	Backend                   = "backend"
+)
+
+// Backends applying the rules, selected by the backend flag
+const (
+	IptablesBackend = "iptables"
+	NftablesBackend = "nftables"
 )
 
 const (
@@ -110,6 +117,16 @@ const (
 	IP6TABLESRESTORE = "ip6tables-restore"
 	IP6TABLESSAVE    = "ip6tables-save"
 	IP               = "ip"
+	NFT              = "nft"
+)
+
+// Constants for nftables
+const (
+	// NftablesTablePrefix is the prefix of the nftables tables holding the rules of each iptables table,
+	// e.g. istio_nat for the nat table.
+	NftablesTablePrefix = "istio_"
+	NftablesFamilyV4    = "ip"
+	NftablesFamilyV6    = "ip6"
 )
 
 // Constants for syscall